@@ -0,0 +1,23 @@
+package api
+
+import "context"
+
+type PasswordResetRequest struct {
+	Email string `json:"email" validate:"required,email,max=40"`
+}
+
+type PasswordResetConfirmRequest struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"newPassword" validate:"required,min=8,max=72"`
+}
+
+type AccountService interface {
+	// RequestPasswordReset mails a reset token to the customer with the email,
+	// if there is one.
+	RequestPasswordReset(ctx context.Context, email string) error
+	// ResetPassword and VerifyEmail fail with domain.ErrInvalid if the token
+	// is unknown, expired or already used.
+	ResetPassword(ctx context.Context, token, newPassword string) error
+	SendVerification(ctx context.Context, customerID string) error
+	VerifyEmail(ctx context.Context, token string) error
+}
@@ -8,10 +8,11 @@ type UserAdressesResponse struct {
 
 type Address struct {
 	ID       uuid.UUID `json:"id"`
-	Street   string    `json:"street"`
-	Number   string    `json:"number"`
-	Country  string    `json:"country"`
-	City     string    `json:"city"`
-	PostCode string    `json:"postcode"`
+	Street   string    `json:"street" validate:"required,max=40"`
+	Number   string    `json:"number" validate:"max=40"`
+	Country  string    `json:"country" validate:"required,max=20"`
+	City     string    `json:"city" validate:"required,max=20"`
+	PostCode string    `json:"postcode" validate:"required,max=20"`
 	Links    Links     `json:"_links"`
+	Version  int       `json:"-"`
 }
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"context"
+	"time"
+)
+
+type APIKey struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Prefix    string     `json:"prefix"`
+	Scopes    []string   `json:"scopes"`
+	CreatedBy string     `json:"createdBy"`
+	CreatedAt time.Time  `json:"createdAt"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+}
+
+// CreatedAPIKey is a minted API key. Key is only returned once, as it is
+// stored hashed.
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" validate:"required,max=40"`
+	Scopes    []string   `json:"scopes" validate:"required"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+type APIKeyScopesRequest struct {
+	Scopes []string `json:"scopes" validate:"required"`
+}
+
+type ListAPIKeysResponse struct {
+	Keys []APIKey `json:"keys"`
+}
+
+type APIKeyService interface {
+	// CreateAPIKey mints a key granted the scopes, which are permissions
+	// from domain.APIKeyScopes, on behalf of the admin createdBy.
+	CreateAPIKey(ctx context.Context, createdBy string, req CreateAPIKeyRequest) (*CreatedAPIKey, error)
+	ListAPIKeys(ctx context.Context) (*ListAPIKeysResponse, error)
+	// SetAPIKeyScopes replaces the scopes of a key. Requests already
+	// authenticated with the key keep their scopes.
+	SetAPIKeyScopes(ctx context.Context, id string, scopes []string) (*APIKey, error)
+	RevokeAPIKey(ctx context.Context, id string) error
+}
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"context"
+
+	"github.com/oshankkumar/sockshop/api/httpkit"
+)
+
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// LoginResponse is the logged in user along with the tokens of its session.
+type LoginResponse struct {
+	User
+	TokenResponse
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+type AuthService interface {
+	// IssueTokens opens a session for the customer.
+	IssueTokens(ctx context.Context, customerID string) (*TokenResponse, error)
+	// RefreshTokens exchanges a refresh token for new tokens of its session.
+	// The refresh token is rotated and cannot be used again.
+	RefreshTokens(ctx context.Context, refreshToken string) (*TokenResponse, error)
+	Authenticate(ctx context.Context, accessToken string) (httpkit.Principal, error)
+	// AuthenticateAPIKey resolves the API key of a service.
+	AuthenticateAPIKey(ctx context.Context, key string) (httpkit.Principal, error)
+	// Logout revokes the session, invalidating all of its tokens.
+	Logout(ctx context.Context, sessionID string) error
+}
@@ -1,25 +1,25 @@
 package api
 
-import (
-	"fmt"
-	"strings"
-
-	"github.com/google/uuid"
-)
+import "github.com/google/uuid"
 
 type UserCardsResponse struct {
 	Cards []Card `json:"cards"`
 }
 
 type Card struct {
-	ID      uuid.UUID `json:"id"`
-	LongNum string    `json:"longNum"`
-	Expires string    `json:"expires"`
-	CCV     string    `json:"ccv"`
-	Links   Links     `json:"_links"`
+	ID uuid.UUID `json:"id"`
+	// LongNum and CCV are only read from requests. The card number is stored
+	// encrypted and the CCV is never stored.
+	LongNum string `json:"longNum,omitempty" validate:"required,max=23"`
+	CCV     string `json:"ccv,omitempty" validate:"digits,min=3,max=4"`
+	Last4   string `json:"last4,omitempty"`
+	Brand   string `json:"brand,omitempty"`
+	Expires string `json:"expires" validate:"required"`
+	Links   Links  `json:"_links"`
+	Version int    `json:"-"`
 }
 
-func (c *Card) MaskCC() {
-	l := len(c.LongNum) - 4
-	c.LongNum = fmt.Sprintf("%v%v", strings.Repeat("*", l), c.LongNum[l:])
+// CardPatch updates the expiry of a card, the only mutable card detail.
+type CardPatch struct {
+	Expires string `json:"expires" validate:"required"`
 }
@@ -0,0 +1,35 @@
+package api
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+type Cart struct {
+	CustomerID string     `json:"customerId"`
+	Items      []CartItem `json:"items"`
+	Total      float64    `json:"total"`
+	Links      Links      `json:"_links"`
+}
+
+type CartItem struct {
+	ItemID    uuid.UUID `json:"itemId" validate:"required"`
+	Quantity  int       `json:"quantity" validate:"min=0"`
+	UnitPrice float64   `json:"unitPrice"`
+}
+
+type CartService interface {
+	// NewSessionCart returns the empty cart of a new anonymous session. Its
+	// ID is passed as sessionId on login to merge the cart.
+	NewSessionCart(ctx context.Context) (*Cart, error)
+	GetCart(ctx context.Context, customerID string) (*Cart, error)
+	DeleteCart(ctx context.Context, customerID string) error
+	AddItem(ctx context.Context, customerID string, item CartItem) (*CartItem, error)
+	UpdateItem(ctx context.Context, customerID string, item CartItem) (*CartItem, error)
+	DeleteItem(ctx context.Context, customerID, itemID string) error
+	// MergeCarts moves the items of the anonymous session cart into the
+	// customer's cart and deletes the session cart. sessionID must be a
+	// session cart ID.
+	MergeCarts(ctx context.Context, customerID, sessionID string) error
+}
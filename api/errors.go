@@ -1,8 +1,43 @@
 package api
 
-import "errors"
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
 
 var (
 	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
 	ErrNotFound     = errors.New("user not found")
 )
+
+// LoginThrottledError rejects a login attempt made too soon after failed
+// ones. Locked is set when the account itself is locked out.
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+	Locked     bool
+}
+
+func (e LoginThrottledError) Error() string {
+	if e.Locked {
+		return "account locked, retry after " + e.RetryAfter.String()
+	}
+	return "too many failed logins, retry after " + e.RetryAfter.String()
+}
+
+// OrderPaymentError fails the checkout of an order which was placed but could
+// not be paid. The order can be paid again with its OrderID.
+type OrderPaymentError struct {
+	OrderID uuid.UUID
+	Err     error
+}
+
+func (e OrderPaymentError) Error() string {
+	return "order " + e.OrderID.String() + ": " + e.Err.Error()
+}
+
+func (e OrderPaymentError) Unwrap() error {
+	return e.Err
+}
@@ -1,9 +1,17 @@
 package httpkit
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/oshankkumar/sockshop/internal/validate"
 )
 
 type Handler interface {
@@ -22,10 +30,27 @@ func DiscardErr(h Handler) http.Handler {
 	})
 }
 
+// Error is an error response, written as a Problem. Message is the problem
+// detail.
 type Error struct {
-	Code    int    `json:"code"`
+	Code    int
+	Message string
+	// Type is the URI of the problem type, about:blank when empty.
+	Type string
+	// Title is the summary of the problem type, the status text when empty.
+	Title string
+	// Fields are the invalid fields of a rejected request.
+	Fields []FieldError
+	// Extensions are the extension members of the problem.
+	Extensions map[string]interface{}
+	// Header is added to the response headers.
+	Header http.Header
+	Err    error
+}
+
+type FieldError struct {
+	Field   string `json:"field"`
 	Message string `json:"message"`
-	Err     error  `json:"-"`
 }
 
 func (e Error) Error() string {
@@ -36,18 +61,87 @@ func (e Error) Error() string {
 	return msg
 }
 
-func RespondError(w http.ResponseWriter, apiErr *Error) {
-	w.Header().Set("Content-Type", "application/json;charset=UTF-8")
-	w.WriteHeader(apiErr.Code)
-	_ = json.NewEncoder(w).Encode(apiErr)
-}
-
 func RespondJSON(w http.ResponseWriter, v interface{}, status int) {
 	w.Header().Set("Content-Type", "application/json;charset=UTF-8")
 	w.WriteHeader(status)
 	_ = json.NewEncoder(w).Encode(v)
 }
 
+// NewValidationError returns the 422 response listing the invalid fields of a
+// request.
+func NewValidationError(message string, fields []FieldError, err error) *Error {
+	t := ValidationProblem
+	return &Error{Code: t.Status, Type: t.URI, Title: t.Title, Message: message, Fields: fields, Err: err}
+}
+
+// MaxBodyBytes is the size limit of the request bodies DecodeJSON reads.
+const MaxBodyBytes = 1 << 20
+
+// DecodeJSON decodes the JSON request body into v and checks v with
+// validate.Struct. Malformed bodies, unknown fields and trailing data are
+// rejected with a 400, bodies over MaxBodyBytes with a 413 and invalid fields
+// with a 422.
+func DecodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxBodyBytes))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(v); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return &Error{Code: http.StatusRequestEntityTooLarge, Message: "request body too large", Err: err}
+		}
+		return &Error{Code: http.StatusBadRequest, Message: "json unmarshal failed: " + err.Error(), Err: err}
+	}
+
+	if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		return &Error{Code: http.StatusBadRequest, Message: "request body must be a single json value", Err: err}
+	}
+
+	var verrs validate.Errors
+	if err := validate.Struct(v); errors.As(err, &verrs) {
+		fields := make([]FieldError, 0, len(verrs))
+		for _, f := range verrs {
+			fields = append(fields, FieldError{Field: f.Field, Message: f.Message})
+		}
+		return NewValidationError("invalid request", fields, err)
+	}
+
+	return nil
+}
+
+// ETag returns the entity tag of the given revision of a resource.
+func ETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// IfMatch returns the resource revision the If-Match header of a conditional
+// update names. Updates without the header are rejected, so that a client
+// cannot overwrite changes it has not seen.
+func IfMatch(r *http.Request) (int, error) {
+	etag := r.Header.Get("If-Match")
+	if etag == "" {
+		return 0, &Error{Code: http.StatusPreconditionRequired, Message: "If-Match header is required"}
+	}
+
+	version, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(etag, "W/"), `"`))
+	if err != nil {
+		return 0, &Error{Code: http.StatusBadRequest, Message: "invalid If-Match header", Err: err}
+	}
+
+	return version, nil
+}
+
+// ClientIP returns the address of the client connection. Proxy headers are
+// not trusted, as a client could set them to escape the login throttle and
+// the rate limits.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
 type MiddlewareFunc func(method, pattern string, h Handler) Handler
 
 func ChainMiddleware(mm ...MiddlewareFunc) MiddlewareFunc {
@@ -58,3 +152,44 @@ func ChainMiddleware(mm ...MiddlewareFunc) MiddlewareFunc {
 		return h
 	}
 }
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	Subject   string
+	SessionID string
+	// APIKeyID is set for the services authenticated with an API key. Their
+	// subject is not a customer.
+	APIKeyID    string
+	Permissions []string
+}
+
+// Can reports whether the principal was granted the permission.
+func (p Principal) Can(permission string) bool {
+	for _, perm := range p.Permissions {
+		if perm == permission {
+			return true
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFrom returns the principal of an authenticated request.
+func PrincipalFrom(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// AuthPolicy tells how the requests of a route are authenticated.
+type AuthPolicy struct {
+	// Required rejects the requests without a valid access token.
+	Required bool
+	// OwnerParam names the URL parameter holding the id of the customer owning
+	// the resource. The token subject must match it.
+	OwnerParam string
+}
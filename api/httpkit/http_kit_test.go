@@ -0,0 +1,35 @@
+package httpkit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		want       string
+	}{
+		{name: "ipv4", remoteAddr: "10.0.0.1:40000", want: "10.0.0.1"},
+		{name: "ipv6", remoteAddr: "[::1]:40000", want: "::1"},
+		{name: "no port", remoteAddr: "10.0.0.1", want: "10.0.0.1"},
+		{name: "proxy headers are ignored", remoteAddr: "10.0.0.1:40000", forwarded: "192.0.2.7", want: "10.0.0.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/login", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.forwarded != "" {
+				r.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+
+			if got := ClientIP(r); got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package httpkit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// Problem is an RFC 7807 problem details object.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+	// Extensions are the extension members of the problem type. They cannot
+	// override the members above.
+	Extensions map[string]interface{} `json:"-"`
+}
+
+// MarshalJSON encodes the extension members next to the standard ones.
+func (p Problem) MarshalJSON() ([]byte, error) {
+	type problem Problem
+
+	b, err := json.Marshal(problem(p))
+	if err != nil || len(p.Extensions) == 0 {
+		return b, err
+	}
+
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(b, &members); err != nil {
+		return nil, err
+	}
+
+	for k, v := range p.Extensions {
+		if _, ok := members[k]; ok {
+			continue
+		}
+
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		members[k] = raw
+	}
+
+	return json.Marshal(members)
+}
+
+// RespondError writes the error as an application/problem+json response about
+// the request.
+func RespondError(w http.ResponseWriter, r *http.Request, apiErr *Error) {
+	p := Problem{
+		Type:       apiErr.Type,
+		Title:      apiErr.Title,
+		Status:     apiErr.Code,
+		Detail:     apiErr.Message,
+		Instance:   r.URL.Path,
+		RequestID:  RequestIDFrom(r.Context()),
+		Errors:     apiErr.Fields,
+		Extensions: apiErr.Extensions,
+	}
+
+	if p.Type == "" {
+		p.Type = "about:blank"
+	}
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+
+	for k, vv := range apiErr.Header {
+		for _, v := range vv {
+			w.Header().Add(k, v)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(p.Status)
+	_ = json.NewEncoder(w).Encode(p)
+}
+
+// ProblemType is a class of errors with its own problem type URI.
+type ProblemType struct {
+	URI    string
+	Title  string
+	Status int
+}
+
+// ValidationProblem is the problem of requests with invalid fields.
+var ValidationProblem = ProblemType{URI: "/problems/validation", Title: "Invalid request fields", Status: http.StatusUnprocessableEntity}
+
+// ProblemMapper returns the response of the errors it knows about.
+type ProblemMapper func(err error) (*Error, bool)
+
+// ProblemRegistry maps the errors the handlers return to error responses, so
+// that handlers only build an Error for the responses specific to them.
+type ProblemRegistry struct {
+	mappers []ProblemMapper
+}
+
+// Register maps the errors matching target, as reported by errors.Is, to the
+// problem type.
+func (p *ProblemRegistry) Register(target error, t ProblemType) {
+	p.RegisterFunc(func(err error) (*Error, bool) {
+		if !errors.Is(err, target) {
+			return nil, false
+		}
+		return &Error{Code: t.Status, Type: t.URI, Title: t.Title, Err: err}, true
+	})
+}
+
+// RegisterFunc adds a mapper, for the errors errors.Is cannot match such as
+// typed errors. Mappers are tried in registration order.
+func (p *ProblemRegistry) RegisterFunc(m ProblemMapper) {
+	p.mappers = append(p.mappers, m)
+}
+
+// Resolve returns the response of err. An Error is returned as is, and errors
+// no mapper knows about are internal server errors.
+func (p *ProblemRegistry) Resolve(err error) *Error {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+
+	for _, m := range p.mappers {
+		if apiErr, ok := m(err); ok {
+			return apiErr
+		}
+	}
+
+	return &Error{Code: http.StatusInternalServerError, Err: err}
+}
+
+type requestIDKey struct{}
+
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFrom returns the id of the request, or "".
+func RequestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
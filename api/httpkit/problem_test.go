@@ -0,0 +1,220 @@
+package httpkit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+var (
+	errMissing = errors.New("missing")
+	errBroken  = errors.New("broken")
+)
+
+var missingProblem = ProblemType{URI: "/problems/missing", Title: "Missing", Status: http.StatusNotFound}
+
+// codeError is a typed error errors.Is cannot match.
+type codeError struct{ code int }
+
+func (e codeError) Error() string { return fmt.Sprintf("code %d", e.code) }
+
+func newRegistry() *ProblemRegistry {
+	reg := &ProblemRegistry{}
+	reg.RegisterFunc(func(err error) (*Error, bool) {
+		var ce codeError
+		if !errors.As(err, &ce) {
+			return nil, false
+		}
+		return &Error{Code: ce.code, Err: err}, true
+	})
+	reg.Register(errMissing, missingProblem)
+	// Never reached for errMissing, the first mapper matching wins.
+	reg.Register(errMissing, ProblemType{Status: http.StatusGone})
+	return reg
+}
+
+func TestProblemRegistryResolve(t *testing.T) {
+	apiErr := &Error{Code: http.StatusConflict, Message: "taken"}
+
+	tests := []struct {
+		name     string
+		err      error
+		wantCode int
+		wantType string
+	}{
+		{name: "registered error", err: errMissing, wantCode: http.StatusNotFound, wantType: missingProblem.URI},
+		{name: "wrapped error", err: fmt.Errorf("UserService.GetUser(id=1): %w", errMissing), wantCode: http.StatusNotFound, wantType: missingProblem.URI},
+		{name: "typed error", err: fmt.Errorf("wrapped: %w", codeError{code: http.StatusTeapot}), wantCode: http.StatusTeapot},
+		{name: "unknown error", err: errBroken, wantCode: http.StatusInternalServerError},
+		{name: "handler error", err: apiErr, wantCode: http.StatusConflict},
+		{name: "wrapped handler error", err: fmt.Errorf("wrapped: %w", apiErr), wantCode: http.StatusConflict},
+	}
+
+	reg := newRegistry()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := reg.Resolve(tt.err)
+
+			if got.Code != tt.wantCode || got.Type != tt.wantType {
+				t.Fatalf("got %d %q, want %d %q", got.Code, got.Type, tt.wantCode, tt.wantType)
+			}
+
+			// The handler errors are returned as is, the others are kept for
+			// the logs.
+			if got != apiErr && got.Err != tt.err {
+				t.Fatalf("got error %v, want %v", got.Err, tt.err)
+			}
+		})
+	}
+}
+
+// respond writes the problem of the error to a request of the path.
+func respond(t *testing.T, path string, apiErr *Error) (*httptest.ResponseRecorder, map[string]interface{}) {
+	t.Helper()
+
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	r = r.WithContext(WithRequestID(r.Context(), "req-1"))
+	w := httptest.NewRecorder()
+
+	RespondError(w, r, apiErr)
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode problem: %v", err)
+	}
+	return w, body
+}
+
+func TestRespondError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      *Error
+		want     map[string]interface{}
+		wantHead http.Header
+	}{
+		{
+			name: "defaults",
+			err:  &Error{Code: http.StatusInternalServerError, Err: errBroken},
+			want: map[string]interface{}{
+				"type":       "about:blank",
+				"title":      "Internal Server Error",
+				"status":     float64(500),
+				"instance":   "/customers/1",
+				"request_id": "req-1",
+			},
+		},
+		{
+			name: "problem type",
+			err:  &Error{Code: http.StatusNotFound, Type: missingProblem.URI, Title: missingProblem.Title, Message: "no such customer"},
+			want: map[string]interface{}{
+				"type":       "/problems/missing",
+				"title":      "Missing",
+				"status":     float64(404),
+				"detail":     "no such customer",
+				"instance":   "/customers/1",
+				"request_id": "req-1",
+			},
+		},
+		{
+			name: "fields",
+			err:  NewValidationError("invalid request", []FieldError{{Field: "email", Message: "must be an email address"}}, nil),
+			want: map[string]interface{}{
+				"type":       ValidationProblem.URI,
+				"title":      ValidationProblem.Title,
+				"status":     float64(422),
+				"detail":     "invalid request",
+				"instance":   "/customers/1",
+				"request_id": "req-1",
+				"errors":     []interface{}{map[string]interface{}{"field": "email", "message": "must be an email address"}},
+			},
+		},
+		{
+			name: "extensions",
+			err: &Error{
+				Code:       http.StatusPaymentRequired,
+				Extensions: map[string]interface{}{"order_id": "o-1", "status": "ignored"},
+			},
+			want: map[string]interface{}{
+				"type":       "about:blank",
+				"title":      "Payment Required",
+				"status":     float64(402),
+				"instance":   "/customers/1",
+				"request_id": "req-1",
+				"order_id":   "o-1",
+			},
+		},
+		{
+			name: "header",
+			err:  &Error{Code: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"3"}}},
+			want: map[string]interface{}{
+				"type":       "about:blank",
+				"title":      "Too Many Requests",
+				"status":     float64(429),
+				"instance":   "/customers/1",
+				"request_id": "req-1",
+			},
+			wantHead: http.Header{"Retry-After": {"3"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, body := respond(t, "/customers/1", tt.err)
+
+			if w.Code != tt.err.Code {
+				t.Fatalf("got status %d, want %d", w.Code, tt.err.Code)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "application/problem+json" {
+				t.Fatalf("got content type %q, want application/problem+json", ct)
+			}
+			if !reflect.DeepEqual(body, tt.want) {
+				t.Fatalf("got problem %v, want %v", body, tt.want)
+			}
+			for k := range tt.wantHead {
+				if got := w.Header().Get(k); got != tt.wantHead.Get(k) {
+					t.Fatalf("got header %s %q, want %q", k, got, tt.wantHead.Get(k))
+				}
+			}
+		})
+	}
+}
+
+func TestIfMatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		want     int
+		wantCode int
+	}{
+		{name: "strong", header: `"3"`, want: 3},
+		{name: "weak", header: `W/"3"`, want: 3},
+		{name: "missing", wantCode: http.StatusPreconditionRequired},
+		{name: "malformed", header: `"three"`, wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPut, "/customers/1", nil)
+			if tt.header != "" {
+				r.Header.Set("If-Match", tt.header)
+			}
+
+			got, err := IfMatch(r)
+
+			var apiErr *Error
+			switch {
+			case tt.wantCode == 0 && err != nil:
+				t.Fatalf("got %v, want no error", err)
+			case tt.wantCode != 0 && (!errors.As(err, &apiErr) || apiErr.Code != tt.wantCode):
+				t.Fatalf("got %v, want a %d", err, tt.wantCode)
+			case got != tt.want:
+				t.Fatalf("got version %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,6 +1,10 @@
 package api
 
-import "fmt"
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+)
 
 type Links map[string]Href
 
@@ -14,6 +18,7 @@ func NewCustomerLinks(domain string, id string) Links {
 	l["customer"] = Href{fmt.Sprintf("http://%v/customers/%v", domain, id)}
 	l["addresses"] = Href{fmt.Sprintf("http://%v/customers/%v/addresses", domain, id)}
 	l["cards"] = Href{fmt.Sprintf("http://%v/customers/%v/cards", domain, id)}
+	l["orders"] = Href{fmt.Sprintf("http://%v/customers/%v/orders", domain, id)}
 	return l
 }
 
@@ -30,3 +35,73 @@ func NewCardLinks(domain string, id string) Links {
 	l["card"] = Href{fmt.Sprintf("http://%v/cards/%v", domain, id)}
 	return l
 }
+
+// NewPageLinks returns the self, next and prev links of a page of a paginated
+// collection. query carries the non paging parameters of the request.
+func NewPageLinks(domain string, path string, query url.Values, page, size, total int) Links {
+	pageURL := func(p int) Href {
+		q := url.Values{}
+		for k, v := range query {
+			q[k] = v
+		}
+		q.Set("page", strconv.Itoa(p))
+		q.Set("size", strconv.Itoa(size))
+		return Href{fmt.Sprintf("http://%v%v?%v", domain, path, q.Encode())}
+	}
+
+	l := make(Links)
+	l["self"] = pageURL(page)
+	if page*size < total {
+		l["next"] = pageURL(page + 1)
+	}
+	if page > 1 {
+		l["prev"] = pageURL(page - 1)
+	}
+	return l
+}
+
+// NewCursorLinks returns the self and next links of a page of a keyset
+// paginated collection. next is empty on the last page.
+func NewCursorLinks(domain string, path string, query url.Values, self, next string) Links {
+	cursorURL := func(c string) Href {
+		q := url.Values{}
+		for k, v := range query {
+			q[k] = v
+		}
+		if c != "" {
+			q.Set("cursor", c)
+		}
+		return Href{fmt.Sprintf("http://%v%v?%v", domain, path, q.Encode())}
+	}
+
+	l := make(Links)
+	l["self"] = cursorURL(self)
+	if next != "" {
+		l["next"] = cursorURL(next)
+	}
+	return l
+}
+
+func NewCartLinks(domain string, customerID string) Links {
+	l := make(Links)
+	l["self"] = Href{fmt.Sprintf("http://%v/carts/%v", domain, customerID)}
+	l["cart"] = Href{fmt.Sprintf("http://%v/carts/%v", domain, customerID)}
+	l["items"] = Href{fmt.Sprintf("http://%v/carts/%v/items", domain, customerID)}
+	return l
+}
+
+func NewSessionCartLinks(domain string, sessionID string) Links {
+	l := make(Links)
+	l["self"] = Href{fmt.Sprintf("http://%v/session-carts/%v", domain, sessionID)}
+	l["cart"] = Href{fmt.Sprintf("http://%v/session-carts/%v", domain, sessionID)}
+	l["items"] = Href{fmt.Sprintf("http://%v/session-carts/%v/items", domain, sessionID)}
+	return l
+}
+
+func NewOrderLinks(domain string, id string, customerID string) Links {
+	l := make(Links)
+	l["self"] = Href{fmt.Sprintf("http://%v/orders/%v", domain, id)}
+	l["order"] = Href{fmt.Sprintf("http://%v/orders/%v", domain, id)}
+	l["customer"] = Href{fmt.Sprintf("http://%v/customers/%v", domain, customerID)}
+	return l
+}
@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/oshankkumar/sockshop/api/httpkit"
+
+	"github.com/go-chi/chi/v5"
+)
+
+var (
+	errMissingToken = errors.New("missing bearer token or api key")
+	errNotOwner     = errors.New("token subject does not own the resource")
+	errNoPermission = errors.New("permission denied")
+)
+
+// Authenticator resolves an access token into the principal it was issued to,
+// and an API key into the service it was minted for.
+type Authenticator interface {
+	Authenticate(ctx context.Context, accessToken string) (httpkit.Principal, error)
+	AuthenticateAPIKey(ctx context.Context, key string) (httpkit.Principal, error)
+}
+
+// WithAuth authenticates the bearer token or the API key of the request, if
+// any, and stores its principal in the request context. It rejects the
+// requests the policy requires to be authenticated, or owned by the token
+// subject, otherwise.
+func WithAuth(authn Authenticator, policy httpkit.AuthPolicy) httpkit.MiddlewareFunc {
+	return func(method, pattern string, h httpkit.Handler) httpkit.Handler {
+		return httpkit.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+			scheme, cred, ok := credentials(r)
+			if !ok {
+				if policy.Required || policy.OwnerParam != "" {
+					return unauthorized(w, errMissingToken)
+				}
+				return h.ServeHTTP(w, r)
+			}
+
+			var (
+				p   httpkit.Principal
+				err error
+			)
+
+			if scheme == schemeAPIKey {
+				p, err = authn.AuthenticateAPIKey(r.Context(), cred)
+			} else {
+				p, err = authn.Authenticate(r.Context(), cred)
+			}
+
+			if err != nil {
+				return unauthorized(w, err)
+			}
+
+			setAPIKeyLabel(r.Context(), p.APIKeyID)
+
+			if policy.OwnerParam != "" && chi.URLParam(r, policy.OwnerParam) != p.Subject {
+				return &httpkit.Error{Code: http.StatusForbidden, Message: "access to the resource is forbidden", Err: errNotOwner}
+			}
+
+			return h.ServeHTTP(w, r.WithContext(httpkit.WithPrincipal(r.Context(), p)))
+		})
+	}
+}
+
+// WithPermission rejects the requests whose principal, stored by WithAuth, was
+// not granted the permission. An empty permission lets every request through.
+func WithPermission(permission string) httpkit.MiddlewareFunc {
+	return func(method, pattern string, h httpkit.Handler) httpkit.Handler {
+		if permission == "" {
+			return h
+		}
+
+		return httpkit.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+			p, ok := httpkit.PrincipalFrom(r.Context())
+			if !ok {
+				return unauthorized(w, errMissingToken)
+			}
+
+			if !p.Can(permission) {
+				return &httpkit.Error{Code: http.StatusForbidden, Message: "access to the resource is forbidden", Err: errNoPermission}
+			}
+
+			return h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Authorization schemes of the customers' access tokens and the services' API
+// keys.
+const (
+	schemeBearer = "Bearer"
+	schemeAPIKey = "ApiKey"
+)
+
+// credentials returns the scheme and the credentials of the Authorization
+// header, if it has a known scheme.
+func credentials(r *http.Request) (string, string, bool) {
+	scheme, cred, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || cred == "" {
+		return "", "", false
+	}
+
+	for _, known := range []string{schemeBearer, schemeAPIKey} {
+		if strings.EqualFold(scheme, known) {
+			return known, cred, true
+		}
+	}
+
+	return "", "", false
+}
+
+func unauthorized(w http.ResponseWriter, err error) error {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="sockshop"`)
+	w.Header().Add("WWW-Authenticate", `ApiKey realm="sockshop"`)
+	return &httpkit.Error{Code: http.StatusUnauthorized, Message: "user not authorised", Err: err}
+}
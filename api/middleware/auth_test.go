@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/oshankkumar/sockshop/api/httpkit"
+)
+
+// keyAuthenticator accepts the access token "good" and the API keys of keys,
+// which are rejected once revoked or expired.
+type keyAuthenticator struct {
+	keys map[string]httpkit.Principal
+}
+
+func (keyAuthenticator) Authenticate(_ context.Context, token string) (httpkit.Principal, error) {
+	if token != "good" {
+		return httpkit.Principal{}, errors.New("invalid token")
+	}
+	return httpkit.Principal{Subject: "alice", Permissions: []string{"orders:manage"}}, nil
+}
+
+func (a keyAuthenticator) AuthenticateAPIKey(_ context.Context, key string) (httpkit.Principal, error) {
+	p, ok := a.keys[key]
+	if !ok {
+		return httpkit.Principal{}, errors.New("unknown, revoked or expired API key")
+	}
+	return p, nil
+}
+
+func TestWithAuthAPIKey(t *testing.T) {
+	authn := keyAuthenticator{keys: map[string]httpkit.Principal{
+		"ssk_orders":    {Subject: "apikey:1", APIKeyID: "1", Permissions: []string{"orders:manage"}},
+		"ssk_catalogue": {Subject: "apikey:2", APIKeyID: "2", Permissions: []string{"catalogue:write"}},
+	}}
+
+	tests := []struct {
+		name          string
+		authorization string
+		want          int
+		wantKeyID     string
+	}{
+		{name: "api key", authorization: "ApiKey ssk_orders", want: http.StatusOK, wantKeyID: "1"},
+		{name: "scheme is case insensitive", authorization: "apikey ssk_orders", want: http.StatusOK, wantKeyID: "1"},
+		{name: "out of scope key", authorization: "ApiKey ssk_catalogue", want: http.StatusForbidden},
+		{name: "revoked or expired key", authorization: "ApiKey ssk_revoked", want: http.StatusUnauthorized},
+		{name: "api key as a bearer token", authorization: "Bearer ssk_orders", want: http.StatusUnauthorized},
+		{name: "access token", authorization: "Bearer good", want: http.StatusOK},
+		{name: "unknown scheme", authorization: "Basic ssk_orders", want: http.StatusUnauthorized},
+		{name: "empty key", authorization: "ApiKey ", want: http.StatusUnauthorized},
+		{name: "no credentials", want: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got httpkit.Principal
+
+			h := httpkit.ChainMiddleware(
+				WithAuth(authn, httpkit.AuthPolicy{Required: true}),
+				WithPermission("orders:manage"),
+			)(http.MethodPatch, "/orders/{id}", httpkit.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+				got, _ = httpkit.PrincipalFrom(r.Context())
+				return nil
+			}))
+
+			r := httptest.NewRequest(http.MethodPatch, "/orders/1", nil)
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+
+			code := http.StatusOK
+
+			var httpErr *httpkit.Error
+			switch err := h.ServeHTTP(w, r); {
+			case errors.As(err, &httpErr):
+				code = httpErr.Code
+			case err != nil:
+				t.Fatal(err)
+			}
+
+			if code != tt.want {
+				t.Fatalf("got %d, want %d", code, tt.want)
+			}
+			if code == http.StatusUnauthorized && len(w.Header().Values("WWW-Authenticate")) != 2 {
+				t.Fatalf("got WWW-Authenticate %v, want the Bearer and ApiKey challenges", w.Header().Values("WWW-Authenticate"))
+			}
+			if code == http.StatusOK && got.APIKeyID != tt.wantKeyID {
+				t.Fatalf("got API key %q, want %q", got.APIKeyID, tt.wantKeyID)
+			}
+		})
+	}
+}
@@ -1,7 +1,6 @@
 package middleware
 
 import (
-	"errors"
 	"net/http"
 	"time"
 
@@ -22,7 +21,8 @@ func WithLog(log *zap.Logger) httpkit.MiddlewareFunc {
 			fields := []zap.Field{
 				zap.String("method", method),
 				zap.String("pattern", pattern),
-				zap.String("url", r.RequestURI),
+				zap.String("url", redactedURI(r)),
+				zap.String("request_id", httpkit.RequestIDFrom(r.Context())),
 				zap.Int("status", wr.Status()),
 				zap.Int("bytes_written", wr.BytesWritten()),
 				zap.Duration("took", time.Since(start)),
@@ -38,21 +38,45 @@ func WithLog(log *zap.Logger) httpkit.MiddlewareFunc {
 	}
 }
 
-func WithHTTPErrStatus(method, pattern string, h httpkit.Handler) httpkit.Handler {
-	return httpkit.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
-		err := h.ServeHTTP(w, r)
-		if err == nil {
-			return nil
-		}
+// sensitiveParams are the query parameters carrying credentials, which are
+// not logged.
+var sensitiveParams = []string{"token", "password", "access_token", "refresh_token", "code", "state"}
 
-		var apiErr *httpkit.Error
+// redactedURI returns the request URI with the values of sensitiveParams
+// replaced.
+func redactedURI(r *http.Request) string {
+	query := r.URL.Query()
 
-		if !errors.As(err, &apiErr) {
-			apiErr = &httpkit.Error{Code: http.StatusInternalServerError, Message: "something went wrong", Err: err}
+	redacted := false
+	for _, p := range sensitiveParams {
+		if query.Has(p) {
+			query.Set(p, "REDACTED")
+			redacted = true
 		}
+	}
 
-		httpkit.RespondError(w, apiErr)
+	if !redacted {
+		return r.RequestURI
+	}
 
-		return err
-	})
+	u := *r.URL
+	u.RawQuery = query.Encode()
+	return u.RequestURI()
+}
+
+// WithProblemDetails writes the errors of the handler as problem details,
+// resolved by the registry.
+func WithProblemDetails(problems *httpkit.ProblemRegistry) httpkit.MiddlewareFunc {
+	return func(method, pattern string, h httpkit.Handler) httpkit.Handler {
+		return httpkit.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+			err := h.ServeHTTP(w, r)
+			if err == nil {
+				return nil
+			}
+
+			httpkit.RespondError(w, r, problems.Resolve(err))
+
+			return err
+		})
+	}
 }
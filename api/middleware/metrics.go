@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
 	"strconv"
 	"time"
@@ -18,20 +19,39 @@ const (
 	MB
 )
 
+// metricsLabels are the labels of a request only known once a later
+// middleware authenticated it.
+type metricsLabels struct {
+	apiKey string
+}
+
+type metricsLabelsKey struct{}
+
+// setAPIKeyLabel labels the metrics of the request with the id of the API
+// key it was authenticated with.
+func setAPIKeyLabel(ctx context.Context, keyID string) {
+	if l, ok := ctx.Value(metricsLabelsKey{}).(*metricsLabels); ok {
+		l.apiKey = keyID
+	}
+}
+
+// WithMetrics records the count, latency and response size of the requests.
+// The count and latency are labelled with the id of the API key of the
+// requests made by services, and an empty api_key otherwise.
 func WithMetrics() httpkit.MiddlewareFunc {
 	var (
 		reqCount = promauto.NewCounterVec(prometheus.CounterOpts{
 			Namespace: "sockshop",
 			Name:      "http_request",
 			Help:      "The total number of http request served",
-		}, []string{"method", "pattern", "code"})
+		}, []string{"method", "pattern", "code", "api_key"})
 
 		reqLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
 			Namespace: "sockshop",
 			Name:      "http_request_latency_ms",
 			Help:      "http request latency in millisecond",
 			Buckets:   []float64{10, 20, 50, 100, 500, 1000, 2000},
-		}, []string{"method", "pattern", "code"})
+		}, []string{"method", "pattern", "code", "api_key"})
 
 		respSize = promauto.NewHistogramVec(prometheus.HistogramOpts{
 			Namespace: "sockshop",
@@ -50,14 +70,15 @@ func WithMetrics() httpkit.MiddlewareFunc {
 				wr = middleware.NewWrapResponseWriter(w, r.ProtoMajor)
 			}
 
-			err := h.ServeHTTP(wr, r)
+			labels := &metricsLabels{}
+			err := h.ServeHTTP(wr, r.WithContext(context.WithValue(r.Context(), metricsLabelsKey{}, labels)))
 
 			took := time.Since(start).Milliseconds()
 
 			code := strconv.Itoa(wr.Status())
 
-			reqCount.WithLabelValues(method, pattern, code).Inc()
-			reqLatency.WithLabelValues(method, pattern, code).Observe(float64(took))
+			reqCount.WithLabelValues(method, pattern, code, labels.apiKey).Inc()
+			reqLatency.WithLabelValues(method, pattern, code, labels.apiKey).Observe(float64(took))
 			respSize.WithLabelValues(method, pattern).Observe(float64(wr.BytesWritten()))
 			return err
 		})
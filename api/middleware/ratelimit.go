@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/oshankkumar/sockshop/api/httpkit"
+	"github.com/oshankkumar/sockshop/internal/ratelimit"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// RateLimiter limits the requests to the routes its rules match, with a token
+// bucket per route and client.
+type RateLimiter struct {
+	Store ratelimit.Store
+	Rules []ratelimit.Rule
+}
+
+// rule returns the rule of the route among those keyed by IP, or by client
+// when ipKeyed is false. A rule for the method of the route takes precedence
+// over a rule for every method.
+func (l *RateLimiter) rule(method, pattern string, ipKeyed bool) (ratelimit.Rule, bool) {
+	var (
+		match ratelimit.Rule
+		found bool
+	)
+
+	for _, r := range l.Rules {
+		if r.Pattern != pattern || (r.Key == ratelimit.KeyIP) != ipKeyed {
+			continue
+		}
+
+		switch {
+		case strings.EqualFold(r.Method, method):
+			return r, true
+		case r.Method == "" && !found:
+			match, found = r, true
+		}
+	}
+
+	return match, found
+}
+
+// WithRateLimit rejects the requests of the clients out of tokens with a
+// ratelimit.LimitedError, and reports the limit of the route in the
+// RateLimit-* headers. It returns a middleware for each side of WithAuth:
+// beforeAuth applies the rules keyed by IP, so that requests failing
+// authentication are limited too, and afterAuth the rules keyed by client,
+// which need the API key or the user WithAuth tells. A route may have a rule
+// of each. Requests are let through when the store fails, so that an outage
+// of a shared store does not take the API down. A nil limiter limits
+// nothing.
+func WithRateLimit(l *RateLimiter) (beforeAuth, afterAuth httpkit.MiddlewareFunc) {
+	if l == nil {
+		none := func(method, pattern string, h httpkit.Handler) httpkit.Handler { return h }
+		return none, none
+	}
+
+	decisions := promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "sockshop",
+		Name:      "ratelimit_decisions",
+		Help:      "The rate limiting decisions, allowed, limited or error when the store failed",
+	}, []string{"method", "pattern", "decision"})
+
+	return l.middleware(decisions, true), l.middleware(decisions, false)
+}
+
+func (l *RateLimiter) middleware(decisions *prometheus.CounterVec, ipKeyed bool) httpkit.MiddlewareFunc {
+	return func(method, pattern string, h httpkit.Handler) httpkit.Handler {
+		rule, ok := l.rule(method, pattern, ipKeyed)
+		if !ok {
+			return h
+		}
+
+		limit := rule.Limit()
+		policy := strconv.Itoa(limit.Burst) + ";w=" + ceilSeconds(limit.Window())
+
+		// The rules of a route keyed by IP and by client both limit the
+		// anonymous clients by IP, in buckets of their own.
+		bucket := method + " " + pattern + "|" + ratelimit.KeyClient + "|"
+		if ipKeyed {
+			bucket = method + " " + pattern + "|" + ratelimit.KeyIP + "|"
+		}
+
+		return httpkit.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+			key := bucket + rateLimitKey(r, rule.Key)
+
+			d, err := l.Store.Take(r.Context(), key, limit, time.Now())
+			if err != nil {
+				decisions.WithLabelValues(method, pattern, "error").Inc()
+				return h.ServeHTTP(w, r)
+			}
+
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(d.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(d.Remaining))
+			w.Header().Set("RateLimit-Reset", ceilSeconds(d.Reset))
+			w.Header().Set("RateLimit-Policy", policy)
+
+			if !d.Allowed {
+				decisions.WithLabelValues(method, pattern, "limited").Inc()
+				return ratelimit.LimitedError{RetryAfter: d.RetryAfter}
+			}
+
+			decisions.WithLabelValues(method, pattern, "allowed").Inc()
+			return h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// rateLimitKey returns the client whose bucket the request takes from.
+func rateLimitKey(r *http.Request, key string) string {
+	if key != ratelimit.KeyIP {
+		if p, ok := httpkit.PrincipalFrom(r.Context()); ok {
+			if p.APIKeyID != "" {
+				return "apikey:" + p.APIKeyID
+			}
+			return "user:" + p.Subject
+		}
+	}
+
+	return "ip:" + httpkit.ClientIP(r)
+}
+
+// ceilSeconds formats the duration in whole seconds, rounded up so that a
+// client waiting for them is not limited again.
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,124 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/oshankkumar/sockshop/api/httpkit"
+	"github.com/oshankkumar/sockshop/internal/ratelimit"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// tokenAuthenticator accepts the access token "good" only.
+type tokenAuthenticator struct{}
+
+func (tokenAuthenticator) Authenticate(_ context.Context, token string) (httpkit.Principal, error) {
+	if token != "good" {
+		return httpkit.Principal{}, errors.New("invalid token")
+	}
+	return httpkit.Principal{Subject: "alice"}, nil
+}
+
+func (tokenAuthenticator) AuthenticateAPIKey(context.Context, string) (httpkit.Principal, error) {
+	return httpkit.Principal{}, errors.New("invalid API key")
+}
+
+func TestWithRateLimit(t *testing.T) {
+	type request struct {
+		ip    string
+		token string
+		// want is the status of the response, 429 when limited.
+		want int
+	}
+
+	tests := []struct {
+		name     string
+		requests []request
+	}{
+		{
+			name: "bad credentials are limited by IP",
+			requests: []request{
+				{ip: "10.0.0.1", token: "bad", want: http.StatusUnauthorized},
+				{ip: "10.0.0.1", token: "bad", want: http.StatusUnauthorized},
+				{ip: "10.0.0.1", token: "bad", want: http.StatusUnauthorized},
+				{ip: "10.0.0.1", token: "bad", want: http.StatusUnauthorized},
+				{ip: "10.0.0.1", token: "bad", want: http.StatusTooManyRequests},
+				{ip: "10.0.0.1", token: "good", want: http.StatusTooManyRequests},
+				{ip: "10.0.0.2", token: "bad", want: http.StatusUnauthorized},
+			},
+		},
+		{
+			name: "authenticated clients are limited by client",
+			requests: []request{
+				{ip: "10.0.0.1", token: "good", want: http.StatusOK},
+				{ip: "10.0.0.2", token: "good", want: http.StatusOK},
+				{ip: "10.0.0.3", token: "good", want: http.StatusTooManyRequests},
+				{ip: "10.0.0.3", want: http.StatusOK},
+			},
+		},
+		{
+			name: "anonymous clients take from both rules",
+			requests: []request{
+				{ip: "10.0.0.1", want: http.StatusOK},
+				{ip: "10.0.0.1", want: http.StatusOK},
+				{ip: "10.0.0.1", want: http.StatusTooManyRequests},
+				{ip: "10.0.0.1", token: "good", want: http.StatusOK},
+				{ip: "10.0.0.1", token: "good", want: http.StatusTooManyRequests},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &RateLimiter{
+				Store: ratelimit.NewMemoryStore(),
+				Rules: []ratelimit.Rule{
+					{Method: "GET", Pattern: "/catalogue", Rate: 0.001, Burst: 2, Key: ratelimit.KeyClient},
+					{Method: "GET", Pattern: "/catalogue", Rate: 0.001, Burst: 4, Key: ratelimit.KeyIP},
+				},
+			}
+
+			decisions := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "decisions"}, []string{"method", "pattern", "decision"})
+
+			h := httpkit.ChainMiddleware(
+				l.middleware(decisions, true),
+				WithAuth(tokenAuthenticator{}, httpkit.AuthPolicy{}),
+				l.middleware(decisions, false),
+			)(http.MethodGet, "/catalogue", httpkit.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+				return nil
+			}))
+
+			for i, req := range tt.requests {
+				r := httptest.NewRequest(http.MethodGet, "/catalogue", nil)
+				r.RemoteAddr = req.ip + ":40000"
+				if req.token != "" {
+					r.Header.Set("Authorization", "Bearer "+req.token)
+				}
+
+				got := http.StatusOK
+
+				var (
+					limited ratelimit.LimitedError
+					httpErr *httpkit.Error
+				)
+
+				switch err := h.ServeHTTP(httptest.NewRecorder(), r); {
+				case errors.As(err, &limited):
+					got = http.StatusTooManyRequests
+				case errors.As(err, &httpErr):
+					got = httpErr.Code
+				case err != nil:
+					t.Fatalf("request %d: %v", i, err)
+				}
+
+				if got != req.want {
+					t.Fatalf("request %d from %s with token %q: got %d, want %d", i, req.ip, req.token, got, req.want)
+				}
+			}
+		})
+	}
+}
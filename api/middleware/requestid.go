@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/oshankkumar/sockshop/api/httpkit"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// WithRequestID stores the id of the request in its context and echoes it in
+// the response. The id of the X-Request-ID request header is kept, so that a
+// request can be traced across services. Otherwise a random id is generated.
+func WithRequestID(method, pattern string, h httpkit.Handler) httpkit.Handler {
+	return httpkit.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+
+		w.Header().Set(requestIDHeader, id)
+
+		return h.ServeHTTP(w, r.WithContext(httpkit.WithRequestID(r.Context(), id)))
+	})
+}
+
+// validRequestID accepts ids of up to 64 printable ASCII characters, which are
+// safe to log and echo.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+
+	for _, c := range id {
+		if c < '!' || c > '~' {
+			return false
+		}
+	}
+
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,13 @@
+package api
+
+import "context"
+
+type OIDCService interface {
+	// StartLogin returns the authorization endpoint URL of the identity
+	// provider to redirect the customer to.
+	StartLogin(ctx context.Context, provider string) (string, error)
+	// CompleteLogin exchanges the authorization code the provider redirected
+	// the customer back with, and returns the customer linked to the
+	// identity. It fails with ErrUnauthorized on an unknown or expired state.
+	CompleteLogin(ctx context.Context, provider, state, code string) (*User, error)
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type CreateOrderRequest struct {
+	Customer uuid.UUID `json:"customer" validate:"required"`
+	Address  uuid.UUID `json:"address" validate:"required"`
+	Card     uuid.UUID `json:"card" validate:"required"`
+}
+
+type ShippingAddress struct {
+	Street   string `json:"street"`
+	Number   string `json:"number"`
+	Country  string `json:"country"`
+	City     string `json:"city"`
+	PostCode string `json:"postcode"`
+}
+
+type OrderItem struct {
+	ItemID    uuid.UUID `json:"itemId"`
+	Quantity  int       `json:"quantity"`
+	UnitPrice float64   `json:"unitPrice"`
+}
+
+type OrderStatus struct {
+	Status string    `json:"status"`
+	Time   time.Time `json:"time"`
+}
+
+type Order struct {
+	ID         uuid.UUID       `json:"id"`
+	CustomerID uuid.UUID       `json:"customerId"`
+	CardID     uuid.UUID       `json:"cardId"`
+	Address    ShippingAddress `json:"address"`
+	Items      []OrderItem     `json:"items"`
+	Subtotal   float64         `json:"subtotal"`
+	Shipping   float64         `json:"shipping"`
+	Total      float64         `json:"total"`
+	Status     string          `json:"status"`
+	History    []OrderStatus   `json:"history"`
+	CreatedAt  time.Time       `json:"createdAt"`
+	Links      Links           `json:"_links"`
+}
+
+type UpdateOrderStatusRequest struct {
+	Status string `json:"status" validate:"required"`
+}
+
+type ListOrdersParams struct {
+	PageSize int
+	Cursor   string
+}
+
+type ListOrdersResponse struct {
+	Orders     []Order `json:"orders"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+	Links      Links   `json:"_links"`
+}
+
+type CustomerOrdersResponse struct {
+	Orders []Order `json:"orders"`
+}
+
+type OrderService interface {
+	CreateOrder(ctx context.Context, req CreateOrderRequest) (*Order, error)
+	GetOrder(ctx context.Context, id string) (*Order, error)
+	PayOrder(ctx context.Context, id string) (*Order, error)
+	UpdateOrderStatus(ctx context.Context, id string, status string) (*Order, error)
+	ListOrders(ctx context.Context, req *ListOrdersParams) (*ListOrdersResponse, error)
+	GetCustomerOrders(ctx context.Context, customerID string) ([]Order, error)
+}
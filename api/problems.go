@@ -0,0 +1,131 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/oshankkumar/sockshop/api/httpkit"
+	"github.com/oshankkumar/sockshop/internal/domain"
+	"github.com/oshankkumar/sockshop/internal/ratelimit"
+)
+
+// Problem types of the domain errors.
+var (
+	ProblemNotFound          = httpkit.ProblemType{URI: "/problems/not-found", Title: "Resource not found", Status: http.StatusNotFound}
+	ProblemInvalid           = httpkit.ProblemType{URI: "/problems/invalid-request", Title: "Invalid request", Status: http.StatusBadRequest}
+	ProblemDuplicate         = httpkit.ProblemType{URI: "/problems/duplicate", Title: "Resource already exists", Status: http.StatusConflict}
+	ProblemConflict          = httpkit.ProblemType{URI: "/problems/conflict", Title: "Conflicting resource state", Status: http.StatusConflict}
+	ProblemVersionMismatch   = httpkit.ProblemType{URI: "/problems/version-mismatch", Title: "Resource was modified", Status: http.StatusPreconditionFailed}
+	ProblemInsufficientStock = httpkit.ProblemType{URI: "/problems/insufficient-stock", Title: "Not enough stock", Status: http.StatusConflict}
+	ProblemPaymentDeclined   = httpkit.ProblemType{URI: "/problems/payment-declined", Title: "Payment declined", Status: http.StatusPaymentRequired}
+	ProblemRefundDeclined    = httpkit.ProblemType{URI: "/problems/refund-declined", Title: "Refund declined", Status: http.StatusBadGateway}
+	ProblemUnauthorized      = httpkit.ProblemType{URI: "/problems/unauthorized", Title: "Not authorised", Status: http.StatusUnauthorized}
+	ProblemForbidden         = httpkit.ProblemType{URI: "/problems/forbidden", Title: "Forbidden", Status: http.StatusForbidden}
+	ProblemLoginThrottled    = httpkit.ProblemType{URI: "/problems/login-throttled", Title: "Too many failed logins", Status: http.StatusTooManyRequests}
+	ProblemAccountLocked     = httpkit.ProblemType{URI: "/problems/account-locked", Title: "Account locked", Status: http.StatusLocked}
+	ProblemRateLimited       = httpkit.ProblemType{URI: "/problems/rate-limited", Title: "Too many requests", Status: http.StatusTooManyRequests}
+)
+
+// NewProblemRegistry returns the registry mapping the domain errors to their
+// problem types.
+func NewProblemRegistry() *httpkit.ProblemRegistry {
+	reg := &httpkit.ProblemRegistry{}
+
+	// The problem of an order which could not be paid is that of its payment
+	// error, which the registry maps below, with the order ID added.
+	reg.RegisterFunc(func(err error) (*httpkit.Error, bool) {
+		var payErr OrderPaymentError
+		if !errors.As(err, &payErr) {
+			return nil, false
+		}
+
+		apiErr := *reg.Resolve(payErr.Err)
+		apiErr.Extensions = map[string]interface{}{"order_id": payErr.OrderID}
+		apiErr.Err = err
+		return &apiErr, true
+	})
+
+	// A ValidationError also matches ErrInvalid, so it is mapped first.
+	reg.RegisterFunc(func(err error) (*httpkit.Error, bool) {
+		var verr domain.ValidationError
+		if !errors.As(err, &verr) {
+			return nil, false
+		}
+
+		fields := make([]httpkit.FieldError, 0, len(verr.Fields))
+		for _, f := range verr.Fields {
+			fields = append(fields, httpkit.FieldError{Field: f.Field, Message: f.Message})
+		}
+		return httpkit.NewValidationError("", fields, err), true
+	})
+
+	reg.RegisterFunc(func(err error) (*httpkit.Error, bool) {
+		var dupErr domain.DuplicateEntryError
+		if !errors.As(err, &dupErr) {
+			return nil, false
+		}
+
+		t := ProblemDuplicate
+		return &httpkit.Error{Code: t.Status, Type: t.URI, Title: t.Title, Message: dupErr.Entity + " already exists", Err: err}, true
+	})
+
+	reg.RegisterFunc(func(err error) (*httpkit.Error, bool) {
+		var throttled LoginThrottledError
+		if !errors.As(err, &throttled) {
+			return nil, false
+		}
+
+		t := ProblemLoginThrottled
+		if throttled.Locked {
+			t = ProblemAccountLocked
+		}
+
+		// Retry-After is in whole seconds, rounded up so that a client
+		// retrying on time is not rejected again.
+		retryAfter := int(math.Ceil(throttled.RetryAfter.Seconds()))
+
+		return &httpkit.Error{
+			Code:    t.Status,
+			Type:    t.URI,
+			Title:   t.Title,
+			Message: fmt.Sprintf("retry in %d seconds", retryAfter),
+			Header:  http.Header{"Retry-After": []string{strconv.Itoa(retryAfter)}},
+			Err:     err,
+		}, true
+	})
+
+	reg.RegisterFunc(func(err error) (*httpkit.Error, bool) {
+		var limited ratelimit.LimitedError
+		if !errors.As(err, &limited) {
+			return nil, false
+		}
+
+		retryAfter := int(math.Ceil(limited.RetryAfter.Seconds()))
+
+		t := ProblemRateLimited
+		return &httpkit.Error{
+			Code:    t.Status,
+			Type:    t.URI,
+			Title:   t.Title,
+			Message: fmt.Sprintf("retry in %d seconds", retryAfter),
+			Header:  http.Header{"Retry-After": []string{strconv.Itoa(retryAfter)}},
+			Err:     err,
+		}, true
+	})
+
+	reg.Register(domain.ErrNotFound, ProblemNotFound)
+	reg.Register(ErrNotFound, ProblemNotFound)
+	reg.Register(domain.ErrInvalid, ProblemInvalid)
+	reg.Register(domain.ErrConflict, ProblemConflict)
+	reg.Register(domain.ErrVersionMismatch, ProblemVersionMismatch)
+	reg.Register(domain.ErrInsufficientStock, ProblemInsufficientStock)
+	reg.Register(domain.ErrPaymentDeclined, ProblemPaymentDeclined)
+	reg.Register(domain.ErrRefundDeclined, ProblemRefundDeclined)
+	reg.Register(ErrUnauthorized, ProblemUnauthorized)
+	reg.Register(ErrForbidden, ProblemForbidden)
+
+	return reg
+}
@@ -0,0 +1,142 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/oshankkumar/sockshop/api/httpkit"
+	"github.com/oshankkumar/sockshop/internal/domain"
+	"github.com/oshankkumar/sockshop/internal/ratelimit"
+)
+
+func TestNewProblemRegistry(t *testing.T) {
+	orderID := uuid.New()
+
+	tests := []struct {
+		name           string
+		err            error
+		wantCode       int
+		wantType       string
+		wantFields     []httpkit.FieldError
+		wantRetry      string
+		wantExtensions map[string]interface{}
+	}{
+		{
+			name:     "not found",
+			err:      fmt.Errorf("UserService.GetUser(id=1): %w", domain.ErrNotFound),
+			wantCode: http.StatusNotFound,
+			wantType: ProblemNotFound.URI,
+		},
+		{
+			name:     "version mismatch",
+			err:      fmt.Errorf("UserService.UpdateUser(id=1): %w", domain.ErrVersionMismatch),
+			wantCode: http.StatusPreconditionFailed,
+			wantType: ProblemVersionMismatch.URI,
+		},
+		{
+			name:       "validation error",
+			err:        fmt.Errorf("UserService.CreateCard: %w", domain.ValidationError{Fields: []domain.FieldError{{Field: "longNum", Message: "invalid card number"}}}),
+			wantCode:   http.StatusUnprocessableEntity,
+			wantType:   httpkit.ValidationProblem.URI,
+			wantFields: []httpkit.FieldError{{Field: "longNum", Message: "invalid card number"}},
+		},
+		{
+			name:     "invalid",
+			err:      fmt.Errorf("%w: cart is empty", domain.ErrInvalid),
+			wantCode: http.StatusBadRequest,
+			wantType: ProblemInvalid.URI,
+		},
+		{
+			name:     "conflict",
+			err:      fmt.Errorf("OrderService.PayOrder(id=1): %w: order is shipped", domain.ErrConflict),
+			wantCode: http.StatusConflict,
+			wantType: ProblemConflict.URI,
+		},
+		{
+			name:     "forbidden",
+			err:      fmt.Errorf("UserService.ChangePassword(id=1): current password: %w", ErrForbidden),
+			wantCode: http.StatusForbidden,
+			wantType: ProblemForbidden.URI,
+		},
+		{
+			name:     "refund declined",
+			err:      fmt.Errorf("PaymentService.Refund(orderID=1): %w: card_blocked", domain.ErrRefundDeclined),
+			wantCode: http.StatusBadGateway,
+			wantType: ProblemRefundDeclined.URI,
+		},
+		{
+			name:     "duplicate",
+			err:      domain.DuplicateEntryError{Entity: "customer"},
+			wantCode: http.StatusConflict,
+			wantType: ProblemDuplicate.URI,
+		},
+		{
+			name:      "login throttled",
+			err:       fmt.Errorf("UserService.Login: %w", LoginThrottledError{RetryAfter: 1500 * time.Millisecond}),
+			wantCode:  http.StatusTooManyRequests,
+			wantType:  ProblemLoginThrottled.URI,
+			wantRetry: "2",
+		},
+		{
+			name:      "account locked",
+			err:       LoginThrottledError{RetryAfter: time.Minute, Locked: true},
+			wantCode:  http.StatusLocked,
+			wantType:  ProblemAccountLocked.URI,
+			wantRetry: "60",
+		},
+		{
+			name:      "rate limited",
+			err:       ratelimit.LimitedError{RetryAfter: 100 * time.Millisecond},
+			wantCode:  http.StatusTooManyRequests,
+			wantType:  ProblemRateLimited.URI,
+			wantRetry: "1",
+		},
+		{
+			name:           "order payment declined",
+			err:            fmt.Errorf("OrderService.CreateOrder: %w", OrderPaymentError{OrderID: orderID, Err: fmt.Errorf("%w: card_blocked", domain.ErrPaymentDeclined)}),
+			wantCode:       http.StatusPaymentRequired,
+			wantType:       ProblemPaymentDeclined.URI,
+			wantExtensions: map[string]interface{}{"order_id": orderID},
+		},
+		{
+			name:           "order payment failed",
+			err:            OrderPaymentError{OrderID: orderID, Err: fmt.Errorf("connection reset")},
+			wantCode:       http.StatusInternalServerError,
+			wantExtensions: map[string]interface{}{"order_id": orderID},
+		},
+		{
+			name:     "unknown",
+			err:      fmt.Errorf("connection reset"),
+			wantCode: http.StatusInternalServerError,
+		},
+	}
+
+	reg := NewProblemRegistry()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := reg.Resolve(tt.err)
+
+			if got.Code != tt.wantCode || got.Type != tt.wantType {
+				t.Fatalf("got %d %q, want %d %q", got.Code, got.Type, tt.wantCode, tt.wantType)
+			}
+			if !reflect.DeepEqual(got.Fields, tt.wantFields) {
+				t.Fatalf("got fields %v, want %v", got.Fields, tt.wantFields)
+			}
+			if retry := got.Header.Get("Retry-After"); retry != tt.wantRetry {
+				t.Fatalf("got Retry-After %q, want %q", retry, tt.wantRetry)
+			}
+			if !reflect.DeepEqual(got.Extensions, tt.wantExtensions) {
+				t.Fatalf("got extensions %v, want %v", got.Extensions, tt.wantExtensions)
+			}
+			if got.Err != tt.err {
+				t.Fatalf("got error %v, want %v", got.Err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package apikey
+
+import (
+	"net/http"
+
+	"github.com/oshankkumar/sockshop/api"
+	"github.com/oshankkumar/sockshop/api/router"
+	"github.com/oshankkumar/sockshop/internal/domain"
+)
+
+func NewRouter(svc api.APIKeyService) *Router {
+	return &Router{apiKeyService: svc}
+}
+
+type Router struct {
+	apiKeyService api.APIKeyService
+}
+
+func (a *Router) Routes() []router.Route {
+	return []router.Route{
+		{Method: http.MethodPost, Pattern: "/api-keys", Handler: createAPIKeyHandler(a.apiKeyService), Permission: domain.PermAPIKeysManage},
+		{Method: http.MethodGet, Pattern: "/api-keys", Handler: listAPIKeysHandler(a.apiKeyService), Permission: domain.PermAPIKeysManage},
+		{Method: http.MethodPut, Pattern: "/api-keys/{id}/scopes", Handler: setAPIKeyScopesHandler(a.apiKeyService), Permission: domain.PermAPIKeysManage},
+		{Method: http.MethodDelete, Pattern: "/api-keys/{id}", Handler: revokeAPIKeyHandler(a.apiKeyService), Permission: domain.PermAPIKeysManage},
+	}
+}
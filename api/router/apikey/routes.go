@@ -0,0 +1,89 @@
+package apikey
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/oshankkumar/sockshop/api"
+	"github.com/oshankkumar/sockshop/api/httpkit"
+)
+
+type apiKeyCreator interface {
+	CreateAPIKey(ctx context.Context, createdBy string, req api.CreateAPIKeyRequest) (*api.CreatedAPIKey, error)
+}
+
+type apiKeysLister interface {
+	ListAPIKeys(ctx context.Context) (*api.ListAPIKeysResponse, error)
+}
+
+type apiKeyScoper interface {
+	SetAPIKeyScopes(ctx context.Context, id string, scopes []string) (*api.APIKey, error)
+}
+
+type apiKeyRevoker interface {
+	RevokeAPIKey(ctx context.Context, id string) error
+}
+
+// createAPIKeyHandler mints a key. The key is only ever returned by this
+// response, which must not be cached.
+func createAPIKeyHandler(kc apiKeyCreator) httpkit.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		var req api.CreateAPIKeyRequest
+		if err := httpkit.DecodeJSON(w, r, &req); err != nil {
+			return err
+		}
+
+		p, _ := httpkit.PrincipalFrom(r.Context())
+
+		key, err := kc.CreateAPIKey(r.Context(), p.Subject, req)
+		if err != nil {
+			return err
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		httpkit.RespondJSON(w, key, http.StatusCreated)
+		return nil
+	}
+}
+
+func listAPIKeysHandler(kl apiKeysLister) httpkit.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		keys, err := kl.ListAPIKeys(r.Context())
+		if err != nil {
+			return err
+		}
+
+		httpkit.RespondJSON(w, keys, http.StatusOK)
+		return nil
+	}
+}
+
+func setAPIKeyScopesHandler(ks apiKeyScoper) httpkit.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		var req api.APIKeyScopesRequest
+		if err := httpkit.DecodeJSON(w, r, &req); err != nil {
+			return err
+		}
+
+		key, err := ks.SetAPIKeyScopes(r.Context(), chi.URLParam(r, "id"), req.Scopes)
+		if err != nil {
+			return err
+		}
+
+		httpkit.RespondJSON(w, key, http.StatusOK)
+		return nil
+	}
+}
+
+func revokeAPIKeyHandler(kr apiKeyRevoker) httpkit.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if err := kr.RevokeAPIKey(r.Context(), chi.URLParam(r, "id")); err != nil {
+			return err
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+}
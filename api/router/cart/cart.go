@@ -0,0 +1,38 @@
+package cart
+
+import (
+	"net/http"
+
+	"github.com/oshankkumar/sockshop/api"
+	"github.com/oshankkumar/sockshop/api/httpkit"
+	"github.com/oshankkumar/sockshop/api/router"
+)
+
+// cartOwnerOnly restricts the carts to the customers owning them.
+var cartOwnerOnly = httpkit.AuthPolicy{Required: true, OwnerParam: "customerId"}
+
+func NewRouter(svc api.CartService) *Router {
+	return &Router{cartService: svc}
+}
+
+type Router struct {
+	cartService api.CartService
+}
+
+func (c *Router) Routes() []router.Route {
+	return []router.Route{
+		{Method: http.MethodGet, Pattern: "/carts/{customerId}", Handler: getCartHandler(c.cartService, customerCartID), Auth: cartOwnerOnly},
+		{Method: http.MethodDelete, Pattern: "/carts/{customerId}", Handler: deleteCartHandler(c.cartService, customerCartID), Auth: cartOwnerOnly},
+		{Method: http.MethodPost, Pattern: "/carts/{customerId}/items", Handler: addItemHandler(c.cartService, customerCartID), Auth: cartOwnerOnly},
+		{Method: http.MethodPatch, Pattern: "/carts/{customerId}/items", Handler: updateItemHandler(c.cartService, customerCartID), Auth: cartOwnerOnly},
+		{Method: http.MethodDelete, Pattern: "/carts/{customerId}/items/{itemId}", Handler: deleteItemHandler(c.cartService, customerCartID), Auth: cartOwnerOnly},
+
+		// Anonymous carts, addressed by the unguessable ID the server issued.
+		{Method: http.MethodPost, Pattern: "/session-carts", Handler: newSessionCartHandler(c.cartService)},
+		{Method: http.MethodGet, Pattern: "/session-carts/{sessionId}", Handler: getCartHandler(c.cartService, sessionCartID)},
+		{Method: http.MethodDelete, Pattern: "/session-carts/{sessionId}", Handler: deleteCartHandler(c.cartService, sessionCartID)},
+		{Method: http.MethodPost, Pattern: "/session-carts/{sessionId}/items", Handler: addItemHandler(c.cartService, sessionCartID)},
+		{Method: http.MethodPatch, Pattern: "/session-carts/{sessionId}/items", Handler: updateItemHandler(c.cartService, sessionCartID)},
+		{Method: http.MethodDelete, Pattern: "/session-carts/{sessionId}/items/{itemId}", Handler: deleteItemHandler(c.cartService, sessionCartID)},
+	}
+}
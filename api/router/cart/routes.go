@@ -0,0 +1,161 @@
+package cart
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/oshankkumar/sockshop/api"
+	"github.com/oshankkumar/sockshop/api/httpkit"
+	"github.com/oshankkumar/sockshop/internal/domain"
+)
+
+// cartIDFunc returns the ID of the cart the request is for.
+type cartIDFunc func(r *http.Request) (string, error)
+
+func customerCartID(r *http.Request) (string, error) {
+	return chi.URLParam(r, "customerId"), nil
+}
+
+// sessionCartID only accepts session cart IDs, so that the anonymous routes
+// never reach the carts of customers.
+func sessionCartID(r *http.Request) (string, error) {
+	id := chi.URLParam(r, "sessionId")
+	if !domain.IsSessionCartID(id) {
+		return "", fmt.Errorf("session cart %q: %w", id, domain.ErrNotFound)
+	}
+	return id, nil
+}
+
+type sessionCartCreator interface {
+	NewSessionCart(ctx context.Context) (*api.Cart, error)
+}
+
+type cartGetter interface {
+	GetCart(ctx context.Context, customerID string) (*api.Cart, error)
+}
+
+type cartDeleter interface {
+	DeleteCart(ctx context.Context, customerID string) error
+}
+
+type itemAdder interface {
+	AddItem(ctx context.Context, customerID string, item api.CartItem) (*api.CartItem, error)
+}
+
+type itemUpdater interface {
+	UpdateItem(ctx context.Context, customerID string, item api.CartItem) (*api.CartItem, error)
+}
+
+type itemDeleter interface {
+	DeleteItem(ctx context.Context, customerID, itemID string) error
+}
+
+func newSessionCartHandler(sc sessionCartCreator) httpkit.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		cart, err := sc.NewSessionCart(r.Context())
+		if err != nil {
+			return err
+		}
+
+		httpkit.RespondJSON(w, cart, http.StatusCreated)
+		return nil
+	}
+}
+
+func getCartHandler(cg cartGetter, cartID cartIDFunc) httpkit.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		customerID, err := cartID(r)
+		if err != nil {
+			return err
+		}
+
+		cart, err := cg.GetCart(r.Context(), customerID)
+		if err != nil {
+			return err
+		}
+
+		httpkit.RespondJSON(w, cart, http.StatusOK)
+		return nil
+	}
+}
+
+func deleteCartHandler(cd cartDeleter, cartID cartIDFunc) httpkit.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		customerID, err := cartID(r)
+		if err != nil {
+			return err
+		}
+
+		if err := cd.DeleteCart(r.Context(), customerID); err != nil {
+			return err
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+}
+
+func addItemHandler(ia itemAdder, cartID cartIDFunc) httpkit.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		customerID, err := cartID(r)
+		if err != nil {
+			return err
+		}
+
+		var item api.CartItem
+		if err := httpkit.DecodeJSON(w, r, &item); err != nil {
+			return err
+		}
+
+		added, err := ia.AddItem(r.Context(), customerID, item)
+
+		if err != nil {
+			return err
+		}
+
+		httpkit.RespondJSON(w, added, http.StatusCreated)
+		return nil
+	}
+}
+
+func updateItemHandler(iu itemUpdater, cartID cartIDFunc) httpkit.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		customerID, err := cartID(r)
+		if err != nil {
+			return err
+		}
+
+		var item api.CartItem
+		if err := httpkit.DecodeJSON(w, r, &item); err != nil {
+			return err
+		}
+
+		updated, err := iu.UpdateItem(r.Context(), customerID, item)
+
+		if err != nil {
+			return err
+		}
+
+		httpkit.RespondJSON(w, updated, http.StatusOK)
+		return nil
+	}
+}
+
+func deleteItemHandler(id itemDeleter, cartID cartIDFunc) httpkit.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		customerID, err := cartID(r)
+		if err != nil {
+			return err
+		}
+
+		if err := id.DeleteItem(r.Context(), customerID, chi.URLParam(r, "itemId")); err != nil {
+			return err
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+}
@@ -21,8 +21,12 @@ type Router struct {
 func (c *Router) Routes() []router.Route {
 	return []router.Route{
 		{Method: http.MethodGet, Pattern: "/catalogue", Handler: listSocksHandler(c.catalogueService)},
+		{Method: http.MethodPost, Pattern: "/catalogue", Handler: createSockHandler(c.catalogueService), Permission: domain.PermCatalogueWrite},
 		{Method: http.MethodGet, Pattern: "/catalogue/size", Handler: countTagsHandler(c.sockStore)},
-		{Method: http.MethodGet, Pattern: "/catalogue/{id}", Handler: getSocksHandler(c.sockStore)},
+		{Method: http.MethodGet, Pattern: "/catalogue/search", Handler: searchSocksHandler(c.catalogueService)},
+		{Method: http.MethodGet, Pattern: "/catalogue/{id}", Handler: getSocksHandler(c.catalogueService)},
+		{Method: http.MethodPut, Pattern: "/catalogue/{id}", Handler: updateSockHandler(c.catalogueService), Permission: domain.PermCatalogueWrite},
+		{Method: http.MethodPatch, Pattern: "/catalogue/{id}", Handler: patchSockHandler(c.catalogueService), Permission: domain.PermCatalogueWrite},
 		{Method: http.MethodGet, Pattern: "/tags", Handler: tagsHandler(c.sockStore)},
 	}
 }
@@ -2,12 +2,14 @@ package catalogue
 
 import (
 	"context"
-	"errors"
+	"fmt"
+	"math"
 	"net/http"
 	"strconv"
 	"strings"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
 
 	"github.com/oshankkumar/sockshop/api"
 	"github.com/oshankkumar/sockshop/api/httpkit"
@@ -18,12 +20,28 @@ type sockLister interface {
 	ListSocks(ctx context.Context, req *api.ListSockParams) (*api.ListSockResponse, error)
 }
 
+type sockSearcher interface {
+	SearchSocks(ctx context.Context, req *api.SearchSockParams) (*api.SearchSockResponse, error)
+}
+
+type sockCreator interface {
+	CreateSock(ctx context.Context, sock api.Sock) (uuid.UUID, error)
+}
+
+type sockUpdater interface {
+	UpdateSock(ctx context.Context, id string, sock api.Sock) (*api.Sock, error)
+}
+
+type sockPatcher interface {
+	PatchSock(ctx context.Context, id string, patch api.SockPatch) (*api.Sock, error)
+}
+
 type tagCounter interface {
-	Count(ctx context.Context, tags []string) (int, error)
+	Count(ctx context.Context, f domain.SockFilter) (int, error)
 }
 
 type sockGetter interface {
-	Get(ctx context.Context, id string) (domain.Sock, error)
+	GetSock(ctx context.Context, id string) (*api.Sock, error)
 }
 
 type tagsGetter interface {
@@ -32,9 +50,37 @@ type tagsGetter interface {
 
 func listSocksHandler(sockLister sockLister) httpkit.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) error {
-		resp, err := sockLister.ListSocks(r.Context(), decodeListReq(r))
+		req, err := decodeListReq(r)
+		if err != nil {
+			return &httpkit.Error{Code: http.StatusBadRequest, Message: "invalid list parameters", Err: err}
+		}
+
+		resp, err := sockLister.ListSocks(r.Context(), req)
+
+		if err != nil {
+			return err
+		}
+
+		httpkit.RespondJSON(w, resp, http.StatusOK)
+		return nil
+	}
+}
+
+func searchSocksHandler(searcher sockSearcher) httpkit.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		limit := 10
+		if l := r.FormValue("limit"); l != "" {
+			n, err := strconv.Atoi(l)
+			if err != nil || n < 1 || n > maxPageSize {
+				return &httpkit.Error{Code: http.StatusBadRequest, Message: "invalid limit", Err: err}
+			}
+			limit = n
+		}
+
+		resp, err := searcher.SearchSocks(r.Context(), &api.SearchSockParams{Query: r.FormValue("q"), Limit: limit})
+
 		if err != nil {
-			return &httpkit.Error{Code: http.StatusInternalServerError, Message: "failed to list socks", Err: err}
+			return err
 		}
 
 		httpkit.RespondJSON(w, resp, http.StatusOK)
@@ -49,9 +95,9 @@ func countTagsHandler(tagCounter tagCounter) httpkit.HandlerFunc {
 			tags = strings.Split(tagsval, ",")
 		}
 
-		c, err := tagCounter.Count(r.Context(), tags)
+		c, err := tagCounter.Count(r.Context(), domain.SockFilter{Tags: tags})
 		if err != nil {
-			return &httpkit.Error{Code: http.StatusInternalServerError, Message: "failed to count tags", Err: err}
+			return err
 		}
 
 		httpkit.RespondJSON(w, &api.CountTagsResponse{Size: c}, http.StatusOK)
@@ -61,29 +107,65 @@ func countTagsHandler(tagCounter tagCounter) httpkit.HandlerFunc {
 
 func getSocksHandler(sockGetter sockGetter) httpkit.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) error {
-		sock, err := sockGetter.Get(r.Context(), chi.URLParam(r, "id"))
+		sock, err := sockGetter.GetSock(r.Context(), chi.URLParam(r, "id"))
 
-		switch {
-		case errors.Is(err, domain.ErrNotFound):
-			return &httpkit.Error{Code: http.StatusNotFound, Message: "failed to get sock", Err: err}
-		case err != nil:
-			return &httpkit.Error{Code: http.StatusInternalServerError, Message: "failed to get sock", Err: err}
+		if err != nil {
+			return err
 		}
 
-		var tags []string
-		for _, t := range sock.Tags {
-			tags = append(tags, t.Name)
-		}
-
-		httpkit.RespondJSON(w, api.Sock{
-			ID:          sock.ID,
-			Name:        sock.Name,
-			Description: sock.Description,
-			ImageURL:    strings.Split(sock.ImageURLs, ","),
-			Price:       sock.Price,
-			Count:       sock.Count,
-			Tags:        tags,
-		}, http.StatusOK)
+		httpkit.RespondJSON(w, sock, http.StatusOK)
+		return nil
+	}
+}
+
+func createSockHandler(sc sockCreator) httpkit.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		var sock api.Sock
+		if err := httpkit.DecodeJSON(w, r, &sock); err != nil {
+			return err
+		}
+
+		id, err := sc.CreateSock(r.Context(), sock)
+
+		if err != nil {
+			return err
+		}
+
+		httpkit.RespondJSON(w, api.CreateResponse{ID: id}, http.StatusCreated)
+		return nil
+	}
+}
+
+func updateSockHandler(su sockUpdater) httpkit.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		var sock api.Sock
+		if err := httpkit.DecodeJSON(w, r, &sock); err != nil {
+			return err
+		}
+
+		updated, err := su.UpdateSock(r.Context(), chi.URLParam(r, "id"), sock)
+		if err != nil {
+			return err
+		}
+
+		httpkit.RespondJSON(w, updated, http.StatusOK)
+		return nil
+	}
+}
+
+func patchSockHandler(sp sockPatcher) httpkit.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		var patch api.SockPatch
+		if err := httpkit.DecodeJSON(w, r, &patch); err != nil {
+			return err
+		}
+
+		updated, err := sp.PatchSock(r.Context(), chi.URLParam(r, "id"), patch)
+		if err != nil {
+			return err
+		}
+
+		httpkit.RespondJSON(w, updated, http.StatusOK)
 		return nil
 	}
 }
@@ -92,7 +174,7 @@ func tagsHandler(t tagsGetter) httpkit.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) error {
 		tags, err := t.Tags(r.Context())
 		if err != nil {
-			return &httpkit.Error{Code: http.StatusInternalServerError, Message: "failed to get tags", Err: err}
+			return err
 		}
 
 		httpkit.RespondJSON(w, api.TagsResponse{Tags: tags}, http.StatusOK)
@@ -100,20 +182,35 @@ func tagsHandler(t tagsGetter) httpkit.HandlerFunc {
 	}
 }
 
-func decodeListReq(r *http.Request) *api.ListSockParams {
+const maxPageSize = 100
+
+func decodeListReq(r *http.Request) (*api.ListSockParams, error) {
 	pageNum := 1
 	if page := r.FormValue("page"); page != "" {
-		pageNum, _ = strconv.Atoi(page)
+		n, err := strconv.Atoi(page)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid page %q", page)
+		}
+		pageNum = n
 	}
 
 	pageSize := 10
 	if size := r.FormValue("size"); size != "" {
-		pageSize, _ = strconv.Atoi(size)
+		n, err := strconv.Atoi(size)
+		if err != nil || n < 1 || n > maxPageSize {
+			return nil, fmt.Errorf("invalid size %q: must be between 1 and %d", size, maxPageSize)
+		}
+		pageSize = n
+	}
+
+	sort := "id"
+	if s := r.FormValue("sort"); s != "" {
+		sort = strings.ToLower(s)
 	}
 
-	order := "id"
-	if sort := r.FormValue("sort"); sort != "" {
-		order = strings.ToLower(sort)
+	order := "asc"
+	if o := r.FormValue("order"); o != "" {
+		order = strings.ToLower(o)
 	}
 
 	var tags []string
@@ -121,10 +218,49 @@ func decodeListReq(r *http.Request) *api.ListSockParams {
 		tags = strings.Split(tagsval, ",")
 	}
 
+	minPrice, err := parsePrice(r.FormValue("minPrice"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid minPrice: %w", err)
+	}
+
+	maxPrice, err := parsePrice(r.FormValue("maxPrice"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid maxPrice: %w", err)
+	}
+
+	var inStock bool
+	if v := r.FormValue("inStock"); v != "" {
+		inStock, err = strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid inStock: %w", err)
+		}
+	}
+
 	return &api.ListSockParams{
 		Tags:     tags,
+		TagMode:  strings.ToLower(r.FormValue("tagMode")),
+		MinPrice: minPrice,
+		MaxPrice: maxPrice,
+		InStock:  inStock,
+		Sort:     sort,
 		Order:    order,
 		PageNum:  pageNum,
 		PageSize: pageSize,
+		Cursor:   r.FormValue("cursor"),
+	}, nil
+}
+
+func parsePrice(v string) (*float64, error) {
+	if v == "" {
+		return nil, nil
+	}
+
+	p, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return nil, err
+	}
+	if math.IsNaN(p) || math.IsInf(p, 0) {
+		return nil, fmt.Errorf("%q is not a finite number", v)
 	}
+	return &p, nil
 }
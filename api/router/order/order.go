@@ -0,0 +1,34 @@
+package order
+
+import (
+	"net/http"
+
+	"github.com/oshankkumar/sockshop/api"
+	"github.com/oshankkumar/sockshop/api/httpkit"
+	"github.com/oshankkumar/sockshop/api/router"
+	"github.com/oshankkumar/sockshop/internal/domain"
+)
+
+var (
+	authRequired = httpkit.AuthPolicy{Required: true}
+	ownerOnly    = httpkit.AuthPolicy{Required: true, OwnerParam: "id"}
+)
+
+func NewRouter(svc api.OrderService) *Router {
+	return &Router{orderService: svc}
+}
+
+type Router struct {
+	orderService api.OrderService
+}
+
+func (o *Router) Routes() []router.Route {
+	return []router.Route{
+		{Method: http.MethodPost, Pattern: "/orders", Handler: createOrderHandler(o.orderService), Auth: authRequired},
+		{Method: http.MethodGet, Pattern: "/orders", Handler: listOrdersHandler(o.orderService), Permission: domain.PermOrdersManage},
+		{Method: http.MethodGet, Pattern: "/orders/{id}", Handler: getOrderHandler(o.orderService), Auth: authRequired},
+		{Method: http.MethodPost, Pattern: "/orders/{id}/payment", Handler: payOrderHandler(o.orderService, o.orderService), Auth: authRequired},
+		{Method: http.MethodPost, Pattern: "/orders/{id}/status", Handler: updateOrderStatusHandler(o.orderService), Permission: domain.PermOrdersManage},
+		{Method: http.MethodGet, Pattern: "/customers/{id}/orders", Handler: getCustomerOrdersHandler(o.orderService), Auth: ownerOnly},
+	}
+}
@@ -0,0 +1,168 @@
+package order
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/oshankkumar/sockshop/api"
+	"github.com/oshankkumar/sockshop/api/httpkit"
+	"github.com/oshankkumar/sockshop/internal/domain"
+)
+
+const maxPageSize = 100
+
+type orderCreator interface {
+	CreateOrder(ctx context.Context, req api.CreateOrderRequest) (*api.Order, error)
+}
+
+type orderGetter interface {
+	GetOrder(ctx context.Context, id string) (*api.Order, error)
+}
+
+type orderPayer interface {
+	PayOrder(ctx context.Context, id string) (*api.Order, error)
+}
+
+type orderStatusUpdater interface {
+	UpdateOrderStatus(ctx context.Context, id string, status string) (*api.Order, error)
+}
+
+type ordersLister interface {
+	ListOrders(ctx context.Context, req *api.ListOrdersParams) (*api.ListOrdersResponse, error)
+}
+
+type customerOrdersGetter interface {
+	GetCustomerOrders(ctx context.Context, customerID string) ([]api.Order, error)
+}
+
+func createOrderHandler(oc orderCreator) httpkit.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		var req api.CreateOrderRequest
+		if err := httpkit.DecodeJSON(w, r, &req); err != nil {
+			return err
+		}
+
+		if err := checkOwner(r, req.Customer.String()); err != nil {
+			return err
+		}
+
+		order, err := oc.CreateOrder(r.Context(), req)
+
+		if err != nil {
+			return err
+		}
+
+		httpkit.RespondJSON(w, order, http.StatusCreated)
+		return nil
+	}
+}
+
+func getOrderHandler(og orderGetter) httpkit.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		order, err := getOwnOrder(r, og)
+		if err != nil {
+			return err
+		}
+
+		httpkit.RespondJSON(w, order, http.StatusOK)
+		return nil
+	}
+}
+
+// getOwnOrder returns the order of the URL, answering 404 for the orders of
+// other customers so that their existence is not disclosed. Order managers
+// can get any order.
+func getOwnOrder(r *http.Request, og orderGetter) (*api.Order, error) {
+	order, err := og.GetOrder(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		return nil, err
+	}
+
+	p, _ := httpkit.PrincipalFrom(r.Context())
+	if !p.Can(domain.PermOrdersManage) && checkOwner(r, order.CustomerID.String()) != nil {
+		return nil, domain.ErrNotFound
+	}
+
+	return order, nil
+}
+
+func checkOwner(r *http.Request, customerID string) error {
+	p, ok := httpkit.PrincipalFrom(r.Context())
+	if !ok || p.Subject != customerID {
+		return fmt.Errorf("%w: order of another customer", api.ErrForbidden)
+	}
+	return nil
+}
+
+func payOrderHandler(og orderGetter, op orderPayer) httpkit.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if _, err := getOwnOrder(r, og); err != nil {
+			return err
+		}
+
+		order, err := op.PayOrder(r.Context(), chi.URLParam(r, "id"))
+
+		if err != nil {
+			return err
+		}
+
+		httpkit.RespondJSON(w, order, http.StatusOK)
+		return nil
+	}
+}
+
+func updateOrderStatusHandler(ou orderStatusUpdater) httpkit.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		var req api.UpdateOrderStatusRequest
+		if err := httpkit.DecodeJSON(w, r, &req); err != nil {
+			return err
+		}
+
+		order, err := ou.UpdateOrderStatus(r.Context(), chi.URLParam(r, "id"), req.Status)
+
+		if err != nil {
+			return err
+		}
+
+		httpkit.RespondJSON(w, order, http.StatusOK)
+		return nil
+	}
+}
+
+func listOrdersHandler(ol ordersLister) httpkit.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		pageSize := 10
+		if size := r.FormValue("size"); size != "" {
+			n, err := strconv.Atoi(size)
+			if err != nil || n < 1 || n > maxPageSize {
+				return &httpkit.Error{Code: http.StatusBadRequest, Message: "invalid page size", Err: err}
+			}
+			pageSize = n
+		}
+
+		resp, err := ol.ListOrders(r.Context(), &api.ListOrdersParams{PageSize: pageSize, Cursor: r.FormValue("cursor")})
+
+		if err != nil {
+			return err
+		}
+
+		httpkit.RespondJSON(w, resp, http.StatusOK)
+		return nil
+	}
+}
+
+func getCustomerOrdersHandler(og customerOrdersGetter) httpkit.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		orders, err := og.GetCustomerOrders(r.Context(), chi.URLParam(r, "id"))
+		if err != nil {
+			return err
+		}
+
+		httpkit.RespondJSON(w, api.CustomerOrdersResponse{Orders: orders}, http.StatusOK)
+		return nil
+	}
+}
@@ -6,6 +6,9 @@ type Route struct {
 	Method  string
 	Pattern string
 	Handler httpkit.Handler
+	Auth    httpkit.AuthPolicy
+	// Permission, when set, restricts the route to the principals granted it.
+	Permission string
 }
 
 type Router interface {
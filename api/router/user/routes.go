@@ -2,20 +2,35 @@ package user
 
 import (
 	"context"
-	"encoding/json"
-	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	"go.uber.org/zap"
 
 	"github.com/oshankkumar/sockshop/api"
 	"github.com/oshankkumar/sockshop/api/httpkit"
-	"github.com/oshankkumar/sockshop/internal/domain"
 )
 
 type loginService interface {
-	Login(ctx context.Context, username, password string) (*api.User, error)
+	Login(ctx context.Context, username, password, clientIP string) (*api.User, error)
+}
+
+type cartMerger interface {
+	MergeCarts(ctx context.Context, customerID, sessionID string) error
+}
+
+type tokenIssuer interface {
+	IssueTokens(ctx context.Context, customerID string) (*api.TokenResponse, error)
+}
+
+type tokenRefresher interface {
+	RefreshTokens(ctx context.Context, refreshToken string) (*api.TokenResponse, error)
+}
+
+type sessionRevoker interface {
+	Logout(ctx context.Context, sessionID string) error
 }
 
 type userRegisterationService interface {
@@ -26,8 +41,12 @@ type userGetter interface {
 	GetUser(ctx context.Context, id string) (*api.User, error)
 }
 
+type usersLister interface {
+	ListUsers(ctx context.Context, req *api.ListUsersParams) (*api.ListUsersResponse, error)
+}
+
 type cardGetter interface {
-	GetCard(ctx context.Context, id string) (*api.Card, error)
+	GetCard(ctx context.Context, userID, id string) (*api.Card, error)
 }
 
 type userCardsGetter interface {
@@ -39,7 +58,7 @@ type userAddressesGetter interface {
 }
 
 type addressGetter interface {
-	GetAddresses(ctx context.Context, id string) (*api.Address, error)
+	GetAddresses(ctx context.Context, userID, id string) (*api.Address, error)
 }
 
 type cardCreator interface {
@@ -50,7 +69,72 @@ type addressCreator interface {
 	CreateAddress(ctx context.Context, addr api.Address, userID string) (uuid.UUID, error)
 }
 
-func loginHandler(l loginService) httpkit.HandlerFunc {
+type userDeleter interface {
+	DeleteUser(ctx context.Context, id string, erase bool) error
+}
+
+type addressDeleter interface {
+	DeleteAddress(ctx context.Context, userID, id string) error
+}
+
+type cardDeleter interface {
+	DeleteCard(ctx context.Context, userID, id string) error
+}
+
+type userPatcher interface {
+	PatchUser(ctx context.Context, id string, patch api.UserPatch, version int) (*api.User, error)
+}
+
+type addressUpdater interface {
+	UpdateAddress(ctx context.Context, userID, id string, addr api.Address, version int) (*api.Address, error)
+}
+
+type cardUpdater interface {
+	UpdateCard(ctx context.Context, userID, id string, patch api.CardPatch, version int) (*api.Card, error)
+}
+
+type passwordChanger interface {
+	ChangePassword(ctx context.Context, id, sessionID, current, newPassword string) error
+}
+
+type mfaStarter interface {
+	StartMFA(ctx context.Context, customerID string) (*api.MFAChallenge, error)
+}
+
+type mfaCompleter interface {
+	CompleteMFA(ctx context.Context, mfaToken, code, clientIP string) (*api.User, error)
+}
+
+type totpEnroller interface {
+	EnrollTOTP(ctx context.Context, customerID string) (*api.TOTPEnrolment, error)
+	ConfirmTOTP(ctx context.Context, customerID, code string) error
+}
+
+type passwordResetter interface {
+	RequestPasswordReset(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, token, newPassword string) error
+}
+
+type verificationSender interface {
+	SendVerification(ctx context.Context, customerID string) error
+}
+
+type emailVerifier interface {
+	VerifyEmail(ctx context.Context, token string) error
+}
+
+type oidcLoginStarter interface {
+	StartLogin(ctx context.Context, provider string) (string, error)
+}
+
+type oidcLoginCompleter interface {
+	CompleteLogin(ctx context.Context, provider, state, code string) (*api.User, error)
+}
+
+// loginHandler logs the customer in with its password. Customers with a
+// second factor get a challenge instead of tokens, completed by
+// mfaLoginHandler.
+func loginHandler(l loginService, ms mfaStarter, cm cartMerger, ti tokenIssuer, log *zap.Logger) httpkit.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) error {
 		username, pass, ok := r.BasicAuth()
 		if !ok {
@@ -58,37 +142,157 @@ func loginHandler(l loginService) httpkit.HandlerFunc {
 
 		}
 
-		user, err := l.Login(r.Context(), username, pass)
+		user, err := l.Login(r.Context(), username, pass, httpkit.ClientIP(r))
 
-		switch {
-		case errors.Is(err, api.ErrUnauthorized):
-			return &httpkit.Error{Code: http.StatusUnauthorized, Message: "user not authorised", Err: err}
-		case errors.Is(err, domain.ErrNotFound):
-			return &httpkit.Error{Code: http.StatusNotFound, Message: "user not found", Err: err}
-		case err != nil:
-			return &httpkit.Error{Code: http.StatusInternalServerError, Message: "user login failed", Err: err}
+		if err != nil {
+			return err
 		}
 
-		httpkit.RespondJSON(w, user, http.StatusOK)
+		challenge, err := ms.StartMFA(r.Context(), user.ID.String())
+		if err != nil {
+			return err
+		}
+
+		if challenge != nil {
+			httpkit.RespondJSON(w, challenge, http.StatusOK)
+			return nil
+		}
+
+		return respondLogin(w, r, user, cm, ti, log)
+	}
+}
+
+func mfaLoginHandler(mc mfaCompleter, cm cartMerger, ti tokenIssuer, log *zap.Logger) httpkit.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		var req api.MFALoginRequest
+		if err := httpkit.DecodeJSON(w, r, &req); err != nil {
+			return err
+		}
+
+		user, err := mc.CompleteMFA(r.Context(), req.MFAToken, req.Code, httpkit.ClientIP(r))
+		if err != nil {
+			return err
+		}
+
+		return respondLogin(w, r, user, cm, ti, log)
+	}
+}
+
+// oidcLoginHandler redirects the customer to the identity provider, which
+// redirects it back to oidcCallbackHandler.
+func oidcLoginHandler(ls oidcLoginStarter) httpkit.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		authURL, err := ls.StartLogin(r.Context(), chi.URLParam(r, "provider"))
+		if err != nil {
+			return err
+		}
+
+		http.Redirect(w, r, authURL, http.StatusFound)
+		return nil
+	}
+}
+
+// oidcCallbackHandler logs in the customer the identity provider redirected
+// back. Like loginHandler, customers with a second factor get a challenge.
+func oidcCallbackHandler(lc oidcLoginCompleter, ms mfaStarter, cm cartMerger, ti tokenIssuer, log *zap.Logger) httpkit.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if e := r.FormValue("error"); e != "" {
+			return &httpkit.Error{Code: http.StatusUnauthorized, Message: "identity provider login failed: " + e, Err: api.ErrUnauthorized}
+		}
+
+		user, err := lc.CompleteLogin(r.Context(), chi.URLParam(r, "provider"), r.FormValue("state"), r.FormValue("code"))
+		if err != nil {
+			return err
+		}
+
+		challenge, err := ms.StartMFA(r.Context(), user.ID.String())
+		if err != nil {
+			return err
+		}
+
+		if challenge != nil {
+			httpkit.RespondJSON(w, challenge, http.StatusOK)
+			return nil
+		}
+
+		return respondLogin(w, r, user, cm, ti, log)
+	}
+}
+
+// respondLogin merges the session cart into the cart of the logged in user
+// and responds with its tokens. The login succeeds even if the cart cannot be
+// merged, which leaves the session cart as it was.
+func respondLogin(w http.ResponseWriter, r *http.Request, user *api.User, cm cartMerger, ti tokenIssuer, log *zap.Logger) error {
+	if err := cm.MergeCarts(r.Context(), user.ID.String(), r.FormValue("sessionId")); err != nil {
+		log.Warn("merging session cart failed",
+			zap.String("customer_id", user.ID.String()),
+			zap.String("request_id", httpkit.RequestIDFrom(r.Context())),
+			zap.Error(err),
+		)
+	}
+
+	tokens, err := ti.IssueTokens(r.Context(), user.ID.String())
+	if err != nil {
+		return err
+	}
+
+	httpkit.RespondJSON(w, api.LoginResponse{User: *user, TokenResponse: *tokens}, http.StatusOK)
+	return nil
+}
+
+func logoutHandler(sr sessionRevoker) httpkit.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		p, _ := httpkit.PrincipalFrom(r.Context())
+
+		if err := sr.Logout(r.Context(), p.SessionID); err != nil {
+			return err
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+}
+
+func refreshTokenHandler(tr tokenRefresher) httpkit.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		var req api.RefreshTokenRequest
+		if err := httpkit.DecodeJSON(w, r, &req); err != nil {
+			return err
+		}
+
+		tokens, err := tr.RefreshTokens(r.Context(), req.RefreshToken)
+
+		if err != nil {
+			return err
+		}
+
+		httpkit.RespondJSON(w, tokens, http.StatusOK)
 		return nil
 	}
 }
 
-func registerUserHandler(ur userRegisterationService) httpkit.HandlerFunc {
+// registerUserHandler responds 201 even if the verification email cannot be
+// sent, since the customer is already registered and the email can be resent.
+func registerUserHandler(ur userRegisterationService, vs verificationSender, log *zap.Logger) httpkit.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) error {
 		var user api.User
 
-		if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
-			return &httpkit.Error{Code: http.StatusBadRequest, Message: "json unmarshal failed", Err: err}
+		if err := httpkit.DecodeJSON(w, r, &user); err != nil {
+			return err
 		}
 
 		id, err := ur.Register(r.Context(), user)
 
-		switch {
-		case errors.As(err, &domain.DuplicateEntryError{}):
-			return &httpkit.Error{Code: http.StatusConflict, Message: "username or email already exists", Err: err}
-		case err != nil:
-			return &httpkit.Error{Code: http.StatusInternalServerError, Message: "user registeration failed", Err: err}
+		if err != nil {
+			return err
+		}
+
+		if err := vs.SendVerification(r.Context(), id.String()); err != nil {
+			log.Warn("sending verification email failed",
+				zap.String("customer_id", id.String()),
+				zap.String("request_id", httpkit.RequestIDFrom(r.Context())),
+				zap.Error(err),
+			)
 		}
 
 		httpkit.RespondJSON(w, api.CreateResponse{ID: id}, http.StatusCreated)
@@ -105,18 +309,40 @@ func getUserHandler(us userGetter) httpkit.HandlerFunc {
 
 		user, err := us.GetUser(r.Context(), userID)
 
-		switch {
-		case errors.Is(err, domain.ErrNotFound):
-			return &httpkit.Error{Code: http.StatusNotFound, Message: "user not found", Err: err}
-		case err != nil:
-			return &httpkit.Error{Code: http.StatusInternalServerError, Message: "get user details failed", Err: err}
+		if err != nil {
+			return err
 		}
 
+		w.Header().Set("ETag", httpkit.ETag(user.Version))
 		httpkit.RespondJSON(w, user, http.StatusOK)
 		return nil
 	}
 }
 
+const maxPageSize = 100
+
+func listUsersHandler(ul usersLister) httpkit.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		pageSize := 10
+		if size := r.FormValue("size"); size != "" {
+			n, err := strconv.Atoi(size)
+			if err != nil || n < 1 || n > maxPageSize {
+				return &httpkit.Error{Code: http.StatusBadRequest, Message: "invalid page size", Err: err}
+			}
+			pageSize = n
+		}
+
+		resp, err := ul.ListUsers(r.Context(), &api.ListUsersParams{PageSize: pageSize, Cursor: r.FormValue("cursor")})
+
+		if err != nil {
+			return err
+		}
+
+		httpkit.RespondJSON(w, resp, http.StatusOK)
+		return nil
+	}
+}
+
 func getCardHandler(cg cardGetter) httpkit.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) error {
 		cardID := chi.URLParam(r, "id")
@@ -124,15 +350,15 @@ func getCardHandler(cg cardGetter) httpkit.HandlerFunc {
 			return &httpkit.Error{Code: http.StatusNotFound, Message: "card does not exist", Err: api.ErrNotFound}
 		}
 
-		card, err := cg.GetCard(r.Context(), cardID)
+		p, _ := httpkit.PrincipalFrom(r.Context())
+
+		card, err := cg.GetCard(r.Context(), p.Subject, cardID)
 
-		switch {
-		case errors.Is(err, domain.ErrNotFound):
-			return &httpkit.Error{Code: http.StatusNotFound, Message: "card not found", Err: err}
-		case err != nil:
-			return &httpkit.Error{Code: http.StatusInternalServerError, Message: "get card details failed", Err: err}
+		if err != nil {
+			return err
 		}
 
+		w.Header().Set("ETag", httpkit.ETag(card.Version))
 		httpkit.RespondJSON(w, card, http.StatusOK)
 		return nil
 	}
@@ -147,11 +373,8 @@ func getUserCardsHandler(cg userCardsGetter) httpkit.HandlerFunc {
 
 		cards, err := cg.GetUserCards(r.Context(), userID)
 
-		switch {
-		case errors.Is(err, domain.ErrNotFound):
-			return &httpkit.Error{Code: http.StatusNotFound, Message: "card not found", Err: err}
-		case err != nil:
-			return &httpkit.Error{Code: http.StatusInternalServerError, Message: "get card details failed", Err: err}
+		if err != nil {
+			return err
 		}
 
 		httpkit.RespondJSON(w, api.UserCardsResponse{Cards: cards}, http.StatusOK)
@@ -166,15 +389,15 @@ func getAddressHandler(ag addressGetter) httpkit.HandlerFunc {
 			return &httpkit.Error{Code: http.StatusNotFound, Message: "address does not exist", Err: api.ErrNotFound}
 		}
 
-		addr, err := ag.GetAddresses(r.Context(), addrID)
+		p, _ := httpkit.PrincipalFrom(r.Context())
+
+		addr, err := ag.GetAddresses(r.Context(), p.Subject, addrID)
 
-		switch {
-		case errors.Is(err, domain.ErrNotFound):
-			return &httpkit.Error{Code: http.StatusNotFound, Message: "address not found", Err: err}
-		case err != nil:
-			return &httpkit.Error{Code: http.StatusInternalServerError, Message: "get address failed", Err: err}
+		if err != nil {
+			return err
 		}
 
+		w.Header().Set("ETag", httpkit.ETag(addr.Version))
 		httpkit.RespondJSON(w, addr, http.StatusOK)
 		return nil
 	}
@@ -189,11 +412,8 @@ func getUserAddressesHandler(ag userAddressesGetter) httpkit.HandlerFunc {
 
 		addrs, err := ag.GetUserAddresses(r.Context(), userID)
 
-		switch {
-		case errors.Is(err, domain.ErrNotFound):
-			return &httpkit.Error{Code: http.StatusNotFound, Message: "address not found", Err: err}
-		case err != nil:
-			return &httpkit.Error{Code: http.StatusInternalServerError, Message: "get address failed", Err: err}
+		if err != nil {
+			return err
 		}
 
 		httpkit.RespondJSON(w, api.UserAdressesResponse{Addresses: addrs}, http.StatusOK)
@@ -209,17 +429,14 @@ func createCardHandler(cc cardCreator) httpkit.HandlerFunc {
 		}
 
 		var card api.Card
-		if err := json.NewDecoder(r.Body).Decode(&card); err != nil {
-			return &httpkit.Error{Code: http.StatusBadRequest, Message: "invalid json body in request", Err: err}
+		if err := httpkit.DecodeJSON(w, r, &card); err != nil {
+			return err
 		}
 
 		id, err := cc.CreateCard(r.Context(), card, userID)
 
-		switch {
-		case errors.As(err, &domain.DuplicateEntryError{}):
-			return &httpkit.Error{Code: http.StatusConflict, Message: "card already registered", Err: err}
-		case err != nil:
-			return &httpkit.Error{Code: http.StatusInternalServerError, Message: "adding card failed", Err: err}
+		if err != nil {
+			return err
 		}
 
 		httpkit.RespondJSON(w, api.CreateResponse{ID: id}, http.StatusOK)
@@ -235,16 +452,261 @@ func createAddressHandler(ac addressCreator) httpkit.HandlerFunc {
 		}
 
 		var addr api.Address
-		if err := json.NewDecoder(r.Body).Decode(&addr); err != nil {
-			return &httpkit.Error{Code: http.StatusBadRequest, Message: "json unmarshal failed", Err: err}
+		if err := httpkit.DecodeJSON(w, r, &addr); err != nil {
+			return err
 		}
 
 		id, err := ac.CreateAddress(r.Context(), addr, userID)
+
 		if err != nil {
-			return &httpkit.Error{Code: http.StatusInternalServerError, Message: "address creation failed", Err: err}
+			return err
 		}
 
 		httpkit.RespondJSON(w, api.CreateResponse{ID: id}, http.StatusOK)
 		return nil
 	}
 }
+
+// deleteUserHandler deletes the customer. With ?erase=true the customer is
+// anonymised instead, which also works for customers with orders.
+func deleteUserHandler(ud userDeleter) httpkit.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		var erase bool
+		if v := r.FormValue("erase"); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return &httpkit.Error{Code: http.StatusBadRequest, Message: "invalid erase parameter", Err: err}
+			}
+			erase = b
+		}
+
+		err := ud.DeleteUser(r.Context(), chi.URLParam(r, "id"), erase)
+
+		if err != nil {
+			return err
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+}
+
+func deleteAddressHandler(ad addressDeleter) httpkit.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		p, _ := httpkit.PrincipalFrom(r.Context())
+
+		err := ad.DeleteAddress(r.Context(), p.Subject, chi.URLParam(r, "id"))
+
+		if err != nil {
+			return err
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+}
+
+func deleteCardHandler(cd cardDeleter) httpkit.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		p, _ := httpkit.PrincipalFrom(r.Context())
+
+		err := cd.DeleteCard(r.Context(), p.Subject, chi.URLParam(r, "id"))
+
+		if err != nil {
+			return err
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+}
+
+// patchUserHandler updates the customer's names and email. The request must
+// carry the ETag of the customer in its If-Match header.
+func patchUserHandler(up userPatcher) httpkit.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		version, err := httpkit.IfMatch(r)
+		if err != nil {
+			return err
+		}
+
+		var patch api.UserPatch
+		if err := httpkit.DecodeJSON(w, r, &patch); err != nil {
+			return err
+		}
+
+		user, err := up.PatchUser(r.Context(), chi.URLParam(r, "id"), patch, version)
+
+		if err != nil {
+			return err
+		}
+
+		w.Header().Set("ETag", httpkit.ETag(user.Version))
+		httpkit.RespondJSON(w, user, http.StatusOK)
+		return nil
+	}
+}
+
+func updateAddressHandler(au addressUpdater) httpkit.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		version, err := httpkit.IfMatch(r)
+		if err != nil {
+			return err
+		}
+
+		var addr api.Address
+		if err := httpkit.DecodeJSON(w, r, &addr); err != nil {
+			return err
+		}
+
+		p, _ := httpkit.PrincipalFrom(r.Context())
+
+		updated, err := au.UpdateAddress(r.Context(), p.Subject, chi.URLParam(r, "id"), addr, version)
+
+		if err != nil {
+			return err
+		}
+
+		w.Header().Set("ETag", httpkit.ETag(updated.Version))
+		httpkit.RespondJSON(w, updated, http.StatusOK)
+		return nil
+	}
+}
+
+func updateCardHandler(cu cardUpdater) httpkit.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		version, err := httpkit.IfMatch(r)
+		if err != nil {
+			return err
+		}
+
+		var patch api.CardPatch
+		if err := httpkit.DecodeJSON(w, r, &patch); err != nil {
+			return err
+		}
+
+		p, _ := httpkit.PrincipalFrom(r.Context())
+
+		card, err := cu.UpdateCard(r.Context(), p.Subject, chi.URLParam(r, "id"), patch, version)
+
+		if err != nil {
+			return err
+		}
+
+		w.Header().Set("ETag", httpkit.ETag(card.Version))
+		httpkit.RespondJSON(w, card, http.StatusOK)
+		return nil
+	}
+}
+
+func changePasswordHandler(pc passwordChanger) httpkit.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		var req api.ChangePasswordRequest
+		if err := httpkit.DecodeJSON(w, r, &req); err != nil {
+			return err
+		}
+
+		p, _ := httpkit.PrincipalFrom(r.Context())
+
+		err := pc.ChangePassword(r.Context(), chi.URLParam(r, "id"), p.SessionID, req.CurrentPassword, req.NewPassword)
+
+		if err != nil {
+			return err
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+}
+
+func enrollTOTPHandler(te totpEnroller) httpkit.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		enrolment, err := te.EnrollTOTP(r.Context(), chi.URLParam(r, "id"))
+
+		if err != nil {
+			return err
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		httpkit.RespondJSON(w, enrolment, http.StatusCreated)
+		return nil
+	}
+}
+
+func confirmTOTPHandler(te totpEnroller) httpkit.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		var req api.MFACodeRequest
+		if err := httpkit.DecodeJSON(w, r, &req); err != nil {
+			return err
+		}
+
+		err := te.ConfirmTOTP(r.Context(), chi.URLParam(r, "id"), req.Code)
+
+		if err != nil {
+			return err
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+}
+
+// passwordResetRequestHandler always accepts the request, so that the
+// response does not tell whether the email is registered.
+func passwordResetRequestHandler(pr passwordResetter) httpkit.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		var req api.PasswordResetRequest
+		if err := httpkit.DecodeJSON(w, r, &req); err != nil {
+			return err
+		}
+
+		if err := pr.RequestPasswordReset(r.Context(), req.Email); err != nil {
+			return err
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+		return nil
+	}
+}
+
+func passwordResetConfirmHandler(pr passwordResetter) httpkit.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		var req api.PasswordResetConfirmRequest
+		if err := httpkit.DecodeJSON(w, r, &req); err != nil {
+			return err
+		}
+
+		err := pr.ResetPassword(r.Context(), req.Token, req.NewPassword)
+
+		if err != nil {
+			return err
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+}
+
+func sendVerificationHandler(vs verificationSender) httpkit.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if err := vs.SendVerification(r.Context(), chi.URLParam(r, "id")); err != nil {
+			return err
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+		return nil
+	}
+}
+
+func verifyEmailHandler(ev emailVerifier) httpkit.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		err := ev.VerifyEmail(r.Context(), r.FormValue("token"))
+
+		if err != nil {
+			return err
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+}
@@ -3,28 +3,61 @@ package user
 import (
 	"net/http"
 
+	"go.uber.org/zap"
+
 	"github.com/oshankkumar/sockshop/api"
+	"github.com/oshankkumar/sockshop/api/httpkit"
 	"github.com/oshankkumar/sockshop/api/router"
+	"github.com/oshankkumar/sockshop/internal/domain"
+)
+
+var (
+	authRequired = httpkit.AuthPolicy{Required: true}
+	ownerOnly    = httpkit.AuthPolicy{Required: true, OwnerParam: "id"}
 )
 
-func NewRouter(svc api.UserService) *Router {
-	return &Router{userService: svc}
+func NewRouter(svc api.UserService, carts api.CartService, auth api.AuthService, accounts api.AccountService, oidc api.OIDCService, log *zap.Logger) *Router {
+	return &Router{userService: svc, cartService: carts, authService: auth, accountService: accounts, oidcService: oidc, log: log}
 }
 
 type Router struct {
-	userService api.UserService
+	userService    api.UserService
+	cartService    api.CartService
+	authService    api.AuthService
+	accountService api.AccountService
+	oidcService    api.OIDCService
+	log            *zap.Logger
 }
 
 func (u *Router) Routes() []router.Route {
 	return []router.Route{
-		{Method: http.MethodPost, Pattern: "/login", Handler: loginHandler(u.userService)},
-		{Method: http.MethodPost, Pattern: "/customers", Handler: registerUserHandler(u.userService)},
-		{Method: http.MethodGet, Pattern: "/customers/{id}", Handler: getUserHandler(u.userService)},
-		{Method: http.MethodGet, Pattern: "/cards/{id}", Handler: getCardHandler(u.userService)},
-		{Method: http.MethodGet, Pattern: "/addresses/{id}", Handler: getAddressHandler(u.userService)},
-		{Method: http.MethodGet, Pattern: "/customers/{id}/cards", Handler: getUserCardsHandler(u.userService)},
-		{Method: http.MethodGet, Pattern: "/customers/{id}/addresses", Handler: getUserAddressesHandler(u.userService)},
-		{Method: http.MethodPost, Pattern: "/customers/{id}/cards", Handler: createCardHandler(u.userService)},
-		{Method: http.MethodPost, Pattern: "/customers/{id}/addresses", Handler: createAddressHandler(u.userService)},
+		{Method: http.MethodPost, Pattern: "/login", Handler: loginHandler(u.userService, u.userService, u.cartService, u.authService, u.log)},
+		{Method: http.MethodPost, Pattern: "/login/mfa", Handler: mfaLoginHandler(u.userService, u.cartService, u.authService, u.log)},
+		{Method: http.MethodGet, Pattern: "/login/oidc/{provider}", Handler: oidcLoginHandler(u.oidcService)},
+		{Method: http.MethodGet, Pattern: "/login/oidc/{provider}/callback", Handler: oidcCallbackHandler(u.oidcService, u.userService, u.cartService, u.authService, u.log)},
+		{Method: http.MethodPost, Pattern: "/logout", Handler: logoutHandler(u.authService), Auth: authRequired},
+		{Method: http.MethodPost, Pattern: "/token/refresh", Handler: refreshTokenHandler(u.authService)},
+		{Method: http.MethodPost, Pattern: "/customers", Handler: registerUserHandler(u.userService, u.accountService, u.log)},
+		{Method: http.MethodPost, Pattern: "/password-reset", Handler: passwordResetRequestHandler(u.accountService)},
+		{Method: http.MethodPost, Pattern: "/password-reset/confirm", Handler: passwordResetConfirmHandler(u.accountService)},
+		{Method: http.MethodGet, Pattern: "/verify-email", Handler: verifyEmailHandler(u.accountService)},
+		{Method: http.MethodGet, Pattern: "/customers", Handler: listUsersHandler(u.userService), Permission: domain.PermCustomersRead},
+		{Method: http.MethodGet, Pattern: "/customers/{id}", Handler: getUserHandler(u.userService), Auth: ownerOnly},
+		{Method: http.MethodPatch, Pattern: "/customers/{id}", Handler: patchUserHandler(u.userService), Auth: ownerOnly},
+		{Method: http.MethodDelete, Pattern: "/customers/{id}", Handler: deleteUserHandler(u.userService), Auth: ownerOnly},
+		{Method: http.MethodPost, Pattern: "/customers/{id}/password", Handler: changePasswordHandler(u.userService), Auth: ownerOnly},
+		{Method: http.MethodPost, Pattern: "/customers/{id}/mfa/totp", Handler: enrollTOTPHandler(u.userService), Auth: ownerOnly},
+		{Method: http.MethodPost, Pattern: "/customers/{id}/mfa/totp/confirm", Handler: confirmTOTPHandler(u.userService), Auth: ownerOnly},
+		{Method: http.MethodPost, Pattern: "/customers/{id}/verify-email", Handler: sendVerificationHandler(u.accountService), Auth: ownerOnly},
+		{Method: http.MethodGet, Pattern: "/cards/{id}", Handler: getCardHandler(u.userService), Auth: authRequired},
+		{Method: http.MethodPatch, Pattern: "/cards/{id}", Handler: updateCardHandler(u.userService), Auth: authRequired},
+		{Method: http.MethodDelete, Pattern: "/cards/{id}", Handler: deleteCardHandler(u.userService), Auth: authRequired},
+		{Method: http.MethodGet, Pattern: "/addresses/{id}", Handler: getAddressHandler(u.userService), Auth: authRequired},
+		{Method: http.MethodPut, Pattern: "/addresses/{id}", Handler: updateAddressHandler(u.userService), Auth: authRequired},
+		{Method: http.MethodDelete, Pattern: "/addresses/{id}", Handler: deleteAddressHandler(u.userService), Auth: authRequired},
+		{Method: http.MethodGet, Pattern: "/customers/{id}/cards", Handler: getUserCardsHandler(u.userService), Auth: ownerOnly},
+		{Method: http.MethodGet, Pattern: "/customers/{id}/addresses", Handler: getUserAddressesHandler(u.userService), Auth: ownerOnly},
+		{Method: http.MethodPost, Pattern: "/customers/{id}/cards", Handler: createCardHandler(u.userService), Auth: ownerOnly},
+		{Method: http.MethodPost, Pattern: "/customers/{id}/addresses", Handler: createAddressHandler(u.userService), Auth: ownerOnly},
 	}
 }
@@ -15,36 +15,36 @@ import (
 	"go.uber.org/zap"
 )
 
+// Worker is a background task run for the lifetime of the Server. Run must
+// return once ctx is done.
+type Worker interface {
+	Run(ctx context.Context)
+}
+
 type Server struct {
 	Addr          string
 	Logger        *zap.Logger
 	HealthChecker HealthChecker
+	Authenticator middleware.Authenticator
 	Router        router.Router
+	Workers       []Worker
+	// Problems maps the errors of the handlers to responses,
+	// NewProblemRegistry() when nil.
+	Problems *httpkit.ProblemRegistry
+	// RateLimiter limits the requests of the clients, none are limited
+	// when nil.
+	RateLimiter *middleware.RateLimiter
 
 	httpServer *http.Server
 	once       sync.Once
 	cancel     func()
+	workers    sync.WaitGroup
 }
 
 func (s *Server) Start(ctx context.Context) error {
 	ctx, s.cancel = context.WithCancel(ctx)
 
-	mux := chi.NewMux()
-	mux.Method(http.MethodGet, "/health", http.HandlerFunc(s.health))
-	mux.Method(http.MethodGet, "/metrics", promhttp.Handler())
-
-	middlewareFunc := httpkit.ChainMiddleware(
-		middleware.WithLog(s.Logger),
-		middleware.WithMetrics(),
-		middleware.WithHTTPErrStatus,
-	)
-
-	for _, rt := range s.Router.Routes() {
-		handler := middlewareFunc(rt.Method, rt.Pattern, rt.Handler)
-		mux.Method(rt.Method, rt.Pattern, httpkit.DiscardErr(handler))
-	}
-
-	s.httpServer = &http.Server{Addr: s.Addr, Handler: mux}
+	s.httpServer = &http.Server{Addr: s.Addr, Handler: s.Handler()}
 
 	go func() {
 		<-ctx.Done()
@@ -53,15 +53,65 @@ func (s *Server) Start(ctx context.Context) error {
 		}
 	}()
 
+	for _, w := range s.Workers {
+		s.workers.Add(1)
+		go func(w Worker) {
+			defer s.workers.Done()
+			w.Run(ctx)
+		}(w)
+	}
+
 	s.Logger.Info("starting api server", zap.String("addr", s.Addr))
 
-	if err := s.httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+	err := s.httpServer.ListenAndServe()
+
+	s.cancel()
+	s.workers.Wait()
+
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		return err
 	}
 
 	return nil
 }
 
+// Handler returns the handler serving the routes of the Router, each behind
+// the middleware chain. The metrics it registers can only be registered once
+// per process.
+func (s *Server) Handler() http.Handler {
+	if s.Problems == nil {
+		s.Problems = NewProblemRegistry()
+	}
+
+	mux := chi.NewMux()
+	mux.Method(http.MethodGet, "/health", http.HandlerFunc(s.health))
+	mux.Method(http.MethodGet, "/metrics", promhttp.Handler())
+	mux.NotFound(problemHandler(http.StatusNotFound))
+	mux.MethodNotAllowed(problemHandler(http.StatusMethodNotAllowed))
+
+	// The metrics are registered once, and shared by the routes.
+	metrics := middleware.WithMetrics()
+	ipRateLimit, clientRateLimit := middleware.WithRateLimit(s.RateLimiter)
+
+	for _, rt := range s.Router.Routes() {
+		middlewareFunc := httpkit.ChainMiddleware(
+			middleware.WithRequestID,
+			middleware.WithLog(s.Logger),
+			metrics,
+			middleware.WithProblemDetails(s.Problems),
+			ipRateLimit,
+			middleware.WithAuth(s.Authenticator, rt.Auth),
+			clientRateLimit,
+			middleware.WithPermission(rt.Permission),
+		)
+
+		handler := middlewareFunc(rt.Method, rt.Pattern, rt.Handler)
+		mux.Method(rt.Method, rt.Pattern, httpkit.DiscardErr(handler))
+	}
+
+	return mux
+}
+
 func (s *Server) Stop() error {
 	s.cancel()
 	var err error
@@ -75,9 +125,15 @@ func (s *Server) Stop() error {
 func (s *Server) health(w http.ResponseWriter, r *http.Request) {
 	hh, err := s.HealthChecker.CheckHealth(r.Context())
 	if err != nil {
-		httpkit.RespondError(w, &httpkit.Error{Code: http.StatusInternalServerError, Message: err.Error(), Err: err})
+		httpkit.RespondError(w, r, &httpkit.Error{Code: http.StatusInternalServerError, Message: err.Error(), Err: err})
 		return
 	}
 
 	httpkit.RespondJSON(w, HealthResponse{Healths: hh}, http.StatusOK)
 }
+
+func problemHandler(status int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		httpkit.RespondError(w, r, &httpkit.Error{Code: status})
+	}
+}
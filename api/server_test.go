@@ -0,0 +1,118 @@
+package api_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/oshankkumar/sockshop/api"
+	"github.com/oshankkumar/sockshop/api/httpkit"
+	"github.com/oshankkumar/sockshop/api/router"
+	"github.com/oshankkumar/sockshop/api/router/apikey"
+	"github.com/oshankkumar/sockshop/api/router/catalogue"
+	"github.com/oshankkumar/sockshop/api/router/order"
+	"github.com/oshankkumar/sockshop/api/router/user"
+	"github.com/oshankkumar/sockshop/internal/domain"
+)
+
+// roleAuthenticator grants the access token "customer" the permissions of a
+// customer, and "admin" those of an admin.
+type roleAuthenticator struct{}
+
+func (roleAuthenticator) Authenticate(_ context.Context, token string) (httpkit.Principal, error) {
+	switch token {
+	case "customer":
+		return httpkit.Principal{Subject: "alice", Permissions: domain.Permissions(nil)}, nil
+	case "admin":
+		return httpkit.Principal{Subject: "root", Permissions: domain.Permissions([]domain.Role{domain.RoleAdmin})}, nil
+	}
+	return httpkit.Principal{}, errors.New("invalid token")
+}
+
+func (roleAuthenticator) AuthenticateAPIKey(context.Context, string) (httpkit.Principal, error) {
+	return httpkit.Principal{}, errors.New("unknown API key")
+}
+
+// reachedRoutes returns the routes of rr, whose handlers only respond 200.
+func reachedRoutes(rr ...router.Router) router.Router {
+	return router.RouterFunc(func() []router.Route {
+		routes := router.ComposeRouters(rr...).Routes()
+		for i := range routes {
+			routes[i].Handler = httpkit.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+				w.WriteHeader(http.StatusOK)
+				return nil
+			})
+		}
+		return routes
+	})
+}
+
+func TestServerAdminRoutes(t *testing.T) {
+	srv := &api.Server{
+		Logger:        zap.NewNop(),
+		Authenticator: roleAuthenticator{},
+		Router: reachedRoutes(
+			catalogue.NewRouter(nil, nil),
+			order.NewRouter(nil),
+			user.NewRouter(nil, nil, nil, nil, nil, zap.NewNop()),
+			apikey.NewRouter(nil),
+		),
+	}
+	handler := srv.Handler()
+
+	adminRoutes := []struct{ method, path string }{
+		{http.MethodPost, "/catalogue"},
+		{http.MethodPut, "/catalogue/1"},
+		{http.MethodPatch, "/catalogue/1"},
+		{http.MethodGet, "/orders"},
+		{http.MethodPost, "/orders/1/status"},
+		{http.MethodGet, "/customers"},
+		{http.MethodPost, "/api-keys"},
+		{http.MethodGet, "/api-keys"},
+		{http.MethodPut, "/api-keys/1/scopes"},
+		{http.MethodDelete, "/api-keys/1"},
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{name: "anonymous", want: http.StatusUnauthorized},
+		{name: "customer", token: "customer", want: http.StatusForbidden},
+		{name: "admin", token: "admin", want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, rt := range adminRoutes {
+				r := httptest.NewRequest(rt.method, rt.path, nil)
+				if tt.token != "" {
+					r.Header.Set("Authorization", "Bearer "+tt.token)
+				}
+				w := httptest.NewRecorder()
+
+				handler.ServeHTTP(w, r)
+
+				if w.Code != tt.want {
+					t.Errorf("%s %s: got %d, want %d", rt.method, rt.path, w.Code, tt.want)
+				}
+			}
+		})
+	}
+
+	// The catalogue is open to the customers.
+	r := httptest.NewRequest(http.MethodGet, "/catalogue", nil)
+	r.Header.Set("Authorization", "Bearer customer")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /catalogue: got %d, want %d", w.Code, http.StatusOK)
+	}
+}
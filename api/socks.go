@@ -9,23 +9,81 @@ import (
 type (
 	Sock struct {
 		ID          uuid.UUID `json:"id"`
-		Name        string    `json:"name"`
-		Description string    `json:"description"`
+		Name        string    `json:"name" validate:"required,max=20"`
+		Description string    `json:"description" validate:"max=200"`
 		ImageURL    []string  `json:"imageUrl"`
-		Price       float64   `json:"price"`
-		Count       int       `json:"count"`
-		Tags        []string  `json:"tag"`
+		Price       float64   `json:"price" validate:"min=0"`
+		// Count is the stock on hand, reserved units included.
+		Count int `json:"count" validate:"min=0"`
+		// Available is the stock which can still be sold, net of
+		// reservations. It is read only and ignored in requests.
+		Available int      `json:"available"`
+		Tags      []string `json:"tag"`
+	}
+
+	// SockPatch is a partial update of a sock. Only the non nil fields are applied.
+	SockPatch struct {
+		Name        *string   `json:"name" validate:"max=20"`
+		Description *string   `json:"description" validate:"max=200"`
+		ImageURL    *[]string `json:"imageUrl"`
+		Price       *float64  `json:"price" validate:"min=0"`
+		Count       *int      `json:"count" validate:"min=0"`
+		Tags        *[]string `json:"tag"`
 	}
 
 	ListSockParams struct {
 		Tags     []string
+		TagMode  string
+		MinPrice *float64
+		MaxPrice *float64
+		InStock  bool
+		Sort     string
 		Order    string
 		PageNum  int
 		PageSize int
+		// Cursor is the next_cursor of a previous response. When set, the
+		// page continues right after that response and PageNum is ignored.
+		Cursor string
 	}
 
 	ListSockResponse struct {
-		Socks []Sock `json:"sock"`
+		Socks      []Sock     `json:"sock"`
+		Total      int        `json:"total"`
+		Page       int        `json:"page,omitempty"`
+		Size       int        `json:"size"`
+		NextCursor string     `json:"next_cursor,omitempty"`
+		Facets     SockFacets `json:"facets"`
+		Links      Links      `json:"_links"`
+	}
+
+	TagFacet struct {
+		Tag   string `json:"tag"`
+		Count int    `json:"count"`
+	}
+
+	PriceFacet struct {
+		Min   float64  `json:"min"`
+		Max   *float64 `json:"max,omitempty"`
+		Count int      `json:"count"`
+	}
+
+	SockFacets struct {
+		Tags   []TagFacet   `json:"tags"`
+		Prices []PriceFacet `json:"prices"`
+	}
+
+	SearchSockParams struct {
+		Query string
+		Limit int
+	}
+
+	SockHit struct {
+		Sock  Sock    `json:"sock"`
+		Score float64 `json:"score"`
+	}
+
+	SearchSockResponse struct {
+		Results []SockHit `json:"results"`
 	}
 
 	CountTagsResponse struct {
@@ -39,4 +97,9 @@ type (
 
 type CatalogueService interface {
 	ListSocks(ctx context.Context, req *ListSockParams) (*ListSockResponse, error)
+	SearchSocks(ctx context.Context, req *SearchSockParams) (*SearchSockResponse, error)
+	GetSock(ctx context.Context, id string) (*Sock, error)
+	CreateSock(ctx context.Context, sock Sock) (uuid.UUID, error)
+	UpdateSock(ctx context.Context, id string, sock Sock) (*Sock, error)
+	PatchSock(ctx context.Context, id string, patch SockPatch) (*Sock, error)
 }
@@ -7,13 +7,40 @@ import (
 )
 
 type User struct {
-	FirstName string    `json:"firstName"`
-	LastName  string    `json:"lastName"`
-	Username  string    `json:"username"`
-	Password  string    `json:"password,omitempty"`
-	Email     string    `json:"email"`
+	FirstName string    `json:"firstName" validate:"max=20"`
+	LastName  string    `json:"lastName" validate:"max=20"`
+	Username  string    `json:"username" validate:"required,max=20"`
+	Password  string    `json:"password,omitempty" validate:"required,min=8,max=72"`
+	Email     string    `json:"email" validate:"required,email,max=40"`
 	ID        uuid.UUID `json:"id"`
 	Links     Links     `json:"_links"`
+	// EmailVerified is read only, it is set by verifying the email.
+	EmailVerified bool `json:"emailVerified"`
+	// Version is the revision of the user, sent as the ETag header.
+	Version int `json:"-"`
+}
+
+// UserPatch is a partial update of a user profile. Only the non nil fields are applied.
+type UserPatch struct {
+	FirstName *string `json:"firstName" validate:"max=20"`
+	LastName  *string `json:"lastName" validate:"max=20"`
+	Email     *string `json:"email" validate:"email,max=40"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword" validate:"required"`
+	NewPassword     string `json:"newPassword" validate:"required,min=8,max=72"`
+}
+
+type ListUsersParams struct {
+	PageSize int
+	Cursor   string
+}
+
+type ListUsersResponse struct {
+	Customers  []User `json:"customer"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Links      Links  `json:"_links"`
 }
 
 type CreateResponse struct {
@@ -21,13 +48,64 @@ type CreateResponse struct {
 }
 
 type UserService interface {
-	Login(ctx context.Context, username, password string) (*User, error)
+	// Login fails with a LoginThrottledError while clientIP or the username
+	// must wait after failed logins.
+	Login(ctx context.Context, username, password, clientIP string) (*User, error)
 	Register(ctx context.Context, user User) (uuid.UUID, error)
 	GetUser(ctx context.Context, id string) (*User, error)
-	GetCard(ctx context.Context, id string) (*Card, error)
+	ListUsers(ctx context.Context, req *ListUsersParams) (*ListUsersResponse, error)
+	// GetCard and GetAddresses fail with domain.ErrNotFound unless the card
+	// or address belongs to the user.
+	GetCard(ctx context.Context, userID, id string) (*Card, error)
 	GetUserCards(ctx context.Context, userID string) ([]Card, error)
 	GetUserAddresses(ctx context.Context, userID string) ([]Address, error)
-	GetAddresses(ctx context.Context, id string) (*Address, error)
+	GetAddresses(ctx context.Context, userID, id string) (*Address, error)
 	CreateCard(ctx context.Context, card Card, userID string) (uuid.UUID, error)
 	CreateAddress(ctx context.Context, addr Address, userID string) (uuid.UUID, error)
+	// DeleteUser deletes the customer, or anonymises it when erase is set.
+	DeleteUser(ctx context.Context, id string, erase bool) error
+	// PatchUser, UpdateAddress and UpdateCard fail with
+	// domain.ErrVersionMismatch unless version is the current revision.
+	PatchUser(ctx context.Context, id string, patch UserPatch, version int) (*User, error)
+	UpdateAddress(ctx context.Context, userID, id string, addr Address, version int) (*Address, error)
+	UpdateCard(ctx context.Context, userID, id string, patch CardPatch, version int) (*Card, error)
+	// ChangePassword fails with ErrUnauthorized if current is not the
+	// user's password. It revokes the other sessions of the user than
+	// sessionID, the session the password is changed from.
+	ChangePassword(ctx context.Context, id, sessionID, current, newPassword string) error
+	DeleteAddress(ctx context.Context, userID, id string) error
+	DeleteCard(ctx context.Context, userID, id string) error
+	EnrollTOTP(ctx context.Context, customerID string) (*TOTPEnrolment, error)
+	ConfirmTOTP(ctx context.Context, customerID, code string) error
+	// StartMFA returns the challenge completing the login of the customer,
+	// or nil if it has no second factor.
+	StartMFA(ctx context.Context, customerID string) (*MFAChallenge, error)
+	// CompleteMFA fails with ErrUnauthorized on an unknown or expired
+	// challenge or a wrong code.
+	CompleteMFA(ctx context.Context, mfaToken, code, clientIP string) (*User, error)
+}
+
+// TOTPEnrolment is a TOTP secret to add to an authenticator app, and the
+// recovery codes logging in without it. They are only shown once.
+type TOTPEnrolment struct {
+	OtpauthURI    string   `json:"otpauthUri"`
+	Secret        string   `json:"secret"`
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+type MFACodeRequest struct {
+	Code string `json:"code" validate:"required,max=20"`
+}
+
+// MFAChallenge is the response of a login that needs a second factor. The
+// token and a code complete the login.
+type MFAChallenge struct {
+	MFARequired bool   `json:"mfaRequired"`
+	MFAToken    string `json:"mfaToken"`
+	ExpiresIn   int    `json:"expiresIn"`
+}
+
+type MFALoginRequest struct {
+	MFAToken string `json:"mfaToken" validate:"required"`
+	Code     string `json:"code" validate:"required,max=20"`
 }
@@ -0,0 +1,59 @@
+// Command oidcmock serves a mock OpenID Connect provider over HTTP, for
+// pointing the sockshop OIDC login at during local runs. It logs in the
+// configured user without any prompt.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/oshankkumar/sockshop/internal/app"
+	"github.com/oshankkumar/sockshop/internal/oidc"
+)
+
+func main() {
+	var (
+		addr                   string
+		clientID, clientSecret string
+		user                   app.OIDCClaims
+	)
+
+	flag.StringVar(&addr, "addr", ":9292", "Listen address, whose host is the issuer")
+	flag.StringVar(&clientID, "client-id", "sockshop", "Client ID of the shop")
+	flag.StringVar(&clientSecret, "client-secret", "secret", "Client secret of the shop")
+	flag.StringVar(&user.Subject, "subject", "mock-user-1", "Subject of the logged in user")
+	flag.StringVar(&user.Email, "email", "mock.user@example.com", "Email of the logged in user")
+	flag.BoolVar(&user.EmailVerified, "email-verified", true, "Whether the email of the user is verified")
+	flag.StringVar(&user.PreferredUsername, "username", "mockuser", "Preferred username of the logged in user")
+	flag.StringVar(&user.GivenName, "given-name", "Mock", "Given name of the logged in user")
+	flag.StringVar(&user.FamilyName, "family-name", "User", "Family name of the logged in user")
+	flag.Parse()
+
+	mock, err := oidc.NewMockProvider(clientID, clientSecret, user)
+	if err != nil {
+		log.Fatalf("oidc mock: %v", err)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	srv := &http.Server{Addr: addr, Handler: mock}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("oidc mock listening on %s", addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatalf("oidc mock: %v", err)
+	}
+}
@@ -0,0 +1,53 @@
+// Command paymentstub serves a fake payment processor over HTTP, for pointing
+// the sockshop http payment gateway at during local runs.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/oshankkumar/sockshop/internal/payment"
+)
+
+func main() {
+	var (
+		addr  string
+		bins  string
+		rules payment.FakeRules
+	)
+
+	flag.StringVar(&addr, "addr", ":9191", "Listen address")
+	flag.Float64Var(&rules.DeclineAbove, "decline-above", 0, "Decline amounts above this value, 0 disables the rule")
+	flag.StringVar(&bins, "decline-bins", "", "Comma separated card number prefixes to decline")
+	flag.Float64Var(&rules.FailureRate, "failure-rate", 0, "Probability of randomly declining an authorization")
+	flag.Int64Var(&rules.Seed, "seed", 1, "Seed of the random failures")
+	flag.Parse()
+
+	if bins != "" {
+		rules.DeclineBINs = strings.Split(bins, ",")
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	srv := &http.Server{Addr: addr, Handler: payment.NewStubHandler(payment.NewFakeGateway(rules))}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("payment stub listening on %s", addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatalf("payment stub: %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/oshankkumar/sockshop/api"
+	"github.com/oshankkumar/sockshop/internal/app"
+	"github.com/oshankkumar/sockshop/internal/db/mysql"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// adminPasswordEnv names the environment variable create-admin reads the
+// password of a new admin from. There is no password flag, as the command
+// lines of processes are visible to the other users of the host.
+const adminPasswordEnv = "SOCKSHOP_ADMIN_PASSWORD"
+
+// createAdmin implements the create-admin subcommand, which bootstraps the
+// first admin of the shop. The password of a new admin is read from the
+// first line of the standard input with -password-stdin, or from
+// adminPasswordEnv:
+//
+//	sockshop create-admin -username admin -email admin@example.com -password-stdin < password.txt
+func createAdmin(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("create-admin", flag.ExitOnError)
+
+	var (
+		connStr       string
+		scheme        string
+		passwordStdin bool
+		user          api.User
+	)
+
+	fs.StringVar(&connStr, "mysql-conn-str", "admin:password@tcp(mysql:3306)/socksdb", "MySQL connection string")
+	fs.StringVar(&scheme, "password-hasher", "argon2id", "Password hashing scheme, one of argon2id or bcrypt")
+	fs.StringVar(&user.Username, "username", "", "Username of the admin, an existing user is promoted")
+	fs.BoolVar(&passwordStdin, "password-stdin", false, "Read the password of the admin, when it is registered, from the standard input instead of "+adminPasswordEnv)
+	fs.StringVar(&user.Email, "email", "", "Email of the admin when it is registered")
+	fs.StringVar(&user.FirstName, "first-name", "", "First name of the admin when it is registered")
+	fs.StringVar(&user.LastName, "last-name", "", "Last name of the admin when it is registered")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if user.Username == "" {
+		return errors.New("username is required")
+	}
+
+	if passwordStdin {
+		pass, err := readPassword(os.Stdin)
+		if err != nil {
+			return err
+		}
+		user.Password = pass
+	} else {
+		user.Password = os.Getenv(adminPasswordEnv)
+	}
+
+	hasher, err := newPasswordHasher(scheme)
+	if err != nil {
+		return err
+	}
+
+	db, err := sqlx.Open("mysql", connStr)
+	if err != nil {
+		return fmt.Errorf("db open: %w", err)
+	}
+	defer db.Close()
+
+	userService := &app.UserService{
+		Hasher:     hasher,
+		UserStore:  mysql.NewUserStore(db),
+		TxBeginner: db,
+	}
+
+	id, err := userService.BootstrapAdmin(ctx, user)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("user %s (%s) is an admin", user.Username, id)
+	return nil
+}
+
+// readPassword reads a password from the first line of r.
+func readPassword(r io.Reader) (string, error) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("read password: %w", err)
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
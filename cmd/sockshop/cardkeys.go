@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/oshankkumar/sockshop/internal/card"
+	"github.com/oshankkumar/sockshop/internal/db/mysql"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// rotateCardKeys implements the rotate-card-keys subcommand, which rewraps the
+// data keys of all the card numbers in the vault with the current key of the
+// keyfile:
+//
+//	sockshop rotate-card-keys -card-keyfile /etc/sockshop/card-keys.json
+func rotateCardKeys(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("rotate-card-keys", flag.ExitOnError)
+
+	var connStr, keyfile string
+
+	fs.StringVar(&connStr, "mysql-conn-str", "admin:password@tcp(mysql:3306)/socksdb", "MySQL connection string")
+	fs.StringVar(&keyfile, "card-keyfile", "", "Keyfile of the keys encrypting card numbers")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if keyfile == "" {
+		return errors.New("card-keyfile is required")
+	}
+
+	db, err := sqlx.Open("mysql", connStr)
+	if err != nil {
+		return fmt.Errorf("db open: %w", err)
+	}
+	defer db.Close()
+
+	cards, err := newCardVault("mysql", keyfile, db)
+	if err != nil {
+		return err
+	}
+
+	n, err := cards.RotateKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("rewrapped the data keys of %d card numbers", n)
+	return nil
+}
+
+// legacyCardBatch is the number of cards migrate-cards encrypts per query.
+const legacyCardBatch = 100
+
+// migrateCards implements the migrate-cards subcommand, which encrypts the card
+// numbers stored in clear by the versions before their encryption and erases
+// them and their CCVs. Run it between the 012 and 013 migrations of
+// deploy/migrations:
+//
+//	sockshop migrate-cards -card-keyfile /etc/sockshop/card-keys.json
+func migrateCards(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("migrate-cards", flag.ExitOnError)
+
+	var connStr, keyfile string
+
+	fs.StringVar(&connStr, "mysql-conn-str", "admin:password@tcp(mysql:3306)/socksdb", "MySQL connection string")
+	fs.StringVar(&keyfile, "card-keyfile", "", "Keyfile of the keys encrypting card numbers")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if keyfile == "" {
+		return errors.New("card-keyfile is required")
+	}
+
+	cipher, err := newSealer(keyfile, "card", true)
+	if err != nil {
+		return err
+	}
+
+	db, err := sqlx.Open("mysql", connStr)
+	if err != nil {
+		return fmt.Errorf("db open: %w", err)
+	}
+	defer db.Close()
+
+	store := mysql.NewLegacyCardStore(db)
+
+	var n int
+	for {
+		legacy, err := store.GetLegacyCards(ctx, legacyCardBatch)
+		if err != nil {
+			return err
+		}
+
+		if len(legacy) == 0 {
+			break
+		}
+
+		for _, c := range legacy {
+			number := card.Normalize(c.Number)
+
+			sealed, err := cipher.Seal(ctx, []byte(number))
+			if err != nil {
+				return fmt.Errorf("card %s: %w", c.ID, err)
+			}
+
+			err = store.MigrateLegacyCard(ctx, c.ID, sealed, cipher.Fingerprint([]byte(number)), card.Last4(number), card.Brand(number))
+			if err != nil {
+				return err
+			}
+			n++
+		}
+	}
+
+	log.Printf("encrypted %d card numbers", n)
+	return nil
+}
@@ -1,11 +1,81 @@
 package main
 
-import "flag"
+import (
+	"flag"
+	"time"
+)
+
+// minCursorSecretLen is the length of the shortest -cursor-secret accepted,
+// that of the HMAC-SHA256 keys signing the cursors.
+const minCursorSecretLen = 32
+
+// minTokenSecretLen is the length of the shortest HS256 -token-secret
+// accepted.
+const minTokenSecretLen = 32
 
 type AppConfig struct {
 	MySQLConnString string
 	ImagePath       string
 	Domain          string
+	CursorSecret    string
+	SearchBackend   string
+	PasswordHasher  string
+	CardKeyfile     string
+	VaultBackend    string
+	MFAKeyfile      string
+	OIDCConfig      string
+	RateLimitConfig string
+	Token           TokenConfig
+	ReservationTTL  time.Duration
+	// CartReservationTTL is how long the stock of a cart stays reserved
+	// after its last change.
+	CartReservationTTL time.Duration
+	// ReservationSweepInterval is how often expired reservations are swept.
+	ReservationSweepInterval time.Duration
+	Payment                  PaymentConfig
+	Mail                     MailConfig
+	Account                  AccountConfig
+	Login                    LoginConfig
+}
+
+type TokenConfig struct {
+	// Alg is the access token signing algorithm, HS256 or EdDSA.
+	Alg        string
+	Secret     string
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+}
+
+type PaymentConfig struct {
+	Gateway      string
+	URL          string
+	Currency     string
+	DeclineAbove float64
+	DeclineBINs  string
+	FailureRate  float64
+	Seed         int64
+}
+
+type MailConfig struct {
+	// Mailer is one of smtp, file or memory.
+	Mailer       string
+	From         string
+	OutboxDir    string
+	SMTPAddr     string
+	SMTPUsername string
+	SMTPPassword string
+}
+
+type AccountConfig struct {
+	PasswordResetTTL time.Duration
+	VerifyEmailTTL   time.Duration
+}
+
+type LoginConfig struct {
+	// AttemptsBackend stores the failed logins, mysql or memory.
+	AttemptsBackend string
+	LockoutAfter    int
+	LockoutDuration time.Duration
 }
 
 func NewConfigFromFlags() AppConfig {
@@ -13,6 +83,39 @@ func NewConfigFromFlags() AppConfig {
 	flag.StringVar(&conf.MySQLConnString, "mysql-conn-str", "admin:password@tcp(mysql:3306)/socksdb", "MySQL connection string")
 	flag.StringVar(&conf.ImagePath, "image-path", "assets/images", "Image path")
 	flag.StringVar(&conf.Domain, "link-domain", "127.0.0.1:9090", "HATEAOS link domain")
+	flag.StringVar(&conf.CursorSecret, "cursor-secret", "", "Key used to sign pagination cursors, required and shared by all the instances")
+	flag.StringVar(&conf.SearchBackend, "search-backend", "mysql", "Catalogue search backend, one of mysql or memory")
+	flag.StringVar(&conf.PasswordHasher, "password-hasher", "argon2id", "Password hashing scheme, one of argon2id or bcrypt")
+	flag.StringVar(&conf.CardKeyfile, "card-keyfile", "", "Keyfile of the keys encrypting card numbers, required unless the vault backend is memory")
+	flag.StringVar(&conf.VaultBackend, "vault-backend", "mysql", "Card vault backend, one of mysql or memory")
+	flag.StringVar(&conf.MFAKeyfile, "mfa-keyfile", "", "Keyfile of the keys encrypting TOTP secrets, required")
+	flag.StringVar(&conf.OIDCConfig, "oidc-config", "", "JSON file of the OpenID Connect identity providers customers may log in with, none when empty")
+	flag.StringVar(&conf.RateLimitConfig, "rate-limit-config", "", "JSON file of the per route rate limit rules, the default rules limit the catalogue and the logins when empty")
+	flag.StringVar(&conf.Token.Alg, "token-alg", "HS256", "Access token signing algorithm, one of HS256 or EdDSA")
+	flag.StringVar(&conf.Token.Secret, "token-secret", "", "HS256 key or hex encoded Ed25519 seed signing access tokens, required and shared by all the instances")
+	flag.DurationVar(&conf.Token.AccessTTL, "token-access-ttl", 15*time.Minute, "Lifetime of access tokens")
+	flag.DurationVar(&conf.Token.RefreshTTL, "token-refresh-ttl", 30*24*time.Hour, "Lifetime of refresh tokens")
+	flag.DurationVar(&conf.ReservationTTL, "reservation-ttl", 15*time.Minute, "How long the stock of an unpaid order stays reserved")
+	flag.DurationVar(&conf.CartReservationTTL, "cart-reservation-ttl", 30*time.Minute, "How long the stock of a cart stays reserved after its last change")
+	flag.DurationVar(&conf.ReservationSweepInterval, "reservation-sweep-interval", time.Minute, "How often expired stock reservations are released")
+	flag.StringVar(&conf.Payment.Gateway, "payment-gateway", "fake", "Payment gateway, one of fake or http")
+	flag.StringVar(&conf.Payment.URL, "payment-url", "http://127.0.0.1:9191", "Base URL of the payment processor used by the http gateway")
+	flag.StringVar(&conf.Payment.Currency, "payment-currency", "USD", "Currency orders are charged in")
+	flag.Float64Var(&conf.Payment.DeclineAbove, "payment-decline-above", 0, "Fake gateway declines amounts above this value, 0 disables the rule")
+	flag.StringVar(&conf.Payment.DeclineBINs, "payment-decline-bins", "", "Comma separated card number prefixes the fake gateway declines")
+	flag.Float64Var(&conf.Payment.FailureRate, "payment-failure-rate", 0, "Probability of the fake gateway randomly declining an authorization")
+	flag.Int64Var(&conf.Payment.Seed, "payment-seed", 1, "Seed of the fake gateway random failures")
+	flag.StringVar(&conf.Mail.Mailer, "mailer", "file", "Mailer sending emails to customers, one of smtp, file or memory")
+	flag.StringVar(&conf.Mail.From, "mail-from", "sockshop <no-reply@sockshop.local>", "Sender of the emails to customers")
+	flag.StringVar(&conf.Mail.OutboxDir, "mail-outbox-dir", "outbox", "Directory the file mailer writes emails to")
+	flag.StringVar(&conf.Mail.SMTPAddr, "smtp-addr", "127.0.0.1:25", "Address of the SMTP server used by the smtp mailer")
+	flag.StringVar(&conf.Mail.SMTPUsername, "smtp-username", "", "SMTP username, authentication is disabled when empty")
+	flag.StringVar(&conf.Mail.SMTPPassword, "smtp-password", "", "SMTP password")
+	flag.DurationVar(&conf.Account.PasswordResetTTL, "password-reset-ttl", time.Hour, "Lifetime of password reset tokens")
+	flag.DurationVar(&conf.Account.VerifyEmailTTL, "verify-email-ttl", 48*time.Hour, "Lifetime of email verification tokens")
+	flag.StringVar(&conf.Login.AttemptsBackend, "login-attempts-backend", "mysql", "Store of failed logins, one of mysql or memory")
+	flag.IntVar(&conf.Login.LockoutAfter, "login-lockout-after", 10, "Failed logins locking an account out, 0 disables the lockout")
+	flag.DurationVar(&conf.Login.LockoutDuration, "login-lockout-duration", 15*time.Minute, "How long an account stays locked out")
 	flag.Parse()
 	return conf
 }
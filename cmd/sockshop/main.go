@@ -2,18 +2,38 @@ package main
 
 import (
 	"context"
+	"crypto/ed25519"
+	"encoding/hex"
 	"fmt"
 	"log"
+	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/oshankkumar/sockshop/api"
+	"github.com/oshankkumar/sockshop/api/middleware"
 	"github.com/oshankkumar/sockshop/api/router"
+	"github.com/oshankkumar/sockshop/api/router/apikey"
+	"github.com/oshankkumar/sockshop/api/router/cart"
 	"github.com/oshankkumar/sockshop/api/router/catalogue"
+	"github.com/oshankkumar/sockshop/api/router/order"
 	"github.com/oshankkumar/sockshop/api/router/user"
 	"github.com/oshankkumar/sockshop/internal/app"
+	"github.com/oshankkumar/sockshop/internal/cursor"
+	"github.com/oshankkumar/sockshop/internal/db/memory"
 	"github.com/oshankkumar/sockshop/internal/db/mysql"
+	"github.com/oshankkumar/sockshop/internal/domain"
+	"github.com/oshankkumar/sockshop/internal/envelope"
+	"github.com/oshankkumar/sockshop/internal/mail"
+	"github.com/oshankkumar/sockshop/internal/oidc"
+	"github.com/oshankkumar/sockshop/internal/password"
+	"github.com/oshankkumar/sockshop/internal/payment"
+	"github.com/oshankkumar/sockshop/internal/ratelimit"
+	"github.com/oshankkumar/sockshop/internal/search"
+	"github.com/oshankkumar/sockshop/internal/token"
+	"github.com/oshankkumar/sockshop/internal/vault"
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
@@ -24,6 +44,26 @@ func main() {
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "create-admin":
+			if err := createAdmin(ctx, os.Args[2:]); err != nil {
+				log.Fatalf("failed creating admin: %v", err)
+			}
+			return
+		case "rotate-card-keys":
+			if err := rotateCardKeys(ctx, os.Args[2:]); err != nil {
+				log.Fatalf("failed rotating card keys: %v", err)
+			}
+			return
+		case "migrate-cards":
+			if err := migrateCards(ctx, os.Args[2:]); err != nil {
+				log.Fatalf("failed migrating cards: %v", err)
+			}
+			return
+		}
+	}
+
 	conf := NewConfigFromFlags()
 	if err := mainE(ctx, conf); err != nil {
 		log.Fatalf("failed running app: %v", err)
@@ -47,32 +87,394 @@ func mainE(ctx context.Context, conf AppConfig) error {
 		return fmt.Errorf("db ping: %w", err)
 	}
 
+	// Cursors handed out by one instance must be accepted by the others and
+	// after a restart, so the key is never generated.
+	if len(conf.CursorSecret) < minCursorSecretLen {
+		return fmt.Errorf("-cursor-secret of at least %d bytes is required", minCursorSecretLen)
+	}
+	cursors := cursor.NewCodec([]byte(conf.CursorSecret))
+
 	sockStore := mysql.NewSockStore(db)
-	catalogueSvc := app.NewCatalogueService(sockStore)
+
+	sockSearcher, err := newSockSearcher(ctx, conf.SearchBackend, db, sockStore)
+	if err != nil {
+		return err
+	}
+
+	catalogueSvc := app.NewCatalogueService(sockStore, sockSearcher, db, cursors, conf.Domain)
+
+	userStore := mysql.NewUserStore(db)
+
+	hasher, err := newPasswordHasher(conf.PasswordHasher)
+	if err != nil {
+		return err
+	}
+
+	cards, err := newCardVault(conf.VaultBackend, conf.CardKeyfile, db)
+	if err != nil {
+		return err
+	}
+
+	throttle, err := newLoginThrottle(conf.Login, db)
+	if err != nil {
+		return err
+	}
+
+	mfaSecrets, err := newSealer(conf.MFAKeyfile, "mfa", true)
+	if err != nil {
+		return err
+	}
+
+	sessionStore := mysql.NewSessionStore(db)
+	orderStore := mysql.NewOrderStore(db)
 
 	userService := &app.UserService{
-		UserStore:    mysql.NewUserStore(db),
+		Hasher:       hasher,
+		Cards:        cards,
+		UserStore:    userStore,
+		Sessions:     sessionStore,
 		CardStore:    mysql.NewCardStore(db),
 		AddressStore: mysql.NewAddressStore(db),
+		OrderStore:   orderStore,
 		TxBeginner:   db,
+		Cursors:      cursors,
 		Domain:       conf.Domain,
+		Throttle:     throttle,
+		MFA:          mysql.NewMFAStore(db),
+		Secrets:      mfaSecrets,
+		Issuer:       "sockshop",
+		Logger:       logger,
+	}
+
+	reservationStore := mysql.NewReservationStore(db)
+
+	cartService := &app.CartService{
+		CartStore:        mysql.NewCartStore(db),
+		SockStore:        sockStore,
+		TxBeginner:       db,
+		Domain:           conf.Domain,
+		ReservationStore: reservationStore,
+		ReservationTTL:   conf.CartReservationTTL,
+	}
+
+	gateway, err := newPaymentGateway(conf.Payment)
+	if err != nil {
+		return err
+	}
+
+	paymentService := &app.PaymentService{
+		Gateway:      gateway,
+		PaymentStore: mysql.NewPaymentStore(db),
+		UserStore:    userStore,
+		Cards:        cards,
+		Currency:     conf.Payment.Currency,
+	}
+
+	orderService := &app.OrderService{
+		OrderStore:       orderStore,
+		CartStore:        cartService.CartStore,
+		ReservationStore: reservationStore,
+		UserStore:        userStore,
+		Payments:         paymentService,
+		TxBeginner:       db,
+		Cursors:          cursors,
+		Domain:           conf.Domain,
+		ReservationTTL:   conf.ReservationTTL,
+	}
+
+	sweeper := &app.ReservationSweeper{
+		ReservationStore: reservationStore,
+		Interval:         conf.ReservationSweepInterval,
+		Logger:           logger,
+	}
+
+	tokens, err := newTokenCodec(conf.Token)
+	if err != nil {
+		return err
+	}
+
+	apiKeyStore := mysql.NewAPIKeyStore(db)
+
+	authService := &app.AuthService{
+		Sessions:   sessionStore,
+		UserStore:  userStore,
+		Tokens:     tokens,
+		AccessTTL:  conf.Token.AccessTTL,
+		RefreshTTL: conf.Token.RefreshTTL,
+		APIKeys:    apiKeyStore,
+	}
+
+	apiKeyService := &app.APIKeyService{
+		Keys:       apiKeyStore,
+		TxBeginner: db,
+	}
+
+	mailer, err := newMailer(conf.Mail)
+	if err != nil {
+		return err
+	}
+
+	accountService := &app.AccountService{
+		UserStore: userStore,
+		Tokens:    mysql.NewEmailTokenStore(db),
+		Sessions:  sessionStore,
+		Hasher:    hasher,
+		Mailer:    mailer,
+		Domain:    conf.Domain,
+		ResetTTL:  conf.Account.PasswordResetTTL,
+		VerifyTTL: conf.Account.VerifyEmailTTL,
+	}
+
+	providers, err := newOIDCProviders(conf.OIDCConfig, conf.Domain)
+	if err != nil {
+		return err
+	}
+
+	oidcService := &app.OIDCService{
+		Providers:  providers,
+		Identities: mysql.NewIdentityStore(db),
+		UserStore:  userStore,
+		Users:      userService,
+		TxBeginner: db,
 	}
 
 	rt := router.ComposeRouters(
 		catalogue.ImageRouter(conf.ImagePath),
 		catalogue.NewRouter(catalogueSvc, sockStore),
-		user.NewRouter(userService),
+		user.NewRouter(userService, cartService, authService, accountService, oidcService, logger),
+		cart.NewRouter(cartService),
+		order.NewRouter(orderService),
+		apikey.NewRouter(apiKeyService),
 	)
+	rateLimiter, err := newRateLimiter(conf.RateLimitConfig)
+	if err != nil {
+		return err
+	}
+
 	apiServer := &api.Server{
 		Addr:          ":9090",
 		Logger:        logger,
 		HealthChecker: doHealthCheck(db),
+		Authenticator: authService,
 		Router:        rt,
+		Workers:       []api.Worker{sweeper},
+		RateLimiter:   rateLimiter,
 	}
 
 	return apiServer.Start(ctx)
 }
 
+func newSockSearcher(ctx context.Context, backend string, db *sqlx.DB, sockStore *mysql.SockStore) (domain.SockSearcher, error) {
+	switch backend {
+	case "mysql":
+		return mysql.NewSockSearcher(db), nil
+	case "memory":
+		idx := search.NewIndex()
+		if err := idx.Load(ctx, sockStore); err != nil {
+			return nil, fmt.Errorf("load search index: %w", err)
+		}
+		return idx, nil
+	default:
+		return nil, fmt.Errorf("unknown search backend %q", backend)
+	}
+}
+
+func newTokenCodec(conf TokenConfig) (*token.Codec, error) {
+	const issuer = "sockshop"
+
+	switch conf.Alg {
+	case "HS256":
+		if len(conf.Secret) < minTokenSecretLen {
+			return nil, fmt.Errorf("-token-secret of at least %d bytes is required", minTokenSecretLen)
+		}
+		return token.NewHS256([]byte(conf.Secret), issuer), nil
+	case "EdDSA":
+		seed, err := hex.DecodeString(conf.Secret)
+		if err != nil || len(seed) != ed25519.SeedSize {
+			return nil, fmt.Errorf("-token-secret must be a hex encoded %d bytes Ed25519 seed", ed25519.SeedSize)
+		}
+		return token.NewEdDSA(ed25519.NewKeyFromSeed(seed), issuer), nil
+	default:
+		return nil, fmt.Errorf("unknown token algorithm %q", conf.Alg)
+	}
+}
+
+func newPasswordHasher(scheme string) (app.PasswordHasher, error) {
+	switch scheme {
+	case "argon2id":
+		return password.DefaultArgon2id, nil
+	case "bcrypt":
+		return password.DefaultBcrypt, nil
+	default:
+		return nil, fmt.Errorf("unknown password hasher %q", scheme)
+	}
+}
+
+// newSealer returns the cipher of the secrets of the named kind, such as card
+// numbers. Random keys are only allowed for secrets which are not persisted,
+// since the stored secrets could not be decrypted after a restart.
+func newSealer(keyfile, kind string, persistent bool) (*envelope.Sealer, error) {
+	var (
+		keys *envelope.Keyring
+		err  error
+	)
+
+	switch {
+	case keyfile != "":
+		keys, err = envelope.LoadKeyfile(keyfile)
+	case persistent:
+		return nil, fmt.Errorf("-%s-keyfile is required, %s secrets are persisted", kind, kind)
+	default:
+		keys, err = envelope.NewRandomKeyring()
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("load %s keys: %w", kind, err)
+	}
+
+	return envelope.NewSealer(keys, keys.FingerprintKey), nil
+}
+
+// newCardVault returns the vault of card numbers. Only the payment path can
+// detokenize.
+func newCardVault(backend, keyfile string, db *sqlx.DB) (*vault.Vault, error) {
+	var store vault.Store
+
+	switch backend {
+	case "mysql":
+		store = mysql.NewVaultStore(db)
+	case "memory":
+		store = memory.NewVaultStore()
+	default:
+		return nil, fmt.Errorf("unknown vault backend %q", backend)
+	}
+
+	cipher, err := newSealer(keyfile, "card", backend != "memory")
+	if err != nil {
+		return nil, err
+	}
+
+	return vault.New(store, cipher, vault.CallerPayments), nil
+}
+
+func newPaymentGateway(conf PaymentConfig) (app.PaymentGateway, error) {
+	switch conf.Gateway {
+	case "fake":
+		rules := payment.FakeRules{
+			DeclineAbove: conf.DeclineAbove,
+			FailureRate:  conf.FailureRate,
+			Seed:         conf.Seed,
+		}
+		if conf.DeclineBINs != "" {
+			rules.DeclineBINs = strings.Split(conf.DeclineBINs, ",")
+		}
+		return payment.NewFakeGateway(rules), nil
+	case "http":
+		return payment.NewHTTPGateway(conf.URL), nil
+	default:
+		return nil, fmt.Errorf("unknown payment gateway %q", conf.Gateway)
+	}
+}
+
+// newLoginThrottle returns the throttle of logins. Usernames back off after 3
+// failures and client IPs, which may be shared by many customers, after 20.
+func newLoginThrottle(conf LoginConfig, db *sqlx.DB) (*app.LoginThrottle, error) {
+	var store domain.LoginAttemptStore
+
+	switch conf.AttemptsBackend {
+	case "mysql":
+		store = mysql.NewLoginAttemptStore(db)
+	case "memory":
+		store = memory.NewLoginAttemptStore()
+	default:
+		return nil, fmt.Errorf("unknown login attempts backend %q", conf.AttemptsBackend)
+	}
+
+	return &app.LoginThrottle{
+		Store: store,
+		User: app.LoginPolicy{
+			FreeAttempts:    3,
+			BaseDelay:       time.Second,
+			MaxDelay:        time.Minute,
+			LockoutAfter:    conf.LockoutAfter,
+			LockoutDuration: conf.LockoutDuration,
+		},
+		IP: app.LoginPolicy{
+			FreeAttempts: 20,
+			BaseDelay:    time.Second,
+			MaxDelay:     time.Minute,
+		},
+		Window: time.Hour,
+	}, nil
+}
+
+func newMailer(conf MailConfig) (app.Mailer, error) {
+	switch conf.Mailer {
+	case "smtp":
+		return mail.NewSMTPMailer(conf.SMTPAddr, conf.From, conf.SMTPUsername, conf.SMTPPassword)
+	case "file":
+		return mail.NewFileMailer(conf.OutboxDir, conf.From)
+	case "memory":
+		return mail.NewMemoryMailer(), nil
+	default:
+		return nil, fmt.Errorf("unknown mailer %q", conf.Mailer)
+	}
+}
+
+// newOIDCProviders returns the identity providers configured in the file. The
+// redirect URL of a provider defaults to its callback on the link domain.
+func newOIDCProviders(path, linkDomain string) (map[string]app.OIDCProvider, error) {
+	providers := make(map[string]app.OIDCProvider)
+	if path == "" {
+		return providers, nil
+	}
+
+	confs, err := oidc.LoadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, c := range confs {
+		if c.RedirectURL == "" {
+			c.RedirectURL = "http://" + linkDomain + "/login/oidc/" + c.Name + "/callback"
+		}
+		providers[c.Name] = oidc.NewProvider(c, nil)
+	}
+
+	return providers, nil
+}
+
+// defaultRateLimitRules limit the catalogue reads per client, and per IP
+// more loosely so that guessed credentials are limited too, and the logins
+// and password resets per IP, on top of the login throttle.
+var defaultRateLimitRules = []ratelimit.Rule{
+	{Method: "GET", Pattern: "/catalogue", Rate: 10, Burst: 50, Key: ratelimit.KeyClient},
+	{Method: "GET", Pattern: "/catalogue", Rate: 50, Burst: 200, Key: ratelimit.KeyIP},
+	{Method: "GET", Pattern: "/catalogue/{id}", Rate: 10, Burst: 50, Key: ratelimit.KeyClient},
+	{Method: "GET", Pattern: "/catalogue/{id}", Rate: 50, Burst: 200, Key: ratelimit.KeyIP},
+	{Method: "GET", Pattern: "/catalogue/search", Rate: 5, Burst: 20, Key: ratelimit.KeyClient},
+	{Method: "GET", Pattern: "/catalogue/search", Rate: 25, Burst: 100, Key: ratelimit.KeyIP},
+	{Method: "POST", Pattern: "/login", Rate: 1, Burst: 10, Key: ratelimit.KeyIP},
+	{Method: "POST", Pattern: "/login/mfa", Rate: 1, Burst: 10, Key: ratelimit.KeyIP},
+	{Method: "POST", Pattern: "/password-reset", Rate: 0.1, Burst: 5, Key: ratelimit.KeyIP},
+}
+
+// newRateLimiter returns the rate limiter of the rules in the file, or of the
+// default rules. Its buckets are kept in memory, so each instance of the shop
+// limits the clients on its own.
+func newRateLimiter(path string) (*middleware.RateLimiter, error) {
+	rules := defaultRateLimitRules
+
+	if path != "" {
+		var err error
+		if rules, err = ratelimit.LoadRules(path); err != nil {
+			return nil, err
+		}
+	}
+
+	return &middleware.RateLimiter{Store: ratelimit.NewMemoryStore(), Rules: rules}, nil
+}
+
 func doHealthCheck(db *sqlx.DB) api.HealthCheckerFunc {
 	return func(ctx context.Context) ([]api.Health, error) {
 		if err := db.PingContext(ctx); err != nil {
@@ -6,18 +6,23 @@ CREATE TABLE IF NOT EXISTS sock (
 	id varchar(40) NOT NULL, 
 	name varchar(20), 
 	description varchar(200), 
-	price float, 
+	price decimal(10,2), 
 	count int, 
 	image_urls varchar(100),  
 	PRIMARY KEY(id)
 );
 
+CREATE UNIQUE INDEX sock_name_uq ON sock (name);
+CREATE FULLTEXT INDEX sock_name_description_ft ON sock (name, description);
+
 CREATE TABLE IF NOT EXISTS tag (
 	id MEDIUMINT NOT NULL AUTO_INCREMENT, 
 	name varchar(20), 
 	PRIMARY KEY(id)
 );
 
+CREATE UNIQUE INDEX tag_name_uq ON tag (name);
+
 CREATE TABLE IF NOT EXISTS sock_tag (
 	sock_id varchar(40), 
 	tag_id MEDIUMINT NOT NULL, 
@@ -83,8 +88,10 @@ CREATE TABLE IF NOT EXISTS customer (
 	last_name varchar(20), 
 	email varchar(40), 
 	username varchar(20), 
-	password varchar(40), 
+	password varchar(255), 
 	salt varchar(40),
+	version int NOT NULL DEFAULT 1,
+	email_verified boolean NOT NULL DEFAULT FALSE,
 	PRIMARY KEY(id),
 	UNIQUE (email)
 );
@@ -98,17 +105,20 @@ CREATE TABLE IF NOT EXISTS address (
 	country varchar(20), 
 	city varchar(20), 
 	postcode varchar(20), 
+	version int NOT NULL DEFAULT 1,
 	PRIMARY KEY(id)
 );
 
 
 CREATE TABLE IF NOT EXISTS card (
 	id varchar(40) NOT NULL, 
-	long_num varchar(60), 
+	token varchar(40) NOT NULL, 
+	last4 char(4), 
+	brand varchar(20), 
 	expires varchar(20), 
-	ccv varchar(20), 
+	version int NOT NULL DEFAULT 1,
 	PRIMARY KEY(id),
-	UNIQUE (long_num)
+	UNIQUE (token)
 );
 
 CREATE TABLE IF NOT EXISTS customer_address (
@@ -131,4 +141,226 @@ CREATE TABLE IF NOT EXISTS customer_card (
 		REFERENCES card(id)
 );
 
-CREATE INDEX customer_card_customer_id ON customer_card (customer_id);
\ No newline at end of file
+CREATE INDEX customer_card_customer_id ON customer_card (customer_id);
+
+CREATE TABLE IF NOT EXISTS cart_item (
+	customer_id varchar(40) NOT NULL,
+	sock_id varchar(40) NOT NULL,
+	quantity int NOT NULL,
+	unit_price decimal(10,2) NOT NULL,
+	PRIMARY KEY(customer_id, sock_id),
+	FOREIGN KEY (sock_id)
+		REFERENCES sock(id)
+);
+
+CREATE TABLE IF NOT EXISTS customer_order (
+	id varchar(40) NOT NULL,
+	customer_id varchar(40) NOT NULL,
+	card_id varchar(40),
+	street varchar(40),
+	number varchar(40),
+	country varchar(20),
+	city varchar(20),
+	postcode varchar(20),
+	subtotal decimal(10,2) NOT NULL,
+	shipping decimal(10,2) NOT NULL,
+	total decimal(10,2) NOT NULL,
+	status varchar(20) NOT NULL,
+	created_at datetime NOT NULL,
+	PRIMARY KEY(id),
+	FOREIGN KEY (customer_id)
+		REFERENCES customer(id)
+);
+
+CREATE INDEX customer_order_customer_id ON customer_order (customer_id);
+
+CREATE TABLE IF NOT EXISTS order_item (
+	order_id varchar(40) NOT NULL,
+	sock_id varchar(40) NOT NULL,
+	quantity int NOT NULL,
+	unit_price decimal(10,2) NOT NULL,
+	PRIMARY KEY(order_id, sock_id),
+	FOREIGN KEY (order_id)
+		REFERENCES customer_order(id),
+	FOREIGN KEY (sock_id)
+		REFERENCES sock(id)
+);
+
+CREATE TABLE IF NOT EXISTS order_status (
+	id int NOT NULL AUTO_INCREMENT,
+	order_id varchar(40) NOT NULL,
+	status varchar(20) NOT NULL,
+	created_at datetime(6) NOT NULL,
+	PRIMARY KEY(id),
+	FOREIGN KEY (order_id)
+		REFERENCES customer_order(id)
+);
+
+CREATE TABLE IF NOT EXISTS payment (
+	id varchar(40) NOT NULL,
+	order_id varchar(40) NOT NULL,
+	attempt int NOT NULL,
+	idempotency_key varchar(80) NOT NULL,
+	amount decimal(10,2) NOT NULL,
+	status varchar(20) NOT NULL,
+	authorization_id varchar(80) NOT NULL DEFAULT '',
+	decline_reason varchar(200) NOT NULL DEFAULT '',
+	created_at datetime(6) NOT NULL,
+	updated_at datetime(6) NOT NULL,
+	PRIMARY KEY(id),
+	UNIQUE (idempotency_key),
+	UNIQUE (order_id, attempt),
+	FOREIGN KEY (order_id)
+		REFERENCES customer_order(id)
+);
+
+CREATE TABLE IF NOT EXISTS reservation (
+	id varchar(40) NOT NULL,
+	sock_id varchar(40) NOT NULL,
+	owner varchar(80) NOT NULL,
+	quantity int NOT NULL,
+	status varchar(20) NOT NULL,
+	expires_at datetime(6) NOT NULL,
+	created_at datetime(6) NOT NULL,
+	PRIMARY KEY(id),
+	INDEX reservation_owner_idx (owner, status),
+	INDEX reservation_sock_idx (sock_id, status, expires_at),
+	FOREIGN KEY (sock_id)
+		REFERENCES sock(id)
+);
+
+CREATE TABLE IF NOT EXISTS session (
+	id varchar(40) NOT NULL,
+	customer_id varchar(40) NOT NULL,
+	refresh_hash char(64) NOT NULL,
+	expires_at datetime(6) NOT NULL,
+	created_at datetime(6) NOT NULL,
+	revoked_at datetime(6) NULL,
+	PRIMARY KEY(id),
+	UNIQUE (refresh_hash),
+	FOREIGN KEY (customer_id)
+		REFERENCES customer(id)
+);
+
+CREATE TABLE IF NOT EXISTS customer_role (
+	customer_id varchar(40) NOT NULL,
+	role varchar(20) NOT NULL,
+	PRIMARY KEY(customer_id, role),
+	FOREIGN KEY (customer_id)
+		REFERENCES customer(id)
+);
+
+CREATE TABLE IF NOT EXISTS vault_entry (
+	token varchar(40) NOT NULL,
+	pan varbinary(128) NOT NULL,
+	data_key varbinary(128) NOT NULL,
+	key_id varchar(64) NOT NULL,
+	fingerprint char(64) NOT NULL,
+	created_at datetime(6) NOT NULL,
+	PRIMARY KEY(token),
+	UNIQUE (fingerprint),
+	INDEX vault_entry_key_idx (key_id)
+);
+
+CREATE TABLE IF NOT EXISTS vault_audit (
+	id bigint NOT NULL AUTO_INCREMENT,
+	token varchar(40) NOT NULL,
+	caller varchar(40) NOT NULL,
+	allowed boolean NOT NULL,
+	created_at datetime(6) NOT NULL,
+	PRIMARY KEY(id),
+	INDEX vault_audit_token_idx (token)
+);
+
+CREATE TABLE IF NOT EXISTS email_token (
+	hash char(64) NOT NULL,
+	customer_id varchar(40) NOT NULL,
+	purpose varchar(20) NOT NULL,
+	email varchar(40) NOT NULL,
+	expires_at datetime(6) NOT NULL,
+	created_at datetime(6) NOT NULL,
+	used_at datetime(6) NULL,
+	PRIMARY KEY(hash),
+	INDEX email_token_customer_idx (customer_id),
+	FOREIGN KEY (customer_id)
+		REFERENCES customer(id)
+);
+
+CREATE TABLE IF NOT EXISTS login_attempt (
+	attempt_key varchar(100) NOT NULL,
+	failures int NOT NULL,
+	last_failure datetime(6) NOT NULL,
+	PRIMARY KEY(attempt_key)
+);
+
+CREATE TABLE IF NOT EXISTS customer_totp (
+	customer_id varchar(40) NOT NULL,
+	secret varbinary(128) NOT NULL,
+	data_key varbinary(128) NOT NULL,
+	key_id varchar(64) NOT NULL,
+	confirmed boolean NOT NULL,
+	last_counter bigint NOT NULL,
+	created_at datetime(6) NOT NULL,
+	PRIMARY KEY(customer_id),
+	FOREIGN KEY (customer_id)
+		REFERENCES customer(id)
+);
+
+CREATE TABLE IF NOT EXISTS recovery_code (
+	customer_id varchar(40) NOT NULL,
+	code_hash char(64) NOT NULL,
+	used_at datetime(6) NULL,
+	PRIMARY KEY(customer_id, code_hash),
+	FOREIGN KEY (customer_id)
+		REFERENCES customer(id)
+);
+
+CREATE TABLE IF NOT EXISTS mfa_challenge (
+	hash char(64) NOT NULL,
+	customer_id varchar(40) NOT NULL,
+	expires_at datetime(6) NOT NULL,
+	PRIMARY KEY(hash),
+	FOREIGN KEY (customer_id)
+		REFERENCES customer(id)
+);
+
+CREATE TABLE IF NOT EXISTS customer_identity (
+	provider varchar(40) NOT NULL,
+	subject varchar(255) NOT NULL,
+	customer_id varchar(40) NOT NULL,
+	created_at datetime(6) NOT NULL,
+	PRIMARY KEY(provider, subject),
+	INDEX customer_identity_customer_idx (customer_id),
+	FOREIGN KEY (customer_id)
+		REFERENCES customer(id)
+);
+
+CREATE TABLE IF NOT EXISTS oidc_login (
+	state_hash char(64) NOT NULL,
+	provider varchar(40) NOT NULL,
+	nonce varchar(64) NOT NULL,
+	verifier varchar(64) NOT NULL,
+	expires_at datetime(6) NOT NULL,
+	PRIMARY KEY(state_hash)
+);
+
+CREATE TABLE IF NOT EXISTS api_key (
+	id varchar(40) NOT NULL,
+	name varchar(40) NOT NULL,
+	prefix varchar(20) NOT NULL,
+	hash char(64) NOT NULL,
+	created_by varchar(40) NOT NULL,
+	created_at datetime(6) NOT NULL,
+	expires_at datetime(6) NULL,
+	revoked_at datetime(6) NULL,
+	PRIMARY KEY(id),
+	UNIQUE (hash)
+);
+
+CREATE TABLE IF NOT EXISTS api_key_scope (
+	key_id varchar(40) NOT NULL,
+	scope varchar(40) NOT NULL,
+	PRIMARY KEY(key_id, scope),
+	FOREIGN KEY (key_id)
+		REFERENCES api_key(id)
+);
@@ -0,0 +1,21 @@
+-- Sock and tag names are unique. The duplicate names of an older database
+-- are resolved first: every sock but the first of a name is renamed with the
+-- start of its ID, and the socks of a duplicate tag are moved to the first
+-- tag of the name, which replaces it.
+UPDATE sock s
+	JOIN (SELECT name, MIN(id) AS id FROM sock GROUP BY name HAVING COUNT(*) > 1) first
+		ON s.name = first.name AND s.id <> first.id
+	SET s.name = CONCAT(LEFT(s.name, 11), '-', LEFT(s.id, 8));
+
+UPDATE sock_tag st
+	JOIN tag t ON st.tag_id = t.id
+	JOIN (SELECT name, MIN(id) AS id FROM tag GROUP BY name HAVING COUNT(*) > 1) first
+		ON t.name = first.name AND t.id <> first.id
+	SET st.tag_id = first.id;
+
+DELETE t FROM tag t
+	JOIN (SELECT name, MIN(id) AS id FROM tag GROUP BY name HAVING COUNT(*) > 1) first
+		ON t.name = first.name AND t.id <> first.id;
+
+CREATE UNIQUE INDEX sock_name_uq ON sock (name);
+CREATE UNIQUE INDEX tag_name_uq ON tag (name);
@@ -0,0 +1,2 @@
+-- Prices are exact decimals. Float prices are rounded to the cent.
+ALTER TABLE sock MODIFY price decimal(10,2);
@@ -0,0 +1,2 @@
+-- The catalogue search matches the names and descriptions of the socks.
+CREATE FULLTEXT INDEX sock_name_description_ft ON sock (name, description);
@@ -0,0 +1,10 @@
+-- The items of the customer carts.
+CREATE TABLE IF NOT EXISTS cart_item (
+	customer_id varchar(40) NOT NULL,
+	sock_id varchar(40) NOT NULL,
+	quantity int NOT NULL,
+	unit_price decimal(10,2) NOT NULL,
+	PRIMARY KEY(customer_id, sock_id),
+	FOREIGN KEY (sock_id)
+		REFERENCES sock(id)
+);
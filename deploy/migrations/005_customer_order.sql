@@ -0,0 +1,43 @@
+-- The orders, their items and their status history.
+CREATE TABLE IF NOT EXISTS customer_order (
+	id varchar(40) NOT NULL,
+	customer_id varchar(40) NOT NULL,
+	card_id varchar(40),
+	street varchar(40),
+	number varchar(40),
+	country varchar(20),
+	city varchar(20),
+	postcode varchar(20),
+	subtotal decimal(10,2) NOT NULL,
+	shipping decimal(10,2) NOT NULL,
+	total decimal(10,2) NOT NULL,
+	status varchar(20) NOT NULL,
+	created_at datetime NOT NULL,
+	PRIMARY KEY(id),
+	FOREIGN KEY (customer_id)
+		REFERENCES customer(id)
+);
+
+CREATE INDEX customer_order_customer_id ON customer_order (customer_id);
+
+CREATE TABLE IF NOT EXISTS order_item (
+	order_id varchar(40) NOT NULL,
+	sock_id varchar(40) NOT NULL,
+	quantity int NOT NULL,
+	unit_price decimal(10,2) NOT NULL,
+	PRIMARY KEY(order_id, sock_id),
+	FOREIGN KEY (order_id)
+		REFERENCES customer_order(id),
+	FOREIGN KEY (sock_id)
+		REFERENCES sock(id)
+);
+
+CREATE TABLE IF NOT EXISTS order_status (
+	id int NOT NULL AUTO_INCREMENT,
+	order_id varchar(40) NOT NULL,
+	status varchar(20) NOT NULL,
+	created_at datetime(6) NOT NULL,
+	PRIMARY KEY(id),
+	FOREIGN KEY (order_id)
+		REFERENCES customer_order(id)
+);
@@ -0,0 +1,18 @@
+-- The payment attempts of the orders.
+CREATE TABLE IF NOT EXISTS payment (
+	id varchar(40) NOT NULL,
+	order_id varchar(40) NOT NULL,
+	attempt int NOT NULL,
+	idempotency_key varchar(80) NOT NULL,
+	amount decimal(10,2) NOT NULL,
+	status varchar(20) NOT NULL,
+	authorization_id varchar(80) NOT NULL DEFAULT '',
+	decline_reason varchar(200) NOT NULL DEFAULT '',
+	created_at datetime(6) NOT NULL,
+	updated_at datetime(6) NOT NULL,
+	PRIMARY KEY(id),
+	UNIQUE (idempotency_key),
+	UNIQUE (order_id, attempt),
+	FOREIGN KEY (order_id)
+		REFERENCES customer_order(id)
+);
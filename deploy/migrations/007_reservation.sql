@@ -0,0 +1,15 @@
+-- The stock reserved by carts and unpaid orders.
+CREATE TABLE IF NOT EXISTS reservation (
+	id varchar(40) NOT NULL,
+	sock_id varchar(40) NOT NULL,
+	owner varchar(80) NOT NULL,
+	quantity int NOT NULL,
+	status varchar(20) NOT NULL,
+	expires_at datetime(6) NOT NULL,
+	created_at datetime(6) NOT NULL,
+	PRIMARY KEY(id),
+	INDEX reservation_owner_idx (owner, status),
+	INDEX reservation_sock_idx (sock_id, status, expires_at),
+	FOREIGN KEY (sock_id)
+		REFERENCES sock(id)
+);
@@ -0,0 +1,3 @@
+-- Password hashes are PHC strings, longer than the legacy SHA-1 hex digests.
+-- The legacy hashes are kept and upgraded on the next login of each customer.
+ALTER TABLE customer MODIFY password varchar(255);
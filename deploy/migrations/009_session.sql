@@ -0,0 +1,13 @@
+-- The login sessions and their refresh tokens.
+CREATE TABLE IF NOT EXISTS session (
+	id varchar(40) NOT NULL,
+	customer_id varchar(40) NOT NULL,
+	refresh_hash char(64) NOT NULL,
+	expires_at datetime(6) NOT NULL,
+	created_at datetime(6) NOT NULL,
+	revoked_at datetime(6) NULL,
+	PRIMARY KEY(id),
+	UNIQUE (refresh_hash),
+	FOREIGN KEY (customer_id)
+		REFERENCES customer(id)
+);
@@ -0,0 +1,8 @@
+-- The roles of the customers. Customers without one are plain customers.
+CREATE TABLE IF NOT EXISTS customer_role (
+	customer_id varchar(40) NOT NULL,
+	role varchar(20) NOT NULL,
+	PRIMARY KEY(customer_id, role),
+	FOREIGN KEY (customer_id)
+		REFERENCES customer(id)
+);
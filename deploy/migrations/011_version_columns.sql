@@ -0,0 +1,5 @@
+-- Optimistic locking of customers, addresses and cards. Every existing row
+-- starts at version 1.
+ALTER TABLE customer ADD COLUMN version int NOT NULL DEFAULT 1;
+ALTER TABLE address ADD COLUMN version int NOT NULL DEFAULT 1;
+ALTER TABLE card ADD COLUMN version int NOT NULL DEFAULT 1;
@@ -0,0 +1,10 @@
+-- Card numbers are encrypted. The encrypted number and its details are added
+-- next to long_num; `sockshop migrate-cards` then fills them in, and erases
+-- the numbers and CCVs, before 013 drops the columns in clear.
+ALTER TABLE card
+	ADD COLUMN pan varbinary(128) NULL AFTER id,
+	ADD COLUMN data_key varbinary(128) NULL AFTER pan,
+	ADD COLUMN key_id varchar(64) NULL AFTER data_key,
+	ADD COLUMN fingerprint char(64) NULL AFTER key_id,
+	ADD COLUMN last4 char(4) AFTER fingerprint,
+	ADD COLUMN brand varchar(20) AFTER last4;
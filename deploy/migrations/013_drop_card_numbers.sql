@@ -0,0 +1,11 @@
+-- Run once `sockshop migrate-cards` encrypted every card number. It fails
+-- while some card has no encrypted number.
+ALTER TABLE card
+	MODIFY pan varbinary(128) NOT NULL,
+	MODIFY data_key varbinary(128) NOT NULL,
+	MODIFY key_id varchar(64) NOT NULL,
+	MODIFY fingerprint char(64) NOT NULL,
+	ADD UNIQUE (fingerprint),
+	ADD INDEX (key_id),
+	DROP COLUMN long_num,
+	DROP COLUMN ccv;
@@ -0,0 +1,38 @@
+-- Card numbers move to the vault. Every card is given a token, and its number,
+-- encrypted with the same card keyfile, becomes the vault entry of the token.
+CREATE TABLE IF NOT EXISTS vault_entry (
+	token varchar(40) NOT NULL,
+	pan varbinary(128) NOT NULL,
+	data_key varbinary(128) NOT NULL,
+	key_id varchar(64) NOT NULL,
+	fingerprint char(64) NOT NULL,
+	created_at datetime(6) NOT NULL,
+	PRIMARY KEY(token),
+	UNIQUE (fingerprint),
+	INDEX vault_entry_key_idx (key_id)
+);
+
+CREATE TABLE IF NOT EXISTS vault_audit (
+	id bigint NOT NULL AUTO_INCREMENT,
+	token varchar(40) NOT NULL,
+	caller varchar(40) NOT NULL,
+	allowed boolean NOT NULL,
+	created_at datetime(6) NOT NULL,
+	PRIMARY KEY(id),
+	INDEX vault_audit_token_idx (token)
+);
+
+ALTER TABLE card ADD COLUMN token varchar(40) NULL AFTER id;
+
+UPDATE card SET token = CONCAT('tok_', LOWER(HEX(RANDOM_BYTES(16))));
+
+INSERT INTO vault_entry (token, pan, data_key, key_id, fingerprint, created_at)
+	SELECT token, pan, data_key, key_id, fingerprint, NOW(6) FROM card;
+
+ALTER TABLE card
+	MODIFY token varchar(40) NOT NULL,
+	ADD UNIQUE (token),
+	DROP COLUMN pan,
+	DROP COLUMN data_key,
+	DROP COLUMN key_id,
+	DROP COLUMN fingerprint;
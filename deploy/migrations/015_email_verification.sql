@@ -0,0 +1,16 @@
+-- No email of the existing customers has been verified.
+ALTER TABLE customer ADD COLUMN email_verified boolean NOT NULL DEFAULT FALSE;
+
+CREATE TABLE IF NOT EXISTS email_token (
+	hash char(64) NOT NULL,
+	customer_id varchar(40) NOT NULL,
+	purpose varchar(20) NOT NULL,
+	email varchar(40) NOT NULL,
+	expires_at datetime(6) NOT NULL,
+	created_at datetime(6) NOT NULL,
+	used_at datetime(6) NULL,
+	PRIMARY KEY(hash),
+	INDEX email_token_customer_idx (customer_id),
+	FOREIGN KEY (customer_id)
+		REFERENCES customer(id)
+);
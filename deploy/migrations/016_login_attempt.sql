@@ -0,0 +1,7 @@
+-- The failed logins of the usernames and client IPs.
+CREATE TABLE IF NOT EXISTS login_attempt (
+	attempt_key varchar(100) NOT NULL,
+	failures int NOT NULL,
+	last_failure datetime(6) NOT NULL,
+	PRIMARY KEY(attempt_key)
+);
@@ -0,0 +1,31 @@
+-- The second factors of the customers.
+CREATE TABLE IF NOT EXISTS customer_totp (
+	customer_id varchar(40) NOT NULL,
+	secret varbinary(128) NOT NULL,
+	data_key varbinary(128) NOT NULL,
+	key_id varchar(64) NOT NULL,
+	confirmed boolean NOT NULL,
+	last_counter bigint NOT NULL,
+	created_at datetime(6) NOT NULL,
+	PRIMARY KEY(customer_id),
+	FOREIGN KEY (customer_id)
+		REFERENCES customer(id)
+);
+
+CREATE TABLE IF NOT EXISTS recovery_code (
+	customer_id varchar(40) NOT NULL,
+	code_hash char(64) NOT NULL,
+	used_at datetime(6) NULL,
+	PRIMARY KEY(customer_id, code_hash),
+	FOREIGN KEY (customer_id)
+		REFERENCES customer(id)
+);
+
+CREATE TABLE IF NOT EXISTS mfa_challenge (
+	hash char(64) NOT NULL,
+	customer_id varchar(40) NOT NULL,
+	expires_at datetime(6) NOT NULL,
+	PRIMARY KEY(hash),
+	FOREIGN KEY (customer_id)
+		REFERENCES customer(id)
+);
@@ -0,0 +1,21 @@
+-- The identities of the customers at the OIDC providers and the pending
+-- OIDC logins.
+CREATE TABLE IF NOT EXISTS customer_identity (
+	provider varchar(40) NOT NULL,
+	subject varchar(255) NOT NULL,
+	customer_id varchar(40) NOT NULL,
+	created_at datetime(6) NOT NULL,
+	PRIMARY KEY(provider, subject),
+	INDEX customer_identity_customer_idx (customer_id),
+	FOREIGN KEY (customer_id)
+		REFERENCES customer(id)
+);
+
+CREATE TABLE IF NOT EXISTS oidc_login (
+	state_hash char(64) NOT NULL,
+	provider varchar(40) NOT NULL,
+	nonce varchar(64) NOT NULL,
+	verifier varchar(64) NOT NULL,
+	expires_at datetime(6) NOT NULL,
+	PRIMARY KEY(state_hash)
+);
@@ -0,0 +1,21 @@
+-- The API keys and their scopes.
+CREATE TABLE IF NOT EXISTS api_key (
+	id varchar(40) NOT NULL,
+	name varchar(40) NOT NULL,
+	prefix varchar(20) NOT NULL,
+	hash char(64) NOT NULL,
+	created_by varchar(40) NOT NULL,
+	created_at datetime(6) NOT NULL,
+	expires_at datetime(6) NULL,
+	revoked_at datetime(6) NULL,
+	PRIMARY KEY(id),
+	UNIQUE (hash)
+);
+
+CREATE TABLE IF NOT EXISTS api_key_scope (
+	key_id varchar(40) NOT NULL,
+	scope varchar(40) NOT NULL,
+	PRIMARY KEY(key_id, scope),
+	FOREIGN KEY (key_id)
+		REFERENCES api_key(id)
+);
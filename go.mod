@@ -9,6 +9,7 @@ require (
 	github.com/jmoiron/sqlx v1.3.5
 	github.com/prometheus/client_golang v1.19.0
 	go.uber.org/zap v1.24.0
+	golang.org/x/crypto v0.21.0
 )
 
 require (
@@ -19,6 +20,6 @@ require (
 	github.com/prometheus/procfs v0.12.0 // indirect
 	go.uber.org/atomic v1.7.0 // indirect
 	go.uber.org/multierr v1.6.0 // indirect
-	golang.org/x/sys v0.16.0 // indirect
+	golang.org/x/sys v0.18.0 // indirect
 	google.golang.org/protobuf v1.32.0 // indirect
 )
@@ -0,0 +1,182 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/oshankkumar/sockshop/internal/domain"
+)
+
+// Email is a plain text email to a customer.
+type Email struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends emails.
+type Mailer interface {
+	Send(ctx context.Context, msg Email) error
+}
+
+// AccountService resets forgotten passwords and verifies the emails of
+// customers with single use, expiring tokens mailed to them. Tokens are only
+// stored hashed.
+type AccountService struct {
+	UserStore domain.UserStore
+	Tokens    domain.EmailTokenStore
+	Sessions  domain.SessionStore
+	Hasher    PasswordHasher
+	Mailer    Mailer
+	Domain    string
+	ResetTTL  time.Duration
+	VerifyTTL time.Duration
+	Clock     Clock
+}
+
+// RequestPasswordReset mails a password reset token to the customer with the
+// email. Unknown emails are ignored, so that the response does not tell
+// whether an email is registered.
+func (a *AccountService) RequestPasswordReset(ctx context.Context, email string) error {
+	user, err := a.UserStore.GetUserByEmail(ctx, email)
+
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return nil
+	case err != nil:
+		return fmt.Errorf("AccountService.RequestPasswordReset: %w", err)
+	}
+
+	tok, err := a.issueToken(ctx, user, domain.PurposePasswordReset, a.ResetTTL)
+	if err != nil {
+		return fmt.Errorf("AccountService.RequestPasswordReset(customerID=%s): %w", user.ID, err)
+	}
+
+	msg := Email{
+		To:      user.Email,
+		Subject: "Reset your sockshop password",
+		Body: "Someone asked to reset the password of your sockshop account.\n\n" +
+			"Use this token to choose a new password, it expires in " + a.ResetTTL.String() + ":\n\n" +
+			tok + "\n\n" +
+			"If it was not you, ignore this email.\n",
+	}
+
+	if err := a.Mailer.Send(ctx, msg); err != nil {
+		return fmt.Errorf("AccountService.RequestPasswordReset(customerID=%s): %w", user.ID, err)
+	}
+
+	return nil
+}
+
+// ResetPassword sets the password of the customer the reset token was mailed
+// to, and revokes all of its sessions. It fails with domain.ErrInvalid if the
+// token is unknown, expired or already used.
+func (a *AccountService) ResetPassword(ctx context.Context, token, newPassword string) error {
+	tok, err := a.consumeToken(ctx, token, domain.PurposePasswordReset)
+	if err != nil {
+		return fmt.Errorf("AccountService.ResetPassword: %w", err)
+	}
+
+	hash, err := a.Hasher.Hash(newPassword)
+	if err != nil {
+		return fmt.Errorf("AccountService.ResetPassword(customerID=%s): %w", tok.CustomerID, err)
+	}
+
+	if err := a.UserStore.UpdatePassword(ctx, tok.CustomerID, hash); err != nil {
+		return fmt.Errorf("AccountService.ResetPassword(customerID=%s): %w", tok.CustomerID, err)
+	}
+
+	if err := a.Sessions.RevokeCustomerSessions(ctx, tok.CustomerID, ""); err != nil {
+		return fmt.Errorf("AccountService.ResetPassword(customerID=%s): %w", tok.CustomerID, err)
+	}
+
+	return nil
+}
+
+// SendVerification mails a token verifying the email of the customer, unless
+// it is already verified.
+func (a *AccountService) SendVerification(ctx context.Context, customerID string) error {
+	user, err := a.UserStore.GetUser(ctx, customerID)
+	if err != nil {
+		return fmt.Errorf("AccountService.SendVerification(customerID=%s): %w", customerID, err)
+	}
+
+	if user.EmailVerified {
+		return nil
+	}
+
+	tok, err := a.issueToken(ctx, user, domain.PurposeVerifyEmail, a.VerifyTTL)
+	if err != nil {
+		return fmt.Errorf("AccountService.SendVerification(customerID=%s): %w", customerID, err)
+	}
+
+	msg := Email{
+		To:      user.Email,
+		Subject: "Verify your sockshop email",
+		Body: "Open this link to verify the email of your sockshop account, it expires in " + a.VerifyTTL.String() + ":\n\n" +
+			fmt.Sprintf("http://%v/verify-email?token=%v", a.Domain, url.QueryEscape(tok)) + "\n",
+	}
+
+	if err := a.Mailer.Send(ctx, msg); err != nil {
+		return fmt.Errorf("AccountService.SendVerification(customerID=%s): %w", customerID, err)
+	}
+
+	return nil
+}
+
+// VerifyEmail marks the email the verification token was mailed to verified.
+// It fails with domain.ErrInvalid if the token is unknown, expired or already
+// used, and with domain.ErrConflict if the customer changed its email since.
+func (a *AccountService) VerifyEmail(ctx context.Context, token string) error {
+	tok, err := a.consumeToken(ctx, token, domain.PurposeVerifyEmail)
+	if err != nil {
+		return fmt.Errorf("AccountService.VerifyEmail: %w", err)
+	}
+
+	err = a.UserStore.MarkEmailVerified(ctx, tok.CustomerID, tok.Email)
+	if errors.Is(err, domain.ErrNotFound) {
+		err = fmt.Errorf("%w: the email changed since the token was sent", domain.ErrConflict)
+	}
+	if err != nil {
+		return fmt.Errorf("AccountService.VerifyEmail(customerID=%s): %w", tok.CustomerID, err)
+	}
+
+	return nil
+}
+
+// issueToken stores a new token for the user and returns it.
+func (a *AccountService) issueToken(ctx context.Context, user domain.User, purpose string, ttl time.Duration) (string, error) {
+	tok, hash, err := newOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = a.Tokens.CreateEmailToken(ctx, &domain.EmailToken{
+		Hash:       hash,
+		CustomerID: user.ID.String(),
+		Purpose:    purpose,
+		Email:      user.Email,
+		ExpiresAt:  now(a.Clock).Add(ttl),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return tok, nil
+}
+
+func (a *AccountService) consumeToken(ctx context.Context, token, purpose string) (domain.EmailToken, error) {
+	tok, err := a.Tokens.ConsumeEmailToken(ctx, hashOpaqueToken(token), purpose, now(a.Clock))
+
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return domain.EmailToken{}, fmt.Errorf("%w: token is unknown, expired or used", domain.ErrInvalid)
+	case err != nil:
+		return domain.EmailToken{}, err
+	}
+
+	return tok, nil
+}
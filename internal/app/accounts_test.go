@@ -0,0 +1,328 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/oshankkumar/sockshop/internal/app"
+	"github.com/oshankkumar/sockshop/internal/domain"
+	"github.com/oshankkumar/sockshop/internal/mail"
+	"github.com/oshankkumar/sockshop/internal/password"
+)
+
+type accountTest struct {
+	svc      *app.AccountService
+	users    *userStore
+	sessions *sessionStore
+	mailer   *mail.MemoryMailer
+	now      time.Time
+	alice    domain.User
+	bob      domain.User
+}
+
+func newAccountTest(t *testing.T) *accountTest {
+	t.Helper()
+
+	at := &accountTest{
+		sessions: newSessionStore(),
+		mailer:   mail.NewMemoryMailer(),
+		now:      time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC),
+		alice:    domain.User{ID: uuid.New(), Username: "alice", Email: "alice@example.com", Version: 1},
+		bob:      domain.User{ID: uuid.New(), Username: "bob", Email: "bob@example.com", Version: 1},
+	}
+	at.users = newUserStore(at.alice, at.bob)
+
+	at.svc = &app.AccountService{
+		UserStore: at.users,
+		Tokens:    newEmailTokenStore(),
+		Sessions:  at.sessions,
+		Hasher:    password.Bcrypt{Cost: 4},
+		Mailer:    at.mailer,
+		Domain:    "shop.test",
+		ResetTTL:  time.Hour,
+		VerifyTTL: 48 * time.Hour,
+		Clock:     app.ClockFunc(func() time.Time { return at.now }),
+	}
+	return at
+}
+
+// lastToken returns the token of the last email sent to the address, either
+// on its own line or in a link.
+func (at *accountTest) lastToken(t *testing.T, to string) string {
+	t.Helper()
+
+	sent := at.mailer.Sent()
+	for i := len(sent) - 1; i >= 0; i-- {
+		if sent[i].To != to {
+			continue
+		}
+
+		paragraphs := strings.Split(sent[i].Body, "\n\n")
+		for j := 1; j < len(paragraphs); j++ {
+			if !strings.HasSuffix(paragraphs[j-1], ":") {
+				continue
+			}
+
+			tok := strings.TrimSpace(paragraphs[j])
+			if u, err := url.Parse(tok); err == nil && u.Query().Has("token") {
+				tok = u.Query().Get("token")
+			}
+			return tok
+		}
+		t.Fatalf("no token in the email to %s:\n%s", to, sent[i].Body)
+	}
+
+	t.Fatalf("no email sent to %s", to)
+	return ""
+}
+
+func TestAccountServiceResetPassword(t *testing.T) {
+	at := newAccountTest(t)
+	ctx := context.Background()
+
+	for _, s := range []domain.Session{
+		{CustomerID: at.alice.ID.String()},
+		{CustomerID: at.alice.ID.String()},
+		{CustomerID: at.bob.ID.String()},
+	} {
+		if err := at.sessions.CreateSession(ctx, &s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Unknown emails are silently ignored.
+	if err := at.svc.RequestPasswordReset(ctx, "nobody@example.com"); err != nil {
+		t.Fatalf("RequestPasswordReset of an unknown email: %v", err)
+	}
+	if n := len(at.mailer.Sent()); n != 0 {
+		t.Fatalf("got %d emails, want none", n)
+	}
+
+	if err := at.svc.RequestPasswordReset(ctx, at.alice.Email); err != nil {
+		t.Fatalf("RequestPasswordReset: %v", err)
+	}
+
+	token := at.lastToken(t, at.alice.Email)
+
+	if err := at.svc.ResetPassword(ctx, token, "n3w-passw0rd"); err != nil {
+		t.Fatalf("ResetPassword: %v", err)
+	}
+
+	alice, _ := at.users.GetUser(ctx, at.alice.ID.String())
+	if ok, err := at.svc.Hasher.Verify("n3w-passw0rd", alice.Password); err != nil || !ok {
+		t.Fatalf("new password does not verify: %v", err)
+	}
+
+	if active := at.sessions.active(at.alice.ID.String()); len(active) != 0 {
+		t.Errorf("got %d active sessions of the customer, want none", len(active))
+	}
+	if active := at.sessions.active(at.bob.ID.String()); len(active) != 1 {
+		t.Errorf("got %d active sessions of another customer, want 1", len(active))
+	}
+
+	if err := at.svc.ResetPassword(ctx, token, "an0ther-passw0rd"); !errors.Is(err, domain.ErrInvalid) {
+		t.Fatalf("ResetPassword with a used token: got %v, want %v", err, domain.ErrInvalid)
+	}
+}
+
+func TestAccountServiceResetPasswordInvalidToken(t *testing.T) {
+	tests := []struct {
+		name string
+		// token returns the token to reset the password with.
+		token func(t *testing.T, at *accountTest) string
+	}{
+		{
+			name:  "unknown token",
+			token: func(*testing.T, *accountTest) string { return "unknown" },
+		},
+		{
+			name: "expired token",
+			token: func(t *testing.T, at *accountTest) string {
+				if err := at.svc.RequestPasswordReset(context.Background(), at.alice.Email); err != nil {
+					t.Fatal(err)
+				}
+				at.now = at.now.Add(time.Hour)
+				return at.lastToken(t, at.alice.Email)
+			},
+		},
+		{
+			name: "email verification token",
+			token: func(t *testing.T, at *accountTest) string {
+				if err := at.svc.SendVerification(context.Background(), at.alice.ID.String()); err != nil {
+					t.Fatal(err)
+				}
+				return at.lastToken(t, at.alice.Email)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			at := newAccountTest(t)
+
+			err := at.svc.ResetPassword(context.Background(), tt.token(t, at), "n3w-passw0rd")
+			if !errors.Is(err, domain.ErrInvalid) {
+				t.Fatalf("ResetPassword: got %v, want %v", err, domain.ErrInvalid)
+			}
+
+			alice, _ := at.users.GetUser(context.Background(), at.alice.ID.String())
+			if alice.Password != "" {
+				t.Fatal("password changed")
+			}
+		})
+	}
+}
+
+func TestAccountServiceVerifyEmail(t *testing.T) {
+	tests := []struct {
+		name string
+		// before runs between sending the verification and using its token.
+		before       func(t *testing.T, at *accountTest)
+		wantErr      error
+		wantVerified bool
+	}{
+		{
+			name:         "verifies the email",
+			wantVerified: true,
+		},
+		{
+			name: "just before expiry",
+			before: func(_ *testing.T, at *accountTest) {
+				at.now = at.now.Add(48*time.Hour - time.Second)
+			},
+			wantVerified: true,
+		},
+		{
+			name: "expired token",
+			before: func(_ *testing.T, at *accountTest) {
+				at.now = at.now.Add(48 * time.Hour)
+			},
+			wantErr: domain.ErrInvalid,
+		},
+		{
+			name: "email changed since",
+			before: func(t *testing.T, at *accountTest) {
+				alice, _ := at.users.GetUser(context.Background(), at.alice.ID.String())
+				alice.Email = "alice@example.org"
+				if err := at.users.UpdateUser(context.Background(), &alice); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr: domain.ErrConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			at := newAccountTest(t)
+			ctx := context.Background()
+			id := at.alice.ID.String()
+
+			if err := at.svc.SendVerification(ctx, id); err != nil {
+				t.Fatalf("SendVerification: %v", err)
+			}
+
+			token := at.lastToken(t, at.alice.Email)
+			if tt.before != nil {
+				tt.before(t, at)
+			}
+
+			err := at.svc.VerifyEmail(ctx, token)
+
+			switch {
+			case tt.wantErr == nil && err != nil:
+				t.Fatalf("VerifyEmail: %v", err)
+			case tt.wantErr != nil && !errors.Is(err, tt.wantErr):
+				t.Fatalf("VerifyEmail: got %v, want %v", err, tt.wantErr)
+			}
+
+			alice, _ := at.users.GetUser(ctx, id)
+			if alice.EmailVerified != tt.wantVerified {
+				t.Fatalf("got email verified %v, want %v", alice.EmailVerified, tt.wantVerified)
+			}
+
+			if err := at.svc.VerifyEmail(ctx, token); !errors.Is(err, domain.ErrInvalid) {
+				t.Fatalf("VerifyEmail with a used token: got %v, want %v", err, domain.ErrInvalid)
+			}
+		})
+	}
+}
+
+func TestAccountServiceVerifyChangedEmail(t *testing.T) {
+	at := newAccountTest(t)
+	ctx := context.Background()
+	id := at.alice.ID.String()
+
+	if err := at.svc.SendVerification(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if err := at.svc.VerifyEmail(ctx, at.lastToken(t, at.alice.Email)); err != nil {
+		t.Fatal(err)
+	}
+
+	// A verified email needs no verification.
+	sent := len(at.mailer.Sent())
+	if err := at.svc.SendVerification(ctx, id); err != nil {
+		t.Fatalf("SendVerification of a verified email: %v", err)
+	}
+	if n := len(at.mailer.Sent()); n != sent {
+		t.Fatalf("got %d emails, want %d", n, sent)
+	}
+
+	alice, _ := at.users.GetUser(ctx, id)
+	alice.Email = "alice@example.org"
+	if err := at.users.UpdateUser(ctx, &alice); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := at.svc.SendVerification(ctx, id); err != nil {
+		t.Fatalf("SendVerification of the new email: %v", err)
+	}
+	if err := at.svc.VerifyEmail(ctx, at.lastToken(t, "alice@example.org")); err != nil {
+		t.Fatalf("VerifyEmail of the new email: %v", err)
+	}
+
+	alice, _ = at.users.GetUser(ctx, id)
+	if !alice.EmailVerified || alice.Email != "alice@example.org" {
+		t.Fatalf("got customer %+v, want alice@example.org verified", alice)
+	}
+}
+
+// emailTokenStore keeps the email tokens in memory.
+type emailTokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]domain.EmailToken
+	used   map[string]bool
+}
+
+func newEmailTokenStore() *emailTokenStore {
+	return &emailTokenStore{tokens: make(map[string]domain.EmailToken), used: make(map[string]bool)}
+}
+
+func (s *emailTokenStore) CreateEmailToken(_ context.Context, t *domain.EmailToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens[t.Hash] = *t
+	return nil
+}
+
+func (s *emailTokenStore) ConsumeEmailToken(_ context.Context, hash, purpose string, now time.Time) (domain.EmailToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tokens[hash]
+	if !ok || t.Purpose != purpose || s.used[hash] || !now.Before(t.ExpiresAt) {
+		return domain.EmailToken{}, domain.ErrNotFound
+	}
+
+	s.used[hash] = true
+	return t, nil
+}
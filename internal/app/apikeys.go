@@ -0,0 +1,168 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/oshankkumar/sockshop/api"
+	"github.com/oshankkumar/sockshop/internal/db"
+	"github.com/oshankkumar/sockshop/internal/domain"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// apiKeyPrefix marks the API keys, so that leaked keys are easy to spot in
+// logs and source code.
+const apiKeyPrefix = "ssk_"
+
+// apiKeyPrefixLen is the length of the key prefix kept in clear to identify
+// the keys.
+const apiKeyPrefixLen = len(apiKeyPrefix) + 8
+
+// APIKeyService mints and revokes the API keys services call the API with.
+// Keys are random and only stored hashed; AuthService authenticates them.
+type APIKeyService struct {
+	Keys       domain.APIKeyStore
+	TxBeginner db.TxBeginner
+	Clock      Clock
+}
+
+func (a *APIKeyService) CreateAPIKey(ctx context.Context, createdBy string, req api.CreateAPIKeyRequest) (*api.CreatedAPIKey, error) {
+	scopes, err := checkAPIKeyScopes(req.Scopes)
+	if err != nil {
+		return nil, fmt.Errorf("APIKeyService.CreateAPIKey(%s): %w", req.Name, err)
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(now(a.Clock)) {
+		err := domain.ValidationError{Fields: []domain.FieldError{{Field: "expiresAt", Message: "must be in the future"}}}
+		return nil, fmt.Errorf("APIKeyService.CreateAPIKey(%s): %w", req.Name, err)
+	}
+
+	tok, _, err := newOpaqueToken()
+	if err != nil {
+		return nil, fmt.Errorf("APIKeyService.CreateAPIKey(%s): %w", req.Name, err)
+	}
+
+	key := apiKeyPrefix + tok
+
+	k := &domain.APIKey{
+		Name:      req.Name,
+		Prefix:    key[:apiKeyPrefixLen],
+		Hash:      hashOpaqueToken(key),
+		Scopes:    scopes,
+		CreatedBy: createdBy,
+		ExpiresAt: req.ExpiresAt,
+	}
+
+	err = db.RunInTransaction(ctx, a.TxBeginner, func(ctx context.Context, tx *sqlx.Tx) error {
+		return a.Keys.WithTx(tx).CreateAPIKey(ctx, k)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("APIKeyService.CreateAPIKey(%s): %w", req.Name, err)
+	}
+
+	return &api.CreatedAPIKey{APIKey: toAPIKey(*k), Key: key}, nil
+}
+
+func (a *APIKeyService) ListAPIKeys(ctx context.Context) (*api.ListAPIKeysResponse, error) {
+	keys, err := a.Keys.ListAPIKeys(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("APIKeyService.ListAPIKeys: %w", err)
+	}
+
+	resp := &api.ListAPIKeysResponse{Keys: make([]api.APIKey, 0, len(keys))}
+	for _, k := range keys {
+		resp.Keys = append(resp.Keys, toAPIKey(k))
+	}
+
+	return resp, nil
+}
+
+func (a *APIKeyService) SetAPIKeyScopes(ctx context.Context, id string, scopes []string) (*api.APIKey, error) {
+	scopes, err := checkAPIKeyScopes(scopes)
+	if err != nil {
+		return nil, fmt.Errorf("APIKeyService.SetAPIKeyScopes(%s): %w", id, err)
+	}
+
+	var key domain.APIKey
+
+	err = db.RunInTransaction(ctx, a.TxBeginner, func(ctx context.Context, tx *sqlx.Tx) error {
+		keys := a.Keys.WithTx(tx)
+
+		if err := keys.ReplaceAPIKeyScopes(ctx, id, scopes); err != nil {
+			return err
+		}
+
+		var err error
+		key, err = keys.GetAPIKey(ctx, id)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("APIKeyService.SetAPIKeyScopes(%s): %w", id, err)
+	}
+
+	k := toAPIKey(key)
+	return &k, nil
+}
+
+func (a *APIKeyService) RevokeAPIKey(ctx context.Context, id string) error {
+	if err := a.Keys.RevokeAPIKey(ctx, id); err != nil {
+		return fmt.Errorf("APIKeyService.RevokeAPIKey(%s): %w", id, err)
+	}
+
+	return nil
+}
+
+// checkAPIKeyScopes returns the scopes without duplicates, failing with a
+// domain.ValidationError on the scopes keys may not be granted.
+func checkAPIKeyScopes(scopes []string) ([]string, error) {
+	grantable := make(map[string]bool, len(domain.APIKeyScopes))
+	for _, s := range domain.APIKeyScopes {
+		grantable[s] = true
+	}
+
+	seen := make(map[string]bool)
+
+	var (
+		result []string
+		fields []domain.FieldError
+	)
+
+	for _, s := range scopes {
+		switch {
+		case !grantable[s]:
+			fields = append(fields, domain.FieldError{Field: "scopes", Message: fmt.Sprintf("unknown scope %q", s)})
+		case !seen[s]:
+			seen[s] = true
+			result = append(result, s)
+		}
+	}
+
+	if len(fields) > 0 {
+		return nil, domain.ValidationError{Fields: fields}
+	}
+
+	if len(result) == 0 {
+		return nil, domain.ValidationError{Fields: []domain.FieldError{{Field: "scopes", Message: "is required"}}}
+	}
+
+	return result, nil
+}
+
+func toAPIKey(k domain.APIKey) api.APIKey {
+	scopes := k.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+
+	return api.APIKey{
+		ID:        k.ID.String(),
+		Name:      k.Name,
+		Prefix:    k.Prefix,
+		Scopes:    scopes,
+		CreatedBy: k.CreatedBy,
+		CreatedAt: k.CreatedAt,
+		ExpiresAt: k.ExpiresAt,
+		RevokedAt: k.RevokedAt,
+	}
+}
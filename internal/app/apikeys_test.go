@@ -0,0 +1,299 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/oshankkumar/sockshop/api"
+	"github.com/oshankkumar/sockshop/internal/app"
+	"github.com/oshankkumar/sockshop/internal/db"
+	"github.com/oshankkumar/sockshop/internal/domain"
+)
+
+type apiKeyTest struct {
+	keys  *apiKeyStore
+	svc   *app.APIKeyService
+	authn *app.AuthService
+	now   time.Time
+}
+
+func newAPIKeyTest(t *testing.T) *apiKeyTest {
+	t.Helper()
+
+	at := &apiKeyTest{keys: &apiKeyStore{}, now: time.Now()}
+
+	at.svc = &app.APIKeyService{
+		Keys:       at.keys,
+		TxBeginner: newNoopTxBeginner(t),
+		Clock:      app.ClockFunc(func() time.Time { return at.now }),
+	}
+	at.authn = &app.AuthService{APIKeys: at.keys}
+	return at
+}
+
+func (at *apiKeyTest) create(t *testing.T, req api.CreateAPIKeyRequest) *api.CreatedAPIKey {
+	t.Helper()
+
+	k, err := at.svc.CreateAPIKey(context.Background(), "admin", req)
+	if err != nil {
+		t.Fatalf("CreateAPIKey: %v", err)
+	}
+	return k
+}
+
+func TestAPIKeyServiceCreateAPIKey(t *testing.T) {
+	at := newAPIKeyTest(t)
+
+	k := at.create(t, api.CreateAPIKeyRequest{
+		Name:   "warehouse",
+		Scopes: []string{domain.PermOrdersManage, domain.PermCatalogueWrite, domain.PermOrdersManage},
+	})
+
+	if !strings.HasPrefix(k.Key, "ssk_") || !strings.HasPrefix(k.Key, k.Prefix) || len(k.Prefix) >= len(k.Key) {
+		t.Fatalf("got key %q with prefix %q, want an ssk_ key starting with its prefix", k.Key, k.Prefix)
+	}
+	if want := []string{domain.PermOrdersManage, domain.PermCatalogueWrite}; !reflect.DeepEqual(k.Scopes, want) {
+		t.Fatalf("got scopes %v, want %v", k.Scopes, want)
+	}
+	if k.CreatedBy != "admin" {
+		t.Fatalf("got created by %q, want admin", k.CreatedBy)
+	}
+
+	// Only the hash of the key is stored.
+	stored, err := at.keys.GetAPIKey(context.Background(), k.ID)
+	if err != nil {
+		t.Fatalf("GetAPIKey: %v", err)
+	}
+	if stored.Hash == "" || strings.Contains(stored.Hash, k.Key) || strings.Contains(k.Key, stored.Hash) {
+		t.Fatalf("got stored hash %q, want the hash of the key", stored.Hash)
+	}
+
+	other := at.create(t, api.CreateAPIKeyRequest{Name: "billing", Scopes: []string{domain.PermCustomersRead}})
+	if other.Key == k.Key || other.Prefix == k.Prefix {
+		t.Fatalf("got the key %q twice, want random keys", k.Key)
+	}
+}
+
+func TestAPIKeyServiceCreateAPIKeyInvalid(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+
+	tests := []struct {
+		name string
+		req  api.CreateAPIKeyRequest
+		want string
+	}{
+		{name: "no scopes", req: api.CreateAPIKeyRequest{Name: "ci"}, want: "scopes"},
+		{name: "unknown scope", req: api.CreateAPIKeyRequest{Name: "ci", Scopes: []string{"orders:delete"}}, want: "scopes"},
+		{name: "apikeys scope", req: api.CreateAPIKeyRequest{Name: "ci", Scopes: []string{domain.PermAPIKeysManage}}, want: "scopes"},
+		{name: "expired", req: api.CreateAPIKeyRequest{Name: "ci", Scopes: []string{domain.PermOrdersManage}, ExpiresAt: &past}, want: "expiresAt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			at := newAPIKeyTest(t)
+
+			_, err := at.svc.CreateAPIKey(context.Background(), "admin", tt.req)
+
+			var verr domain.ValidationError
+			if !errors.As(err, &verr) || len(verr.Fields) != 1 || verr.Fields[0].Field != tt.want {
+				t.Fatalf("got %v, want a validation error on %s", err, tt.want)
+			}
+			if len(at.keys.keys) != 0 {
+				t.Fatalf("got %d keys stored, want none", len(at.keys.keys))
+			}
+		})
+	}
+}
+
+func TestAuthServiceAuthenticateAPIKey(t *testing.T) {
+	ctx := context.Background()
+	at := newAPIKeyTest(t)
+
+	k := at.create(t, api.CreateAPIKeyRequest{Name: "warehouse", Scopes: []string{domain.PermOrdersManage}})
+
+	p, err := at.authn.AuthenticateAPIKey(ctx, k.Key)
+	if err != nil {
+		t.Fatalf("AuthenticateAPIKey: %v", err)
+	}
+	if p.APIKeyID != k.ID || p.Subject != "apikey:"+k.ID {
+		t.Fatalf("got principal %+v, want the key %s", p, k.ID)
+	}
+	if !p.Can(domain.PermOrdersManage) || p.Can(domain.PermCatalogueWrite) {
+		t.Fatalf("got permissions %v, want the scopes of the key", p.Permissions)
+	}
+
+	// The new scopes apply to the next requests.
+	if _, err := at.svc.SetAPIKeyScopes(ctx, k.ID, []string{domain.PermCatalogueWrite}); err != nil {
+		t.Fatalf("SetAPIKeyScopes: %v", err)
+	}
+	if p, err := at.authn.AuthenticateAPIKey(ctx, k.Key); err != nil || p.Can(domain.PermOrdersManage) || !p.Can(domain.PermCatalogueWrite) {
+		t.Fatalf("got %v %v, want the new scopes", p.Permissions, err)
+	}
+}
+
+func TestAuthServiceAuthenticateAPIKeyRejected(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		// key returns the key to authenticate.
+		key func(t *testing.T, at *apiKeyTest) string
+	}{
+		{
+			name: "unknown",
+			key: func(t *testing.T, at *apiKeyTest) string {
+				k := at.create(t, api.CreateAPIKeyRequest{Name: "ci", Scopes: []string{domain.PermOrdersManage}})
+				return k.Key + "x"
+			},
+		},
+		{
+			name: "not an API key",
+			key: func(t *testing.T, at *apiKeyTest) string {
+				k := at.create(t, api.CreateAPIKeyRequest{Name: "ci", Scopes: []string{domain.PermOrdersManage}})
+				return strings.TrimPrefix(k.Key, "ssk_")
+			},
+		},
+		{
+			name: "revoked",
+			key: func(t *testing.T, at *apiKeyTest) string {
+				k := at.create(t, api.CreateAPIKeyRequest{Name: "ci", Scopes: []string{domain.PermOrdersManage}})
+				if err := at.svc.RevokeAPIKey(ctx, k.ID); err != nil {
+					t.Fatalf("RevokeAPIKey: %v", err)
+				}
+				return k.Key
+			},
+		},
+		{
+			name: "expired",
+			key: func(t *testing.T, at *apiKeyTest) string {
+				at.now = time.Now().Add(-2 * time.Hour)
+				expiresAt := at.now.Add(time.Hour)
+				k := at.create(t, api.CreateAPIKeyRequest{Name: "ci", Scopes: []string{domain.PermOrdersManage}, ExpiresAt: &expiresAt})
+				return k.Key
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			at := newAPIKeyTest(t)
+			key := tt.key(t, at)
+
+			if _, err := at.authn.AuthenticateAPIKey(ctx, key); !errors.Is(err, api.ErrUnauthorized) {
+				t.Fatalf("got %v, want %v", err, api.ErrUnauthorized)
+			}
+		})
+	}
+}
+
+func TestAuthServiceAuthenticateAPIKeyDisabled(t *testing.T) {
+	at := newAPIKeyTest(t)
+	k := at.create(t, api.CreateAPIKeyRequest{Name: "ci", Scopes: []string{domain.PermOrdersManage}})
+
+	authn := &app.AuthService{}
+	if _, err := authn.AuthenticateAPIKey(context.Background(), k.Key); !errors.Is(err, api.ErrUnauthorized) {
+		t.Fatalf("got %v, want %v", err, api.ErrUnauthorized)
+	}
+}
+
+func TestAPIKeyServiceRevokeAPIKey(t *testing.T) {
+	ctx := context.Background()
+	at := newAPIKeyTest(t)
+
+	k := at.create(t, api.CreateAPIKeyRequest{Name: "ci", Scopes: []string{domain.PermOrdersManage}})
+
+	if err := at.svc.RevokeAPIKey(ctx, k.ID); err != nil {
+		t.Fatalf("RevokeAPIKey: %v", err)
+	}
+	if err := at.svc.RevokeAPIKey(ctx, k.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("got %v revoking twice, want %v", err, domain.ErrNotFound)
+	}
+	if _, err := at.svc.SetAPIKeyScopes(ctx, k.ID, []string{domain.PermCatalogueWrite}); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("got %v setting the scopes of a revoked key, want %v", err, domain.ErrNotFound)
+	}
+
+	resp, err := at.svc.ListAPIKeys(ctx)
+	if err != nil {
+		t.Fatalf("ListAPIKeys: %v", err)
+	}
+	if len(resp.Keys) != 1 || resp.Keys[0].RevokedAt == nil {
+		t.Fatalf("got keys %+v, want the revoked key listed", resp.Keys)
+	}
+}
+
+// apiKeyStore keeps the API keys in memory.
+type apiKeyStore struct {
+	mu   sync.Mutex
+	keys []domain.APIKey
+}
+
+func (s *apiKeyStore) WithTx(db.DB) domain.APIKeyStore { return s }
+
+func (s *apiKeyStore) CreateAPIKey(_ context.Context, k *domain.APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k.ID = uuid.New()
+	k.CreatedAt = time.Now().UTC()
+	s.keys = append(s.keys, *k)
+	return nil
+}
+
+func (s *apiKeyStore) find(match func(domain.APIKey) bool) (domain.APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, k := range s.keys {
+		if match(k) {
+			return k, nil
+		}
+	}
+	return domain.APIKey{}, domain.ErrNotFound
+}
+
+func (s *apiKeyStore) GetAPIKey(_ context.Context, id string) (domain.APIKey, error) {
+	return s.find(func(k domain.APIKey) bool { return k.ID.String() == id })
+}
+
+func (s *apiKeyStore) GetAPIKeyByHash(_ context.Context, hash string) (domain.APIKey, error) {
+	return s.find(func(k domain.APIKey) bool { return k.Hash == hash })
+}
+
+func (s *apiKeyStore) ListAPIKeys(context.Context) ([]domain.APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]domain.APIKey(nil), s.keys...), nil
+}
+
+// update applies f to the key with the id, unless it is revoked.
+func (s *apiKeyStore) update(id string, f func(k *domain.APIKey)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.keys {
+		if s.keys[i].ID.String() == id && s.keys[i].RevokedAt == nil {
+			f(&s.keys[i])
+			return nil
+		}
+	}
+	return domain.ErrNotFound
+}
+
+func (s *apiKeyStore) ReplaceAPIKeyScopes(_ context.Context, id string, scopes []string) error {
+	return s.update(id, func(k *domain.APIKey) { k.Scopes = scopes })
+}
+
+func (s *apiKeyStore) RevokeAPIKey(_ context.Context, id string) error {
+	return s.update(id, func(k *domain.APIKey) {
+		now := time.Now().UTC()
+		k.RevokedAt = &now
+	})
+}
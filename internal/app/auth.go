@@ -0,0 +1,215 @@
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/oshankkumar/sockshop/api"
+	"github.com/oshankkumar/sockshop/api/httpkit"
+	"github.com/oshankkumar/sockshop/internal/domain"
+	"github.com/oshankkumar/sockshop/internal/token"
+
+	"github.com/google/uuid"
+)
+
+// AuthService issues short lived JWT access tokens and long lived opaque
+// refresh tokens. Refresh tokens are only stored hashed.
+type AuthService struct {
+	Sessions   domain.SessionStore
+	UserStore  domain.UserStoreReader
+	Tokens     *token.Codec
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+	// APIKeys authenticates the API keys of services, none are accepted
+	// when nil.
+	APIKeys domain.APIKeyStore
+}
+
+func (a *AuthService) IssueTokens(ctx context.Context, customerID string) (*api.TokenResponse, error) {
+	refresh, hash, err := newOpaqueToken()
+	if err != nil {
+		return nil, fmt.Errorf("AuthService.IssueTokens(customerID=%s): %w", customerID, err)
+	}
+
+	sess := &domain.Session{
+		CustomerID:  customerID,
+		RefreshHash: hash,
+		ExpiresAt:   time.Now().Add(a.RefreshTTL),
+	}
+
+	if err := a.Sessions.CreateSession(ctx, sess); err != nil {
+		return nil, fmt.Errorf("AuthService.IssueTokens(customerID=%s): %w", customerID, err)
+	}
+
+	resp, err := a.tokenResponse(ctx, *sess, refresh)
+	if err != nil {
+		return nil, fmt.Errorf("AuthService.IssueTokens(customerID=%s): %w", customerID, err)
+	}
+
+	return resp, nil
+}
+
+func (a *AuthService) RefreshTokens(ctx context.Context, refreshToken string) (*api.TokenResponse, error) {
+	oldHash := hashOpaqueToken(refreshToken)
+
+	sess, err := a.Sessions.GetSessionByRefreshHash(ctx, oldHash)
+
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return nil, fmt.Errorf("AuthService.RefreshTokens: %w", api.ErrUnauthorized)
+	case err != nil:
+		return nil, fmt.Errorf("AuthService.RefreshTokens: %w", err)
+	case sess.RevokedAt != nil || time.Now().After(sess.ExpiresAt):
+		return nil, fmt.Errorf("AuthService.RefreshTokens(session=%s): session ended: %w", sess.ID, api.ErrUnauthorized)
+	}
+
+	refresh, hash, err := newOpaqueToken()
+	if err != nil {
+		return nil, fmt.Errorf("AuthService.RefreshTokens(session=%s): %w", sess.ID, err)
+	}
+
+	sess.RefreshHash = hash
+	sess.ExpiresAt = time.Now().Add(a.RefreshTTL)
+
+	err = a.Sessions.RotateRefresh(ctx, sess.ID.String(), oldHash, hash, sess.ExpiresAt)
+
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		// The token was rotated by a concurrent refresh.
+		return nil, fmt.Errorf("AuthService.RefreshTokens(session=%s): %w", sess.ID, api.ErrUnauthorized)
+	case err != nil:
+		return nil, fmt.Errorf("AuthService.RefreshTokens(session=%s): %w", sess.ID, err)
+	}
+
+	resp, err := a.tokenResponse(ctx, sess, refresh)
+	if err != nil {
+		return nil, fmt.Errorf("AuthService.RefreshTokens(session=%s): %w", sess.ID, err)
+	}
+
+	return resp, nil
+}
+
+// Authenticate verifies the access token and checks its session has neither
+// been revoked nor expired.
+func (a *AuthService) Authenticate(ctx context.Context, accessToken string) (httpkit.Principal, error) {
+	claims, err := a.Tokens.Verify(accessToken, time.Now())
+	if err != nil {
+		return httpkit.Principal{}, fmt.Errorf("AuthService.Authenticate: %w: %v", api.ErrUnauthorized, err)
+	}
+
+	sess, err := a.Sessions.GetSession(ctx, claims.SessionID)
+
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return httpkit.Principal{}, fmt.Errorf("AuthService.Authenticate(session=%s): %w", claims.SessionID, api.ErrUnauthorized)
+	case err != nil:
+		return httpkit.Principal{}, fmt.Errorf("AuthService.Authenticate(session=%s): %w", claims.SessionID, err)
+	case sess.RevokedAt != nil || sess.CustomerID != claims.Subject:
+		return httpkit.Principal{}, fmt.Errorf("AuthService.Authenticate(session=%s): session revoked: %w", claims.SessionID, api.ErrUnauthorized)
+	case time.Now().After(sess.ExpiresAt):
+		return httpkit.Principal{}, fmt.Errorf("AuthService.Authenticate(session=%s): session expired: %w", claims.SessionID, api.ErrUnauthorized)
+	}
+
+	roles := make([]domain.Role, 0, len(claims.Roles))
+	for _, r := range claims.Roles {
+		roles = append(roles, domain.Role(r))
+	}
+
+	return httpkit.Principal{
+		Subject:     claims.Subject,
+		SessionID:   claims.SessionID,
+		Permissions: domain.Permissions(roles),
+	}, nil
+}
+
+// AuthenticateAPIKey checks the API key is known, unexpired and not revoked.
+// Its principal is granted the scopes of the key, and owns no customer.
+func (a *AuthService) AuthenticateAPIKey(ctx context.Context, key string) (httpkit.Principal, error) {
+	if a.APIKeys == nil || !strings.HasPrefix(key, apiKeyPrefix) {
+		return httpkit.Principal{}, fmt.Errorf("AuthService.AuthenticateAPIKey: %w", api.ErrUnauthorized)
+	}
+
+	k, err := a.APIKeys.GetAPIKeyByHash(ctx, hashOpaqueToken(key))
+
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return httpkit.Principal{}, fmt.Errorf("AuthService.AuthenticateAPIKey: %w", api.ErrUnauthorized)
+	case err != nil:
+		return httpkit.Principal{}, fmt.Errorf("AuthService.AuthenticateAPIKey: %w", err)
+	case k.RevokedAt != nil || (k.ExpiresAt != nil && time.Now().After(*k.ExpiresAt)):
+		return httpkit.Principal{}, fmt.Errorf("AuthService.AuthenticateAPIKey(key=%s): key revoked or expired: %w", k.ID, api.ErrUnauthorized)
+	}
+
+	return httpkit.Principal{
+		Subject:     "apikey:" + k.ID.String(),
+		APIKeyID:    k.ID.String(),
+		Permissions: k.Scopes,
+	}, nil
+}
+
+func (a *AuthService) Logout(ctx context.Context, sessionID string) error {
+	if err := a.Sessions.RevokeSession(ctx, sessionID); err != nil {
+		return fmt.Errorf("AuthService.Logout(session=%s): %w", sessionID, err)
+	}
+
+	return nil
+}
+
+// tokenResponse signs an access token carrying the current roles of the
+// session's customer, so that granted roles take effect on the next refresh.
+func (a *AuthService) tokenResponse(ctx context.Context, sess domain.Session, refresh string) (*api.TokenResponse, error) {
+	user, err := a.UserStore.GetUser(ctx, sess.CustomerID)
+	if err != nil {
+		return nil, err
+	}
+
+	roles := make([]string, 0, len(user.Roles))
+	for _, r := range user.Roles {
+		roles = append(roles, string(r))
+	}
+
+	now := time.Now()
+
+	access, err := a.Tokens.Sign(token.Claims{
+		Subject:   sess.CustomerID,
+		ID:        uuid.NewString(),
+		SessionID: sess.ID.String(),
+		Roles:     roles,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(a.AccessTTL).Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &api.TokenResponse{
+		AccessToken:  access,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(a.AccessTTL.Seconds()),
+		RefreshToken: refresh,
+	}, nil
+}
+
+// newOpaqueToken returns a random token, such as a refresh token, and the hash
+// it is stored under.
+func newOpaqueToken() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("generate token: %w", err)
+	}
+
+	tok := base64.RawURLEncoding.EncodeToString(b)
+	return tok, hashOpaqueToken(tok), nil
+}
+
+func hashOpaqueToken(tok string) string {
+	sum := sha256.Sum256([]byte(tok))
+	return hex.EncodeToString(sum[:])
+}
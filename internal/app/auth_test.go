@@ -0,0 +1,63 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/oshankkumar/sockshop/api"
+	"github.com/oshankkumar/sockshop/internal/app"
+	"github.com/oshankkumar/sockshop/internal/domain"
+	"github.com/oshankkumar/sockshop/internal/token"
+)
+
+func TestAuthServiceAuthenticate(t *testing.T) {
+	tests := []struct {
+		name string
+		// change changes the session after the tokens were issued.
+		change  func(sess *domain.Session)
+		wantErr error
+	}{
+		{name: "active", change: func(*domain.Session) {}},
+		{name: "revoked", change: func(sess *domain.Session) { now := time.Now(); sess.RevokedAt = &now }, wantErr: api.ErrUnauthorized},
+		{name: "expired", change: func(sess *domain.Session) { sess.ExpiresAt = time.Now().Add(-time.Second) }, wantErr: api.ErrUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			alice := domain.User{ID: uuid.New(), Username: "alice"}
+			sessions := newSessionStore()
+
+			authn := &app.AuthService{
+				Sessions:   sessions,
+				UserStore:  newUserStore(alice),
+				Tokens:     token.NewHS256([]byte("0123456789abcdef0123456789abcdef"), "sockshop"),
+				AccessTTL:  15 * time.Minute,
+				RefreshTTL: time.Hour,
+			}
+
+			resp, err := authn.IssueTokens(ctx, alice.ID.String())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The session changes while its access token is still valid.
+			for id, sess := range sessions.sessions {
+				tt.change(&sess)
+				sessions.sessions[id] = sess
+			}
+
+			p, err := authn.Authenticate(ctx, resp.AccessToken)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && p.Subject != alice.ID.String() {
+				t.Fatalf("got subject %s, want %s", p.Subject, alice.ID)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	cardutil "github.com/oshankkumar/sockshop/internal/card"
+	"github.com/oshankkumar/sockshop/internal/domain"
+)
+
+// CardTokenizer swaps card numbers for vault tokens.
+type CardTokenizer interface {
+	Tokenize(ctx context.Context, pan string) (string, error)
+}
+
+// CardDetokenizer turns vault tokens back into card numbers, for the callers
+// the vault allows.
+type CardDetokenizer interface {
+	Detokenize(ctx context.Context, caller, token string) (string, error)
+}
+
+// validateCard checks the number and expiry of a new card, and reports every
+// invalid field in a domain.ValidationError.
+func validateCard(number, expires string, now time.Time) error {
+	var fields []domain.FieldError
+
+	if err := cardutil.CheckNumber(number); err != nil {
+		fields = append(fields, domain.FieldError{Field: "longNum", Message: err.Error()})
+	}
+
+	if err := validateExpiry(expires, now); err != nil {
+		fields = append(fields, domain.FieldError{Field: "expires", Message: err.Error()})
+	}
+
+	if len(fields) > 0 {
+		return domain.ValidationError{Fields: fields}
+	}
+
+	return nil
+}
+
+func validateExpiry(expires string, now time.Time) error {
+	exp, err := cardutil.ParseExpiry(expires)
+	if err != nil {
+		return err
+	}
+
+	if exp.Expired(now) {
+		return cardutil.ErrExpired
+	}
+
+	return nil
+}
@@ -0,0 +1,264 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/oshankkumar/sockshop/api"
+	"github.com/oshankkumar/sockshop/internal/db"
+	"github.com/oshankkumar/sockshop/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type CartService struct {
+	CartStore  domain.CartStore
+	SockStore  domain.SockStoreReader
+	TxBeginner db.TxBeginner
+	Domain     string
+	// ReservationStore reserves the stock of the items of the carts for
+	// ReservationTTL, renewed by every change of the cart. Carts reserve
+	// no stock when it is nil.
+	ReservationStore domain.ReservationStore
+	ReservationTTL   time.Duration
+	Clock            Clock
+}
+
+func (c *CartService) NewSessionCart(ctx context.Context) (*api.Cart, error) {
+	sessionID, err := domain.NewSessionCartID()
+	if err != nil {
+		return nil, fmt.Errorf("CartService.NewSessionCart: %w", err)
+	}
+
+	return &api.Cart{
+		CustomerID: sessionID,
+		Items:      []api.CartItem{},
+		Links:      api.NewSessionCartLinks(c.Domain, sessionID),
+	}, nil
+}
+
+func (c *CartService) GetCart(ctx context.Context, customerID string) (*api.Cart, error) {
+	cartM, err := c.CartStore.GetCart(ctx, customerID)
+	if err != nil {
+		return nil, fmt.Errorf("CartService.GetCart(customerID=%s): %w", customerID, err)
+	}
+
+	cart := &api.Cart{
+		CustomerID: customerID,
+		Items:      []api.CartItem{},
+		Links:      api.NewCartLinks(c.Domain, customerID),
+	}
+
+	if domain.IsSessionCartID(customerID) {
+		cart.Links = api.NewSessionCartLinks(c.Domain, customerID)
+	}
+
+	for _, item := range cartM.Items {
+		cart.Items = append(cart.Items, toAPICartItem(item))
+		cart.Total += float64(item.Quantity) * item.UnitPrice
+	}
+
+	return cart, nil
+}
+
+func (c *CartService) DeleteCart(ctx context.Context, customerID string) error {
+	err := db.RunInTransaction(ctx, c.TxBeginner, func(ctx context.Context, tx *sqlx.Tx) error {
+		if err := c.CartStore.WithTx(tx).DeleteCart(ctx, customerID); err != nil {
+			return err
+		}
+
+		return c.reserve(ctx, tx, customerID)
+	})
+
+	if err != nil {
+		return fmt.Errorf("CartService.DeleteCart(customerID=%s): %w", customerID, err)
+	}
+
+	return nil
+}
+
+func (c *CartService) AddItem(ctx context.Context, customerID string, item api.CartItem) (*api.CartItem, error) {
+	if item.Quantity == 0 {
+		item.Quantity = 1
+	}
+
+	if item.Quantity < 0 {
+		return nil, fmt.Errorf("CartService.AddItem(customerID=%s): %w: quantity must be positive", customerID, domain.ErrInvalid)
+	}
+
+	sock, err := c.SockStore.Get(ctx, item.ItemID.String())
+
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return nil, fmt.Errorf("CartService.AddItem(customerID=%s): %w: unknown item %s", customerID, domain.ErrInvalid, item.ItemID)
+	case err != nil:
+		return nil, fmt.Errorf("CartService.AddItem(customerID=%s): %w", customerID, err)
+	}
+
+	itemM := domain.CartItem{
+		CustomerID: customerID,
+		SockID:     sock.ID.String(),
+		Quantity:   item.Quantity,
+		UnitPrice:  sock.Price,
+	}
+
+	var added domain.CartItem
+
+	err = db.RunInTransaction(ctx, c.TxBeginner, func(ctx context.Context, tx *sqlx.Tx) error {
+		cartStore := c.CartStore.WithTx(tx)
+
+		if err := cartStore.AddItem(ctx, itemM); err != nil {
+			return err
+		}
+
+		if added, err = cartStore.GetItem(ctx, customerID, itemM.SockID); err != nil {
+			return err
+		}
+
+		return c.reserve(ctx, tx, customerID)
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("CartService.AddItem(customerID=%s): %w", customerID, err)
+	}
+
+	resp := toAPICartItem(added)
+	return &resp, nil
+}
+
+func (c *CartService) UpdateItem(ctx context.Context, customerID string, item api.CartItem) (*api.CartItem, error) {
+	if item.Quantity <= 0 {
+		return nil, fmt.Errorf("CartService.UpdateItem(customerID=%s): %w: quantity must be positive", customerID, domain.ErrInvalid)
+	}
+
+	var updated domain.CartItem
+
+	err := db.RunInTransaction(ctx, c.TxBeginner, func(ctx context.Context, tx *sqlx.Tx) error {
+		cartStore := c.CartStore.WithTx(tx)
+
+		current, err := cartStore.GetItem(ctx, customerID, item.ItemID.String())
+		if err != nil {
+			return err
+		}
+
+		updated = current
+		updated.Quantity = item.Quantity
+
+		if err := cartStore.UpdateItem(ctx, updated); err != nil {
+			return err
+		}
+
+		return c.reserve(ctx, tx, customerID)
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("CartService.UpdateItem(customerID=%s): %w", customerID, err)
+	}
+
+	resp := toAPICartItem(updated)
+	return &resp, nil
+}
+
+func (c *CartService) DeleteItem(ctx context.Context, customerID, itemID string) error {
+	err := db.RunInTransaction(ctx, c.TxBeginner, func(ctx context.Context, tx *sqlx.Tx) error {
+		if err := c.CartStore.WithTx(tx).DeleteItem(ctx, customerID, itemID); err != nil {
+			return err
+		}
+
+		return c.reserve(ctx, tx, customerID)
+	})
+
+	if err != nil {
+		return fmt.Errorf("CartService.DeleteItem(customerID=%s): %w", customerID, err)
+	}
+
+	return nil
+}
+
+func (c *CartService) MergeCarts(ctx context.Context, customerID, sessionID string) error {
+	if sessionID == "" {
+		return nil
+	}
+
+	// Only anonymous carts may be merged, never the cart of another customer.
+	if !domain.IsSessionCartID(sessionID) {
+		return fmt.Errorf("CartService.MergeCarts(customerID=%s): %w: not a session cart", customerID, domain.ErrInvalid)
+	}
+
+	err := db.RunInTransaction(ctx, c.TxBeginner, func(ctx context.Context, tx *sqlx.Tx) error {
+		cartStore := c.CartStore.WithTx(tx)
+
+		sessionCart, err := cartStore.GetCart(ctx, sessionID)
+		if err != nil {
+			return err
+		}
+
+		for _, item := range sessionCart.Items {
+			item.CustomerID = customerID
+			if err := cartStore.AddItem(ctx, item); err != nil {
+				return err
+			}
+		}
+
+		if err := cartStore.DeleteCart(ctx, sessionID); err != nil {
+			return err
+		}
+
+		// The merged items are held by the customer's cart from now on.
+		if err := c.reserve(ctx, tx, sessionID); err != nil {
+			return err
+		}
+
+		return c.reserve(ctx, tx, customerID)
+	})
+
+	if err != nil {
+		return fmt.Errorf("CartService.MergeCarts(customerID=%s): %w", customerID, err)
+	}
+
+	return nil
+}
+
+// reserve replaces the reservations of the cart with ones of its current
+// items, failing with domain.ErrInsufficientStock if any item is short of
+// stock.
+func (c *CartService) reserve(ctx context.Context, tx db.DB, customerID string) error {
+	if c.ReservationStore == nil {
+		return nil
+	}
+
+	store, owner := c.ReservationStore.WithTx(tx), domain.CartReservationOwner(customerID)
+
+	if err := store.Release(ctx, owner); err != nil {
+		return err
+	}
+
+	cart, err := c.CartStore.WithTx(tx).GetCart(ctx, customerID)
+	if err != nil {
+		return err
+	}
+
+	expiresAt := now(c.Clock).Add(c.ReservationTTL)
+
+	for _, item := range cart.Items {
+		err := store.Reserve(ctx, &domain.Reservation{
+			SockID:    item.SockID,
+			Owner:     owner,
+			Quantity:  item.Quantity,
+			ExpiresAt: expiresAt,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func toAPICartItem(item domain.CartItem) api.CartItem {
+	id, _ := uuid.Parse(item.SockID)
+	return api.CartItem{ItemID: id, Quantity: item.Quantity, UnitPrice: item.UnitPrice}
+}
@@ -0,0 +1,531 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/oshankkumar/sockshop/api"
+	"github.com/oshankkumar/sockshop/internal/app"
+	"github.com/oshankkumar/sockshop/internal/db"
+	"github.com/oshankkumar/sockshop/internal/domain"
+)
+
+type cartTest struct {
+	svc          *app.CartService
+	reservations *reservationStore
+	sock         domain.Sock
+	now          time.Time
+}
+
+func newCartTest(t *testing.T) *cartTest {
+	t.Helper()
+
+	ct := &cartTest{
+		sock: domain.Sock{ID: uuid.New(), Name: "crew", Price: 10, Count: 5},
+		now:  time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	socks := newSockStore(ct.sock)
+	ct.reservations = newReservationStore(socks, func() time.Time { return ct.now })
+
+	ct.svc = &app.CartService{
+		CartStore:        newCartStore(),
+		SockStore:        socks,
+		TxBeginner:       newNoopTxBeginner(t),
+		Domain:           "shop.test",
+		ReservationStore: ct.reservations,
+		ReservationTTL:   30 * time.Minute,
+		Clock:            app.ClockFunc(func() time.Time { return ct.now }),
+	}
+	return ct
+}
+
+func (ct *cartTest) add(customerID string, quantity int) error {
+	_, err := ct.svc.AddItem(context.Background(), customerID, api.CartItem{ItemID: ct.sock.ID, Quantity: quantity})
+	return err
+}
+
+// reserved returns the units of the sock reserved by the cart.
+func (ct *cartTest) reserved(customerID string) int {
+	return ct.reservations.reserved(domain.CartReservationOwner(customerID), ct.sock.ID.String())
+}
+
+// quantity returns the units of the sock in the cart.
+func (ct *cartTest) quantity(customerID string) int {
+	cart, err := ct.svc.GetCart(context.Background(), customerID)
+	if err != nil {
+		return 0
+	}
+
+	n := 0
+	for _, item := range cart.Items {
+		n += item.Quantity
+	}
+	return n
+}
+
+func TestCartServiceAddItem(t *testing.T) {
+	ct := newCartTest(t)
+	ctx := context.Background()
+
+	// A zero quantity adds one unit, and adding a sock again sums up.
+	if err := ct.add("alice", 0); err != nil {
+		t.Fatalf("AddItem: %v", err)
+	}
+	if err := ct.add("alice", 2); err != nil {
+		t.Fatalf("second AddItem: %v", err)
+	}
+
+	cart, err := ct.svc.GetCart(ctx, "alice")
+	if err != nil {
+		t.Fatalf("GetCart: %v", err)
+	}
+	want := []api.CartItem{{ItemID: ct.sock.ID, Quantity: 3, UnitPrice: 10}}
+	if !reflect.DeepEqual(cart.Items, want) || cart.Total != 30 {
+		t.Fatalf("got items %+v totalling %v, want %+v totalling 30", cart.Items, cart.Total, want)
+	}
+
+	if err := ct.add("alice", -1); !errors.Is(err, domain.ErrInvalid) {
+		t.Fatalf("AddItem of a negative quantity: got %v, want %v", err, domain.ErrInvalid)
+	}
+
+	_, err = ct.svc.AddItem(ctx, "alice", api.CartItem{ItemID: uuid.New(), Quantity: 1})
+	if !errors.Is(err, domain.ErrInvalid) {
+		t.Fatalf("AddItem of an unknown sock: got %v, want %v", err, domain.ErrInvalid)
+	}
+}
+
+func TestCartServiceUpdateItem(t *testing.T) {
+	ct := newCartTest(t)
+	ctx := context.Background()
+
+	if err := ct.add("alice", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		customer string
+		quantity int
+		wantErr  error
+	}{
+		{name: "quantity", customer: "alice", quantity: 2},
+		{name: "zero quantity", customer: "alice", quantity: 0, wantErr: domain.ErrInvalid},
+		{name: "not in the cart", customer: "bob", quantity: 1, wantErr: domain.ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ct.svc.UpdateItem(ctx, tt.customer, api.CartItem{ItemID: ct.sock.ID, Quantity: tt.quantity})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && got.Quantity != tt.quantity {
+				t.Fatalf("got quantity %d, want %d", got.Quantity, tt.quantity)
+			}
+		})
+	}
+}
+
+func TestCartServiceMergeCarts(t *testing.T) {
+	ct := newCartTest(t)
+	ctx := context.Background()
+
+	session, err := ct.svc.NewSessionCart(ctx)
+	if err != nil {
+		t.Fatalf("NewSessionCart: %v", err)
+	}
+	if !domain.IsSessionCartID(session.CustomerID) {
+		t.Fatalf("got session cart %q, want a session cart ID", session.CustomerID)
+	}
+
+	if err := ct.add(session.CustomerID, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := ct.add("alice", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ct.svc.MergeCarts(ctx, "alice", session.CustomerID); err != nil {
+		t.Fatalf("MergeCarts: %v", err)
+	}
+
+	if got := ct.quantity(session.CustomerID); got != 0 {
+		t.Fatalf("got %d units left in the session cart, want none", got)
+	}
+	if got := ct.quantity("alice"); got != 3 {
+		t.Fatalf("got %d units in the customer cart, want 3", got)
+	}
+}
+
+func TestCartServiceMergeCartsRejected(t *testing.T) {
+	tests := []struct {
+		name      string
+		sessionID string
+		wantErr   error
+	}{
+		{name: "no session"},
+		{name: "cart of another customer", sessionID: "bob", wantErr: domain.ErrInvalid},
+		{name: "customer's own cart", sessionID: "alice", wantErr: domain.ErrInvalid},
+		{name: "malformed session", sessionID: domain.SessionCartPrefix + "bob", wantErr: domain.ErrInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ct := newCartTest(t)
+			ctx := context.Background()
+
+			if err := ct.add("alice", 1); err != nil {
+				t.Fatal(err)
+			}
+			if err := ct.add("bob", 2); err != nil {
+				t.Fatal(err)
+			}
+			if err := ct.add(domain.SessionCartPrefix+"bob", 1); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := ct.svc.MergeCarts(ctx, "alice", tt.sessionID); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got %v, want %v", err, tt.wantErr)
+			}
+
+			// No cart changed.
+			if got := ct.quantity("alice"); got != 1 {
+				t.Fatalf("got %d units in the customer cart, want 1", got)
+			}
+			if got := ct.quantity("bob"); got != 2 {
+				t.Fatalf("got %d units in the cart of bob, want 2", got)
+			}
+		})
+	}
+}
+
+func TestCartServiceReservesStock(t *testing.T) {
+	ct := newCartTest(t)
+	ctx := context.Background()
+
+	if err := ct.add("alice", 2); err != nil {
+		t.Fatalf("AddItem: %v", err)
+	}
+	if err := ct.add("alice", 1); err != nil {
+		t.Fatalf("second AddItem: %v", err)
+	}
+	if n := ct.reserved("alice"); n != 3 {
+		t.Fatalf("got %d units reserved, want 3", n)
+	}
+
+	if err := ct.add("bob", 3); !errors.Is(err, domain.ErrInsufficientStock) {
+		t.Fatalf("AddItem past the stock: got %v, want %v", err, domain.ErrInsufficientStock)
+	}
+
+	if _, err := ct.svc.UpdateItem(ctx, "alice", api.CartItem{ItemID: ct.sock.ID, Quantity: 1}); err != nil {
+		t.Fatalf("UpdateItem: %v", err)
+	}
+	if n := ct.reserved("alice"); n != 1 {
+		t.Fatalf("got %d units reserved after UpdateItem, want 1", n)
+	}
+
+	// The memory transactions do not roll the failed item of bob back, so
+	// carol takes the units given back.
+	if err := ct.add("carol", 4); err != nil {
+		t.Fatalf("AddItem of the units given back: %v", err)
+	}
+
+	if err := ct.svc.DeleteItem(ctx, "carol", ct.sock.ID.String()); err != nil {
+		t.Fatalf("DeleteItem: %v", err)
+	}
+	if n := ct.reserved("carol"); n != 0 {
+		t.Fatalf("got %d units reserved after DeleteItem, want none", n)
+	}
+
+	if err := ct.svc.DeleteCart(ctx, "alice"); err != nil {
+		t.Fatalf("DeleteCart: %v", err)
+	}
+	if n := ct.reserved("alice"); n != 0 {
+		t.Fatalf("got %d units reserved after DeleteCart, want none", n)
+	}
+}
+
+func TestCartServiceReservationExpiry(t *testing.T) {
+	tests := []struct {
+		name string
+		// after is the time from the change of alice's cart to bob's.
+		after   time.Duration
+		wantErr error
+	}{
+		{name: "before the TTL", after: 30*time.Minute - time.Second, wantErr: domain.ErrInsufficientStock},
+		{name: "after the TTL", after: 30 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ct := newCartTest(t)
+
+			if err := ct.add("alice", 5); err != nil {
+				t.Fatal(err)
+			}
+
+			ct.now = ct.now.Add(tt.after)
+
+			if err := ct.add("bob", 1); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("AddItem: got %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCartServiceReservationRenewed(t *testing.T) {
+	ct := newCartTest(t)
+
+	if err := ct.add("alice", 2); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every change holds the whole cart for another TTL.
+	ct.now = ct.now.Add(20 * time.Minute)
+	if err := ct.add("alice", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	ct.now = ct.now.Add(20 * time.Minute)
+	if n := ct.reserved("alice"); n != 3 {
+		t.Fatalf("got %d units reserved, want 3", n)
+	}
+}
+
+func TestCartServiceMergeCartsMovesReservations(t *testing.T) {
+	ct := newCartTest(t)
+	ctx := context.Background()
+
+	session, err := ct.svc.NewSessionCart(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ct.add(session.CustomerID, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := ct.add("alice", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ct.svc.MergeCarts(ctx, "alice", session.CustomerID); err != nil {
+		t.Fatalf("MergeCarts: %v", err)
+	}
+
+	if n := ct.reserved(session.CustomerID); n != 0 {
+		t.Errorf("got %d units reserved by the session cart, want none", n)
+	}
+	if n := ct.reserved("alice"); n != 3 {
+		t.Errorf("got %d units reserved by the customer cart, want 3", n)
+	}
+}
+
+// cartStore keeps the carts in memory.
+type cartStore struct {
+	mu    sync.Mutex
+	carts map[string][]domain.CartItem
+}
+
+func newCartStore() *cartStore {
+	return &cartStore{carts: make(map[string][]domain.CartItem)}
+}
+
+func (s *cartStore) WithTx(db.DB) domain.CartStore { return s }
+
+func (s *cartStore) GetCart(_ context.Context, customerID string) (domain.Cart, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return domain.Cart{CustomerID: customerID, Items: append([]domain.CartItem(nil), s.carts[customerID]...)}, nil
+}
+
+func (s *cartStore) GetItem(_ context.Context, customerID, sockID string) (domain.CartItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, item := range s.carts[customerID] {
+		if item.SockID == sockID {
+			return item, nil
+		}
+	}
+	return domain.CartItem{}, domain.ErrNotFound
+}
+
+func (s *cartStore) AddItem(_ context.Context, item domain.CartItem) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	items := s.carts[item.CustomerID]
+	for i := range items {
+		if items[i].SockID == item.SockID {
+			items[i].Quantity += item.Quantity
+			return nil
+		}
+	}
+
+	s.carts[item.CustomerID] = append(items, item)
+	return nil
+}
+
+func (s *cartStore) UpdateItem(_ context.Context, item domain.CartItem) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	items := s.carts[item.CustomerID]
+	for i := range items {
+		if items[i].SockID == item.SockID {
+			items[i] = item
+			return nil
+		}
+	}
+	return domain.ErrNotFound
+}
+
+func (s *cartStore) DeleteItem(_ context.Context, customerID, sockID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	items := s.carts[customerID]
+	for i := range items {
+		if items[i].SockID == sockID {
+			s.carts[customerID] = append(items[:i], items[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (s *cartStore) DeleteCart(_ context.Context, customerID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.carts, customerID)
+	return nil
+}
+
+// reservationStore keeps the reservations in memory, against the stock of a
+// sockStore.
+type reservationStore struct {
+	socks *sockStore
+	now   func() time.Time
+
+	mu           sync.Mutex
+	reservations []domain.Reservation
+}
+
+func newReservationStore(socks *sockStore, now func() time.Time) *reservationStore {
+	return &reservationStore{socks: socks, now: now}
+}
+
+func (s *reservationStore) WithTx(db.DB) domain.ReservationStore { return s }
+
+func (s *reservationStore) activeLocked(match func(domain.Reservation) bool) []domain.Reservation {
+	var active []domain.Reservation
+	for _, r := range s.reservations {
+		if r.Status == domain.ReservationStatusActive && r.ExpiresAt.After(s.now()) && match(r) {
+			active = append(active, r)
+		}
+	}
+	return active
+}
+
+func (s *reservationStore) Reserve(ctx context.Context, r *domain.Reservation) error {
+	sock, err := s.socks.Get(ctx, r.SockID)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reserved := 0
+	for _, a := range s.activeLocked(func(a domain.Reservation) bool { return a.SockID == r.SockID }) {
+		reserved += a.Quantity
+	}
+
+	if sock.Count-reserved < r.Quantity {
+		return domain.ErrInsufficientStock
+	}
+
+	r.ID = uuid.New()
+	r.Status = domain.ReservationStatusActive
+	r.CreatedAt = s.now()
+	s.reservations = append(s.reservations, *r)
+	return nil
+}
+
+func (s *reservationStore) GetOwnerReservations(_ context.Context, owner string) ([]domain.Reservation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.activeLocked(func(r domain.Reservation) bool { return r.Owner == owner }), nil
+}
+
+func (s *reservationStore) setStatus(owner string, status domain.ReservationStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, r := range s.reservations {
+		if r.Owner == owner && r.Status == domain.ReservationStatusActive {
+			s.reservations[i].Status = status
+		}
+	}
+}
+
+// Commit takes the units of the owner's reservations which have not expired
+// out of stock, like the MySQL store.
+func (s *reservationStore) Commit(ctx context.Context, owner string) (map[string]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	committed := make(map[string]int)
+	for _, r := range s.activeLocked(func(r domain.Reservation) bool { return r.Owner == owner }) {
+		committed[r.SockID] += r.Quantity
+	}
+
+	for sockID, n := range committed {
+		sock, err := s.socks.Get(ctx, sockID)
+		if err != nil {
+			return nil, err
+		}
+		if sock.Count < n {
+			return nil, domain.ErrInsufficientStock
+		}
+	}
+
+	for i, r := range s.reservations {
+		if r.Owner == owner && r.Status == domain.ReservationStatusActive && r.ExpiresAt.After(s.now()) {
+			s.socks.take(r.SockID, r.Quantity)
+			s.reservations[i].Status = domain.ReservationStatusCommitted
+		}
+	}
+	return committed, nil
+}
+
+func (s *reservationStore) Release(_ context.Context, owner string) error {
+	s.setStatus(owner, domain.ReservationStatusReleased)
+	return nil
+}
+
+func (s *reservationStore) Expire(context.Context, time.Time) (int, error) {
+	return 0, errors.New("reservationStore: Expire not implemented")
+}
+
+// reserved returns the units of the sock reserved by the owner.
+func (s *reservationStore) reserved(owner, sockID string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for _, r := range s.activeLocked(func(r domain.Reservation) bool { return r.Owner == owner && r.SockID == sockID }) {
+		n += r.Quantity
+	}
+	return n
+}
@@ -3,45 +3,471 @@ package app
 import (
 	"context"
 	"fmt"
+	"math"
+	"net/url"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/oshankkumar/sockshop/api"
+	"github.com/oshankkumar/sockshop/internal/cursor"
+	"github.com/oshankkumar/sockshop/internal/db"
 	"github.com/oshankkumar/sockshop/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
 )
 
-func NewCatalogueService(s domain.SockStore) *CatalogueService {
-	return &CatalogueService{sockStore: s}
+func NewCatalogueService(s domain.SockStore, searcher domain.SockSearcher, t db.TxBeginner, cursors *cursor.Codec, linkDomain string) *CatalogueService {
+	return &CatalogueService{sockStore: s, searcher: searcher, txBeginner: t, cursors: cursors, domain: linkDomain}
 }
 
 type CatalogueService struct {
-	sockStore domain.SockStore
+	sockStore  domain.SockStore
+	searcher   domain.SockSearcher
+	txBeginner db.TxBeginner
+	cursors    *cursor.Codec
+	domain     string
+}
+
+// sockCursor is the keyset position carried by the opaque catalogue cursor.
+type sockCursor struct {
+	Sort  domain.SockSortField `json:"s"`
+	Desc  bool                 `json:"d,omitempty"`
+	Value any                  `json:"v,omitempty"`
+	ID    string               `json:"id"`
 }
 
 func (s *CatalogueService) ListSocks(ctx context.Context, req *api.ListSockParams) (*api.ListSockResponse, error) {
-	offset := req.PageSize * (req.PageNum - 1)
+	order, err := parseSockOrder(req.Sort, req.Order)
+	if err != nil {
+		return nil, fmt.Errorf("CatalogueService.ListSocks: %w", err)
+	}
+
+	filter, err := sockFilter(req)
+	if err != nil {
+		return nil, fmt.Errorf("CatalogueService.ListSocks: %w", err)
+	}
+
+	// Fetch one extra sock to find out whether there is a next page.
+	q := domain.SockQuery{
+		SockFilter: filter,
+		Order:      order,
+		Page:       domain.Page{Limit: req.PageSize + 1, Offset: req.PageSize * (req.PageNum - 1)},
+	}
+
+	if req.Cursor != "" {
+		var c sockCursor
+		if err := s.cursors.Decode(req.Cursor, &c); err != nil {
+			return nil, fmt.Errorf("CatalogueService.ListSocks: %w: %v", domain.ErrInvalid, err)
+		}
+
+		if c.Sort != order.Field || c.Desc != order.Desc {
+			return nil, fmt.Errorf("CatalogueService.ListSocks: %w: cursor does not match the sort order", domain.ErrInvalid)
+		}
+
+		q.Page.After = &domain.Key{Value: c.Value, ID: c.ID}
+	}
+
+	socks, err := s.sockStore.List(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("CatalogueService.ListSocks: %w", err)
+	}
+
+	total, err := s.sockStore.Count(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("CatalogueService.ListSocks: %w", err)
+	}
 
-	socks, err := s.sockStore.List(ctx, req.Tags, req.Order, req.PageSize, offset)
+	facets, err := s.sockStore.Facets(ctx, filter)
 	if err != nil {
 		return nil, fmt.Errorf("CatalogueService.ListSocks: %w", err)
 	}
 
+	var nextCursor string
+	if len(socks) > req.PageSize {
+		socks = socks[:req.PageSize]
+		last := socks[len(socks)-1]
+
+		nextCursor, err = s.cursors.Encode(sockCursor{
+			Sort:  order.Field,
+			Desc:  order.Desc,
+			Value: sortValue(last, order.Field),
+			ID:    last.ID.String(),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("CatalogueService.ListSocks: %w", err)
+		}
+	}
+
 	var socksResp []api.Sock
 	for _, s := range socks {
-		var tags []string
-		for _, t := range s.Tags {
-			tags = append(tags, t.Name)
-		}
-
-		socksResp = append(socksResp, api.Sock{
-			ID:          s.ID,
-			Name:        s.Name,
-			Description: s.Description,
-			ImageURL:    strings.Split(s.ImageURLs, ","),
-			Price:       s.Price,
-			Count:       s.Count,
-			Tags:        tags,
-		})
+		socksResp = append(socksResp, toAPISock(s))
+	}
+
+	query := listQuery(req, filter)
+	query.Set("sort", string(order.Field))
+	query.Set("order", req.Order)
+
+	resp := &api.ListSockResponse{
+		Socks:      socksResp,
+		Total:      total,
+		Size:       req.PageSize,
+		NextCursor: nextCursor,
+		Facets:     toAPIFacets(facets),
+	}
+
+	if req.Cursor != "" {
+		query.Set("size", strconv.Itoa(req.PageSize))
+		resp.Links = api.NewCursorLinks(s.domain, "/catalogue", query, req.Cursor, nextCursor)
+	} else {
+		resp.Page = req.PageNum
+		resp.Links = api.NewPageLinks(s.domain, "/catalogue", query, req.PageNum, req.PageSize, total)
+	}
+
+	return resp, nil
+}
+
+func sockFilter(req *api.ListSockParams) (domain.SockFilter, error) {
+	f := domain.SockFilter{
+		Tags:     req.Tags,
+		TagMode:  domain.TagMatchAny,
+		MinPrice: req.MinPrice,
+		MaxPrice: req.MaxPrice,
+		InStock:  req.InStock,
+	}
+
+	switch req.TagMode {
+	case "", string(domain.TagMatchAny):
+	case string(domain.TagMatchAll):
+		f.TagMode = domain.TagMatchAll
+	default:
+		return f, fmt.Errorf("%w: unsupported tag mode %q", domain.ErrInvalid, req.TagMode)
+	}
+
+	switch {
+	case f.MinPrice != nil && !isFinite(*f.MinPrice), f.MaxPrice != nil && !isFinite(*f.MaxPrice):
+		return f, fmt.Errorf("%w: price bounds must be finite numbers", domain.ErrInvalid)
+	case f.MinPrice != nil && *f.MinPrice < 0:
+		return f, fmt.Errorf("%w: minPrice must not be negative", domain.ErrInvalid)
+	case f.MaxPrice != nil && *f.MaxPrice < 0:
+		return f, fmt.Errorf("%w: maxPrice must not be negative", domain.ErrInvalid)
+	case f.MinPrice != nil && f.MaxPrice != nil && *f.MinPrice > *f.MaxPrice:
+		return f, fmt.Errorf("%w: minPrice must not exceed maxPrice", domain.ErrInvalid)
+	}
+
+	return f, nil
+}
+
+func isFinite(f float64) bool {
+	return !math.IsNaN(f) && !math.IsInf(f, 0)
+}
+
+// listQuery returns the filter parameters of req to carry over into links.
+func listQuery(req *api.ListSockParams, f domain.SockFilter) url.Values {
+	query := url.Values{}
+	if len(f.Tags) > 0 {
+		query.Set("tags", strings.Join(f.Tags, ","))
+		query.Set("tagMode", string(f.TagMode))
+	}
+	if f.MinPrice != nil {
+		query.Set("minPrice", strconv.FormatFloat(*f.MinPrice, 'f', -1, 64))
+	}
+	if f.MaxPrice != nil {
+		query.Set("maxPrice", strconv.FormatFloat(*f.MaxPrice, 'f', -1, 64))
+	}
+	if f.InStock {
+		query.Set("inStock", "true")
+	}
+	return query
+}
+
+func toAPIFacets(f domain.SockFacets) api.SockFacets {
+	facets := api.SockFacets{Tags: []api.TagFacet{}, Prices: []api.PriceFacet{}}
+
+	for _, t := range f.Tags {
+		facets.Tags = append(facets.Tags, api.TagFacet{Tag: t.Name, Count: t.Count})
+	}
+
+	for _, p := range f.Prices {
+		facets.Prices = append(facets.Prices, api.PriceFacet{Min: p.Min, Max: p.Max, Count: p.Count})
+	}
+
+	return facets
+}
+
+func (s *CatalogueService) SearchSocks(ctx context.Context, req *api.SearchSockParams) (*api.SearchSockResponse, error) {
+	if strings.TrimSpace(req.Query) == "" {
+		return nil, fmt.Errorf("CatalogueService.SearchSocks: %w: empty query", domain.ErrInvalid)
+	}
+
+	hits, err := s.searcher.Search(ctx, req.Query, req.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("CatalogueService.SearchSocks(q=%s): %w", req.Query, err)
+	}
+
+	if err := s.refreshStock(ctx, hits); err != nil {
+		return nil, fmt.Errorf("CatalogueService.SearchSocks(q=%s): %w", req.Query, err)
+	}
+
+	results := make([]api.SockHit, 0, len(hits))
+	for _, h := range hits {
+		results = append(results, api.SockHit{Sock: toAPISock(h.Sock), Score: h.Score})
+	}
+
+	return &api.SearchSockResponse{Results: results}, nil
+}
+
+// refreshStock reads the stock of the hits of a searcher which maintains its
+// own index from the store. The index is only told about the socks written
+// through the catalogue, not about the units reserved and sold since.
+func (s *CatalogueService) refreshStock(ctx context.Context, hits []domain.SockHit) error {
+	if _, ok := s.searcher.(domain.SockIndexer); !ok {
+		return nil
+	}
+
+	for i, h := range hits {
+		sock, err := s.sockStore.Get(ctx, h.Sock.ID.String())
+		if err != nil {
+			return err
+		}
+
+		hits[i].Sock.Count, hits[i].Sock.Reserved = sock.Count, sock.Reserved
+	}
+
+	return nil
+}
+
+// reindex hands a written sock to the searcher if it maintains its own index.
+func (s *CatalogueService) reindex(ctx context.Context, sock domain.Sock) error {
+	indexer, ok := s.searcher.(domain.SockIndexer)
+	if !ok {
+		return nil
+	}
+
+	return indexer.Index(ctx, sock)
+}
+
+func sortValue(sock domain.Sock, field domain.SockSortField) any {
+	switch field {
+	case domain.SortByName:
+		return sock.Name
+	case domain.SortByPrice:
+		return sock.Price
+	case domain.SortByCount:
+		return sock.Count
+	default:
+		return nil
+	}
+}
+
+// parseSockOrder validates the sort field against domain.SockSortFields and
+// the direction against asc/desc.
+func parseSockOrder(sort, direction string) (domain.SockOrder, error) {
+	var order domain.SockOrder
+
+	for _, f := range domain.SockSortFields {
+		if string(f) == sort {
+			order.Field = f
+		}
+	}
+
+	if order.Field == "" {
+		return order, fmt.Errorf("%w: unsupported sort field %q", domain.ErrInvalid, sort)
+	}
+
+	switch direction {
+	case "asc":
+	case "desc":
+		order.Desc = true
+	default:
+		return order, fmt.Errorf("%w: unsupported sort order %q", domain.ErrInvalid, direction)
+	}
+
+	return order, nil
+}
+
+func (s *CatalogueService) GetSock(ctx context.Context, id string) (*api.Sock, error) {
+	sockM, err := s.sockStore.Get(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("CatalogueService.GetSock(id=%s): %w", id, err)
+	}
+
+	sock := toAPISock(sockM)
+	return &sock, nil
+}
+
+func (s *CatalogueService) CreateSock(ctx context.Context, sock api.Sock) (uuid.UUID, error) {
+	sockM := toDomainSock(sock)
+
+	if err := validateSock(sockM); err != nil {
+		return uuid.UUID{}, fmt.Errorf("CatalogueService.CreateSock(name=%s): %w", sock.Name, err)
+	}
+
+	err := db.RunInTransaction(ctx, s.txBeginner, func(ctx context.Context, tx *sqlx.Tx) error {
+		return s.sockStore.WithTx(tx).Create(ctx, &sockM)
+	})
+
+	if err != nil {
+		return uuid.UUID{}, fmt.Errorf("CatalogueService.CreateSock(name=%s): %w", sock.Name, err)
+	}
+
+	if err := s.reindex(ctx, sockM); err != nil {
+		return uuid.UUID{}, fmt.Errorf("CatalogueService.CreateSock(name=%s): %w", sock.Name, err)
+	}
+
+	return sockM.ID, nil
+}
+
+func (s *CatalogueService) UpdateSock(ctx context.Context, id string, sock api.Sock) (*api.Sock, error) {
+	updated, err := s.updateSock(ctx, id, func(sockM *domain.Sock) {
+		reserved := sockM.Reserved
+		*sockM = toDomainSock(sock)
+		sockM.Reserved = reserved
+	})
+	if err != nil {
+		return nil, fmt.Errorf("CatalogueService.UpdateSock(id=%s): %w", id, err)
+	}
+
+	return updated, nil
+}
+
+func (s *CatalogueService) PatchSock(ctx context.Context, id string, patch api.SockPatch) (*api.Sock, error) {
+	updated, err := s.updateSock(ctx, id, func(sockM *domain.Sock) {
+		if patch.Name != nil {
+			sockM.Name = *patch.Name
+		}
+		if patch.Description != nil {
+			sockM.Description = *patch.Description
+		}
+		if patch.ImageURL != nil {
+			sockM.ImageURLs = strings.Join(*patch.ImageURL, ",")
+		}
+		if patch.Price != nil {
+			sockM.Price = *patch.Price
+		}
+		if patch.Count != nil {
+			sockM.Count = *patch.Count
+		}
+		if patch.Tags != nil {
+			sockM.Tags = toDomainTags(*patch.Tags)
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("CatalogueService.PatchSock(id=%s): %w", id, err)
+	}
+
+	return updated, nil
+}
+
+// updateSock loads and locks the sock, applies mutate to it and writes it
+// back in a single transaction.
+func (s *CatalogueService) updateSock(ctx context.Context, id string, mutate func(sock *domain.Sock)) (*api.Sock, error) {
+	var sockM domain.Sock
+
+	err := db.RunInTransaction(ctx, s.txBeginner, func(ctx context.Context, tx *sqlx.Tx) error {
+		sockStore := s.sockStore.WithTx(tx)
+
+		current, err := sockStore.GetForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		sockM = current
+		mutate(&sockM)
+		sockM.ID = current.ID
+
+		if err := validateSock(sockM); err != nil {
+			return err
+		}
+
+		return sockStore.Update(ctx, sockM)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.reindex(ctx, sockM); err != nil {
+		return nil, err
+	}
+
+	sock := toAPISock(sockM)
+	return &sock, nil
+}
+
+func validateSock(sock domain.Sock) error {
+	switch {
+	case strings.TrimSpace(sock.Name) == "":
+		return fmt.Errorf("%w: name is required", domain.ErrInvalid)
+	case utf8.RuneCountInString(sock.Name) > 20:
+		return fmt.Errorf("%w: name must be at most 20 characters", domain.ErrInvalid)
+	case utf8.RuneCountInString(sock.Description) > 200:
+		return fmt.Errorf("%w: description must be at most 200 characters", domain.ErrInvalid)
+	case utf8.RuneCountInString(sock.ImageURLs) > 100:
+		return fmt.Errorf("%w: imageUrl must be at most 100 characters", domain.ErrInvalid)
+	case sock.Price < 0:
+		return fmt.Errorf("%w: price must not be negative", domain.ErrInvalid)
+	case sock.Count < 0:
+		return fmt.Errorf("%w: count must not be negative", domain.ErrInvalid)
+	}
+
+	for _, t := range sock.Tags {
+		if t.Name == "" || utf8.RuneCountInString(t.Name) > 20 || strings.Contains(t.Name, ",") {
+			return fmt.Errorf("%w: invalid tag %q", domain.ErrInvalid, t.Name)
+		}
+	}
+
+	return nil
+}
+
+func toAPISock(s domain.Sock) api.Sock {
+	var tags []string
+	for _, t := range s.Tags {
+		tags = append(tags, t.Name)
+	}
+
+	imageURLs := []string{}
+	if s.ImageURLs != "" {
+		imageURLs = strings.Split(s.ImageURLs, ",")
+	}
+
+	return api.Sock{
+		ID:          s.ID,
+		Name:        s.Name,
+		Description: s.Description,
+		ImageURL:    imageURLs,
+		Price:       s.Price,
+		Count:       s.Count,
+		Available:   s.Available(),
+		Tags:        tags,
+	}
+}
+
+func toDomainSock(s api.Sock) domain.Sock {
+	return domain.Sock{
+		ID:          s.ID,
+		Name:        s.Name,
+		Description: s.Description,
+		ImageURLs:   strings.Join(s.ImageURL, ","),
+		Price:       s.Price,
+		Count:       s.Count,
+		Tags:        toDomainTags(s.Tags),
+	}
+}
+
+func toDomainTags(names []string) []domain.Tag {
+	seen := make(map[string]bool)
+
+	var tags []domain.Tag
+	for _, n := range names {
+		n = strings.ToLower(strings.TrimSpace(n))
+		if seen[n] {
+			continue
+		}
+		seen[n] = true
+		tags = append(tags, domain.Tag{Name: n})
 	}
 
-	return &api.ListSockResponse{Socks: socksResp}, nil
+	return tags
 }
@@ -0,0 +1,572 @@
+package app_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/oshankkumar/sockshop/api"
+	"github.com/oshankkumar/sockshop/internal/app"
+	"github.com/oshankkumar/sockshop/internal/cursor"
+	"github.com/oshankkumar/sockshop/internal/db"
+	"github.com/oshankkumar/sockshop/internal/domain"
+	"github.com/oshankkumar/sockshop/internal/search"
+)
+
+func intPtr(n int) *int { return &n }
+
+func TestCatalogueServiceUpdateSockCount(t *testing.T) {
+	sock := domain.Sock{ID: uuid.New(), Name: "crew", Price: 10, Count: 10, Reserved: 3}
+
+	tests := []struct {
+		name string
+		// update updates the sock through the service.
+		update        func(svc *app.CatalogueService, id string) (*api.Sock, error)
+		wantCount     int
+		wantAvailable int
+	}{
+		{
+			name: "put",
+			update: func(svc *app.CatalogueService, id string) (*api.Sock, error) {
+				return svc.UpdateSock(context.Background(), id, api.Sock{Name: "crew", Price: 10, Count: 20, Available: 99})
+			},
+			wantCount:     20,
+			wantAvailable: 17,
+		},
+		{
+			name: "patch",
+			update: func(svc *app.CatalogueService, id string) (*api.Sock, error) {
+				return svc.PatchSock(context.Background(), id, api.SockPatch{Count: intPtr(5)})
+			},
+			wantCount:     5,
+			wantAvailable: 2,
+		},
+		{
+			name: "count below the reservations",
+			update: func(svc *app.CatalogueService, id string) (*api.Sock, error) {
+				return svc.PatchSock(context.Background(), id, api.SockPatch{Count: intPtr(1)})
+			},
+			wantCount:     1,
+			wantAvailable: 0,
+		},
+		{
+			name: "other field",
+			update: func(svc *app.CatalogueService, id string) (*api.Sock, error) {
+				price := 12.5
+				return svc.PatchSock(context.Background(), id, api.SockPatch{Price: &price})
+			},
+			wantCount:     10,
+			wantAvailable: 7,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newSockStore(sock)
+			svc := app.NewCatalogueService(store, nil, newNoopTxBeginner(t), nil, "shop.test")
+
+			got, err := tt.update(svc, sock.ID.String())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The count is the stock on hand both ways, what can still be
+			// sold is read back apart.
+			if got.Count != tt.wantCount || got.Available != tt.wantAvailable {
+				t.Fatalf("got count %d, available %d, want %d, %d", got.Count, got.Available, tt.wantCount, tt.wantAvailable)
+			}
+
+			stored, _ := store.Get(context.Background(), sock.ID.String())
+			if stored.Count != tt.wantCount {
+				t.Fatalf("stored count %d, want %d", stored.Count, tt.wantCount)
+			}
+
+			// The sock is locked while it is read and written back.
+			if len(store.locked) != 1 || store.locked[0] != sock.ID.String() {
+				t.Fatalf("got socks read for update %v, want %s", store.locked, sock.ID)
+			}
+		})
+	}
+}
+
+func TestCatalogueServiceGetSock(t *testing.T) {
+	tests := []struct {
+		name          string
+		sock          domain.Sock
+		wantImageURLs []string
+		wantTags      []string
+	}{
+		{
+			name:          "images and tags",
+			sock:          domain.Sock{ID: uuid.New(), Name: "crew", ImageURLs: "/a.jpg,/b.jpg", Count: 10, Reserved: 4, Tags: []domain.Tag{{Name: "blue"}}},
+			wantImageURLs: []string{"/a.jpg", "/b.jpg"},
+			wantTags:      []string{"blue"},
+		},
+		{
+			name:          "no images",
+			sock:          domain.Sock{ID: uuid.New(), Name: "crew", Count: 10, Reserved: 4},
+			wantImageURLs: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := app.NewCatalogueService(newSockStore(tt.sock), nil, newNoopTxBeginner(t), nil, "shop.test")
+
+			got, err := svc.GetSock(context.Background(), tt.sock.ID.String())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The sock reads the same as in the list and update responses.
+			if !reflect.DeepEqual(got.ImageURL, tt.wantImageURLs) {
+				t.Fatalf("got image urls %#v, want %#v", got.ImageURL, tt.wantImageURLs)
+			}
+			if !reflect.DeepEqual(got.Tags, tt.wantTags) {
+				t.Fatalf("got tags %v, want %v", got.Tags, tt.wantTags)
+			}
+			if got.Count != 10 || got.Available != 6 {
+				t.Fatalf("got count %d, available %d, want 10, 6", got.Count, got.Available)
+			}
+		})
+	}
+
+	t.Run("not found", func(t *testing.T) {
+		svc := app.NewCatalogueService(newSockStore(), nil, newNoopTxBeginner(t), nil, "shop.test")
+
+		if _, err := svc.GetSock(context.Background(), uuid.NewString()); !errors.Is(err, domain.ErrNotFound) {
+			t.Fatalf("got %v, want %v", err, domain.ErrNotFound)
+		}
+	})
+}
+
+func TestCatalogueServiceSockRoundTrip(t *testing.T) {
+	sock := domain.Sock{ID: uuid.New(), Name: "crew", Price: 10, Count: 10, Reserved: 3}
+	store := newSockStore(sock)
+	svc := app.NewCatalogueService(store, nil, newNoopTxBeginner(t), nil, "shop.test")
+
+	got, err := svc.GetSock(context.Background(), sock.ID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Writing back a sock as it was read leaves its stock alone.
+	for i := 0; i < 2; i++ {
+		body, err := json.Marshal(got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var req api.Sock
+		if err := json.Unmarshal(body, &req); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err = svc.UpdateSock(context.Background(), sock.ID.String(), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Count != 10 || got.Available != 7 {
+			t.Fatalf("got count %d, available %d after %d round trips, want 10, 7", got.Count, got.Available, i+1)
+		}
+	}
+
+	stored, _ := store.Get(context.Background(), sock.ID.String())
+	if stored.Count != 10 || stored.Reserved != 3 {
+		t.Fatalf("stored count %d, reserved %d, want 10, 3", stored.Count, stored.Reserved)
+	}
+}
+
+func TestCatalogueServiceSearchSocksStock(t *testing.T) {
+	ctx := context.Background()
+	sock := domain.Sock{ID: uuid.New(), Name: "crew", Description: "wool crew socks", Price: 10, Count: 10}
+
+	idx := search.NewIndex()
+	if err := idx.Index(ctx, sock); err != nil {
+		t.Fatal(err)
+	}
+
+	// Units were sold and reserved since the sock was indexed.
+	sold := sock
+	sold.Count, sold.Reserved = 8, 3
+	svc := app.NewCatalogueService(newSockStore(sold), idx, newNoopTxBeginner(t), nil, "shop.test")
+
+	resp, err := svc.SearchSocks(ctx, &api.SearchSockParams{Query: "wool", Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(resp.Results) != 1 {
+		t.Fatalf("got %d results, want 1", len(resp.Results))
+	}
+	if got := resp.Results[0].Sock; got.Count != 8 || got.Available != 5 {
+		t.Fatalf("got count %d, available %d, want 8, 5", got.Count, got.Available)
+	}
+}
+
+func TestCatalogueServicePatchSockNameLength(t *testing.T) {
+	sock := domain.Sock{ID: uuid.New(), Name: "crew", Price: 10, Count: 10}
+
+	tests := []struct {
+		name    string
+		value   string
+		wantErr error
+	}{
+		// The limits are in characters, like those of the request bodies.
+		{name: "multibyte characters at the limit", value: strings.Repeat("é", 20)},
+		{name: "multibyte characters over the limit", value: strings.Repeat("é", 21), wantErr: domain.ErrInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := app.NewCatalogueService(newSockStore(sock), nil, newNoopTxBeginner(t), nil, "shop.test")
+
+			_, err := svc.PatchSock(context.Background(), sock.ID.String(), api.SockPatch{Name: &tt.value})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("PatchSock: got %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCatalogueServiceListSocksOrder(t *testing.T) {
+	tests := []struct {
+		name      string
+		sort      string
+		order     string
+		wantOrder domain.SockOrder
+		wantErr   error
+	}{
+		{name: "id", sort: "id", order: "asc", wantOrder: domain.SockOrder{Field: domain.SortByID}},
+		{name: "price descending", sort: "price", order: "desc", wantOrder: domain.SockOrder{Field: domain.SortByPrice, Desc: true}},
+		{name: "unknown field", sort: "description", order: "asc", wantErr: domain.ErrInvalid},
+		{name: "column", sort: "sock.price", order: "asc", wantErr: domain.ErrInvalid},
+		{name: "unknown direction", sort: "name", order: "up", wantErr: domain.ErrInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newSockStore(domain.Sock{ID: uuid.New(), Name: "crew", Price: 10, Count: 10})
+			svc := app.NewCatalogueService(store, nil, nil, nil, "shop.test")
+
+			_, err := svc.ListSocks(context.Background(), &api.ListSockParams{Sort: tt.sort, Order: tt.order, PageNum: 1, PageSize: 10})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if len(store.queries) != 0 {
+					t.Fatalf("got %d socks queries, want none", len(store.queries))
+				}
+				return
+			}
+
+			if len(store.queries) != 1 || store.queries[0].Order != tt.wantOrder {
+				t.Fatalf("got queries %+v, want the order %+v", store.queries, tt.wantOrder)
+			}
+		})
+	}
+}
+
+func TestCatalogueServiceListSocksPage(t *testing.T) {
+	var socks []domain.Sock
+	for n := 0; n < 7; n++ {
+		socks = append(socks, domain.Sock{ID: uuid.New(), Name: "crew", Price: 10, Count: 10})
+	}
+
+	tests := []struct {
+		name       string
+		page       int
+		wantOffset int
+		wantSocks  int
+		wantLinks  []string
+	}{
+		{name: "first page", page: 1, wantOffset: 0, wantSocks: 3, wantLinks: []string{"next", "self"}},
+		{name: "middle page", page: 2, wantOffset: 3, wantSocks: 3, wantLinks: []string{"next", "prev", "self"}},
+		{name: "last page", page: 3, wantOffset: 6, wantSocks: 1, wantLinks: []string{"prev", "self"}},
+		{name: "past the end", page: 4, wantOffset: 9, wantLinks: []string{"prev", "self"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newSockStore(socks...)
+			svc := app.NewCatalogueService(store, nil, nil, cursor.NewCodec([]byte("secret")), "shop.test")
+
+			resp, err := svc.ListSocks(context.Background(), &api.ListSockParams{Sort: "id", Order: "asc", PageNum: tt.page, PageSize: 3})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// One extra sock is fetched to find out whether there is a next page.
+			if q := store.queries[0]; q.Page.Offset != tt.wantOffset || q.Page.Limit != 4 {
+				t.Fatalf("got offset %d, limit %d, want %d, 4", q.Page.Offset, q.Page.Limit, tt.wantOffset)
+			}
+
+			if len(resp.Socks) != tt.wantSocks || resp.Total != len(socks) || resp.Page != tt.page || resp.Size != 3 {
+				t.Fatalf("got %d of %d socks, page %d of size %d, want %d of %d, page %d of size 3",
+					len(resp.Socks), resp.Total, resp.Page, resp.Size, tt.wantSocks, len(socks), tt.page)
+			}
+
+			if _, next := resp.Links["next"]; next != (resp.NextCursor != "") {
+				t.Fatalf("got next cursor %q with links %v, want one with a next page", resp.NextCursor, resp.Links)
+			}
+
+			var links []string
+			for rel := range resp.Links {
+				links = append(links, rel)
+			}
+			sort.Strings(links)
+			if !reflect.DeepEqual(links, tt.wantLinks) {
+				t.Fatalf("got links %v, want %v", links, tt.wantLinks)
+			}
+		})
+	}
+}
+
+func floatPtr(f float64) *float64 { return &f }
+
+func TestCatalogueServiceListSocksFilter(t *testing.T) {
+	tests := []struct {
+		name       string
+		params     api.ListSockParams
+		wantFilter domain.SockFilter
+		wantQuery  url.Values
+		wantErr    error
+	}{
+		{
+			name:       "no filter",
+			wantFilter: domain.SockFilter{TagMode: domain.TagMatchAny},
+			wantQuery:  url.Values{},
+		},
+		{
+			name:       "tags match any by default",
+			params:     api.ListSockParams{Tags: []string{"blue", "wool"}},
+			wantFilter: domain.SockFilter{Tags: []string{"blue", "wool"}, TagMode: domain.TagMatchAny},
+			wantQuery:  url.Values{"tags": {"blue,wool"}, "tagMode": {"any"}},
+		},
+		{
+			name:       "tags match all",
+			params:     api.ListSockParams{Tags: []string{"blue", "wool"}, TagMode: "all"},
+			wantFilter: domain.SockFilter{Tags: []string{"blue", "wool"}, TagMode: domain.TagMatchAll},
+			wantQuery:  url.Values{"tags": {"blue,wool"}, "tagMode": {"all"}},
+		},
+		{
+			name:       "price range and stock",
+			params:     api.ListSockParams{MinPrice: floatPtr(10), MaxPrice: floatPtr(12.5), InStock: true},
+			wantFilter: domain.SockFilter{TagMode: domain.TagMatchAny, MinPrice: floatPtr(10), MaxPrice: floatPtr(12.5), InStock: true},
+			wantQuery:  url.Values{"minPrice": {"10"}, "maxPrice": {"12.5"}, "inStock": {"true"}},
+		},
+		{name: "unknown tag mode", params: api.ListSockParams{Tags: []string{"blue"}, TagMode: "some"}, wantErr: domain.ErrInvalid},
+		{name: "negative price", params: api.ListSockParams{MinPrice: floatPtr(-1)}, wantErr: domain.ErrInvalid},
+		{name: "NaN price", params: api.ListSockParams{MinPrice: floatPtr(math.NaN())}, wantErr: domain.ErrInvalid},
+		{name: "infinite price", params: api.ListSockParams{MaxPrice: floatPtr(math.Inf(1))}, wantErr: domain.ErrInvalid},
+		{name: "empty price range", params: api.ListSockParams{MinPrice: floatPtr(20), MaxPrice: floatPtr(10)}, wantErr: domain.ErrInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newSockStore(domain.Sock{ID: uuid.New(), Name: "crew", Price: 10, Count: 10})
+			svc := app.NewCatalogueService(store, nil, newNoopTxBeginner(t), nil, "shop.test")
+
+			params := tt.params
+			params.Sort, params.Order, params.PageNum, params.PageSize = "id", "asc", 1, 10
+
+			resp, err := svc.ListSocks(context.Background(), &params)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if len(store.queries) != 0 {
+					t.Fatalf("got %d socks queries, want none", len(store.queries))
+				}
+				return
+			}
+
+			// The socks, their total and their facets are all filtered alike.
+			if len(store.queries) != 1 || !reflect.DeepEqual(store.queries[0].SockFilter, tt.wantFilter) {
+				t.Fatalf("got queries %+v, want the filter %+v", store.queries, tt.wantFilter)
+			}
+			for _, f := range store.filters {
+				if !reflect.DeepEqual(f, tt.wantFilter) {
+					t.Fatalf("got filter %+v, want %+v", f, tt.wantFilter)
+				}
+			}
+			if len(store.filters) != 2 {
+				t.Fatalf("got %d filters counted and faceted, want 2", len(store.filters))
+			}
+
+			// The links carry the filter over.
+			self, err := url.Parse(resp.Links["self"].Href)
+			if err != nil {
+				t.Fatal(err)
+			}
+			query := self.Query()
+			for _, k := range []string{"page", "size", "sort", "order"} {
+				query.Del(k)
+			}
+			if !reflect.DeepEqual(query, tt.wantQuery) {
+				t.Fatalf("got link query %v, want %v", query, tt.wantQuery)
+			}
+		})
+	}
+}
+
+func TestCatalogueServiceListSocksFacets(t *testing.T) {
+	ctx := context.Background()
+
+	store := newSockStore(domain.Sock{ID: uuid.New(), Name: "crew", Price: 10, Count: 10, Reserved: 4})
+	svc := app.NewCatalogueService(store, nil, newNoopTxBeginner(t), nil, "shop.test")
+
+	params := api.ListSockParams{Sort: "id", Order: "asc", PageNum: 1, PageSize: 10}
+
+	// No facets are listed as empty lists, not nulls.
+	resp, err := svc.ListSocks(ctx, &params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Facets.Tags == nil || resp.Facets.Prices == nil {
+		t.Fatalf("got facets %+v, want empty lists", resp.Facets)
+	}
+
+	store.facets = domain.SockFacets{
+		Tags:   []domain.TagFacet{{Name: "blue", Count: 3}, {Name: "wool", Count: 1}},
+		Prices: []domain.PriceFacet{{Min: 0, Max: floatPtr(10), Count: 2}, {Min: 50, Count: 1}},
+	}
+
+	resp, err = svc.ListSocks(ctx, &params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := api.SockFacets{
+		Tags:   []api.TagFacet{{Tag: "blue", Count: 3}, {Tag: "wool", Count: 1}},
+		Prices: []api.PriceFacet{{Min: 0, Max: floatPtr(10), Count: 2}, {Min: 50, Count: 1}},
+	}
+	if !reflect.DeepEqual(resp.Facets, want) {
+		t.Fatalf("got facets %+v, want %+v", resp.Facets, want)
+	}
+
+	// The socks listed tell what can still be sold apart from the stock.
+	if len(resp.Socks) != 1 || resp.Socks[0].Count != 10 || resp.Socks[0].Available != 6 {
+		t.Fatalf("got socks %+v, want the crew socks with 6 available", resp.Socks)
+	}
+}
+
+// sockStore keeps the socks in memory. It only implements the methods the
+// tested services call.
+type sockStore struct {
+	domain.SockStore
+
+	mu    sync.Mutex
+	socks map[string]domain.Sock
+	// locked are the IDs of the socks read for update.
+	locked []string
+	// queries are the queries listed, and filters the filters counted and
+	// faceted.
+	queries []domain.SockQuery
+	filters []domain.SockFilter
+	// facets are the facets returned for every filter.
+	facets domain.SockFacets
+}
+
+func newSockStore(socks ...domain.Sock) *sockStore {
+	s := &sockStore{socks: make(map[string]domain.Sock)}
+	for _, sock := range socks {
+		s.socks[sock.ID.String()] = sock
+	}
+	return s
+}
+
+func (s *sockStore) WithTx(db.DB) domain.SockStore { return s }
+
+func (s *sockStore) Get(_ context.Context, id string) (domain.Sock, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sock, ok := s.socks[id]
+	if !ok {
+		return domain.Sock{}, domain.ErrNotFound
+	}
+	return sock, nil
+}
+
+// List returns a page of the socks sorted by ID. It records the query but does
+// not filter, which is left to the stores.
+func (s *sockStore) List(_ context.Context, q domain.SockQuery) ([]domain.Sock, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.queries = append(s.queries, q)
+
+	var socks []domain.Sock
+	for _, sock := range s.socks {
+		socks = append(socks, sock)
+	}
+	sort.Slice(socks, func(a, b int) bool { return socks[a].ID.String() < socks[b].ID.String() })
+
+	if q.Page.Offset > len(socks) {
+		q.Page.Offset = len(socks)
+	}
+	socks = socks[q.Page.Offset:]
+	if len(socks) > q.Page.Limit {
+		socks = socks[:q.Page.Limit]
+	}
+	return socks, nil
+}
+
+func (s *sockStore) Count(_ context.Context, f domain.SockFilter) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.filters = append(s.filters, f)
+	return len(s.socks), nil
+}
+
+func (s *sockStore) Facets(_ context.Context, f domain.SockFilter) (domain.SockFacets, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.filters = append(s.filters, f)
+	return s.facets, nil
+}
+
+func (s *sockStore) GetForUpdate(ctx context.Context, id string) (domain.Sock, error) {
+	s.mu.Lock()
+	s.locked = append(s.locked, id)
+	s.mu.Unlock()
+
+	return s.Get(ctx, id)
+}
+
+// Update writes the sock. Like the stores, it ignores Reserved, which is
+// derived from the reservations.
+func (s *sockStore) Update(_ context.Context, sock domain.Sock) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, ok := s.socks[sock.ID.String()]
+	if !ok {
+		return domain.ErrNotFound
+	}
+
+	sock.Reserved = current.Reserved
+	s.socks[sock.ID.String()] = sock
+	return nil
+}
+
+// take takes n units of the sock out of stock.
+func (s *sockStore) take(id string, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sock := s.socks[id]
+	sock.Count -= n
+	s.socks[id] = sock
+}
@@ -0,0 +1,21 @@
+package app
+
+import "time"
+
+// Clock tells the current time. Services take one so that tests can control
+// time, a nil Clock is the system clock.
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc adapts a function to a Clock.
+type ClockFunc func() time.Time
+
+func (f ClockFunc) Now() time.Time { return f() }
+
+func now(c Clock) time.Time {
+	if c == nil {
+		return time.Now()
+	}
+	return c.Now()
+}
@@ -0,0 +1,127 @@
+package app
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/oshankkumar/sockshop/api"
+	"github.com/oshankkumar/sockshop/internal/domain"
+)
+
+// LoginPolicy tells how failed logins slow down the next attempts.
+type LoginPolicy struct {
+	// FreeAttempts is the number of failures allowed before any backoff.
+	FreeAttempts int
+	// BaseDelay is the wait after the first failure past FreeAttempts. It
+	// doubles with every further failure, up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// LockoutAfter locks the key out for LockoutDuration once it reaches
+	// that many failures. Zero disables the lockout.
+	LockoutAfter    int
+	LockoutDuration time.Duration
+}
+
+// wait returns how long the key must wait after its last failure, and
+// whether it is locked out.
+func (p LoginPolicy) wait(failures int) (time.Duration, bool) {
+	if p.LockoutAfter > 0 && failures >= p.LockoutAfter {
+		return p.LockoutDuration, true
+	}
+
+	if failures <= p.FreeAttempts {
+		return 0, false
+	}
+
+	delay := p.BaseDelay
+	for i := p.FreeAttempts + 1; i < failures && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+
+	return delay, false
+}
+
+// LoginThrottle tracks the failed logins of every username and client IP, and
+// rejects the attempts made before their backoff elapsed with an
+// api.LoginThrottledError.
+type LoginThrottle struct {
+	Store domain.LoginAttemptStore
+	User  LoginPolicy
+	IP    LoginPolicy
+	// Window is how long a failure is remembered.
+	Window time.Duration
+	Clock  Clock
+}
+
+func userAttemptKey(username string) string { return "user:" + strings.ToLower(username) }
+
+func ipAttemptKey(ip string) string { return "ip:" + ip }
+
+// Check fails with an api.LoginThrottledError if the username or the IP must
+// still wait before trying again.
+func (t *LoginThrottle) Check(ctx context.Context, username, ip string) error {
+	now := now(t.Clock)
+
+	var throttled *api.LoginThrottledError
+
+	for _, k := range []struct {
+		key    string
+		policy LoginPolicy
+	}{
+		{userAttemptKey(username), t.User},
+		{ipAttemptKey(ip), t.IP},
+	} {
+		a, err := t.Store.GetLoginAttempts(ctx, k.key)
+		if err != nil {
+			return err
+		}
+
+		if a.Failures == 0 || now.Sub(a.LastFailure) > t.Window {
+			continue
+		}
+
+		wait, locked := k.policy.wait(a.Failures)
+		retryAfter := a.LastFailure.Add(wait).Sub(now)
+		if retryAfter <= 0 {
+			continue
+		}
+
+		// A lockout is reported over a backoff, and a longer wait over a
+		// shorter one.
+		if throttled == nil || (locked && !throttled.Locked) ||
+			(locked == throttled.Locked && retryAfter > throttled.RetryAfter) {
+			throttled = &api.LoginThrottledError{RetryAfter: retryAfter, Locked: locked}
+		}
+	}
+
+	if throttled != nil {
+		return *throttled
+	}
+
+	return nil
+}
+
+// Fail records a failed login of the username from the IP.
+func (t *LoginThrottle) Fail(ctx context.Context, username, ip string) error {
+	now := now(t.Clock)
+
+	for _, key := range []string{userAttemptKey(username), ipAttemptKey(ip)} {
+		if _, err := t.Store.RecordLoginFailure(ctx, key, now, t.Window); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Succeed forgets the failed logins of the username. Those of the IP are
+// kept, so that an attacker cannot reset its backoff with an account of its
+// own.
+func (t *LoginThrottle) Succeed(ctx context.Context, username string) error {
+	return t.Store.ResetLoginAttempts(ctx, userAttemptKey(username))
+}
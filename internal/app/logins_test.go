@@ -0,0 +1,187 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/oshankkumar/sockshop/api"
+	"github.com/oshankkumar/sockshop/internal/db/memory"
+)
+
+func TestLoginPolicyWait(t *testing.T) {
+	p := LoginPolicy{
+		FreeAttempts:    3,
+		BaseDelay:       time.Second,
+		MaxDelay:        10 * time.Second,
+		LockoutAfter:    10,
+		LockoutDuration: 15 * time.Minute,
+	}
+
+	tests := []struct {
+		policy     LoginPolicy
+		failures   int
+		wantWait   time.Duration
+		wantLocked bool
+	}{
+		{policy: p, failures: 0},
+		{policy: p, failures: 3},
+		{policy: p, failures: 4, wantWait: time.Second},
+		{policy: p, failures: 5, wantWait: 2 * time.Second},
+		{policy: p, failures: 7, wantWait: 8 * time.Second},
+		{policy: p, failures: 8, wantWait: 10 * time.Second},
+		{policy: p, failures: 9, wantWait: 10 * time.Second},
+		{policy: p, failures: 10, wantWait: 15 * time.Minute, wantLocked: true},
+		{policy: p, failures: 50, wantWait: 15 * time.Minute, wantLocked: true},
+		// Without a lockout the backoff stays capped.
+		{policy: LoginPolicy{BaseDelay: time.Second, MaxDelay: time.Minute}, failures: 1000, wantWait: time.Minute},
+		{policy: LoginPolicy{}, failures: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.failures), func(t *testing.T) {
+			wait, locked := tt.policy.wait(tt.failures)
+			if wait != tt.wantWait || locked != tt.wantLocked {
+				t.Fatalf("got %s, %v, want %s, %v", wait, locked, tt.wantWait, tt.wantLocked)
+			}
+		})
+	}
+}
+
+func TestLoginThrottle(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	type attempt struct {
+		// after is the time since start.
+		after    time.Duration
+		username string
+		ip       string
+		fail     bool
+		succeed  bool
+	}
+
+	tests := []struct {
+		name     string
+		attempts []attempt
+		// check is the attempt checked last.
+		check attempt
+		want  *api.LoginThrottledError
+	}{
+		{
+			name:  "no failures",
+			check: attempt{username: "alice", ip: "10.0.0.1"},
+		},
+		{
+			name:     "free attempts",
+			attempts: []attempt{{username: "alice", ip: "10.0.0.1", fail: true}},
+			check:    attempt{username: "alice", ip: "10.0.0.1"},
+		},
+		{
+			name: "username backoff from another IP",
+			attempts: []attempt{
+				{username: "alice", ip: "10.0.0.1", fail: true},
+				{username: "Alice", ip: "10.0.0.1", fail: true},
+			},
+			check: attempt{after: 400 * time.Millisecond, username: "alice", ip: "10.0.0.2"},
+			want:  &api.LoginThrottledError{RetryAfter: 600 * time.Millisecond},
+		},
+		{
+			name: "backoff elapsed",
+			attempts: []attempt{
+				{username: "alice", ip: "10.0.0.1", fail: true},
+				{username: "alice", ip: "10.0.0.1", fail: true},
+			},
+			check: attempt{after: time.Second, username: "alice", ip: "10.0.0.1"},
+		},
+		{
+			name: "IP backoff for another username",
+			attempts: []attempt{
+				{username: "alice", ip: "10.0.0.1", fail: true},
+				{username: "bob", ip: "10.0.0.1", fail: true},
+				{username: "carol", ip: "10.0.0.1", fail: true},
+				{username: "dave", ip: "10.0.0.1", fail: true},
+			},
+			check: attempt{username: "erin", ip: "10.0.0.1"},
+			want:  &api.LoginThrottledError{RetryAfter: 2 * time.Second},
+		},
+		{
+			name: "lockout",
+			attempts: []attempt{
+				{username: "alice", ip: "10.0.0.1", fail: true},
+				{username: "alice", ip: "10.0.0.2", fail: true},
+				{username: "alice", ip: "10.0.0.3", fail: true},
+				{username: "alice", ip: "10.0.0.4", fail: true},
+			},
+			check: attempt{after: time.Minute, username: "alice", ip: "10.0.0.5"},
+			want:  &api.LoginThrottledError{RetryAfter: 14 * time.Minute, Locked: true},
+		},
+		{
+			name: "failures forgotten after the window",
+			attempts: []attempt{
+				{username: "alice", ip: "10.0.0.1", fail: true},
+				{username: "alice", ip: "10.0.0.2", fail: true},
+				{username: "alice", ip: "10.0.0.3", fail: true},
+				{username: "alice", ip: "10.0.0.4", fail: true},
+			},
+			check: attempt{after: time.Hour + time.Second, username: "alice", ip: "10.0.0.5"},
+		},
+		{
+			name: "success forgets the username failures only",
+			attempts: []attempt{
+				{username: "alice", ip: "10.0.0.1", fail: true},
+				{username: "alice", ip: "10.0.0.1", fail: true},
+				{username: "alice", ip: "10.0.0.1", fail: true},
+				{username: "alice", ip: "10.0.0.1", succeed: true},
+			},
+			check: attempt{username: "alice", ip: "10.0.0.1"},
+			want:  &api.LoginThrottledError{RetryAfter: time.Second},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			now := start
+
+			throttle := &LoginThrottle{
+				Store:  memory.NewLoginAttemptStore(),
+				User:   LoginPolicy{FreeAttempts: 1, BaseDelay: time.Second, MaxDelay: time.Minute, LockoutAfter: 4, LockoutDuration: 15 * time.Minute},
+				IP:     LoginPolicy{FreeAttempts: 2, BaseDelay: time.Second, MaxDelay: time.Minute},
+				Window: time.Hour,
+				Clock:  ClockFunc(func() time.Time { return now }),
+			}
+
+			for _, a := range tt.attempts {
+				now = start.Add(a.after)
+
+				var err error
+				switch {
+				case a.fail:
+					err = throttle.Fail(ctx, a.username, a.ip)
+				case a.succeed:
+					err = throttle.Succeed(ctx, a.username)
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			now = start.Add(tt.check.after)
+			err := throttle.Check(ctx, tt.check.username, tt.check.ip)
+
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("Check: %v", err)
+				}
+				return
+			}
+
+			var got api.LoginThrottledError
+			if !errors.As(err, &got) || got != *tt.want {
+				t.Fatalf("Check: got %v, want %v", err, *tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,288 @@
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/oshankkumar/sockshop/api"
+	"github.com/oshankkumar/sockshop/internal/db"
+	"github.com/oshankkumar/sockshop/internal/domain"
+	"github.com/oshankkumar/sockshop/internal/envelope"
+	"github.com/oshankkumar/sockshop/internal/totp"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// SecretSealer encrypts the secrets stored at rest.
+type SecretSealer interface {
+	Seal(ctx context.Context, plaintext []byte) (envelope.Sealed, error)
+	Open(ctx context.Context, sealed envelope.Sealed) ([]byte, error)
+}
+
+const (
+	// mfaChallengeTTL is how long a customer has to enter its second factor
+	// after its password.
+	mfaChallengeTTL = 5 * time.Minute
+	// totpSkew is the number of time steps a code may be early or late.
+	totpSkew          = 1
+	recoveryCodeCount = 10
+)
+
+var recoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// EnrollTOTP generates a TOTP secret and recovery codes for the customer. The
+// TOTP protects logins once confirmed with ConfirmTOTP. Enrolling again
+// replaces an unconfirmed TOTP, and fails with domain.ErrConflict once it is
+// confirmed.
+func (u *UserService) EnrollTOTP(ctx context.Context, customerID string) (*api.TOTPEnrolment, error) {
+	user, err := u.UserStore.GetUser(ctx, customerID)
+	if err != nil {
+		return nil, fmt.Errorf("UserService.EnrollTOTP(customerID=%s): %w", customerID, err)
+	}
+
+	secret, err := totp.NewSecret()
+	if err != nil {
+		return nil, fmt.Errorf("UserService.EnrollTOTP(customerID=%s): %w", customerID, err)
+	}
+
+	sealed, err := u.Secrets.Seal(ctx, secret)
+	if err != nil {
+		return nil, fmt.Errorf("UserService.EnrollTOTP(customerID=%s): %w", customerID, err)
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, fmt.Errorf("UserService.EnrollTOTP(customerID=%s): %w", customerID, err)
+	}
+
+	err = db.RunInTransaction(ctx, u.TxBeginner, func(ctx context.Context, tx *sqlx.Tx) error {
+		mfa := u.MFA.WithTx(tx)
+
+		existing, err := mfa.GetTOTP(ctx, customerID)
+
+		switch {
+		case errors.Is(err, domain.ErrNotFound):
+		case err != nil:
+			return err
+		case existing.Confirmed:
+			return fmt.Errorf("%w: a TOTP is already enrolled", domain.ErrConflict)
+		}
+
+		if err := mfa.SaveTOTP(ctx, &domain.TOTP{CustomerID: customerID, Secret: sealed}); err != nil {
+			return err
+		}
+
+		return mfa.ReplaceRecoveryCodes(ctx, customerID, hashes)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("UserService.EnrollTOTP(customerID=%s): %w", customerID, err)
+	}
+
+	return &api.TOTPEnrolment{
+		OtpauthURI:    totp.URI(u.Issuer, user.Username, secret),
+		Secret:        totp.Encoding.EncodeToString(secret),
+		RecoveryCodes: codes,
+	}, nil
+}
+
+// ConfirmTOTP turns on the enrolled TOTP of the customer, proving the
+// authenticator works with a first code. It fails with domain.ErrInvalid on a
+// wrong code and with domain.ErrNotFound if no TOTP waits for confirmation.
+func (u *UserService) ConfirmTOTP(ctx context.Context, customerID, code string) error {
+	t, err := u.MFA.GetTOTP(ctx, customerID)
+	if err != nil {
+		return fmt.Errorf("UserService.ConfirmTOTP(customerID=%s): %w", customerID, err)
+	}
+
+	if t.Confirmed {
+		return fmt.Errorf("UserService.ConfirmTOTP(customerID=%s): %w", customerID, domain.ErrNotFound)
+	}
+
+	secret, err := u.Secrets.Open(ctx, t.Secret)
+	if err != nil {
+		return fmt.Errorf("UserService.ConfirmTOTP(customerID=%s): %w", customerID, err)
+	}
+
+	counter, ok := totp.Verify(secret, code, now(u.Clock), totpSkew)
+	if !ok {
+		return fmt.Errorf("UserService.ConfirmTOTP(customerID=%s): wrong code: %w", customerID, domain.ErrInvalid)
+	}
+
+	if err := u.MFA.ConfirmTOTP(ctx, customerID, counter); err != nil {
+		return fmt.Errorf("UserService.ConfirmTOTP(customerID=%s): %w", customerID, err)
+	}
+
+	return nil
+}
+
+// StartMFA opens the second step of the login of a customer with a confirmed
+// TOTP. It returns nil when the customer has none, and the password alone
+// logs it in.
+func (u *UserService) StartMFA(ctx context.Context, customerID string) (*api.MFAChallenge, error) {
+	enabled, err := u.mfaEnabled(ctx, customerID)
+
+	switch {
+	case err != nil:
+		return nil, fmt.Errorf("UserService.StartMFA(customerID=%s): %w", customerID, err)
+	case !enabled:
+		return nil, nil
+	}
+
+	tok, hash, err := newOpaqueToken()
+	if err != nil {
+		return nil, fmt.Errorf("UserService.StartMFA(customerID=%s): %w", customerID, err)
+	}
+
+	c := &domain.MFAChallenge{Hash: hash, CustomerID: customerID, ExpiresAt: now(u.Clock).Add(mfaChallengeTTL)}
+	if err := u.MFA.CreateMFAChallenge(ctx, c); err != nil {
+		return nil, fmt.Errorf("UserService.StartMFA(customerID=%s): %w", customerID, err)
+	}
+
+	return &api.MFAChallenge{
+		MFARequired: true,
+		MFAToken:    tok,
+		ExpiresIn:   int(mfaChallengeTTL.Seconds()),
+	}, nil
+}
+
+// mfaEnabled reports whether the customer confirmed a second factor, and so
+// must complete it to log in.
+func (u *UserService) mfaEnabled(ctx context.Context, customerID string) (bool, error) {
+	if u.MFA == nil {
+		return false, nil
+	}
+
+	t, err := u.MFA.GetTOTP(ctx, customerID)
+
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+
+	return t.Confirmed, nil
+}
+
+// CompleteMFA finishes the login of the challenge with a TOTP code or an
+// unused recovery code. Wrong codes fail with api.ErrUnauthorized and are
+// throttled like wrong passwords.
+func (u *UserService) CompleteMFA(ctx context.Context, mfaToken, code, clientIP string) (*api.User, error) {
+	c, err := u.MFA.GetMFAChallenge(ctx, hashOpaqueToken(mfaToken))
+
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return nil, fmt.Errorf("UserService.CompleteMFA: unknown challenge: %w", api.ErrUnauthorized)
+	case err != nil:
+		return nil, fmt.Errorf("UserService.CompleteMFA: %w", err)
+	case now(u.Clock).After(c.ExpiresAt):
+		return nil, fmt.Errorf("UserService.CompleteMFA(customerID=%s): challenge expired: %w", c.CustomerID, api.ErrUnauthorized)
+	}
+
+	user, err := u.UserStore.GetUser(ctx, c.CustomerID)
+	if err != nil {
+		return nil, fmt.Errorf("UserService.CompleteMFA(customerID=%s): %w", c.CustomerID, err)
+	}
+
+	if u.Throttle != nil {
+		if err := u.Throttle.Check(ctx, user.Username, clientIP); err != nil {
+			return nil, fmt.Errorf("UserService.CompleteMFA(customerID=%s): %w", c.CustomerID, err)
+		}
+	}
+
+	ok, err := u.verifySecondFactor(ctx, c.CustomerID, code)
+	if err != nil {
+		return nil, fmt.Errorf("UserService.CompleteMFA(customerID=%s): %w", c.CustomerID, err)
+	}
+
+	if !ok {
+		if u.Throttle != nil {
+			if err := u.Throttle.Fail(ctx, user.Username, clientIP); err != nil {
+				return nil, fmt.Errorf("UserService.CompleteMFA(customerID=%s): %w", c.CustomerID, err)
+			}
+		}
+		return nil, fmt.Errorf("UserService.CompleteMFA(customerID=%s): wrong code: %w", c.CustomerID, api.ErrUnauthorized)
+	}
+
+	if err := u.MFA.DeleteMFAChallenge(ctx, c.Hash); err != nil {
+		return nil, fmt.Errorf("UserService.CompleteMFA(customerID=%s): %w", c.CustomerID, err)
+	}
+
+	if u.Throttle != nil {
+		if err := u.Throttle.Succeed(ctx, user.Username); err != nil {
+			return nil, fmt.Errorf("UserService.CompleteMFA(customerID=%s): %w", c.CustomerID, err)
+		}
+	}
+
+	return u.toAPIUser(user), nil
+}
+
+// verifySecondFactor checks code as a TOTP code, then as a recovery code.
+// Both are single use.
+func (u *UserService) verifySecondFactor(ctx context.Context, customerID, code string) (bool, error) {
+	t, err := u.MFA.GetTOTP(ctx, customerID)
+	if err != nil {
+		return false, err
+	}
+
+	secret, err := u.Secrets.Open(ctx, t.Secret)
+	if err != nil {
+		return false, err
+	}
+
+	if counter, ok := totp.Verify(secret, code, now(u.Clock), totpSkew); ok {
+		err := u.MFA.UseTOTPCounter(ctx, customerID, counter)
+
+		switch {
+		case errors.Is(err, domain.ErrConflict):
+			// The code was already used.
+			return false, nil
+		case err != nil:
+			return false, err
+		}
+
+		return true, nil
+	}
+
+	err = u.MFA.UseRecoveryCode(ctx, customerID, hashOpaqueToken(normalizeRecoveryCode(code)))
+
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+
+	return true, nil
+}
+
+// newRecoveryCodes returns random recovery codes, formatted as xxxxx-xxxxx,
+// and the hashes they are stored under.
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, fmt.Errorf("generate recovery code: %w", err)
+		}
+
+		code := strings.ToLower(recoveryEncoding.EncodeToString(b))[:10]
+		codes = append(codes, code[:5]+"-"+code[5:])
+		hashes = append(hashes, hashOpaqueToken(code))
+	}
+
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode lets customers type recovery codes in any case, with
+// or without separators.
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
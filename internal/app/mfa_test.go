@@ -0,0 +1,268 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/oshankkumar/sockshop/api"
+	"github.com/oshankkumar/sockshop/internal/app"
+	"github.com/oshankkumar/sockshop/internal/db/memory"
+	"github.com/oshankkumar/sockshop/internal/domain"
+	"github.com/oshankkumar/sockshop/internal/envelope"
+	"github.com/oshankkumar/sockshop/internal/totp"
+)
+
+type mfaTest struct {
+	svc    *app.UserService
+	user   domain.User
+	now    time.Time
+	secret []byte
+	// recoveryCodes are the codes of the enrolment.
+	recoveryCodes []string
+}
+
+func newMFATest(t *testing.T) *mfaTest {
+	t.Helper()
+
+	keys, err := envelope.NewRandomKeyring()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mt := &mfaTest{
+		user: domain.User{ID: uuid.New(), Username: "alice"},
+		now:  time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	mt.svc = &app.UserService{
+		UserStore:  newUserStore(mt.user),
+		MFA:        memory.NewMFAStore(),
+		Secrets:    envelope.NewSealer(keys, keys.FingerprintKey),
+		TxBeginner: newNoopTxBeginner(t),
+		Issuer:     "sockshop",
+		Clock:      app.ClockFunc(func() time.Time { return mt.now }),
+	}
+	return mt
+}
+
+// enroll enrolls and confirms a TOTP for the customer ten minutes ago, so
+// that the codes of the last steps are still unused.
+func (mt *mfaTest) enroll(t *testing.T) {
+	t.Helper()
+	ctx := context.Background()
+
+	e, err := mt.svc.EnrollTOTP(ctx, mt.user.ID.String())
+	if err != nil {
+		t.Fatalf("EnrollTOTP: %v", err)
+	}
+
+	mt.secret, err = totp.Encoding.DecodeString(e.Secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mt.recoveryCodes = e.RecoveryCodes
+
+	now := mt.now
+	mt.now = now.Add(-10 * time.Minute)
+	defer func() { mt.now = now }()
+
+	if err := mt.svc.ConfirmTOTP(ctx, mt.user.ID.String(), mt.code(mt.now, 0)); err != nil {
+		t.Fatalf("ConfirmTOTP: %v", err)
+	}
+}
+
+// code returns the TOTP code steps time steps after at.
+func (mt *mfaTest) code(at time.Time, steps int64) string {
+	return totp.Code(mt.secret, totp.Counter(at)+steps)
+}
+
+// challenge starts the second step of a login.
+func (mt *mfaTest) challenge(t *testing.T) string {
+	t.Helper()
+
+	c, err := mt.svc.StartMFA(context.Background(), mt.user.ID.String())
+	if err != nil {
+		t.Fatalf("StartMFA: %v", err)
+	}
+	if c == nil || !c.MFARequired {
+		t.Fatalf("StartMFA: got challenge %+v, want a challenge", c)
+	}
+	return c.MFAToken
+}
+
+func (mt *mfaTest) complete(t *testing.T, code string) error {
+	t.Helper()
+
+	user, err := mt.svc.CompleteMFA(context.Background(), mt.challenge(t), code, "10.0.0.1")
+	if err == nil && user.ID != mt.user.ID {
+		t.Fatalf("CompleteMFA: got customer %s, want %s", user.ID, mt.user.ID)
+	}
+	return err
+}
+
+func TestUserServiceEnrollTOTP(t *testing.T) {
+	mt := newMFATest(t)
+	ctx := context.Background()
+	id := mt.user.ID.String()
+
+	e, err := mt.svc.EnrollTOTP(ctx, id)
+	if err != nil {
+		t.Fatalf("EnrollTOTP: %v", err)
+	}
+
+	if !strings.HasPrefix(e.OtpauthURI, "otpauth://totp/sockshop:alice?") {
+		t.Errorf("got otpauth URI %s", e.OtpauthURI)
+	}
+	if len(e.RecoveryCodes) != 10 {
+		t.Errorf("got %d recovery codes, want 10", len(e.RecoveryCodes))
+	}
+
+	// An unconfirmed TOTP does not protect logins yet.
+	if c, err := mt.svc.StartMFA(ctx, id); err != nil || c != nil {
+		t.Fatalf("StartMFA before confirmation: got %+v, %v, want no challenge", c, err)
+	}
+
+	// Enrolling again replaces the unconfirmed TOTP.
+	e, err = mt.svc.EnrollTOTP(ctx, id)
+	if err != nil {
+		t.Fatalf("second EnrollTOTP: %v", err)
+	}
+
+	mt.secret, err = totp.Encoding.DecodeString(e.Secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wrong := mt.code(mt.now, 5)
+	if err := mt.svc.ConfirmTOTP(ctx, id, wrong); !errors.Is(err, domain.ErrInvalid) {
+		t.Fatalf("ConfirmTOTP with a wrong code: got %v, want %v", err, domain.ErrInvalid)
+	}
+
+	if err := mt.svc.ConfirmTOTP(ctx, id, mt.code(mt.now, 0)); err != nil {
+		t.Fatalf("ConfirmTOTP: %v", err)
+	}
+
+	if err := mt.svc.ConfirmTOTP(ctx, id, mt.code(mt.now, 1)); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("second ConfirmTOTP: got %v, want %v", err, domain.ErrNotFound)
+	}
+
+	if _, err := mt.svc.EnrollTOTP(ctx, id); !errors.Is(err, domain.ErrConflict) {
+		t.Fatalf("EnrollTOTP once confirmed: got %v, want %v", err, domain.ErrConflict)
+	}
+
+	mt.challenge(t)
+}
+
+func TestUserServiceCompleteMFASkew(t *testing.T) {
+	tests := []struct {
+		steps  int64
+		wantOK bool
+	}{
+		{steps: -2, wantOK: false},
+		{steps: -1, wantOK: true},
+		{steps: 0, wantOK: true},
+		{steps: 1, wantOK: true},
+		{steps: 2, wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(time.Duration(tt.steps*int64(totp.Period)).String(), func(t *testing.T) {
+			mt := newMFATest(t)
+			mt.enroll(t)
+
+			err := mt.complete(t, mt.code(mt.now, tt.steps))
+
+			switch {
+			case tt.wantOK && err != nil:
+				t.Fatalf("CompleteMFA: %v", err)
+			case !tt.wantOK && !errors.Is(err, api.ErrUnauthorized):
+				t.Fatalf("CompleteMFA: got %v, want %v", err, api.ErrUnauthorized)
+			}
+		})
+	}
+}
+
+func TestUserServiceCompleteMFAReplay(t *testing.T) {
+	mt := newMFATest(t)
+	mt.enroll(t)
+
+	if err := mt.complete(t, mt.code(mt.now, 0)); err != nil {
+		t.Fatalf("CompleteMFA: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		code string
+	}{
+		{name: "same code", code: mt.code(mt.now, 0)},
+		{name: "code of the previous step", code: mt.code(mt.now, -1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := mt.complete(t, tt.code); !errors.Is(err, api.ErrUnauthorized) {
+				t.Fatalf("CompleteMFA: got %v, want %v", err, api.ErrUnauthorized)
+			}
+		})
+	}
+
+	// The code of the next step is still fresh.
+	if err := mt.complete(t, mt.code(mt.now, 1)); err != nil {
+		t.Fatalf("CompleteMFA with the next code: %v", err)
+	}
+}
+
+func TestUserServiceCompleteMFARecoveryCode(t *testing.T) {
+	mt := newMFATest(t)
+	mt.enroll(t)
+
+	code := mt.recoveryCodes[3]
+
+	// Recovery codes may be typed in upper case and without the dash.
+	if err := mt.complete(t, strings.ToUpper(strings.ReplaceAll(code, "-", ""))); err != nil {
+		t.Fatalf("CompleteMFA: %v", err)
+	}
+
+	if err := mt.complete(t, code); !errors.Is(err, api.ErrUnauthorized) {
+		t.Fatalf("CompleteMFA with a used recovery code: got %v, want %v", err, api.ErrUnauthorized)
+	}
+
+	if err := mt.complete(t, mt.recoveryCodes[4]); err != nil {
+		t.Fatalf("CompleteMFA with another recovery code: %v", err)
+	}
+}
+
+func TestUserServiceCompleteMFAChallengeExpiry(t *testing.T) {
+	tests := []struct {
+		after  time.Duration
+		wantOK bool
+	}{
+		{after: 4 * time.Minute, wantOK: true},
+		{after: 5 * time.Minute, wantOK: true},
+		{after: 5*time.Minute + time.Second, wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.after.String(), func(t *testing.T) {
+			mt := newMFATest(t)
+			mt.enroll(t)
+
+			token := mt.challenge(t)
+			mt.now = mt.now.Add(tt.after)
+
+			_, err := mt.svc.CompleteMFA(context.Background(), token, mt.code(mt.now, 0), "10.0.0.1")
+
+			switch {
+			case tt.wantOK && err != nil:
+				t.Fatalf("CompleteMFA: %v", err)
+			case !tt.wantOK && !errors.Is(err, api.ErrUnauthorized):
+				t.Fatalf("CompleteMFA: got %v, want %v", err, api.ErrUnauthorized)
+			}
+		})
+	}
+}
@@ -0,0 +1,248 @@
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/oshankkumar/sockshop/api"
+	"github.com/oshankkumar/sockshop/internal/db"
+	"github.com/oshankkumar/sockshop/internal/domain"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// OIDCClaims are the claims of a verified ID token the shop uses.
+type OIDCClaims struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	PreferredUsername string
+	GivenName         string
+	FamilyName        string
+}
+
+// OIDCProvider is an OpenID Connect identity provider, used with the
+// authorization code flow and PKCE.
+type OIDCProvider interface {
+	// AuthCodeURL returns the authorization endpoint URL starting a login,
+	// with the S256 challenge of verifier.
+	AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error)
+	// Exchange redeems the code and returns the claims of the ID token,
+	// once its signature, issuer, audience, expiry and nonce are verified.
+	Exchange(ctx context.Context, code, verifier, nonce string) (OIDCClaims, error)
+	// TrustsEmail reports whether the emails the provider verified may be
+	// used to link its identities to existing customers.
+	TrustsEmail() bool
+}
+
+// oidcLoginTTL is how long a customer has to log in with the provider.
+const oidcLoginTTL = 10 * time.Minute
+
+// OIDCService logs customers in with external identity providers. An identity
+// is linked to the customer it logged in first, which is found by email if the
+// provider is trusted with emails, and registered otherwise.
+type OIDCService struct {
+	Providers  map[string]OIDCProvider
+	Identities domain.IdentityStore
+	UserStore  domain.UserStore
+	Users      *UserService
+	TxBeginner db.TxBeginner
+	Clock      Clock
+}
+
+func (o *OIDCService) StartLogin(ctx context.Context, provider string) (string, error) {
+	p, ok := o.Providers[provider]
+	if !ok {
+		return "", fmt.Errorf("OIDCService.StartLogin(%s): unknown provider: %w", provider, domain.ErrNotFound)
+	}
+
+	state, stateHash, err := newOpaqueToken()
+	if err != nil {
+		return "", fmt.Errorf("OIDCService.StartLogin(%s): %w", provider, err)
+	}
+
+	nonce, _, err := newOpaqueToken()
+	if err != nil {
+		return "", fmt.Errorf("OIDCService.StartLogin(%s): %w", provider, err)
+	}
+
+	verifier, _, err := newOpaqueToken()
+	if err != nil {
+		return "", fmt.Errorf("OIDCService.StartLogin(%s): %w", provider, err)
+	}
+
+	authURL, err := p.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		return "", fmt.Errorf("OIDCService.StartLogin(%s): %w", provider, err)
+	}
+
+	login := &domain.OIDCLogin{
+		StateHash: stateHash,
+		Provider:  provider,
+		Nonce:     nonce,
+		Verifier:  verifier,
+		ExpiresAt: now(o.Clock).Add(oidcLoginTTL),
+	}
+
+	if err := o.Identities.CreateOIDCLogin(ctx, login); err != nil {
+		return "", fmt.Errorf("OIDCService.StartLogin(%s): %w", provider, err)
+	}
+
+	return authURL, nil
+}
+
+func (o *OIDCService) CompleteLogin(ctx context.Context, provider, state, code string) (*api.User, error) {
+	p, ok := o.Providers[provider]
+	if !ok {
+		return nil, fmt.Errorf("OIDCService.CompleteLogin(%s): unknown provider: %w", provider, domain.ErrNotFound)
+	}
+
+	login, err := o.Identities.ConsumeOIDCLogin(ctx, hashOpaqueToken(state))
+
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return nil, fmt.Errorf("OIDCService.CompleteLogin(%s): unknown state: %w", provider, api.ErrUnauthorized)
+	case err != nil:
+		return nil, fmt.Errorf("OIDCService.CompleteLogin(%s): %w", provider, err)
+	case login.Provider != provider || now(o.Clock).After(login.ExpiresAt):
+		return nil, fmt.Errorf("OIDCService.CompleteLogin(%s): state expired: %w", provider, api.ErrUnauthorized)
+	}
+
+	claims, err := p.Exchange(ctx, code, login.Verifier, login.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("OIDCService.CompleteLogin(%s): %w: %v", provider, api.ErrUnauthorized, err)
+	}
+
+	customerID, err := o.linkIdentity(ctx, provider, p.TrustsEmail(), claims)
+	if err != nil {
+		return nil, fmt.Errorf("OIDCService.CompleteLogin(%s, subject=%s): %w", provider, claims.Subject, err)
+	}
+
+	user, err := o.Users.GetUser(ctx, customerID)
+	if err != nil {
+		return nil, fmt.Errorf("OIDCService.CompleteLogin(%s, subject=%s): %w", provider, claims.Subject, err)
+	}
+
+	return user, nil
+}
+
+// linkIdentity returns the customer linked to the identity, linking it first
+// if needed.
+func (o *OIDCService) linkIdentity(ctx context.Context, provider string, trustEmail bool, claims OIDCClaims) (string, error) {
+	var customerID string
+
+	err := db.RunInTransaction(ctx, o.TxBeginner, func(ctx context.Context, tx *sqlx.Tx) error {
+		identities := o.Identities.WithTx(tx)
+		userStore := o.UserStore.WithTx(tx)
+
+		identity, err := identities.GetIdentity(ctx, provider, claims.Subject)
+
+		switch {
+		case err == nil:
+			customerID = identity.CustomerID
+			return nil
+		case !errors.Is(err, domain.ErrNotFound):
+			return err
+		}
+
+		user, err := domain.User{}, domain.ErrNotFound
+		if claims.Email != "" {
+			user, err = userStore.GetUserByEmail(ctx, claims.Email)
+		}
+
+		switch {
+		case err == nil && trustEmail && claims.EmailVerified:
+			customerID = user.ID.String()
+		case err == nil:
+			return domain.DuplicateEntryError{Entity: "user", Err: errors.New("email registered by another customer")}
+		case errors.Is(err, domain.ErrNotFound):
+			id, err := o.provisionUser(ctx, userStore, claims)
+			if err != nil {
+				return err
+			}
+			customerID = id
+		default:
+			return err
+		}
+
+		return identities.CreateIdentity(ctx, &domain.Identity{
+			Provider:   provider,
+			Subject:    claims.Subject,
+			CustomerID: customerID,
+		})
+	})
+
+	return customerID, err
+}
+
+// provisionUser registers a customer for the identity. It has no password,
+// and logs in with the provider until it sets one with a password reset. It
+// has no email either if the provider did not share one.
+func (o *OIDCService) provisionUser(ctx context.Context, userStore domain.UserStore, claims OIDCClaims) (string, error) {
+	username, err := newProvisionedUsername(claims)
+	if err != nil {
+		return "", err
+	}
+
+	user := &domain.User{
+		FirstName: truncate(claims.GivenName, 20),
+		LastName:  truncate(claims.FamilyName, 20),
+		Email:     claims.Email,
+		Username:  username,
+	}
+
+	if err := userStore.CreateUser(ctx, user); err != nil {
+		return "", err
+	}
+
+	if claims.EmailVerified && claims.Email != "" {
+		if err := userStore.MarkEmailVerified(ctx, user.ID.String(), claims.Email); err != nil {
+			return "", err
+		}
+	}
+
+	return user.ID.String(), nil
+}
+
+// newProvisionedUsername derives a username from the claims, with a random
+// suffix so that it does not clash with existing usernames.
+func newProvisionedUsername(claims OIDCClaims) (string, error) {
+	base := claims.PreferredUsername
+	if base == "" {
+		base, _, _ = strings.Cut(claims.Email, "@")
+	}
+
+	base = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '.', r == '_', r == '-':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		default:
+			return -1
+		}
+	}, base)
+
+	if base == "" {
+		base = "user"
+	}
+
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", fmt.Errorf("generate username: %w", err)
+	}
+
+	return truncate(base, maxUsernameLen-7) + "-" + hex.EncodeToString(suffix), nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
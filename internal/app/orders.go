@@ -0,0 +1,466 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/oshankkumar/sockshop/api"
+	"github.com/oshankkumar/sockshop/internal/cursor"
+	"github.com/oshankkumar/sockshop/internal/db"
+	"github.com/oshankkumar/sockshop/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// ShippingCost is the flat shipping fee added to every order.
+const ShippingCost = 4.99
+
+type OrderService struct {
+	OrderStore       domain.OrderStore
+	CartStore        domain.CartStore
+	ReservationStore domain.ReservationStore
+	UserStore        domain.UserStore
+	Payments         *PaymentService
+	TxBeginner       db.TxBeginner
+	Cursors          *cursor.Codec
+	Domain           string
+	// ReservationTTL is how long the stock of an unpaid order stays reserved.
+	ReservationTTL time.Duration
+}
+
+// CreateOrder checks out the customer's cart. The order is persisted, the
+// stock of every item is reserved for it and the cart is emptied in a single
+// transaction, which is rolled back if any item is short of stock. The order
+// is then charged: the reservations are committed once it is paid and
+// released when the payment is declined, leaving the order in the
+// payment_declined status from where PayOrder can retry it. The errors of
+// the payment are returned as an api.OrderPaymentError naming the order.
+func (o *OrderService) CreateOrder(ctx context.Context, req api.CreateOrderRequest) (*api.Order, error) {
+	customerID := req.Customer.String()
+
+	addr, err := o.customerAddress(ctx, customerID, req.Address.String())
+	if err != nil {
+		return nil, fmt.Errorf("OrderService.CreateOrder(customerID=%s): %w", customerID, err)
+	}
+
+	if err := o.checkCustomerCard(ctx, customerID, req.Card.String()); err != nil {
+		return nil, fmt.Errorf("OrderService.CreateOrder(customerID=%s): %w", customerID, err)
+	}
+
+	orderM := &domain.Order{
+		CustomerID: customerID,
+		CardID:     req.Card.String(),
+		Address:    addr,
+		Shipping:   ShippingCost,
+		Status:     domain.OrderStatusCreated,
+	}
+
+	err = db.RunInTransaction(ctx, o.TxBeginner, func(ctx context.Context, tx *sqlx.Tx) error {
+		cartStore, orderStore := o.CartStore.WithTx(tx), o.OrderStore.WithTx(tx)
+
+		cart, err := cartStore.GetCart(ctx, customerID)
+		if err != nil {
+			return err
+		}
+
+		if len(cart.Items) == 0 {
+			return fmt.Errorf("%w: cart is empty", domain.ErrInvalid)
+		}
+
+		for _, item := range cart.Items {
+			orderM.Items = append(orderM.Items, domain.OrderItem{
+				SockID:    item.SockID,
+				Quantity:  item.Quantity,
+				UnitPrice: item.UnitPrice,
+			})
+			orderM.Subtotal += float64(item.Quantity) * item.UnitPrice
+		}
+
+		orderM.Total = orderM.Subtotal + orderM.Shipping
+
+		if err := orderStore.CreateOrder(ctx, orderM); err != nil {
+			return err
+		}
+
+		reservationStore := o.ReservationStore.WithTx(tx)
+
+		// The stock held by the cart is reserved for the order instead.
+		if err := reservationStore.Release(ctx, domain.CartReservationOwner(customerID)); err != nil {
+			return err
+		}
+
+		if err := o.reserve(ctx, reservationStore, *orderM); err != nil {
+			return err
+		}
+
+		return cartStore.DeleteCart(ctx, customerID)
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("OrderService.CreateOrder(customerID=%s): %w", customerID, err)
+	}
+
+	if err := o.charge(ctx, orderM); err != nil {
+		return nil, fmt.Errorf("OrderService.CreateOrder(customerID=%s): %w", customerID, api.OrderPaymentError{OrderID: orderM.ID, Err: err})
+	}
+
+	order := o.toAPIOrder(*orderM)
+	return &order, nil
+}
+
+// PayOrder retries the payment of an order whose payment was declined or
+// did not complete.
+func (o *OrderService) PayOrder(ctx context.Context, id string) (*api.Order, error) {
+	orderM, err := o.OrderStore.GetOrder(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("OrderService.PayOrder(id=%s): %w", id, err)
+	}
+
+	switch orderM.Status {
+	case domain.OrderStatusCreated, domain.OrderStatusPaymentDeclined:
+		if err := o.charge(ctx, &orderM); err != nil {
+			return nil, fmt.Errorf("OrderService.PayOrder(id=%s): %w", id, err)
+		}
+	case domain.OrderStatusPaid:
+	default:
+		return nil, fmt.Errorf("OrderService.PayOrder(id=%s): %w: order is %s", id, domain.ErrConflict, orderM.Status)
+	}
+
+	order := o.toAPIOrder(orderM)
+	return &order, nil
+}
+
+// orderTransitions lists the statuses an order can be moved to by hand from
+// each status. Payment statuses are only set by charging the order.
+var orderTransitions = map[domain.OrderStatus][]domain.OrderStatus{
+	domain.OrderStatusCreated:         {domain.OrderStatusCancelled},
+	domain.OrderStatusPaymentDeclined: {domain.OrderStatusCancelled},
+	domain.OrderStatusPaid:            {domain.OrderStatusShipped, domain.OrderStatusCancelled},
+	domain.OrderStatusShipped:         {domain.OrderStatusDelivered},
+}
+
+// UpdateOrderStatus moves the order through its fulfilment. Cancelling an
+// unpaid order releases its reserved stock, cancelling a paid one refunds it.
+func (o *OrderService) UpdateOrderStatus(ctx context.Context, id string, status string) (*api.Order, error) {
+	orderM, err := o.OrderStore.GetOrder(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("OrderService.UpdateOrderStatus(id=%s): %w", id, err)
+	}
+
+	to := domain.OrderStatus(status)
+
+	allowed := false
+	for _, s := range orderTransitions[orderM.Status] {
+		allowed = allowed || s == to
+	}
+	if !allowed {
+		return nil, fmt.Errorf("OrderService.UpdateOrderStatus(id=%s): %w: cannot move a %s order to %q", id, domain.ErrConflict, orderM.Status, status)
+	}
+
+	if to == domain.OrderStatusCancelled && orderM.Status == domain.OrderStatusPaid {
+		if _, err := o.Payments.Refund(ctx, id); err != nil {
+			return nil, fmt.Errorf("OrderService.UpdateOrderStatus(id=%s): %w", id, err)
+		}
+	}
+
+	err = db.RunInTransaction(ctx, o.TxBeginner, func(ctx context.Context, tx *sqlx.Tx) error {
+		if to == domain.OrderStatusCancelled {
+			if err := o.ReservationStore.WithTx(tx).Release(ctx, id); err != nil {
+				return err
+			}
+		}
+
+		return o.OrderStore.WithTx(tx).UpdateStatus(ctx, id, to)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("OrderService.UpdateOrderStatus(id=%s): %w", id, err)
+	}
+
+	orderM.Status = to
+	orderM.History = append(orderM.History, domain.OrderStatusChange{Status: to, CreatedAt: time.Now().UTC()})
+
+	order := o.toAPIOrder(orderM)
+	return &order, nil
+}
+
+// reserve reserves the stock of every item of the order, failing with
+// ErrInsufficientStock if any item is short of it.
+func (o *OrderService) reserve(ctx context.Context, store domain.ReservationStore, order domain.Order) error {
+	expiresAt := time.Now().Add(o.ReservationTTL)
+
+	for _, item := range order.Items {
+		err := store.Reserve(ctx, &domain.Reservation{
+			SockID:    item.SockID,
+			Owner:     order.ID.String(),
+			Quantity:  item.Quantity,
+			ExpiresAt: expiresAt,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// charge charges the order and moves it to the paid or payment_declined
+// status accordingly. The order's stock is reserved again first if its
+// reservations were released or have expired. A paid order whose stock
+// cannot be taken any more is refunded and cancelled.
+func (o *OrderService) charge(ctx context.Context, order *domain.Order) error {
+	orderID := order.ID.String()
+
+	err := db.RunInTransaction(ctx, o.TxBeginner, func(ctx context.Context, tx *sqlx.Tx) error {
+		store := o.ReservationStore.WithTx(tx)
+
+		reservations, err := store.GetOwnerReservations(ctx, orderID)
+		if err != nil || len(reservations) > 0 {
+			return err
+		}
+
+		return o.reserve(ctx, store, *order)
+	})
+	if err != nil {
+		return err
+	}
+
+	status := domain.OrderStatusPaid
+
+	_, chargeErr := o.Payments.Charge(ctx, *order)
+
+	switch {
+	case errors.Is(chargeErr, domain.ErrPaymentDeclined):
+		status = domain.OrderStatusPaymentDeclined
+	case chargeErr != nil:
+		return chargeErr
+	}
+
+	err = db.RunInTransaction(ctx, o.TxBeginner, func(ctx context.Context, tx *sqlx.Tx) error {
+		store := o.ReservationStore.WithTx(tx)
+
+		var err error
+		if status == domain.OrderStatusPaid {
+			err = o.commit(ctx, store, *order)
+		} else {
+			err = store.Release(ctx, orderID)
+		}
+		if err != nil {
+			return err
+		}
+
+		return o.OrderStore.WithTx(tx).UpdateStatus(ctx, orderID, status)
+	})
+	if errors.Is(err, domain.ErrInsufficientStock) {
+		return o.cancelPaid(ctx, order, err)
+	}
+	if err != nil {
+		return err
+	}
+
+	order.Status = status
+	order.History = append(order.History, domain.OrderStatusChange{Status: status, CreatedAt: time.Now().UTC()})
+
+	return chargeErr
+}
+
+// commit takes the stock of the order out for good. The units whose
+// reservations expired while the order was charged are reserved again
+// first, failing with ErrInsufficientStock if they have been sold since.
+func (o *OrderService) commit(ctx context.Context, store domain.ReservationStore, order domain.Order) error {
+	committed, err := store.Commit(ctx, order.ID.String())
+	if err != nil {
+		return err
+	}
+
+	short := order
+	short.Items = nil
+	for _, item := range order.Items {
+		n := min(item.Quantity, committed[item.SockID])
+		committed[item.SockID] -= n
+
+		if n < item.Quantity {
+			item.Quantity -= n
+			short.Items = append(short.Items, item)
+		}
+	}
+
+	if len(short.Items) == 0 {
+		return nil
+	}
+
+	if err := o.reserve(ctx, store, short); err != nil {
+		return err
+	}
+
+	_, err = store.Commit(ctx, order.ID.String())
+	return err
+}
+
+// cancelPaid refunds an order which was paid but whose stock ran out before
+// it could be taken, and cancels it. A failed refund leaves the order
+// unpaid, so that PayOrder picks up the captured payment again.
+func (o *OrderService) cancelPaid(ctx context.Context, order *domain.Order, cause error) error {
+	orderID := order.ID.String()
+
+	if _, err := o.Payments.Refund(ctx, orderID); err != nil {
+		return errors.Join(cause, err)
+	}
+
+	err := db.RunInTransaction(ctx, o.TxBeginner, func(ctx context.Context, tx *sqlx.Tx) error {
+		if err := o.ReservationStore.WithTx(tx).Release(ctx, orderID); err != nil {
+			return err
+		}
+
+		return o.OrderStore.WithTx(tx).UpdateStatus(ctx, orderID, domain.OrderStatusCancelled)
+	})
+	if err != nil {
+		return errors.Join(cause, err)
+	}
+
+	order.Status = domain.OrderStatusCancelled
+	order.History = append(order.History, domain.OrderStatusChange{Status: domain.OrderStatusCancelled, CreatedAt: time.Now().UTC()})
+
+	return cause
+}
+
+func (o *OrderService) customerAddress(ctx context.Context, customerID, addrID string) (domain.Address, error) {
+	addrs, err := o.UserStore.GetUserAddresses(ctx, customerID)
+	if err != nil {
+		return domain.Address{}, err
+	}
+
+	for _, a := range addrs {
+		if a.ID.String() == addrID {
+			return a, nil
+		}
+	}
+
+	return domain.Address{}, fmt.Errorf("%w: address %s does not belong to the customer", domain.ErrInvalid, addrID)
+}
+
+func (o *OrderService) checkCustomerCard(ctx context.Context, customerID, cardID string) error {
+	cards, err := o.UserStore.GetUserCards(ctx, customerID)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range cards {
+		if c.ID.String() == cardID {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: card %s does not belong to the customer", domain.ErrInvalid, cardID)
+}
+
+func (o *OrderService) GetOrder(ctx context.Context, id string) (*api.Order, error) {
+	orderM, err := o.OrderStore.GetOrder(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("OrderService.GetOrder(id=%s): %w", id, err)
+	}
+
+	order := o.toAPIOrder(orderM)
+	return &order, nil
+}
+
+// orderCursor is the keyset position carried by the opaque orders cursor.
+type orderCursor struct {
+	ID string `json:"id"`
+}
+
+func (o *OrderService) ListOrders(ctx context.Context, req *api.ListOrdersParams) (*api.ListOrdersResponse, error) {
+	// Fetch one extra order to find out whether there is a next page.
+	page := domain.Page{Limit: req.PageSize + 1}
+
+	if req.Cursor != "" {
+		var c orderCursor
+		if err := o.Cursors.Decode(req.Cursor, &c); err != nil {
+			return nil, fmt.Errorf("OrderService.ListOrders: %w: %v", domain.ErrInvalid, err)
+		}
+		page.After = &domain.Key{ID: c.ID}
+	}
+
+	ordersM, err := o.OrderStore.GetOrders(ctx, page)
+	if err != nil {
+		return nil, fmt.Errorf("OrderService.ListOrders: %w", err)
+	}
+
+	var nextCursor string
+	if len(ordersM) > req.PageSize {
+		ordersM = ordersM[:req.PageSize]
+
+		nextCursor, err = o.Cursors.Encode(orderCursor{ID: ordersM[len(ordersM)-1].ID.String()})
+		if err != nil {
+			return nil, fmt.Errorf("OrderService.ListOrders: %w", err)
+		}
+	}
+
+	orders := make([]api.Order, 0, len(ordersM))
+	for _, ord := range ordersM {
+		orders = append(orders, o.toAPIOrder(ord))
+	}
+
+	query := url.Values{"size": {strconv.Itoa(req.PageSize)}}
+
+	return &api.ListOrdersResponse{
+		Orders:     orders,
+		NextCursor: nextCursor,
+		Links:      api.NewCursorLinks(o.Domain, "/orders", query, req.Cursor, nextCursor),
+	}, nil
+}
+
+func (o *OrderService) GetCustomerOrders(ctx context.Context, customerID string) ([]api.Order, error) {
+	ordersM, err := o.OrderStore.GetCustomerOrders(ctx, customerID)
+	if err != nil {
+		return nil, fmt.Errorf("OrderService.GetCustomerOrders(customerID=%s): %w", customerID, err)
+	}
+
+	orders := make([]api.Order, 0, len(ordersM))
+	for _, ord := range ordersM {
+		orders = append(orders, o.toAPIOrder(ord))
+	}
+
+	return orders, nil
+}
+
+func (o *OrderService) toAPIOrder(ord domain.Order) api.Order {
+	customerID, _ := uuid.Parse(ord.CustomerID)
+	cardID, _ := uuid.Parse(ord.CardID)
+
+	order := api.Order{
+		ID:         ord.ID,
+		CustomerID: customerID,
+		CardID:     cardID,
+		Address: api.ShippingAddress{
+			Street:   ord.Address.Street,
+			Number:   ord.Address.Number,
+			Country:  ord.Address.Country,
+			City:     ord.Address.City,
+			PostCode: ord.Address.PostCode,
+		},
+		Items:     []api.OrderItem{},
+		Subtotal:  ord.Subtotal,
+		Shipping:  ord.Shipping,
+		Total:     ord.Total,
+		Status:    string(ord.Status),
+		History:   []api.OrderStatus{},
+		CreatedAt: ord.CreatedAt,
+		Links:     api.NewOrderLinks(o.Domain, ord.ID.String(), ord.CustomerID),
+	}
+
+	for _, item := range ord.Items {
+		itemID, _ := uuid.Parse(item.SockID)
+		order.Items = append(order.Items, api.OrderItem{ItemID: itemID, Quantity: item.Quantity, UnitPrice: item.UnitPrice})
+	}
+
+	for _, h := range ord.History {
+		order.History = append(order.History, api.OrderStatus{Status: string(h.Status), Time: h.CreatedAt})
+	}
+
+	return order
+}
@@ -0,0 +1,596 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/oshankkumar/sockshop/api"
+	"github.com/oshankkumar/sockshop/internal/app"
+	"github.com/oshankkumar/sockshop/internal/db"
+	"github.com/oshankkumar/sockshop/internal/domain"
+)
+
+// gateway is a payment gateway declining or failing the authorizations on
+// demand.
+type gateway struct {
+	// decline declines the authorizations, fail fails them with an unknown
+	// outcome.
+	decline bool
+	fail    error
+	refunds []string
+	// authorizing is called before every authorization.
+	authorizing func()
+}
+
+func (g *gateway) Authorize(_ context.Context, req app.PaymentRequest) (app.PaymentResult, error) {
+	if g.authorizing != nil {
+		g.authorizing()
+	}
+
+	switch {
+	case g.fail != nil:
+		return app.PaymentResult{}, g.fail
+	case g.decline:
+		return app.PaymentResult{Declined: true, DeclineReason: "card_blocked"}, nil
+	}
+	return app.PaymentResult{AuthorizationID: "auth-" + req.IdempotencyKey}, nil
+}
+
+func (g *gateway) Capture(_ context.Context, id string, _ float64) (app.PaymentResult, error) {
+	return app.PaymentResult{AuthorizationID: id}, nil
+}
+
+func (g *gateway) Void(_ context.Context, id string) (app.PaymentResult, error) {
+	return app.PaymentResult{AuthorizationID: id}, nil
+}
+
+func (g *gateway) Refund(_ context.Context, id string, _ float64) (app.PaymentResult, error) {
+	g.refunds = append(g.refunds, id)
+	return app.PaymentResult{AuthorizationID: id}, nil
+}
+
+// cardNumbers detokenizes every token to the same test card number.
+type cardNumbers struct{}
+
+func (cardNumbers) Detokenize(context.Context, string, string) (string, error) {
+	return "4111111111111111", nil
+}
+
+type orderTest struct {
+	svc          *app.OrderService
+	carts        *app.CartService
+	socks        *sockStore
+	reservations *reservationStore
+	payments     *paymentStore
+	gateway      *gateway
+	sock         domain.Sock
+	customer     domain.User
+	now          time.Time
+}
+
+func newOrderTest(t *testing.T) *orderTest {
+	t.Helper()
+
+	addr := domain.Address{ID: uuid.New(), Street: "Main Street", Number: "1", Country: "UK", City: "London", PostCode: "N1"}
+	card := domain.Card{ID: uuid.New(), Token: "tok_1", Last4: "1111", Expires: "12/99"}
+
+	ot := &orderTest{
+		sock:     domain.Sock{ID: uuid.New(), Name: "crew", Price: 10, Count: 5},
+		customer: domain.User{ID: uuid.New(), Username: "alice", AddressIDs: []string{addr.ID.String()}, CardIDs: []string{card.ID.String()}},
+		gateway:  &gateway{},
+		payments: &paymentStore{},
+		now:      time.Now(),
+	}
+
+	users := newUserStore(ot.customer)
+	users.addresses = []domain.Address{addr}
+	users.cards = []domain.Card{card}
+
+	ot.socks = newSockStore(ot.sock)
+	// The order service reserves with time.Now, the clock of the store only
+	// moves forward to expire the reservations.
+	ot.reservations = newReservationStore(ot.socks, func() time.Time { return ot.now })
+
+	txBeginner := newNoopTxBeginner(t)
+	carts := newCartStore()
+
+	ot.carts = &app.CartService{
+		CartStore:        carts,
+		SockStore:        ot.socks,
+		TxBeginner:       txBeginner,
+		ReservationStore: ot.reservations,
+		ReservationTTL:   30 * time.Minute,
+	}
+
+	ot.svc = &app.OrderService{
+		OrderStore:       newOrderStore(),
+		CartStore:        carts,
+		ReservationStore: ot.reservations,
+		UserStore:        users,
+		Payments: &app.PaymentService{
+			Gateway:      ot.gateway,
+			PaymentStore: ot.payments,
+			UserStore:    users,
+			Cards:        cardNumbers{},
+			Currency:     "USD",
+		},
+		TxBeginner:     txBeginner,
+		Domain:         "shop.test",
+		ReservationTTL: 15 * time.Minute,
+	}
+
+	return ot
+}
+
+// checkout adds quantity socks to the cart of the customer and orders them.
+func (ot *orderTest) checkout(t *testing.T, quantity int) (*api.Order, error) {
+	t.Helper()
+
+	ctx := context.Background()
+	customerID := ot.customer.ID.String()
+
+	if _, err := ot.carts.AddItem(ctx, customerID, api.CartItem{ItemID: ot.sock.ID, Quantity: quantity}); err != nil {
+		t.Fatalf("AddItem: %v", err)
+	}
+
+	return ot.svc.CreateOrder(ctx, api.CreateOrderRequest{
+		Customer: ot.customer.ID,
+		Address:  uuid.MustParse(ot.customer.AddressIDs[0]),
+		Card:     uuid.MustParse(ot.customer.CardIDs[0]),
+	})
+}
+
+// stock returns the units of the sock on hand.
+func (ot *orderTest) stock() int {
+	sock, _ := ot.socks.Get(context.Background(), ot.sock.ID.String())
+	return sock.Count
+}
+
+// reserved returns the units of the sock reserved by the owner.
+func (ot *orderTest) reserved(owner string) int {
+	return ot.reservations.reserved(owner, ot.sock.ID.String())
+}
+
+// orderID returns the ID of the order which could not be paid.
+func orderID(t *testing.T, err error) string {
+	t.Helper()
+
+	var payErr api.OrderPaymentError
+	if !errors.As(err, &payErr) {
+		t.Fatalf("got %v, want an api.OrderPaymentError", err)
+	}
+	return payErr.OrderID.String()
+}
+
+func TestOrderServiceCheckout(t *testing.T) {
+	ot := newOrderTest(t)
+	ctx := context.Background()
+	customerID := ot.customer.ID.String()
+
+	order, err := ot.checkout(t, 2)
+	if err != nil {
+		t.Fatalf("CreateOrder: %v", err)
+	}
+
+	if order.Status != string(domain.OrderStatusPaid) || order.Subtotal != 20 || order.Total != order.Subtotal+app.ShippingCost {
+		t.Fatalf("got order %s of %.2f + %.2f, want paid of 20 + %.2f", order.Status, order.Subtotal, order.Total-order.Subtotal, app.ShippingCost)
+	}
+
+	// The units held by the cart were handed over to the order, then taken
+	// out of stock once it was paid.
+	if n := ot.reserved(domain.CartReservationOwner(customerID)); n != 0 {
+		t.Errorf("got %d units reserved by the cart, want none", n)
+	}
+	if n := ot.reserved(order.ID.String()); n != 0 {
+		t.Errorf("got %d units still reserved by the order, want none", n)
+	}
+	if n := ot.stock(); n != 3 {
+		t.Errorf("got %d units on hand, want 3", n)
+	}
+
+	cart, _ := ot.carts.CartStore.GetCart(ctx, customerID)
+	if len(cart.Items) != 0 {
+		t.Errorf("got %d items left in the cart, want none", len(cart.Items))
+	}
+
+	if len(ot.payments.payments) != 1 || ot.payments.payments[0].Status != domain.PaymentStatusCaptured {
+		t.Errorf("got payments %+v, want one captured", ot.payments.payments)
+	}
+}
+
+func TestOrderServiceCheckoutEmptyCart(t *testing.T) {
+	ot := newOrderTest(t)
+
+	_, err := ot.svc.CreateOrder(context.Background(), api.CreateOrderRequest{
+		Customer: ot.customer.ID,
+		Address:  uuid.MustParse(ot.customer.AddressIDs[0]),
+		Card:     uuid.MustParse(ot.customer.CardIDs[0]),
+	})
+	if !errors.Is(err, domain.ErrInvalid) {
+		t.Fatalf("CreateOrder: got %v, want %v", err, domain.ErrInvalid)
+	}
+}
+
+func TestOrderServiceCheckoutOtherCustomerCard(t *testing.T) {
+	ot := newOrderTest(t)
+
+	_, err := ot.svc.CreateOrder(context.Background(), api.CreateOrderRequest{
+		Customer: ot.customer.ID,
+		Address:  uuid.MustParse(ot.customer.AddressIDs[0]),
+		Card:     uuid.New(),
+	})
+	if !errors.Is(err, domain.ErrInvalid) {
+		t.Fatalf("CreateOrder: got %v, want %v", err, domain.ErrInvalid)
+	}
+}
+
+func TestOrderServiceCheckoutOutOfStock(t *testing.T) {
+	ot := newOrderTest(t)
+	ctx := context.Background()
+
+	if _, err := ot.carts.AddItem(ctx, ot.customer.ID.String(), api.CartItem{ItemID: ot.sock.ID, Quantity: 2}); err != nil {
+		t.Fatalf("AddItem: %v", err)
+	}
+
+	// The sock sells out while it sits in the cart.
+	ot.socks.take(ot.sock.ID.String(), 4)
+
+	_, err := ot.svc.CreateOrder(ctx, api.CreateOrderRequest{
+		Customer: ot.customer.ID,
+		Address:  uuid.MustParse(ot.customer.AddressIDs[0]),
+		Card:     uuid.MustParse(ot.customer.CardIDs[0]),
+	})
+	if !errors.Is(err, domain.ErrInsufficientStock) {
+		t.Fatalf("CreateOrder: got %v, want %v", err, domain.ErrInsufficientStock)
+	}
+
+	if n := ot.stock(); n != 1 {
+		t.Errorf("got %d units on hand, want 1", n)
+	}
+}
+
+func TestOrderServiceRetryDeclinedPayment(t *testing.T) {
+	ot := newOrderTest(t)
+	ctx := context.Background()
+
+	ot.gateway.decline = true
+
+	_, err := ot.checkout(t, 2)
+	if !errors.Is(err, domain.ErrPaymentDeclined) {
+		t.Fatalf("CreateOrder: got %v, want %v", err, domain.ErrPaymentDeclined)
+	}
+
+	// The error names the order, which is kept for the retry.
+	id := orderID(t, err)
+
+	order, err := ot.svc.GetOrder(ctx, id)
+	if err != nil {
+		t.Fatalf("GetOrder: %v", err)
+	}
+	if order.Status != string(domain.OrderStatusPaymentDeclined) {
+		t.Fatalf("got order %s, want %s", order.Status, domain.OrderStatusPaymentDeclined)
+	}
+
+	// The stock of a declined order is given back.
+	if n := ot.reserved(id); n != 0 {
+		t.Fatalf("got %d units reserved by the declined order, want none", n)
+	}
+
+	ot.gateway.decline = false
+
+	order, err = ot.svc.PayOrder(ctx, id)
+	if err != nil {
+		t.Fatalf("PayOrder: %v", err)
+	}
+	if order.Status != string(domain.OrderStatusPaid) {
+		t.Fatalf("got order %s after the retry, want %s", order.Status, domain.OrderStatusPaid)
+	}
+	if n := ot.stock(); n != 3 {
+		t.Fatalf("got %d units on hand, want 3", n)
+	}
+
+	if n := len(ot.payments.payments); n != 2 {
+		t.Fatalf("got %d payment attempts, want 2", n)
+	}
+
+	// Paying a paid order again charges nothing.
+	if _, err := ot.svc.PayOrder(ctx, id); err != nil {
+		t.Fatalf("PayOrder of the paid order: %v", err)
+	}
+	if n := len(ot.payments.payments); n != 2 {
+		t.Fatalf("got %d payment attempts after paying twice, want 2", n)
+	}
+}
+
+func TestOrderServiceRetryAfterReservationExpiry(t *testing.T) {
+	ot := newOrderTest(t)
+	ctx := context.Background()
+
+	ot.gateway.fail = errors.New("connection reset")
+
+	_, err := ot.checkout(t, 2)
+	if err == nil {
+		t.Fatal("CreateOrder: got no error, want the gateway failure")
+	}
+	id := orderID(t, err)
+
+	// The payment outcome is unknown, so the stock stays reserved until the
+	// reservations expire. They are retried before the sweeper runs, with
+	// new reservations outliving the clock of the store.
+	ot.now = ot.now.Add(time.Hour)
+	ot.svc.ReservationTTL = 2 * time.Hour
+	ot.gateway.fail = nil
+
+	if _, err := ot.svc.PayOrder(ctx, id); err != nil {
+		t.Fatalf("PayOrder: %v", err)
+	}
+
+	// The expired reservations were replaced, not added to.
+	if n := ot.stock(); n != 3 {
+		t.Fatalf("got %d units on hand, want 3", n)
+	}
+}
+
+func TestOrderServiceReservationExpiresWhileCharging(t *testing.T) {
+	tests := []struct {
+		name string
+		// soldUnits are reserved by another customer once the reservations
+		// of the order have expired.
+		soldUnits   int
+		wantErr     error
+		wantStatus  domain.OrderStatus
+		wantStock   int
+		wantRefunds int
+	}{
+		{name: "stock left", wantStatus: domain.OrderStatusPaid, wantStock: 3},
+		{name: "sold meanwhile", soldUnits: 4, wantErr: domain.ErrInsufficientStock, wantStatus: domain.OrderStatusCancelled, wantStock: 5, wantRefunds: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ot := newOrderTest(t)
+			ctx := context.Background()
+
+			// The reservations expire during the payment. Those made after
+			// it outlive the clock of the store.
+			ot.gateway.authorizing = func() {
+				ot.now = ot.now.Add(time.Hour)
+				ot.svc.ReservationTTL = 2 * time.Hour
+				if tt.soldUnits == 0 {
+					return
+				}
+
+				err := ot.reservations.Reserve(ctx, &domain.Reservation{SockID: ot.sock.ID.String(), Owner: "other", Quantity: tt.soldUnits, ExpiresAt: ot.now.Add(time.Hour)})
+				if err != nil {
+					t.Fatalf("Reserve: %v", err)
+				}
+			}
+
+			order, err := ot.checkout(t, 2)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CreateOrder: got %v, want %v", err, tt.wantErr)
+			}
+
+			var id string
+			if order != nil {
+				id = order.ID.String()
+			} else {
+				id = orderID(t, err)
+			}
+
+			stored, err := ot.svc.OrderStore.GetOrder(ctx, id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.Status != tt.wantStatus {
+				t.Errorf("got order %s, want %s", stored.Status, tt.wantStatus)
+			}
+
+			// The units are taken out of stock once, or not at all and the
+			// payment is given back.
+			if n := ot.stock(); n != tt.wantStock {
+				t.Errorf("got %d units on hand, want %d", n, tt.wantStock)
+			}
+			if n := ot.reserved(id); n != 0 {
+				t.Errorf("got %d units still reserved by the order, want none", n)
+			}
+			if len(ot.gateway.refunds) != tt.wantRefunds {
+				t.Errorf("got refunds %v, want %d", ot.gateway.refunds, tt.wantRefunds)
+			}
+		})
+	}
+}
+
+func TestOrderServiceUpdateOrderStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		decline bool
+		// statuses are the statuses the order is moved to in turn.
+		statuses    []domain.OrderStatus
+		wantErr     error
+		wantStock   int
+		wantRefunds int
+	}{
+		{
+			name:      "fulfilment",
+			statuses:  []domain.OrderStatus{domain.OrderStatusShipped, domain.OrderStatusDelivered},
+			wantStock: 3,
+		},
+		{
+			name:        "cancel paid order",
+			statuses:    []domain.OrderStatus{domain.OrderStatusCancelled},
+			wantStock:   3,
+			wantRefunds: 1,
+		},
+		{
+			name:      "cancel declined order",
+			decline:   true,
+			statuses:  []domain.OrderStatus{domain.OrderStatusCancelled},
+			wantStock: 5,
+		},
+		{
+			name:      "ship declined order",
+			decline:   true,
+			statuses:  []domain.OrderStatus{domain.OrderStatusShipped},
+			wantErr:   domain.ErrConflict,
+			wantStock: 5,
+		},
+		{
+			name:      "cancel shipped order",
+			statuses:  []domain.OrderStatus{domain.OrderStatusShipped, domain.OrderStatusCancelled},
+			wantErr:   domain.ErrConflict,
+			wantStock: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ot := newOrderTest(t)
+			ctx := context.Background()
+
+			ot.gateway.decline = tt.decline
+
+			order, err := ot.checkout(t, 2)
+
+			id := ""
+			if tt.decline {
+				id = orderID(t, err)
+			} else if err != nil {
+				t.Fatalf("CreateOrder: %v", err)
+			} else {
+				id = order.ID.String()
+			}
+
+			for _, status := range tt.statuses {
+				order, err = ot.svc.UpdateOrderStatus(ctx, id, string(status))
+			}
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UpdateOrderStatus: got %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && order.Status != string(tt.statuses[len(tt.statuses)-1]) {
+				t.Fatalf("got order %s, want %s", order.Status, tt.statuses[len(tt.statuses)-1])
+			}
+
+			if n := ot.stock(); n != tt.wantStock {
+				t.Errorf("got %d units on hand, want %d", n, tt.wantStock)
+			}
+			if n := len(ot.gateway.refunds); n != tt.wantRefunds {
+				t.Errorf("got %d refunds, want %d", n, tt.wantRefunds)
+			}
+		})
+	}
+}
+
+// orderStore keeps the orders in memory. It only implements the methods the
+// tested services call.
+type orderStore struct {
+	domain.OrderStore
+
+	mu     sync.Mutex
+	orders map[string]domain.Order
+}
+
+func newOrderStore() *orderStore {
+	return &orderStore{orders: make(map[string]domain.Order)}
+}
+
+func (s *orderStore) WithTx(db.DB) domain.OrderStore { return s }
+
+func (s *orderStore) CreateOrder(_ context.Context, order *domain.Order) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order.ID = uuid.New()
+	order.CreatedAt = time.Now().UTC()
+	order.History = []domain.OrderStatusChange{{Status: order.Status, CreatedAt: order.CreatedAt}}
+	s.orders[order.ID.String()] = *order
+	return nil
+}
+
+func (s *orderStore) GetOrder(_ context.Context, id string) (domain.Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, ok := s.orders[id]
+	if !ok {
+		return domain.Order{}, domain.ErrNotFound
+	}
+	return order, nil
+}
+
+func (s *orderStore) GetCustomerOrders(_ context.Context, customerID string) ([]domain.Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var orders []domain.Order
+	for _, o := range s.orders {
+		if o.CustomerID == customerID {
+			orders = append(orders, o)
+		}
+	}
+	return orders, nil
+}
+
+func (s *orderStore) UpdateStatus(_ context.Context, id string, status domain.OrderStatus) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, ok := s.orders[id]
+	if !ok {
+		return domain.ErrNotFound
+	}
+
+	order.Status = status
+	order.History = append(order.History, domain.OrderStatusChange{Status: status, CreatedAt: time.Now().UTC()})
+	s.orders[id] = order
+	return nil
+}
+
+// paymentStore keeps the payment attempts in memory.
+type paymentStore struct {
+	mu       sync.Mutex
+	payments []domain.Payment
+}
+
+func (s *paymentStore) WithTx(db.DB) domain.PaymentStore { return s }
+
+func (s *paymentStore) CreatePayment(_ context.Context, p *domain.Payment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p.ID = uuid.New()
+	s.payments = append(s.payments, *p)
+	return nil
+}
+
+func (s *paymentStore) UpdatePayment(_ context.Context, p *domain.Payment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.payments {
+		if s.payments[i].ID == p.ID {
+			s.payments[i] = *p
+			return nil
+		}
+	}
+	return domain.ErrNotFound
+}
+
+func (s *paymentStore) GetOrderPayments(_ context.Context, orderID string) ([]domain.Payment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var payments []domain.Payment
+	for _, p := range s.payments {
+		if p.OrderID == orderID {
+			payments = append(payments, p)
+		}
+	}
+	return payments, nil
+}
@@ -0,0 +1,202 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/oshankkumar/sockshop/internal/domain"
+	"github.com/oshankkumar/sockshop/internal/vault"
+)
+
+type PaymentRequest struct {
+	// IdempotencyKey identifies the attempt. Gateways must answer a replayed
+	// key with the result of the first request instead of charging again.
+	IdempotencyKey string
+	Amount         float64
+	Currency       string
+	CardNumber     string
+	CardExpires    string
+}
+
+// PaymentResult is the outcome of a gateway operation. A decline is not an
+// error: Declined is set and DeclineReason tells why.
+type PaymentResult struct {
+	AuthorizationID string
+	Declined        bool
+	DeclineReason   string
+}
+
+// PaymentGateway talks to a payment processor. Errors are reserved for
+// failures where the outcome of the operation is unknown.
+type PaymentGateway interface {
+	Authorize(ctx context.Context, req PaymentRequest) (PaymentResult, error)
+	Capture(ctx context.Context, authorizationID string, amount float64) (PaymentResult, error)
+	Void(ctx context.Context, authorizationID string) (PaymentResult, error)
+	Refund(ctx context.Context, authorizationID string, amount float64) (PaymentResult, error)
+}
+
+type PaymentService struct {
+	Gateway      PaymentGateway
+	PaymentStore domain.PaymentStore
+	UserStore    domain.UserStore
+	Cards        CardDetokenizer
+	Currency     string
+}
+
+// Charge authorises and captures the order total with the order's card. Every
+// attempt is persisted before the gateway is called. Charging an order again
+// returns its captured payment, resumes an attempt whose outcome is unknown
+// with the same idempotency key, and only starts a new attempt once the
+// previous one was declined.
+func (p *PaymentService) Charge(ctx context.Context, order domain.Order) (domain.Payment, error) {
+	orderID := order.ID.String()
+
+	pay, err := p.nextAttempt(ctx, order)
+	if err != nil {
+		return pay, fmt.Errorf("PaymentService.Charge(orderID=%s): %w", orderID, err)
+	}
+
+	if pay.Status == domain.PaymentStatusCaptured {
+		return pay, nil
+	}
+
+	if pay.Status == domain.PaymentStatusPending {
+		if err := p.authorize(ctx, order, &pay); err != nil {
+			return pay, fmt.Errorf("PaymentService.Charge(orderID=%s): %w", orderID, err)
+		}
+	}
+
+	res, err := p.Gateway.Capture(ctx, pay.AuthorizationID, pay.Amount)
+	if err != nil {
+		return pay, fmt.Errorf("PaymentService.Charge(orderID=%s): capture: %w", orderID, err)
+	}
+
+	if res.Declined {
+		if _, err := p.Gateway.Void(ctx, pay.AuthorizationID); err != nil {
+			return pay, fmt.Errorf("PaymentService.Charge(orderID=%s): void: %w", orderID, err)
+		}
+
+		if err := p.setStatus(ctx, &pay, domain.PaymentStatusDeclined, res.DeclineReason); err != nil {
+			return pay, fmt.Errorf("PaymentService.Charge(orderID=%s): %w", orderID, err)
+		}
+
+		return pay, fmt.Errorf("PaymentService.Charge(orderID=%s): %w: %s", orderID, domain.ErrPaymentDeclined, res.DeclineReason)
+	}
+
+	if err := p.setStatus(ctx, &pay, domain.PaymentStatusCaptured, ""); err != nil {
+		return pay, fmt.Errorf("PaymentService.Charge(orderID=%s): %w", orderID, err)
+	}
+
+	return pay, nil
+}
+
+// Refund refunds the captured payment of the order.
+func (p *PaymentService) Refund(ctx context.Context, orderID string) (domain.Payment, error) {
+	payments, err := p.PaymentStore.GetOrderPayments(ctx, orderID)
+	if err != nil {
+		return domain.Payment{}, fmt.Errorf("PaymentService.Refund(orderID=%s): %w", orderID, err)
+	}
+
+	for _, pay := range payments {
+		if pay.Status != domain.PaymentStatusCaptured {
+			continue
+		}
+
+		res, err := p.Gateway.Refund(ctx, pay.AuthorizationID, pay.Amount)
+		if err != nil {
+			return pay, fmt.Errorf("PaymentService.Refund(orderID=%s): %w", orderID, err)
+		}
+
+		if res.Declined {
+			return pay, fmt.Errorf("PaymentService.Refund(orderID=%s): %w: %s", orderID, domain.ErrRefundDeclined, res.DeclineReason)
+		}
+
+		if err := p.setStatus(ctx, &pay, domain.PaymentStatusRefunded, ""); err != nil {
+			return pay, fmt.Errorf("PaymentService.Refund(orderID=%s): %w", orderID, err)
+		}
+
+		return pay, nil
+	}
+
+	return domain.Payment{}, fmt.Errorf("PaymentService.Refund(orderID=%s): captured payment: %w", orderID, domain.ErrNotFound)
+}
+
+// nextAttempt returns the attempt Charge should carry on with: the last one
+// unless it has been declined, voided or refunded, in which case a new
+// pending attempt is persisted.
+func (p *PaymentService) nextAttempt(ctx context.Context, order domain.Order) (domain.Payment, error) {
+	orderID := order.ID.String()
+
+	payments, err := p.PaymentStore.GetOrderPayments(ctx, orderID)
+	if err != nil {
+		return domain.Payment{}, err
+	}
+
+	if n := len(payments); n > 0 {
+		switch last := payments[n-1]; last.Status {
+		case domain.PaymentStatusPending, domain.PaymentStatusAuthorized, domain.PaymentStatusCaptured:
+			return last, nil
+		}
+	}
+
+	attempt := len(payments) + 1
+
+	pay := domain.Payment{
+		OrderID:        orderID,
+		Attempt:        attempt,
+		IdempotencyKey: fmt.Sprintf("%s-%d", orderID, attempt),
+		Amount:         order.Total,
+		Status:         domain.PaymentStatusPending,
+	}
+
+	if err := p.PaymentStore.CreatePayment(ctx, &pay); err != nil {
+		return pay, err
+	}
+
+	return pay, nil
+}
+
+func (p *PaymentService) authorize(ctx context.Context, order domain.Order, pay *domain.Payment) error {
+	card, err := p.UserStore.GetCard(ctx, order.CardID)
+	if err != nil {
+		return err
+	}
+
+	pan, err := p.Cards.Detokenize(ctx, vault.CallerPayments, card.Token)
+	if err != nil {
+		return fmt.Errorf("detokenize card: %w", err)
+	}
+
+	res, err := p.Gateway.Authorize(ctx, PaymentRequest{
+		IdempotencyKey: pay.IdempotencyKey,
+		Amount:         pay.Amount,
+		Currency:       p.Currency,
+		CardNumber:     pan,
+		CardExpires:    card.Expires,
+	})
+	if err != nil {
+		// The attempt stays pending: retrying replays its idempotency key.
+		return fmt.Errorf("authorize: %w", err)
+	}
+
+	if res.Declined {
+		if err := p.setStatus(ctx, pay, domain.PaymentStatusDeclined, res.DeclineReason); err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: %s", domain.ErrPaymentDeclined, res.DeclineReason)
+	}
+
+	pay.AuthorizationID = res.AuthorizationID
+	return p.setStatus(ctx, pay, domain.PaymentStatusAuthorized, "")
+}
+
+func (p *PaymentService) setStatus(ctx context.Context, pay *domain.Payment, status domain.PaymentStatus, reason string) error {
+	pay.Status = status
+	pay.DeclineReason = reason
+
+	if err := p.PaymentStore.UpdatePayment(ctx, pay); err != nil {
+		return fmt.Errorf("set payment status %s: %w", status, err)
+	}
+
+	return nil
+}
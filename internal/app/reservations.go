@@ -0,0 +1,40 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"github.com/oshankkumar/sockshop/internal/domain"
+
+	"go.uber.org/zap"
+)
+
+// ReservationSweeper periodically expires the reservations whose TTL has
+// elapsed, giving their units back to the available stock.
+type ReservationSweeper struct {
+	ReservationStore domain.ReservationStore
+	Interval         time.Duration
+	Logger           *zap.Logger
+}
+
+// Run sweeps every Interval until ctx is done.
+func (r *ReservationSweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			n, err := r.ReservationStore.Expire(ctx, now)
+			if err != nil {
+				r.Logger.Error("expire reservations", zap.Error(err))
+				continue
+			}
+			if n > 0 {
+				r.Logger.Info("expired reservations", zap.Int("count", n))
+			}
+		}
+	}
+}
@@ -0,0 +1,394 @@
+package app_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/oshankkumar/sockshop/internal/db"
+	"github.com/oshankkumar/sockshop/internal/domain"
+)
+
+// noopDriver is a database driver whose transactions do nothing, for the
+// services running the memory stores in transactions.
+type noopDriver struct{}
+
+func (noopDriver) Open(string) (driver.Conn, error) { return noopConn{}, nil }
+
+type noopConn struct{}
+
+func (noopConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("noop: no statements") }
+func (noopConn) Close() error                        { return nil }
+func (noopConn) Begin() (driver.Tx, error)           { return noopConn{}, nil }
+func (noopConn) Commit() error                       { return nil }
+func (noopConn) Rollback() error                     { return nil }
+
+func init() {
+	sql.Register("noop", noopDriver{})
+}
+
+func newNoopTxBeginner(t *testing.T) *sqlx.DB {
+	t.Helper()
+
+	dbx, err := sqlx.Open("noop", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { dbx.Close() })
+	return dbx
+}
+
+// userStore keeps the customers in memory. It only implements the methods
+// the tested services call.
+type userStore struct {
+	domain.UserStore
+
+	mu        sync.Mutex
+	users     []domain.User
+	addresses []domain.Address
+	cards     []domain.Card
+	// anonymised are the IDs of the customers erased.
+	anonymised []string
+}
+
+func newUserStore(users ...domain.User) *userStore {
+	return &userStore{users: users}
+}
+
+func (s *userStore) WithTx(db.DB) domain.UserStore { return s }
+
+// update runs f on the customer with the id.
+func (s *userStore) update(id string, f func(u *domain.User) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.users {
+		if s.users[i].ID.String() == id {
+			return f(&s.users[i])
+		}
+	}
+	return domain.ErrNotFound
+}
+
+func (s *userStore) find(match func(domain.User) bool) (domain.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if match(u) {
+			return u, nil
+		}
+	}
+	return domain.User{}, domain.ErrNotFound
+}
+
+func (s *userStore) GetUser(_ context.Context, id string) (domain.User, error) {
+	return s.find(func(u domain.User) bool { return u.ID.String() == id })
+}
+
+func (s *userStore) GetUserByName(_ context.Context, username string) (domain.User, error) {
+	return s.find(func(u domain.User) bool { return u.Username == username })
+}
+
+func (s *userStore) GetUserByEmail(_ context.Context, email string) (domain.User, error) {
+	return s.find(func(u domain.User) bool { return u.Email != "" && u.Email == email })
+}
+
+func (s *userStore) UpdateUser(_ context.Context, user *domain.User) error {
+	return s.update(user.ID.String(), func(u *domain.User) error {
+		if u.Version != user.Version {
+			return domain.ErrVersionMismatch
+		}
+
+		u.EmailVerified = u.EmailVerified && u.Email == user.Email
+		u.FirstName, u.LastName, u.Email = user.FirstName, user.LastName, user.Email
+		u.Version++
+		user.Version = u.Version
+		return nil
+	})
+}
+
+func (s *userStore) MarkEmailVerified(_ context.Context, id, email string) error {
+	return s.update(id, func(u *domain.User) error {
+		if u.Email != email {
+			return domain.ErrNotFound
+		}
+		u.EmailVerified = true
+		return nil
+	})
+}
+
+func (s *userStore) UpdatePassword(_ context.Context, id, hash string) error {
+	return s.update(id, func(u *domain.User) error {
+		u.Password, u.Salt = hash, ""
+		return nil
+	})
+}
+
+func (s *userStore) GetUserAddresses(ctx context.Context, userID string) ([]domain.Address, error) {
+	user, err := s.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var addrs []domain.Address
+	for _, a := range s.addresses {
+		if contains(user.AddressIDs, a.ID.String()) {
+			addrs = append(addrs, a)
+		}
+	}
+	return addrs, nil
+}
+
+func (s *userStore) GetCard(_ context.Context, id string) (domain.Card, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, c := range s.cards {
+		if c.ID.String() == id {
+			return c, nil
+		}
+	}
+	return domain.Card{}, domain.ErrNotFound
+}
+
+func (s *userStore) GetUserCards(ctx context.Context, userID string) ([]domain.Card, error) {
+	user, err := s.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var cards []domain.Card
+	for _, c := range s.cards {
+		if contains(user.CardIDs, c.ID.String()) {
+			cards = append(cards, c)
+		}
+	}
+	return cards, nil
+}
+
+// Delete deletes the customer, or the address or card and its link to the
+// customers.
+func (s *userStore) Delete(_ context.Context, entity, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.users {
+		u := &s.users[i]
+
+		switch {
+		case entity == domain.EntityCustomer && u.ID.String() == id:
+			s.users = append(s.users[:i], s.users[i+1:]...)
+			return nil
+		case entity == domain.EntityAddress:
+			u.AddressIDs = remove(u.AddressIDs, id)
+		case entity == domain.EntityCard:
+			u.CardIDs = remove(u.CardIDs, id)
+		}
+	}
+
+	if entity == domain.EntityCustomer {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (s *userStore) Anonymise(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.anonymised = append(s.anonymised, id)
+	return nil
+}
+
+// addressStore updates the addresses of a userStore.
+type addressStore struct {
+	domain.AddressStore
+	users *userStore
+}
+
+func (s addressStore) WithTx(db.DB) domain.AddressStore { return s }
+
+func (s addressStore) UpdateAddress(_ context.Context, addr *domain.Address) error {
+	s.users.mu.Lock()
+	defer s.users.mu.Unlock()
+
+	for i, a := range s.users.addresses {
+		if a.ID != addr.ID {
+			continue
+		}
+		if a.Version != addr.Version {
+			return domain.ErrVersionMismatch
+		}
+
+		addr.Version++
+		s.users.addresses[i] = *addr
+		return nil
+	}
+	return domain.ErrNotFound
+}
+
+// cardStore updates the cards of a userStore.
+type cardStore struct {
+	domain.CardStore
+	users *userStore
+}
+
+func (s cardStore) WithTx(db.DB) domain.CardStore { return s }
+
+func (s cardStore) UpdateCard(_ context.Context, card *domain.Card) error {
+	s.users.mu.Lock()
+	defer s.users.mu.Unlock()
+
+	for i, c := range s.users.cards {
+		if c.ID != card.ID {
+			continue
+		}
+		if c.Version != card.Version {
+			return domain.ErrVersionMismatch
+		}
+
+		card.Version++
+		s.users.cards[i].Expires, s.users.cards[i].Version = card.Expires, card.Version
+		return nil
+	}
+	return domain.ErrNotFound
+}
+
+func remove(ids []string, id string) []string {
+	var kept []string
+	for _, i := range ids {
+		if i != id {
+			kept = append(kept, i)
+		}
+	}
+	return kept
+}
+
+func contains(ids []string, id string) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
+
+// sessionStore keeps the sessions in memory.
+type sessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]domain.Session
+}
+
+func newSessionStore() *sessionStore {
+	return &sessionStore{sessions: make(map[string]domain.Session)}
+}
+
+func (s *sessionStore) CreateSession(_ context.Context, sess *domain.Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if sess.ID == uuid.Nil {
+		sess.ID = uuid.New()
+	}
+	s.sessions[sess.ID.String()] = *sess
+	return nil
+}
+
+func (s *sessionStore) GetSession(_ context.Context, id string) (domain.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess, ok := s.sessions[id]
+	if !ok {
+		return domain.Session{}, domain.ErrNotFound
+	}
+	return sess, nil
+}
+
+func (s *sessionStore) GetSessionByRefreshHash(_ context.Context, hash string) (domain.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, sess := range s.sessions {
+		if sess.RefreshHash == hash {
+			return sess, nil
+		}
+	}
+	return domain.Session{}, domain.ErrNotFound
+}
+
+func (s *sessionStore) RotateRefresh(_ context.Context, id, oldHash, newHash string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess, ok := s.sessions[id]
+	if !ok || sess.RefreshHash != oldHash {
+		return domain.ErrNotFound
+	}
+
+	sess.RefreshHash, sess.ExpiresAt = newHash, expiresAt
+	s.sessions[id] = sess
+	return nil
+}
+
+func (s *sessionStore) RevokeSession(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess, ok := s.sessions[id]
+	if !ok {
+		return domain.ErrNotFound
+	}
+
+	s.revoke(&sess)
+	s.sessions[id] = sess
+	return nil
+}
+
+func (s *sessionStore) RevokeCustomerSessions(_ context.Context, customerID, exceptID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, sess := range s.sessions {
+		if sess.CustomerID == customerID && id != exceptID {
+			s.revoke(&sess)
+			s.sessions[id] = sess
+		}
+	}
+	return nil
+}
+
+func (s *sessionStore) revoke(sess *domain.Session) {
+	if sess.RevokedAt == nil {
+		now := time.Now()
+		sess.RevokedAt = &now
+	}
+}
+
+// active returns the IDs of the sessions of the customer not revoked.
+func (s *sessionStore) active(customerID string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var ids []string
+	for id, sess := range s.sessions {
+		if sess.CustomerID == customerID && sess.RevokedAt == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
@@ -2,50 +2,135 @@ package app
 
 import (
 	"context"
-	"crypto/sha1"
+	"errors"
 	"fmt"
-	"io"
+	"net/url"
 	"strconv"
-	"time"
 
 	"github.com/oshankkumar/sockshop/api"
+	cardutil "github.com/oshankkumar/sockshop/internal/card"
+	"github.com/oshankkumar/sockshop/internal/cursor"
 	"github.com/oshankkumar/sockshop/internal/db"
 	"github.com/oshankkumar/sockshop/internal/domain"
+	"github.com/oshankkumar/sockshop/internal/password"
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
 )
 
+// PasswordHasher hashes passwords into self-describing hash strings.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	// Verify reports whether password matches hash, which may have been
+	// produced by any supported scheme.
+	Verify(password, hash string) (bool, error)
+	// NeedsRehash reports whether hash should be replaced by a hash of the
+	// hasher's current scheme and parameters.
+	NeedsRehash(hash string) bool
+}
+
 type UserService struct {
+	Hasher       PasswordHasher
+	Cards        CardTokenizer
 	UserStore    domain.UserStore
 	CardStore    domain.CardStore
 	AddressStore domain.AddressStore
-	TxBeginner   db.TxBeginner
-	Domain       string
+	// OrderStore holds the orders whose cards must be kept while unpaid.
+	OrderStore domain.OrderStore
+	TxBeginner db.TxBeginner
+	Cursors    *cursor.Codec
+	Domain     string
+	// Throttle slows down password guessing, logins are not throttled when
+	// nil.
+	Throttle *LoginThrottle
+	// Sessions are the sessions revoked when the password changes.
+	Sessions domain.SessionStore
+	// MFA stores the second factors, sealed by Secrets.
+	MFA     domain.MFAStore
+	Secrets SecretSealer
+	// Issuer names the shop in authenticator apps.
+	Issuer string
+	Clock  Clock
+	// Logger logs the failures which do not fail the request, none are
+	// logged when nil.
+	Logger *zap.Logger
 }
 
-func (u *UserService) Login(ctx context.Context, username, password string) (*api.User, error) {
+// maxUsernameLen is the length of the longest username Register accepts.
+const maxUsernameLen = 20
+
+// dummyPasswordHash is the argon2id hash of a random password that nobody
+// knows. Logins of unknown usernames verify against it so that they take as
+// long as those of known usernames.
+const dummyPasswordHash = "$argon2id$v=19$m=19456,t=2,p=1$+mzGcZBhv86vT2x0BJXCmQ$cMAHdA2rH+ucFj8S5o0B/isirfT9iXFH43JupGeInlc"
+
+// Login checks the password of the user. Unknown usernames fail like wrong
+// passwords with api.ErrUnauthorized, so that logins do not tell which
+// usernames exist. Failures are throttled by Throttle, per username and per
+// clientIP.
+func (u *UserService) Login(ctx context.Context, username, password, clientIP string) (*api.User, error) {
+	if len(username) > maxUsernameLen {
+		return nil, fmt.Errorf("UserService.Login: username too long: %w", api.ErrUnauthorized)
+	}
+
+	if u.Throttle != nil {
+		if err := u.Throttle.Check(ctx, username, clientIP); err != nil {
+			return nil, fmt.Errorf("UserService.Login(username=%s): %w", username, err)
+		}
+	}
+
 	user, err := u.UserStore.GetUserByName(ctx, username)
-	if err != nil {
+
+	ok := false
+
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		// The result is ignored, only the time it takes matters.
+		_, _ = u.verifyPassword(password, domain.User{Password: dummyPasswordHash})
+	case err != nil:
 		return nil, fmt.Errorf("UserService.Login(username=%s): %w", username, err)
+	default:
+		ok, err = u.verifyPassword(password, user)
+		if err != nil {
+			return nil, fmt.Errorf("UserService.Login(username=%s): %w", username, err)
+		}
 	}
 
-	passHash := calculatePassHash(password, user.Salt)
+	if !ok {
+		if u.Throttle != nil {
+			if err := u.Throttle.Fail(ctx, username, clientIP); err != nil {
+				return nil, fmt.Errorf("UserService.Login(username=%s): %w", username, err)
+			}
+		}
+		return nil, fmt.Errorf("UserService.Login(username=%s): %w", username, api.ErrUnauthorized)
+	}
+
+	// The failures of customers with a second factor are only cleared once
+	// they complete it, so that a right password does not reset the
+	// throttling of wrong codes.
+	if u.Throttle != nil {
+		enabled, err := u.mfaEnabled(ctx, user.ID.String())
+		if err != nil {
+			return nil, fmt.Errorf("UserService.Login(username=%s): %w", username, err)
+		}
 
-	if user.Password != passHash {
-		return nil, fmt.Errorf("UserService.Login(password=%s): %w", password, api.ErrUnauthorized)
+		if !enabled {
+			if err := u.Throttle.Succeed(ctx, username); err != nil {
+				return nil, fmt.Errorf("UserService.Login(username=%s): %w", username, err)
+			}
+		}
 	}
 
-	usr := &api.User{
-		FirstName: user.FirstName,
-		LastName:  user.LastName,
-		Username:  username,
-		Email:     user.Email,
-		ID:        user.ID,
-		Links:     api.NewCustomerLinks(u.Domain, user.ID.String()),
+	if u.Hasher.NeedsRehash(user.Password) {
+		// A failed upgrade is not fatal: the current hash keeps working and
+		// the upgrade is attempted again on the next login.
+		if err := u.rehashPassword(ctx, user.ID.String(), password); err != nil && u.Logger != nil {
+			u.Logger.Warn("upgrading password hash failed", zap.String("customer_id", user.ID.String()), zap.Error(err))
+		}
 	}
 
-	return usr, nil
+	return u.toAPIUser(user), nil
 }
 
 func (u *UserService) Register(ctx context.Context, user api.User) (uuid.UUID, error) {
@@ -54,10 +139,13 @@ func (u *UserService) Register(ctx context.Context, user api.User) (uuid.UUID, e
 		LastName:  user.LastName,
 		Email:     user.Email,
 		Username:  user.Username,
-		Salt:      salt(),
 	}
 
-	userM.Password = calculatePassHash(user.Password, userM.Salt)
+	hash, err := u.Hasher.Hash(user.Password)
+	if err != nil {
+		return uuid.UUID{}, fmt.Errorf("UserService.Register(username=%s): %w", user.Username, err)
+	}
+	userM.Password = hash
 
 	if err := u.UserStore.CreateUser(ctx, userM); err != nil {
 		return uuid.UUID{}, fmt.Errorf("UserService.Register(username=%s): %w", user.Username, err)
@@ -66,44 +154,108 @@ func (u *UserService) Register(ctx context.Context, user api.User) (uuid.UUID, e
 	return userM.ID, nil
 }
 
+// BootstrapAdmin grants the admin role to the user, registering it first if
+// no user goes by its username.
+func (u *UserService) BootstrapAdmin(ctx context.Context, user api.User) (uuid.UUID, error) {
+	var id uuid.UUID
+
+	err := db.RunInTransaction(ctx, u.TxBeginner, func(ctx context.Context, tx *sqlx.Tx) error {
+		userStore := u.UserStore.WithTx(tx)
+
+		existing, err := userStore.GetUserByName(ctx, user.Username)
+
+		switch {
+		case errors.Is(err, domain.ErrNotFound):
+			if user.Password == "" {
+				return fmt.Errorf("%w: a password is required to register the admin", domain.ErrInvalid)
+			}
+
+			hash, err := u.Hasher.Hash(user.Password)
+			if err != nil {
+				return err
+			}
+
+			userM := &domain.User{
+				FirstName: user.FirstName,
+				LastName:  user.LastName,
+				Email:     user.Email,
+				Username:  user.Username,
+				Password:  hash,
+			}
+			if err := userStore.CreateUser(ctx, userM); err != nil {
+				return err
+			}
+			id = userM.ID
+		case err != nil:
+			return err
+		default:
+			id = existing.ID
+		}
+
+		return userStore.AddUserRole(ctx, id.String(), domain.RoleAdmin)
+	})
+
+	if err != nil {
+		return uuid.UUID{}, fmt.Errorf("UserService.BootstrapAdmin(username=%s): %w", user.Username, err)
+	}
+
+	return id, nil
+}
+
 func (u *UserService) GetUser(ctx context.Context, id string) (*api.User, error) {
 	user, err := u.UserStore.GetUser(ctx, id)
 	if err != nil {
 		return nil, fmt.Errorf("UserService.GetUser(id=%s): %w", id, err)
 	}
 
-	usr := &api.User{
-		FirstName: user.FirstName,
-		LastName:  user.LastName,
-		Username:  user.Username,
-		Email:     user.Email,
-		ID:        user.ID,
-		Links:     api.NewCustomerLinks(u.Domain, user.ID.String()),
-	}
+	return u.toAPIUser(user), nil
+}
 
-	return usr, nil
+// userCursor is the keyset position carried by the opaque customers cursor.
+type userCursor struct {
+	ID string `json:"id"`
 }
 
-func (u *UserService) GetUsers(ctx context.Context) ([]api.User, error) {
-	users, err := u.UserStore.GetUsers(ctx)
+func (u *UserService) ListUsers(ctx context.Context, req *api.ListUsersParams) (*api.ListUsersResponse, error) {
+	// Fetch one extra user to find out whether there is a next page.
+	page := domain.Page{Limit: req.PageSize + 1}
+
+	if req.Cursor != "" {
+		var c userCursor
+		if err := u.Cursors.Decode(req.Cursor, &c); err != nil {
+			return nil, fmt.Errorf("UserService.ListUsers: %w: %v", domain.ErrInvalid, err)
+		}
+		page.After = &domain.Key{ID: c.ID}
+	}
+
+	users, err := u.UserStore.GetUsers(ctx, page)
 	if err != nil {
-		return nil, fmt.Errorf("UserService.GetUser: %w", err)
+		return nil, fmt.Errorf("UserService.ListUsers: %w", err)
+	}
+
+	var nextCursor string
+	if len(users) > req.PageSize {
+		users = users[:req.PageSize]
+
+		nextCursor, err = u.Cursors.Encode(userCursor{ID: users[len(users)-1].ID.String()})
+		if err != nil {
+			return nil, fmt.Errorf("UserService.ListUsers: %w", err)
+		}
 	}
 
 	var usrs []api.User
 
 	for _, user := range users {
-		usrs = append(usrs, api.User{
-			FirstName: user.FirstName,
-			LastName:  user.LastName,
-			Username:  user.Username,
-			Email:     user.Email,
-			ID:        user.ID,
-			Links:     api.NewCustomerLinks(u.Domain, user.ID.String()),
-		})
+		usrs = append(usrs, *u.toAPIUser(user))
 	}
 
-	return usrs, nil
+	query := url.Values{"size": {strconv.Itoa(req.PageSize)}}
+
+	return &api.ListUsersResponse{
+		Customers:  usrs,
+		NextCursor: nextCursor,
+		Links:      api.NewCursorLinks(u.Domain, "/customers", query, req.Cursor, nextCursor),
+	}, nil
 }
 
 func (u *UserService) CreateAddress(ctx context.Context, addr api.Address, userID string) (uuid.UUID, error) {
@@ -136,17 +288,30 @@ func (u *UserService) CreateAddress(ctx context.Context, addr api.Address, userI
 	return addrM.ID, nil
 }
 
+// CreateCard stores the card with its number tokenized. The CCV is dropped.
 func (u *UserService) CreateCard(ctx context.Context, card api.Card, userID string) (uuid.UUID, error) {
-	cardM := &domain.Card{
-		LongNum: card.LongNum,
+	number := cardutil.Normalize(card.LongNum)
+
+	if err := validateCard(number, card.Expires, now(u.Clock)); err != nil {
+		return uuid.UUID{}, fmt.Errorf("UserService.CreateCard(userID=%s): %w", userID, err)
+	}
+
+	token, err := u.Cards.Tokenize(ctx, number)
+	if err != nil {
+		return uuid.UUID{}, fmt.Errorf("UserService.CreateCard(userID=%s): %w", userID, err)
+	}
+
+	cardM := domain.Card{
+		Token:   token,
+		Last4:   cardutil.Last4(number),
+		Brand:   cardutil.Brand(number),
 		Expires: card.Expires,
-		CCV:     card.CCV,
 	}
 
-	err := db.RunInTransaction(ctx, u.TxBeginner, func(ctx context.Context, tx *sqlx.Tx) error {
+	err = db.RunInTransaction(ctx, u.TxBeginner, func(ctx context.Context, tx *sqlx.Tx) error {
 		cardStore, userStore := u.CardStore.WithTx(tx), u.UserStore.WithTx(tx)
 
-		if err := cardStore.CreateCard(ctx, cardM); err != nil {
+		if err := cardStore.CreateCard(ctx, &cardM); err != nil {
 			return err
 		}
 
@@ -164,41 +329,45 @@ func (u *UserService) CreateCard(ctx context.Context, card api.Card, userID stri
 	return cardM.ID, nil
 }
 
-func (u *UserService) GetAddresses(ctx context.Context, id string) (*api.Address, error) {
+func (u *UserService) GetAddresses(ctx context.Context, userID, id string) (*api.Address, error) {
+	if err := u.checkOwned(ctx, userID, domain.EntityAddress, id); err != nil {
+		return nil, fmt.Errorf("UserService.GetAddresses(userID=%s, id=%s): %w", userID, id, err)
+	}
+
 	addrM, err := u.UserStore.GetAddress(ctx, id)
 	if err != nil {
-		return nil, fmt.Errorf("UserService.GetAddresses(id=%s): %w", id, err)
+		return nil, fmt.Errorf("UserService.GetAddresses(userID=%s, id=%s): %w", userID, id, err)
 	}
 
-	addr := &api.Address{
-		ID:       addrM.ID,
-		Street:   addrM.Street,
-		Number:   addrM.Number,
-		Country:  addrM.Country,
-		City:     addrM.City,
-		PostCode: addrM.PostCode,
-		Links:    api.NewAddressLinks(u.Domain, addrM.ID.String()),
+	return u.toAPIAddress(addrM), nil
+}
+
+func (u *UserService) GetCard(ctx context.Context, userID, id string) (*api.Card, error) {
+	if err := u.checkOwned(ctx, userID, domain.EntityCard, id); err != nil {
+		return nil, fmt.Errorf("UserService.GetCard(userID=%s, id=%s): %w", userID, id, err)
+	}
+
+	cardM, err := u.UserStore.GetCard(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("UserService.GetCard(userID=%s, id=%s): %w", userID, id, err)
 	}
 
-	return addr, nil
+	return u.toAPICard(cardM), nil
 }
 
-func (u *UserService) GetCard(ctx context.Context, id string) (*api.Card, error) {
-	cardM, err := u.UserStore.GetCard(ctx, id)
+// checkOwned fails with ErrNotFound unless the address or card belongs to
+// the user, so that the existence of the others is not disclosed.
+func (u *UserService) checkOwned(ctx context.Context, userID, entity, id string) error {
+	user, err := u.UserStore.GetUser(ctx, userID)
 	if err != nil {
-		return nil, fmt.Errorf("UserService.GetCard(id=%s): %w", id, err)
+		return err
 	}
 
-	card := &api.Card{
-		ID:      cardM.ID,
-		LongNum: cardM.LongNum,
-		Expires: cardM.Expires,
-		CCV:     cardM.CCV,
-		Links:   api.NewCardLinks(u.Domain, cardM.ID.String()),
+	if !owns(user, entity, id) {
+		return domain.ErrNotFound
 	}
-	card.MaskCC()
 
-	return card, nil
+	return nil
 }
 
 func (u *UserService) GetUserCards(ctx context.Context, userID string) ([]api.Card, error) {
@@ -209,15 +378,7 @@ func (u *UserService) GetUserCards(ctx context.Context, userID string) ([]api.Ca
 
 	var cards []api.Card
 	for _, c := range cardsM {
-		card := api.Card{
-			ID:      c.ID,
-			LongNum: c.LongNum,
-			Expires: c.Expires,
-			CCV:     c.CCV,
-			Links:   api.NewCardLinks(u.Domain, c.ID.String()),
-		}
-		card.MaskCC()
-		cards = append(cards, card)
+		cards = append(cards, *u.toAPICard(c))
 	}
 
 	return cards, nil
@@ -231,29 +392,317 @@ func (u *UserService) GetUserAddresses(ctx context.Context, userID string) ([]ap
 
 	var addresses []api.Address
 	for _, adr := range addrsM {
-		addresses = append(addresses, api.Address{
-			ID:       adr.ID,
-			Street:   adr.Street,
-			Number:   adr.Number,
-			Country:  adr.Country,
-			City:     adr.City,
-			PostCode: adr.PostCode,
-			Links:    api.NewAddressLinks(u.Domain, adr.ID.String()),
-		})
+		addresses = append(addresses, *u.toAPIAddress(adr))
 	}
 
 	return addresses, nil
 }
 
-func calculatePassHash(pass, salt string) string {
-	h := sha1.New()
-	_, _ = io.WriteString(h, salt)
-	_, _ = io.WriteString(h, pass)
-	return fmt.Sprintf("%x", h.Sum(nil))
+// DeleteUser deletes the customer and all of its data. Customers with orders
+// can only be erased: with erase set, the customer's personal data is
+// anonymised instead and its order history is kept. Customers with unpaid
+// orders can be neither, as their cards are still needed.
+func (u *UserService) DeleteUser(ctx context.Context, id string, erase bool) error {
+	err := db.RunInTransaction(ctx, u.TxBeginner, func(ctx context.Context, tx *sqlx.Tx) error {
+		if err := checkUnpaidOrders(ctx, u.OrderStore.WithTx(tx), id, ""); err != nil {
+			return err
+		}
+
+		if erase {
+			return u.UserStore.WithTx(tx).Anonymise(ctx, id)
+		}
+		return u.UserStore.WithTx(tx).Delete(ctx, domain.EntityCustomer, id)
+	})
+
+	if err != nil {
+		return fmt.Errorf("UserService.DeleteUser(id=%s, erase=%t): %w", id, erase, err)
+	}
+
+	return nil
+}
+
+func (u *UserService) DeleteAddress(ctx context.Context, userID, id string) error {
+	if err := u.deleteOwned(ctx, userID, domain.EntityAddress, id); err != nil {
+		return fmt.Errorf("UserService.DeleteAddress(userID=%s, id=%s): %w", userID, id, err)
+	}
+
+	return nil
+}
+
+func (u *UserService) DeleteCard(ctx context.Context, userID, id string) error {
+	if err := u.deleteOwned(ctx, userID, domain.EntityCard, id); err != nil {
+		return fmt.Errorf("UserService.DeleteCard(userID=%s, id=%s): %w", userID, id, err)
+	}
+
+	return nil
+}
+
+// deleteOwned deletes the address or card if it belongs to the user, and
+// fails with ErrNotFound otherwise. A card charged for an unpaid order is
+// kept.
+func (u *UserService) deleteOwned(ctx context.Context, userID, entity, id string) error {
+	return db.RunInTransaction(ctx, u.TxBeginner, func(ctx context.Context, tx *sqlx.Tx) error {
+		userStore := u.UserStore.WithTx(tx)
+
+		user, err := userStore.GetUser(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		if !owns(user, entity, id) {
+			return domain.ErrNotFound
+		}
+
+		if entity == domain.EntityCard {
+			if err := checkUnpaidOrders(ctx, u.OrderStore.WithTx(tx), userID, id); err != nil {
+				return err
+			}
+		}
+
+		return userStore.Delete(ctx, entity, id)
+	})
+}
+
+// checkUnpaidOrders fails with ErrConflict if an unpaid order of the customer
+// is charged to the card, or to any card when cardID is empty.
+func checkUnpaidOrders(ctx context.Context, orderStore domain.OrderStore, customerID, cardID string) error {
+	orders, err := orderStore.GetCustomerOrders(ctx, customerID)
+	if err != nil {
+		return err
+	}
+
+	for _, o := range orders {
+		if o.Status.Unpaid() && (cardID == "" || o.CardID == cardID) {
+			return fmt.Errorf("%w: order %s is awaiting its payment", domain.ErrConflict, o.ID)
+		}
+	}
+
+	return nil
+}
+
+// owns reports whether the address or card belongs to the user.
+func owns(user domain.User, entity, id string) bool {
+	owned := user.AddressIDs
+	if entity == domain.EntityCard {
+		owned = user.CardIDs
+	}
+
+	for _, ownedID := range owned {
+		if ownedID == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// PatchUser applies the patch to the user's profile. A changed email must not
+// be used by another user.
+func (u *UserService) PatchUser(ctx context.Context, id string, patch api.UserPatch, version int) (*api.User, error) {
+	var user domain.User
+
+	err := db.RunInTransaction(ctx, u.TxBeginner, func(ctx context.Context, tx *sqlx.Tx) error {
+		userStore := u.UserStore.WithTx(tx)
+
+		var err error
+		if user, err = userStore.GetUser(ctx, id); err != nil {
+			return err
+		}
+
+		if user.Version != version {
+			return domain.ErrVersionMismatch
+		}
+
+		if patch.FirstName != nil {
+			user.FirstName = *patch.FirstName
+		}
+		if patch.LastName != nil {
+			user.LastName = *patch.LastName
+		}
+		if patch.Email != nil {
+			if *patch.Email == "" {
+				return fmt.Errorf("%w: email is required", domain.ErrInvalid)
+			}
+			// A new email is unverified, as the store records it.
+			user.EmailVerified = user.EmailVerified && *patch.Email == user.Email
+			user.Email = *patch.Email
+		}
+
+		return userStore.UpdateUser(ctx, &user)
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("UserService.PatchUser(id=%s): %w", id, err)
+	}
+
+	return u.toAPIUser(user), nil
 }
 
-func salt() string {
-	h := sha1.New()
-	_, _ = io.WriteString(h, strconv.Itoa(int(time.Now().UnixNano())))
-	return fmt.Sprintf("%x", h.Sum(nil))
+func (u *UserService) UpdateAddress(ctx context.Context, userID, id string, addr api.Address, version int) (*api.Address, error) {
+	addrID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, fmt.Errorf("UserService.UpdateAddress(userID=%s, id=%s): %w", userID, id, domain.ErrNotFound)
+	}
+
+	addrM := domain.Address{
+		ID:       addrID,
+		Street:   addr.Street,
+		Number:   addr.Number,
+		Country:  addr.Country,
+		City:     addr.City,
+		PostCode: addr.PostCode,
+		Version:  version,
+	}
+
+	err = db.RunInTransaction(ctx, u.TxBeginner, func(ctx context.Context, tx *sqlx.Tx) error {
+		user, err := u.UserStore.WithTx(tx).GetUser(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		if !owns(user, domain.EntityAddress, id) {
+			return domain.ErrNotFound
+		}
+
+		return u.AddressStore.WithTx(tx).UpdateAddress(ctx, &addrM)
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("UserService.UpdateAddress(userID=%s, id=%s): %w", userID, id, err)
+	}
+
+	return u.toAPIAddress(addrM), nil
+}
+
+// UpdateCard updates the expiry of the card. The card number and CCV cannot be
+// changed; a new card has to be added instead.
+func (u *UserService) UpdateCard(ctx context.Context, userID, id string, patch api.CardPatch, version int) (*api.Card, error) {
+	if err := validateExpiry(patch.Expires, now(u.Clock)); err != nil {
+		verr := domain.ValidationError{Fields: []domain.FieldError{{Field: "expires", Message: err.Error()}}}
+		return nil, fmt.Errorf("UserService.UpdateCard(userID=%s, id=%s): %w", userID, id, verr)
+	}
+
+	var card domain.Card
+
+	err := db.RunInTransaction(ctx, u.TxBeginner, func(ctx context.Context, tx *sqlx.Tx) error {
+		userStore := u.UserStore.WithTx(tx)
+
+		user, err := userStore.GetUser(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		if !owns(user, domain.EntityCard, id) {
+			return domain.ErrNotFound
+		}
+
+		if card, err = userStore.GetCard(ctx, id); err != nil {
+			return err
+		}
+
+		card.Expires, card.Version = patch.Expires, version
+
+		return u.CardStore.WithTx(tx).UpdateCard(ctx, &card)
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("UserService.UpdateCard(userID=%s, id=%s): %w", userID, id, err)
+	}
+
+	return u.toAPICard(card), nil
+}
+
+func (u *UserService) ChangePassword(ctx context.Context, id, sessionID, current, newPassword string) error {
+	if newPassword == "" {
+		return fmt.Errorf("UserService.ChangePassword(id=%s): %w: new password is required", id, domain.ErrInvalid)
+	}
+
+	user, err := u.UserStore.GetUser(ctx, id)
+	if err != nil {
+		return fmt.Errorf("UserService.ChangePassword(id=%s): %w", id, err)
+	}
+
+	ok, err := u.verifyPassword(current, user)
+	if err != nil {
+		return fmt.Errorf("UserService.ChangePassword(id=%s): %w", id, err)
+	}
+
+	if !ok {
+		return fmt.Errorf("UserService.ChangePassword(id=%s): current password: %w", id, api.ErrForbidden)
+	}
+
+	if err := u.rehashPassword(ctx, id, newPassword); err != nil {
+		return fmt.Errorf("UserService.ChangePassword(id=%s): %w", id, err)
+	}
+
+	// Whoever knew the old password is logged out, but not the customer
+	// changing it.
+	if err := u.Sessions.RevokeCustomerSessions(ctx, id, sessionID); err != nil {
+		return fmt.Errorf("UserService.ChangePassword(id=%s): %w", id, err)
+	}
+
+	return nil
+}
+
+func (u *UserService) toAPIUser(user domain.User) *api.User {
+	return &api.User{
+		FirstName: user.FirstName,
+		LastName:  user.LastName,
+		Username:  user.Username,
+		Email:     user.Email,
+		ID:        user.ID,
+		Links:     api.NewCustomerLinks(u.Domain, user.ID.String()),
+		Version:   user.Version,
+
+		EmailVerified: user.EmailVerified,
+	}
+}
+
+func (u *UserService) toAPIAddress(addr domain.Address) *api.Address {
+	return &api.Address{
+		ID:       addr.ID,
+		Street:   addr.Street,
+		Number:   addr.Number,
+		Country:  addr.Country,
+		City:     addr.City,
+		PostCode: addr.PostCode,
+		Links:    api.NewAddressLinks(u.Domain, addr.ID.String()),
+		Version:  addr.Version,
+	}
+}
+
+func (u *UserService) toAPICard(c domain.Card) *api.Card {
+	return &api.Card{
+		ID:      c.ID,
+		Last4:   c.Last4,
+		Brand:   c.Brand,
+		Expires: c.Expires,
+		Links:   api.NewCardLinks(u.Domain, c.ID.String()),
+		Version: c.Version,
+	}
+}
+
+// verifyPassword checks password against the user's hash. Users registered
+// before passwords were hashed with a PasswordHasher carry a legacy salted
+// SHA-1 hash.
+func (u *UserService) verifyPassword(pass string, user domain.User) (bool, error) {
+	// Customers registered by an identity provider have no password.
+	if user.Password == "" {
+		return false, nil
+	}
+
+	if password.IsLegacy(user.Password) {
+		return password.VerifyLegacy(pass, user.Salt, user.Password), nil
+	}
+
+	return u.Hasher.Verify(pass, user.Password)
+}
+
+func (u *UserService) rehashPassword(ctx context.Context, userID, pass string) error {
+	hash, err := u.Hasher.Hash(pass)
+	if err != nil {
+		return err
+	}
+
+	return u.UserStore.UpdatePassword(ctx, userID, hash)
 }
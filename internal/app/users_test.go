@@ -0,0 +1,484 @@
+package app_test
+
+import (
+	"context"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/oshankkumar/sockshop/api"
+	"github.com/oshankkumar/sockshop/internal/app"
+	"github.com/oshankkumar/sockshop/internal/domain"
+	"github.com/oshankkumar/sockshop/internal/password"
+)
+
+func TestUserServiceLoginUpgradesHash(t *testing.T) {
+	hasher := password.Bcrypt{Cost: 4}
+
+	legacySalt := "c748112bc027878aa62812ba1ae00e40ad46d497"
+	legacy := fmt.Sprintf("%x", sha1.Sum([]byte(legacySalt+"s3cret")))
+
+	weaker, err := password.Bcrypt{Cost: 5}.Hash("s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	current, err := hasher.Hash("s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		hash       string
+		salt       string
+		password   string
+		wantErr    error
+		wantRehash bool
+	}{
+		{name: "legacy hash", hash: legacy, salt: legacySalt, password: "s3cret", wantRehash: true},
+		{name: "legacy hash wrong password", hash: legacy, salt: legacySalt, password: "wrong", wantErr: api.ErrUnauthorized},
+		{name: "outdated cost", hash: weaker, password: "s3cret", wantRehash: true},
+		{name: "current hash", hash: current, password: "s3cret"},
+		{name: "current hash wrong password", hash: current, password: "wrong", wantErr: api.ErrUnauthorized},
+		{name: "no password", hash: "", password: "", wantErr: api.ErrUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := domain.User{ID: uuid.New(), Username: "alice", Password: tt.hash, Salt: tt.salt}
+			users := newUserStore(user)
+			svc := &app.UserService{UserStore: users, Hasher: hasher}
+			ctx := context.Background()
+
+			_, err := svc.Login(ctx, "alice", tt.password, "10.0.0.1")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Login: got %v, want %v", err, tt.wantErr)
+			}
+
+			stored, _ := users.GetUser(ctx, user.ID.String())
+
+			if rehashed := stored.Password != tt.hash; rehashed != tt.wantRehash {
+				t.Fatalf("got rehashed %v, want %v", rehashed, tt.wantRehash)
+			}
+
+			if !tt.wantRehash {
+				return
+			}
+
+			if hasher.NeedsRehash(stored.Password) || stored.Salt != "" {
+				t.Fatalf("got hash %q and salt %q, want a current hash", stored.Password, stored.Salt)
+			}
+
+			// The upgraded hash keeps working.
+			if _, err := svc.Login(ctx, "alice", tt.password, "10.0.0.1"); err != nil {
+				t.Fatalf("Login after the upgrade: %v", err)
+			}
+		})
+	}
+}
+
+// verifyCounter counts the passwords verified by the hasher.
+type verifyCounter struct {
+	app.PasswordHasher
+	hashes []string
+}
+
+func (v *verifyCounter) Verify(password, hash string) (bool, error) {
+	v.hashes = append(v.hashes, hash)
+	return v.PasswordHasher.Verify(password, hash)
+}
+
+func TestUserServiceLoginUnknownUsername(t *testing.T) {
+	hasher := &verifyCounter{PasswordHasher: password.Bcrypt{Cost: 4}}
+	svc := &app.UserService{UserStore: newUserStore(), Hasher: hasher}
+
+	_, err := svc.Login(context.Background(), "bob", "s3cret", "10.0.0.1")
+	if !errors.Is(err, api.ErrUnauthorized) {
+		t.Fatalf("Login: got %v, want %v", err, api.ErrUnauthorized)
+	}
+
+	// A password is still verified, against a hash as costly as those of
+	// the known usernames, so that unknown usernames take as long.
+	if len(hasher.hashes) != 1 || password.DefaultArgon2id.NeedsRehash(hasher.hashes[0]) {
+		t.Fatalf("got verified hashes %q, want one default argon2id hash", hasher.hashes)
+	}
+}
+
+// failingPasswordStore fails every password update.
+type failingPasswordStore struct {
+	*userStore
+}
+
+func (s failingPasswordStore) UpdatePassword(context.Context, string, string) error {
+	return errors.New("connection reset")
+}
+
+func TestUserServiceLoginLogsFailedRehash(t *testing.T) {
+	legacySalt := "c748112bc027878aa62812ba1ae00e40ad46d497"
+	user := domain.User{
+		ID:       uuid.New(),
+		Username: "alice",
+		Password: fmt.Sprintf("%x", sha1.Sum([]byte(legacySalt+"s3cret"))),
+		Salt:     legacySalt,
+	}
+
+	core, logs := observer.New(zap.WarnLevel)
+	svc := &app.UserService{
+		UserStore: failingPasswordStore{newUserStore(user)},
+		Hasher:    password.Bcrypt{Cost: 4},
+		Logger:    zap.New(core),
+	}
+
+	// The legacy hash keeps working.
+	if _, err := svc.Login(context.Background(), "alice", "s3cret", "10.0.0.1"); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+
+	entries := logs.All()
+	if len(entries) != 1 {
+		t.Fatalf("got %d log entries, want 1", len(entries))
+	}
+
+	fields := entries[0].ContextMap()
+	if fields["customer_id"] != user.ID.String() || fields["error"] != "connection reset" {
+		t.Fatalf("got log entry %q %v", entries[0].Message, fields)
+	}
+}
+
+func TestUserServiceChangePassword(t *testing.T) {
+	hasher := password.Bcrypt{Cost: 4}
+
+	hash, err := hasher.Hash("0ld-passw0rd")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		current     string
+		newPassword string
+		wantErr     error
+	}{
+		{name: "changed", current: "0ld-passw0rd", newPassword: "n3w-passw0rd"},
+		{name: "wrong current password", current: "wrong", newPassword: "n3w-passw0rd", wantErr: api.ErrForbidden},
+		{name: "no new password", current: "0ld-passw0rd", wantErr: domain.ErrInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			alice := domain.User{ID: uuid.New(), Username: "alice", Password: hash}
+			bob := domain.User{ID: uuid.New(), Username: "bob"}
+
+			users, sessions := newUserStore(alice, bob), newSessionStore()
+			svc := &app.UserService{UserStore: users, Sessions: sessions, Hasher: hasher}
+
+			current := domain.Session{CustomerID: alice.ID.String()}
+			for _, s := range []*domain.Session{&current, {CustomerID: alice.ID.String()}, {CustomerID: bob.ID.String()}} {
+				if err := sessions.CreateSession(ctx, s); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := svc.ChangePassword(ctx, alice.ID.String(), current.ID.String(), tt.current, tt.newPassword)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ChangePassword: got %v, want %v", err, tt.wantErr)
+			}
+
+			// The other sessions of the customer are revoked only once the
+			// password changed.
+			active := sessions.active(alice.ID.String())
+			switch {
+			case tt.wantErr != nil && len(active) != 2:
+				t.Fatalf("got %d active sessions, want both", len(active))
+			case tt.wantErr == nil && (len(active) != 1 || active[0] != current.ID.String()):
+				t.Fatalf("got active sessions %v, want the current one %s only", active, current.ID)
+			}
+			if active := sessions.active(bob.ID.String()); len(active) != 1 {
+				t.Fatalf("got %d active sessions of another customer, want 1", len(active))
+			}
+
+			stored, _ := users.GetUser(ctx, alice.ID.String())
+			if ok, _ := hasher.Verify(tt.newPassword, stored.Password); ok != (tt.wantErr == nil) {
+				t.Fatalf("new password verifies %v, want %v", ok, tt.wantErr == nil)
+			}
+		})
+	}
+}
+
+func TestUserServiceDeleteCard(t *testing.T) {
+	tests := []struct {
+		name string
+		// orderStatus is the status of an order charged to the card, none
+		// when empty.
+		orderStatus domain.OrderStatus
+		otherUser   bool
+		wantErr     error
+	}{
+		{name: "deleted"},
+		{name: "paid order", orderStatus: domain.OrderStatusPaid},
+		{name: "unpaid order", orderStatus: domain.OrderStatusCreated, wantErr: domain.ErrConflict},
+		{name: "declined order", orderStatus: domain.OrderStatusPaymentDeclined, wantErr: domain.ErrConflict},
+		{name: "card of another customer", otherUser: true, wantErr: domain.ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			card, other := uuid.NewString(), uuid.NewString()
+			alice := domain.User{ID: uuid.New(), Username: "alice", CardIDs: []string{card, other}}
+			bob := domain.User{ID: uuid.New(), Username: "bob"}
+
+			users, orders := newUserStore(alice, bob), newOrderStore()
+			svc := &app.UserService{UserStore: users, OrderStore: orders, TxBeginner: newNoopTxBeginner(t)}
+
+			if tt.orderStatus != "" {
+				if err := orders.CreateOrder(ctx, &domain.Order{CustomerID: alice.ID.String(), CardID: card, Status: tt.orderStatus}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// An unpaid order charged to another card does not matter.
+			if err := orders.CreateOrder(ctx, &domain.Order{CustomerID: alice.ID.String(), CardID: other, Status: domain.OrderStatusCreated}); err != nil {
+				t.Fatal(err)
+			}
+
+			owner := alice
+			if tt.otherUser {
+				owner = bob
+			}
+
+			err := svc.DeleteCard(ctx, owner.ID.String(), card)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("DeleteCard: got %v, want %v", err, tt.wantErr)
+			}
+
+			stored, _ := users.GetUser(ctx, alice.ID.String())
+			want := []string{other}
+			if tt.wantErr != nil {
+				want = []string{card, other}
+			}
+			if !reflect.DeepEqual(stored.CardIDs, want) {
+				t.Fatalf("got cards %v, want %v", stored.CardIDs, want)
+			}
+		})
+	}
+}
+
+func TestUserServiceDeleteAddress(t *testing.T) {
+	ctx := context.Background()
+	addr := uuid.NewString()
+	alice := domain.User{ID: uuid.New(), Username: "alice", AddressIDs: []string{addr}}
+	bob := domain.User{ID: uuid.New(), Username: "bob"}
+
+	users := newUserStore(alice, bob)
+	svc := &app.UserService{UserStore: users, OrderStore: newOrderStore(), TxBeginner: newNoopTxBeginner(t)}
+
+	if err := svc.DeleteAddress(ctx, bob.ID.String(), addr); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("DeleteAddress of another customer: got %v, want %v", err, domain.ErrNotFound)
+	}
+	if err := svc.DeleteAddress(ctx, alice.ID.String(), addr); err != nil {
+		t.Fatalf("DeleteAddress: %v", err)
+	}
+
+	stored, _ := users.GetUser(ctx, alice.ID.String())
+	if len(stored.AddressIDs) != 0 {
+		t.Fatalf("got addresses %v, want none", stored.AddressIDs)
+	}
+}
+
+func TestUserServiceDeleteUser(t *testing.T) {
+	tests := []struct {
+		name        string
+		erase       bool
+		orderStatus domain.OrderStatus
+		wantErr     error
+		// wantDeleted and wantErased tell whether the customer was deleted or
+		// anonymised.
+		wantDeleted bool
+		wantErased  bool
+	}{
+		{name: "deleted", wantDeleted: true},
+		{name: "erased", erase: true, orderStatus: domain.OrderStatusDelivered, wantErased: true},
+		{name: "erased with an unpaid order", erase: true, orderStatus: domain.OrderStatusPaymentDeclined, wantErr: domain.ErrConflict},
+		{name: "deleted with an unpaid order", orderStatus: domain.OrderStatusCreated, wantErr: domain.ErrConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			alice := domain.User{ID: uuid.New(), Username: "alice"}
+
+			users, orders := newUserStore(alice), newOrderStore()
+			svc := &app.UserService{UserStore: users, OrderStore: orders, TxBeginner: newNoopTxBeginner(t)}
+
+			if tt.orderStatus != "" {
+				if err := orders.CreateOrder(ctx, &domain.Order{CustomerID: alice.ID.String(), CardID: uuid.NewString(), Status: tt.orderStatus}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := svc.DeleteUser(ctx, alice.ID.String(), tt.erase)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("DeleteUser: got %v, want %v", err, tt.wantErr)
+			}
+
+			_, err = users.GetUser(ctx, alice.ID.String())
+			if deleted := errors.Is(err, domain.ErrNotFound); deleted != tt.wantDeleted {
+				t.Fatalf("customer deleted %v, want %v", deleted, tt.wantDeleted)
+			}
+			if erased := len(users.anonymised) == 1; erased != tt.wantErased {
+				t.Fatalf("customer anonymised %v, want %v", erased, tt.wantErased)
+			}
+		})
+	}
+}
+
+func TestUserServicePatchUser(t *testing.T) {
+	strPtr := func(s string) *string { return &s }
+
+	tests := []struct {
+		name    string
+		patch   api.UserPatch
+		version int
+		// wantVerified tells whether the email is still verified.
+		wantVerified bool
+		wantErr      error
+	}{
+		{name: "names", patch: api.UserPatch{FirstName: strPtr("Alice"), LastName: strPtr("Smith")}, version: 1, wantVerified: true},
+		{name: "same email", patch: api.UserPatch{Email: strPtr("alice@example.com")}, version: 1, wantVerified: true},
+		{name: "new email", patch: api.UserPatch{Email: strPtr("alice@example.org")}, version: 1},
+		{name: "empty email", patch: api.UserPatch{Email: strPtr("")}, version: 1, wantVerified: true, wantErr: domain.ErrInvalid},
+		{name: "stale version", patch: api.UserPatch{FirstName: strPtr("Alice")}, version: 0, wantVerified: true, wantErr: domain.ErrVersionMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			alice := domain.User{ID: uuid.New(), Username: "alice", Email: "alice@example.com", EmailVerified: true, Version: 1}
+
+			users := newUserStore(alice)
+			svc := &app.UserService{UserStore: users, TxBeginner: newNoopTxBeginner(t)}
+
+			got, err := svc.PatchUser(ctx, alice.ID.String(), tt.patch, tt.version)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("PatchUser: got %v, want %v", err, tt.wantErr)
+			}
+
+			stored, _ := users.GetUser(ctx, alice.ID.String())
+			if stored.EmailVerified != tt.wantVerified {
+				t.Fatalf("stored email verified %v, want %v", stored.EmailVerified, tt.wantVerified)
+			}
+			if tt.wantErr != nil {
+				if stored.Version != 1 {
+					t.Fatalf("got version %d after a failed patch, want 1", stored.Version)
+				}
+				return
+			}
+
+			// The response reads the same as the customer fetched again.
+			if got.EmailVerified != stored.EmailVerified || got.Email != stored.Email || got.Version != stored.Version {
+				t.Fatalf("got %s verified %v at version %d, want %s verified %v at version %d",
+					got.Email, got.EmailVerified, got.Version, stored.Email, stored.EmailVerified, stored.Version)
+			}
+			if got.Version != 2 {
+				t.Fatalf("got version %d, want 2", got.Version)
+			}
+		})
+	}
+}
+
+func TestUserServiceUpdateAddress(t *testing.T) {
+	tests := []struct {
+		name      string
+		otherUser bool
+		version   int
+		wantErr   error
+	}{
+		{name: "updated", version: 3},
+		{name: "stale version", version: 2, wantErr: domain.ErrVersionMismatch},
+		{name: "address of another customer", otherUser: true, version: 3, wantErr: domain.ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			addr := domain.Address{ID: uuid.New(), Street: "Main Street", Number: "1", Country: "UK", City: "London", PostCode: "N1", Version: 3}
+			alice := domain.User{ID: uuid.New(), Username: "alice", AddressIDs: []string{addr.ID.String()}}
+			bob := domain.User{ID: uuid.New(), Username: "bob"}
+
+			users := newUserStore(alice, bob)
+			users.addresses = []domain.Address{addr}
+			svc := &app.UserService{UserStore: users, AddressStore: addressStore{users: users}, TxBeginner: newNoopTxBeginner(t)}
+
+			owner := alice
+			if tt.otherUser {
+				owner = bob
+			}
+
+			update := api.Address{Street: "High Street", Number: "2", Country: "UK", City: "Leeds", PostCode: "LS1"}
+			got, err := svc.UpdateAddress(ctx, owner.ID.String(), addr.ID.String(), update, tt.version)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UpdateAddress: got %v, want %v", err, tt.wantErr)
+			}
+
+			want := addr
+			if tt.wantErr == nil {
+				want = domain.Address{ID: addr.ID, Street: "High Street", Number: "2", Country: "UK", City: "Leeds", PostCode: "LS1", Version: 4}
+				if got.Street != want.Street || got.Version != want.Version {
+					t.Fatalf("got %s at version %d, want %s at version %d", got.Street, got.Version, want.Street, want.Version)
+				}
+			}
+			if !reflect.DeepEqual(users.addresses[0], want) {
+				t.Fatalf("stored %+v, want %+v", users.addresses[0], want)
+			}
+		})
+	}
+}
+
+func TestUserServiceUpdateCard(t *testing.T) {
+	tests := []struct {
+		name    string
+		expires string
+		version int
+		wantErr error
+	}{
+		{name: "updated", expires: "10/99", version: 1},
+		{name: "stale version", expires: "10/99", version: 0, wantErr: domain.ErrVersionMismatch},
+		{name: "expired", expires: "01/20", version: 1, wantErr: domain.ErrInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			card := domain.Card{ID: uuid.New(), Token: "tok_1", Last4: "1111", Expires: "12/99", Version: 1}
+			alice := domain.User{ID: uuid.New(), Username: "alice", CardIDs: []string{card.ID.String()}}
+
+			users := newUserStore(alice)
+			users.cards = []domain.Card{card}
+			svc := &app.UserService{UserStore: users, CardStore: cardStore{users: users}, TxBeginner: newNoopTxBeginner(t)}
+
+			got, err := svc.UpdateCard(ctx, alice.ID.String(), card.ID.String(), api.CardPatch{Expires: tt.expires}, tt.version)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UpdateCard: got %v, want %v", err, tt.wantErr)
+			}
+
+			want := card
+			if tt.wantErr == nil {
+				want.Expires, want.Version = tt.expires, 2
+				if got.Expires != want.Expires || got.Version != want.Version {
+					t.Fatalf("got expiry %s at version %d, want %s at version %d", got.Expires, got.Version, want.Expires, want.Version)
+				}
+			}
+			if !reflect.DeepEqual(users.cards[0], want) {
+				t.Fatalf("stored %+v, want %+v", users.cards[0], want)
+			}
+		})
+	}
+}
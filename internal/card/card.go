@@ -0,0 +1,168 @@
+// Package card validates payment card numbers and extracts their non
+// sensitive details.
+package card
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNumberFormat = errors.New("card number must be 12 to 19 digits")
+	ErrNumberLength = errors.New("card number length does not match its brand")
+	ErrChecksum     = errors.New("card number fails the Luhn checksum")
+	ErrExpiryFormat = errors.New("expiry must be MM/YY or MM/YYYY")
+	ErrExpired      = errors.New("card has expired")
+)
+
+// Card brands.
+const (
+	BrandVisa       = "visa"
+	BrandMastercard = "mastercard"
+	BrandAmex       = "amex"
+	BrandDiscover   = "discover"
+	BrandRuPay      = "rupay"
+	BrandUnknown    = "unknown"
+)
+
+// brandLengths are the valid number lengths of the brands. Unknown brands
+// take any length from 12 to 19 digits.
+var brandLengths = map[string][]int{
+	BrandVisa:       {13, 16, 19},
+	BrandMastercard: {16},
+	BrandAmex:       {15},
+	BrandDiscover:   {16, 17, 18, 19},
+	BrandRuPay:      {16},
+}
+
+// Normalize strips the spaces and dashes card numbers are often written with.
+func Normalize(number string) string {
+	return strings.NewReplacer(" ", "", "-", "").Replace(number)
+}
+
+// Brand returns the brand of the card number, found from its issuer
+// identification number.
+func Brand(number string) string {
+	switch {
+	case strings.HasPrefix(number, "4"):
+		return BrandVisa
+	case prefixIn(number, 2, 51, 55) || prefixIn(number, 4, 2221, 2720):
+		return BrandMastercard
+	case prefixIn(number, 2, 34, 34) || prefixIn(number, 2, 37, 37):
+		return BrandAmex
+	// RuPay ranges overlap Discover ones, so they are matched first.
+	case prefixIn(number, 6, 508500, 508999) || prefixIn(number, 6, 606985, 607984) ||
+		prefixIn(number, 6, 608001, 608500) || prefixIn(number, 6, 652150, 653149) ||
+		prefixIn(number, 6, 817200, 820199):
+		return BrandRuPay
+	case prefixIn(number, 4, 6011, 6011) || prefixIn(number, 2, 65, 65) || prefixIn(number, 3, 644, 649):
+		return BrandDiscover
+	default:
+		return BrandUnknown
+	}
+}
+
+// CheckNumber checks the format, length and Luhn checksum of a normalized
+// card number.
+func CheckNumber(number string) error {
+	if len(number) < 12 || len(number) > 19 || !digits(number) {
+		return ErrNumberFormat
+	}
+
+	if lengths, ok := brandLengths[Brand(number)]; ok && !contains(lengths, len(number)) {
+		return ErrNumberLength
+	}
+
+	if !Luhn(number) {
+		return ErrChecksum
+	}
+
+	return nil
+}
+
+// Luhn reports whether the digits pass the Luhn checksum.
+func Luhn(number string) bool {
+	var sum int
+	for i := range number {
+		d := int(number[len(number)-1-i] - '0')
+		if i%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+// Expiry is the month a card expires at the end of.
+type Expiry struct {
+	Month int
+	Year  int
+}
+
+// ParseExpiry parses an expiry written MM/YY or MM/YYYY.
+func ParseExpiry(s string) (Expiry, error) {
+	mm, yy, ok := strings.Cut(strings.TrimSpace(s), "/")
+	if !ok || len(mm) != 2 || (len(yy) != 2 && len(yy) != 4) || !digits(mm) || !digits(yy) {
+		return Expiry{}, ErrExpiryFormat
+	}
+
+	month, _ := strconv.Atoi(mm)
+	year, _ := strconv.Atoi(yy)
+
+	if month < 1 || month > 12 {
+		return Expiry{}, ErrExpiryFormat
+	}
+
+	if len(yy) == 2 {
+		year += 2000
+	}
+
+	return Expiry{Month: month, Year: year}, nil
+}
+
+// Expired reports whether the card has expired at t. Cards are valid until the
+// end of their expiry month.
+func (e Expiry) Expired(t time.Time) bool {
+	end := time.Date(e.Year, time.Month(e.Month)+1, 1, 0, 0, 0, 0, time.UTC)
+	return !t.Before(end)
+}
+
+// Last4 returns the last four digits of the card number.
+func Last4(number string) string {
+	if len(number) < 4 {
+		return number
+	}
+	return number[len(number)-4:]
+}
+
+// prefixIn reports whether the first n digits of number are in [lo, hi].
+func prefixIn(number string, n, lo, hi int) bool {
+	if len(number) < n || !digits(number[:n]) {
+		return false
+	}
+
+	p, _ := strconv.Atoi(number[:n])
+	return p >= lo && p <= hi
+}
+
+func digits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func contains(ns []int, n int) bool {
+	for _, m := range ns {
+		if m == n {
+			return true
+		}
+	}
+	return false
+}
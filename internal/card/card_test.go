@@ -0,0 +1,130 @@
+package card
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBrand(t *testing.T) {
+	tests := []struct {
+		number string
+		want   string
+	}{
+		{number: "4111111111111111", want: BrandVisa},
+		{number: "5555555555554444", want: BrandMastercard},
+		{number: "2223003122003222", want: BrandMastercard},
+		{number: "2720990000000007", want: BrandMastercard},
+		{number: "2721000000000000", want: BrandUnknown},
+		{number: "378282246310005", want: BrandAmex},
+		{number: "341111111111111", want: BrandAmex},
+		{number: "6011111111111117", want: BrandDiscover},
+		{number: "6445644564456445", want: BrandDiscover},
+		{number: "6500000000000002", want: BrandDiscover},
+		{number: "6521500000000000", want: BrandRuPay},
+		{number: "6085000000000000", want: BrandRuPay},
+		{number: "3530111333300000", want: BrandUnknown},
+		{number: "", want: BrandUnknown},
+		{number: "5", want: BrandUnknown},
+	}
+
+	for _, tt := range tests {
+		if got := Brand(tt.number); got != tt.want {
+			t.Errorf("Brand(%q): got %s, want %s", tt.number, got, tt.want)
+		}
+	}
+}
+
+func TestLuhn(t *testing.T) {
+	tests := []struct {
+		number string
+		want   bool
+	}{
+		{number: "4111111111111111", want: true},
+		{number: "4111111111111112", want: false},
+		{number: "378282246310005", want: true},
+		{number: "6011000990139424", want: true},
+		{number: "79927398713", want: true},
+		{number: "79927398710", want: false},
+		// Swapped adjacent digits.
+		{number: "4111111111111161", want: false},
+		{number: "0", want: true},
+	}
+
+	for _, tt := range tests {
+		if got := Luhn(tt.number); got != tt.want {
+			t.Errorf("Luhn(%q): got %v, want %v", tt.number, got, tt.want)
+		}
+	}
+}
+
+func TestCheckNumber(t *testing.T) {
+	tests := []struct {
+		number string
+		want   error
+	}{
+		{number: "4111111111111111"},
+		{number: "4222222222222"},
+		{number: "378282246310005"},
+		{number: "3530111333300000"},
+		{number: "41111111111", want: ErrNumberFormat},
+		{number: "41111111111111111111", want: ErrNumberFormat},
+		{number: "4111 1111 1111 1111", want: ErrNumberFormat},
+		{number: "411111111111111a", want: ErrNumberFormat},
+		{number: "37828224631000", want: ErrNumberLength},
+		{number: "55555555555544", want: ErrNumberLength},
+		{number: "4111111111111112", want: ErrChecksum},
+	}
+
+	for _, tt := range tests {
+		if err := CheckNumber(tt.number); !errors.Is(err, tt.want) {
+			t.Errorf("CheckNumber(%q): got %v, want %v", tt.number, err, tt.want)
+		}
+	}
+}
+
+func TestParseExpiry(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    Expiry
+		wantErr error
+	}{
+		{s: "01/27", want: Expiry{Month: 1, Year: 2027}},
+		{s: "12/2030", want: Expiry{Month: 12, Year: 2030}},
+		{s: " 06/28 ", want: Expiry{Month: 6, Year: 2028}},
+		{s: "00/27", wantErr: ErrExpiryFormat},
+		{s: "13/27", wantErr: ErrExpiryFormat},
+		{s: "1/27", wantErr: ErrExpiryFormat},
+		{s: "01/027", wantErr: ErrExpiryFormat},
+		{s: "0127", wantErr: ErrExpiryFormat},
+		{s: "01-27", wantErr: ErrExpiryFormat},
+		{s: "ab/cd", wantErr: ErrExpiryFormat},
+		{s: "", wantErr: ErrExpiryFormat},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseExpiry(tt.s)
+		if !errors.Is(err, tt.wantErr) || got != tt.want {
+			t.Errorf("ParseExpiry(%q): got %+v, %v, want %+v, %v", tt.s, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestExpiryExpired(t *testing.T) {
+	e := Expiry{Month: 12, Year: 2026}
+
+	tests := []struct {
+		at   time.Time
+		want bool
+	}{
+		{at: time.Date(2026, 12, 1, 0, 0, 0, 0, time.UTC), want: false},
+		{at: time.Date(2026, 12, 31, 23, 59, 59, 0, time.UTC), want: false},
+		{at: time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC), want: true},
+	}
+
+	for _, tt := range tests {
+		if got := e.Expired(tt.at); got != tt.want {
+			t.Errorf("Expired(%s): got %v, want %v", tt.at, got, tt.want)
+		}
+	}
+}
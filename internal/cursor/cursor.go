@@ -0,0 +1,66 @@
+// Package cursor encodes pagination cursors as opaque, tamper proof strings.
+//
+// A cursor is the base64url encoded JSON of a value followed by a dot and the
+// base64url encoded HMAC-SHA256 of that JSON.
+package cursor
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrInvalid = errors.New("invalid cursor")
+
+type Codec struct {
+	key []byte
+}
+
+func NewCodec(key []byte) *Codec {
+	return &Codec{key: key}
+}
+
+func (c *Codec) Encode(v any) (string, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("cursor.Encode: %w", err)
+	}
+
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString(payload) + "." + enc.EncodeToString(c.sign(payload)), nil
+}
+
+func (c *Codec) Decode(s string, v any) error {
+	enc := base64.RawURLEncoding
+
+	p, sig, ok := strings.Cut(s, ".")
+	if !ok {
+		return ErrInvalid
+	}
+
+	payload, err := enc.DecodeString(p)
+	if err != nil {
+		return ErrInvalid
+	}
+
+	mac, err := enc.DecodeString(sig)
+	if err != nil || !hmac.Equal(mac, c.sign(payload)) {
+		return ErrInvalid
+	}
+
+	if err := json.Unmarshal(payload, v); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	return nil
+}
+
+func (c *Codec) sign(payload []byte) []byte {
+	h := hmac.New(sha256.New, c.key)
+	h.Write(payload)
+	return h.Sum(nil)
+}
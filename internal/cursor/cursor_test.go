@@ -0,0 +1,79 @@
+package cursor
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type position struct {
+	ID    string  `json:"id"`
+	Price float64 `json:"price"`
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	c := NewCodec([]byte("secret"))
+	want := position{ID: "a1", Price: 12.5}
+
+	s, err := c.Encode(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got position
+	if err := c.Decode(s, &got); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+
+	if got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestCodecDecodeTampered(t *testing.T) {
+	c := NewCodec([]byte("secret"))
+
+	valid, err := c.Encode(position{ID: "a1", Price: 12.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, sig, _ := strings.Cut(valid, ".")
+
+	forged, err := NewCodec([]byte("other secret")).Encode(position{ID: "z9"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	enc := base64.RawURLEncoding
+
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{name: "empty", cursor: ""},
+		{name: "no signature", cursor: payload},
+		{name: "empty signature", cursor: payload + "."},
+		{name: "changed payload", cursor: enc.EncodeToString([]byte(`{"id":"z9","price":12.5}`)) + "." + sig},
+		{name: "truncated signature", cursor: payload + "." + sig[:len(sig)-2]},
+		{name: "payload not base64", cursor: "!!." + sig},
+		{name: "signature not base64", cursor: payload + ".!!"},
+		{name: "signed with another key", cursor: forged},
+		{name: "signed payload not JSON", cursor: mustSignRaw(c, "not json")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got position
+			if err := c.Decode(tt.cursor, &got); !errors.Is(err, ErrInvalid) {
+				t.Fatalf("Decode: got %v, want %v", err, ErrInvalid)
+			}
+		})
+	}
+}
+
+// mustSignRaw returns a validly signed cursor of an arbitrary payload.
+func mustSignRaw(c *Codec, payload string) string {
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString([]byte(payload)) + "." + enc.EncodeToString(c.sign([]byte(payload)))
+}
@@ -0,0 +1,77 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/oshankkumar/sockshop/internal/db"
+	"github.com/oshankkumar/sockshop/internal/domain"
+)
+
+func NewIdentityStore() *IdentityStore {
+	return &IdentityStore{
+		identities: make(map[[2]string]domain.Identity),
+		logins:     make(map[string]domain.OIDCLogin),
+	}
+}
+
+// IdentityStore keeps external identities in memory. It has no transactions,
+// WithTx returns the store itself.
+type IdentityStore struct {
+	mu sync.Mutex
+	// identities is keyed by provider and subject.
+	identities map[[2]string]domain.Identity
+	logins     map[string]domain.OIDCLogin
+}
+
+func (s *IdentityStore) WithTx(db.DB) domain.IdentityStore {
+	return s
+}
+
+func (s *IdentityStore) GetIdentity(_ context.Context, provider, subject string) (domain.Identity, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, ok := s.identities[[2]string{provider, subject}]
+	if !ok {
+		return domain.Identity{}, domain.ErrNotFound
+	}
+	return i, nil
+}
+
+func (s *IdentityStore) CreateIdentity(_ context.Context, i *domain.Identity) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := [2]string{i.Provider, i.Subject}
+	if _, ok := s.identities[key]; ok {
+		return domain.DuplicateEntryError{Entity: "identity", Err: errors.New("identity already linked")}
+	}
+
+	i.CreatedAt = time.Now().UTC()
+	s.identities[key] = *i
+	return nil
+}
+
+func (s *IdentityStore) CreateOIDCLogin(_ context.Context, l *domain.OIDCLogin) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.logins[l.StateHash] = *l
+	return nil
+}
+
+func (s *IdentityStore) ConsumeOIDCLogin(_ context.Context, stateHash string) (domain.OIDCLogin, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l, ok := s.logins[stateHash]
+	if !ok {
+		return domain.OIDCLogin{}, domain.ErrNotFound
+	}
+
+	delete(s.logins, stateHash)
+	return l, nil
+}
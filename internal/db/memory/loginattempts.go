@@ -0,0 +1,54 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/oshankkumar/sockshop/internal/domain"
+)
+
+func NewLoginAttemptStore() *LoginAttemptStore {
+	return &LoginAttemptStore{attempts: make(map[string]domain.LoginAttempts)}
+}
+
+type LoginAttemptStore struct {
+	mu       sync.Mutex
+	attempts map[string]domain.LoginAttempts
+}
+
+func (s *LoginAttemptStore) GetLoginAttempts(_ context.Context, key string) (domain.LoginAttempts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.attempts[key]
+	if !ok {
+		return domain.LoginAttempts{Key: key}, nil
+	}
+	return a, nil
+}
+
+func (s *LoginAttemptStore) RecordLoginFailure(_ context.Context, key string, now time.Time, window time.Duration) (domain.LoginAttempts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a := s.attempts[key]
+	if a.LastFailure.Before(now.Add(-window)) {
+		a.Failures = 0
+	}
+
+	a.Key = key
+	a.Failures++
+	a.LastFailure = now
+	s.attempts[key] = a
+
+	return a, nil
+}
+
+func (s *LoginAttemptStore) ResetLoginAttempts(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.attempts, key)
+	return nil
+}
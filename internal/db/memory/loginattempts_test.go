@@ -0,0 +1,61 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLoginAttemptStore(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		// failures are the times of the failures since start.
+		failures []time.Duration
+		reset    bool
+		want     int
+	}{
+		{name: "none", want: 0},
+		{name: "counted", failures: []time.Duration{0, time.Minute, 2 * time.Minute}, want: 3},
+		{name: "within the window", failures: []time.Duration{0, time.Hour}, want: 2},
+		{name: "past the window", failures: []time.Duration{0, time.Hour + time.Second}, want: 1},
+		{name: "reset", failures: []time.Duration{0, time.Minute}, reset: true, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewLoginAttemptStore()
+
+			for _, d := range tt.failures {
+				if _, err := s.RecordLoginFailure(ctx, "user:alice", start.Add(d), time.Hour); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// Other keys are counted apart.
+			if _, err := s.RecordLoginFailure(ctx, "ip:10.0.0.1", start, time.Hour); err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.reset {
+				if err := s.ResetLoginAttempts(ctx, "user:alice"); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			a, err := s.GetLoginAttempts(ctx, "user:alice")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if a.Key != "user:alice" || a.Failures != tt.want {
+				t.Fatalf("got %+v, want %d failures", a, tt.want)
+			}
+			if n := len(tt.failures); n > 0 && !tt.reset && !a.LastFailure.Equal(start.Add(tt.failures[n-1])) {
+				t.Fatalf("got last failure %s", a.LastFailure)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/oshankkumar/sockshop/internal/db"
+	"github.com/oshankkumar/sockshop/internal/domain"
+)
+
+func NewMFAStore() *MFAStore {
+	return &MFAStore{
+		totps:      make(map[string]domain.TOTP),
+		codes:      make(map[string]map[string]bool),
+		challenges: make(map[string]domain.MFAChallenge),
+	}
+}
+
+// MFAStore keeps second factors in memory. It has no transactions, WithTx
+// returns the store itself.
+type MFAStore struct {
+	mu    sync.Mutex
+	totps map[string]domain.TOTP
+	// codes maps the customers to their recovery code hashes, and the hashes
+	// to whether they were used.
+	codes      map[string]map[string]bool
+	challenges map[string]domain.MFAChallenge
+}
+
+func (s *MFAStore) WithTx(db.DB) domain.MFAStore {
+	return s
+}
+
+func (s *MFAStore) GetTOTP(_ context.Context, customerID string) (domain.TOTP, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.totps[customerID]
+	if !ok {
+		return domain.TOTP{}, domain.ErrNotFound
+	}
+	return t, nil
+}
+
+func (s *MFAStore) SaveTOTP(_ context.Context, t *domain.TOTP) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t.CreatedAt = time.Now().UTC()
+	s.totps[t.CustomerID] = *t
+	return nil
+}
+
+func (s *MFAStore) ConfirmTOTP(_ context.Context, customerID string, counter int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.totps[customerID]
+	if !ok || t.Confirmed {
+		return domain.ErrNotFound
+	}
+
+	t.Confirmed = true
+	t.LastCounter = counter
+	s.totps[customerID] = t
+	return nil
+}
+
+func (s *MFAStore) UseTOTPCounter(_ context.Context, customerID string, counter int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.totps[customerID]
+	if !ok || counter <= t.LastCounter {
+		return domain.ErrConflict
+	}
+
+	t.LastCounter = counter
+	s.totps[customerID] = t
+	return nil
+}
+
+func (s *MFAStore) ReplaceRecoveryCodes(_ context.Context, customerID string, hashes []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	codes := make(map[string]bool, len(hashes))
+	for _, h := range hashes {
+		codes[h] = false
+	}
+	s.codes[customerID] = codes
+	return nil
+}
+
+func (s *MFAStore) UseRecoveryCode(_ context.Context, customerID, hash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	used, ok := s.codes[customerID][hash]
+	if !ok || used {
+		return domain.ErrNotFound
+	}
+
+	s.codes[customerID][hash] = true
+	return nil
+}
+
+func (s *MFAStore) CreateMFAChallenge(_ context.Context, c *domain.MFAChallenge) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.challenges[c.Hash] = *c
+	return nil
+}
+
+func (s *MFAStore) GetMFAChallenge(_ context.Context, hash string) (domain.MFAChallenge, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.challenges[hash]
+	if !ok {
+		return domain.MFAChallenge{}, domain.ErrNotFound
+	}
+	return c, nil
+}
+
+func (s *MFAStore) DeleteMFAChallenge(_ context.Context, hash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.challenges, hash)
+	return nil
+}
@@ -0,0 +1,107 @@
+// Package memory implements stores in memory, for tests and single instance
+// deployments.
+package memory
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/oshankkumar/sockshop/internal/domain"
+	"github.com/oshankkumar/sockshop/internal/vault"
+)
+
+var errDuplicateFingerprint = errors.New("duplicate fingerprint")
+
+func NewVaultStore() *VaultStore {
+	return &VaultStore{entries: make(map[string]vault.Entry)}
+}
+
+type VaultStore struct {
+	mu      sync.RWMutex
+	entries map[string]vault.Entry
+	audit   []vault.AuditRecord
+}
+
+func (s *VaultStore) CreateEntry(_ context.Context, e vault.Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.entries {
+		if existing.Fingerprint == e.Fingerprint {
+			return domain.DuplicateEntryError{Entity: "vault entry", Err: errDuplicateFingerprint}
+		}
+	}
+
+	s.entries[e.Token] = e
+	return nil
+}
+
+func (s *VaultStore) GetEntry(_ context.Context, token string) (vault.Entry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	e, ok := s.entries[token]
+	if !ok {
+		return vault.Entry{}, domain.ErrNotFound
+	}
+	return e, nil
+}
+
+func (s *VaultStore) GetEntryByFingerprint(_ context.Context, fingerprint string) (vault.Entry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, e := range s.entries {
+		if e.Fingerprint == fingerprint {
+			return e, nil
+		}
+	}
+	return vault.Entry{}, domain.ErrNotFound
+}
+
+func (s *VaultStore) GetEntriesNotUnderKey(_ context.Context, keyID string, limit int) ([]vault.Entry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var entries []vault.Entry
+	for _, e := range s.entries {
+		if len(entries) == limit {
+			break
+		}
+		if e.PAN.KeyID != keyID {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+func (s *VaultStore) UpdateEntryKey(_ context.Context, e vault.Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.entries[e.Token]
+	if !ok {
+		return domain.ErrNotFound
+	}
+
+	existing.PAN.DataKey, existing.PAN.KeyID = e.PAN.DataKey, e.PAN.KeyID
+	s.entries[e.Token] = existing
+	return nil
+}
+
+func (s *VaultStore) AppendAudit(_ context.Context, r vault.AuditRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.audit = append(s.audit, r)
+	return nil
+}
+
+// Audit returns the audit trail, oldest record first.
+func (s *VaultStore) Audit() []vault.AuditRecord {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]vault.AuditRecord(nil), s.audit...)
+}
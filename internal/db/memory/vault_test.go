@@ -0,0 +1,145 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/oshankkumar/sockshop/internal/domain"
+	"github.com/oshankkumar/sockshop/internal/envelope"
+	"github.com/oshankkumar/sockshop/internal/vault"
+)
+
+func TestVaultStore(t *testing.T) {
+	ctx := context.Background()
+
+	entry := func(token, fingerprint, keyID string) vault.Entry {
+		return vault.Entry{Token: token, Fingerprint: fingerprint, PAN: envelope.Sealed{KeyID: keyID, DataKey: []byte(keyID), Ciphertext: []byte(token)}}
+	}
+
+	tests := []struct {
+		name string
+		run  func(s *VaultStore) error
+		want error
+	}{
+		{
+			name: "create and get",
+			run: func(s *VaultStore) error {
+				if err := s.CreateEntry(ctx, entry("tok_1", "fp1", "k1")); err != nil {
+					return err
+				}
+				e, err := s.GetEntry(ctx, "tok_1")
+				if err == nil && e.Fingerprint != "fp1" {
+					t.Errorf("got entry %+v", e)
+				}
+				return err
+			},
+		},
+		{
+			name: "duplicate fingerprint",
+			run: func(s *VaultStore) error {
+				_ = s.CreateEntry(ctx, entry("tok_1", "fp1", "k1"))
+				return s.CreateEntry(ctx, entry("tok_2", "fp1", "k1"))
+			},
+			want: domain.DuplicateEntryError{Entity: "vault entry", Err: errDuplicateFingerprint},
+		},
+		{
+			name: "unknown token",
+			run: func(s *VaultStore) error {
+				_, err := s.GetEntry(ctx, "tok_1")
+				return err
+			},
+			want: domain.ErrNotFound,
+		},
+		{
+			name: "unknown fingerprint",
+			run: func(s *VaultStore) error {
+				_ = s.CreateEntry(ctx, entry("tok_1", "fp1", "k1"))
+				_, err := s.GetEntryByFingerprint(ctx, "fp2")
+				return err
+			},
+			want: domain.ErrNotFound,
+		},
+		{
+			name: "update key of an unknown entry",
+			run: func(s *VaultStore) error {
+				return s.UpdateEntryKey(ctx, entry("tok_1", "fp1", "k2"))
+			},
+			want: domain.ErrNotFound,
+		},
+		{
+			name: "update key",
+			run: func(s *VaultStore) error {
+				_ = s.CreateEntry(ctx, entry("tok_1", "fp1", "k1"))
+
+				// Only the wrapped data key changes.
+				updated := entry("tok_1", "other", "k2")
+				updated.PAN.Ciphertext = []byte("other")
+				if err := s.UpdateEntryKey(ctx, updated); err != nil {
+					return err
+				}
+
+				e, err := s.GetEntry(ctx, "tok_1")
+				if err == nil && (e.PAN.KeyID != "k2" || string(e.PAN.DataKey) != "k2" || string(e.PAN.Ciphertext) != "tok_1" || e.Fingerprint != "fp1") {
+					t.Errorf("got entry %+v", e)
+				}
+				return err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.run(NewVaultStore())
+
+			var dupe domain.DuplicateEntryError
+			switch {
+			case errors.As(tt.want, &dupe):
+				if !errors.As(err, &dupe) {
+					t.Fatalf("got %v, want %v", err, tt.want)
+				}
+			case !errors.Is(err, tt.want):
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVaultStoreGetEntriesNotUnderKey(t *testing.T) {
+	ctx := context.Background()
+	s := NewVaultStore()
+
+	for i, keyID := range []string{"k1", "k1", "k2", "k1", "k2"} {
+		e := vault.Entry{Token: "tok_" + string(rune('a'+i)), Fingerprint: string(rune('a' + i)), PAN: envelope.Sealed{KeyID: keyID}}
+		if err := s.CreateEntry(ctx, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		keyID string
+		limit int
+		want  int
+	}{
+		{keyID: "k2", limit: 10, want: 3},
+		{keyID: "k2", limit: 2, want: 2},
+		{keyID: "k1", limit: 10, want: 2},
+		{keyID: "k3", limit: 10, want: 5},
+	}
+
+	for _, tt := range tests {
+		entries, err := s.GetEntriesNotUnderKey(ctx, tt.keyID, tt.limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(entries) != tt.want {
+			t.Errorf("GetEntriesNotUnderKey(%s, %d): got %d entries, want %d", tt.keyID, tt.limit, len(entries), tt.want)
+		}
+		for _, e := range entries {
+			if e.PAN.KeyID == tt.keyID {
+				t.Errorf("GetEntriesNotUnderKey(%s, %d): got entry %s under the key", tt.keyID, tt.limit, e.Token)
+			}
+		}
+	}
+}
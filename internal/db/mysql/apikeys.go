@@ -0,0 +1,188 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/google/uuid"
+
+	"github.com/oshankkumar/sockshop/internal/db"
+	"github.com/oshankkumar/sockshop/internal/domain"
+)
+
+type apiKey struct {
+	ID        uuid.UUID      `db:"id"`
+	Name      string         `db:"name"`
+	Prefix    string         `db:"prefix"`
+	Hash      string         `db:"hash"`
+	CreatedBy string         `db:"created_by"`
+	CreatedAt mysql.NullTime `db:"created_at"`
+	ExpiresAt mysql.NullTime `db:"expires_at"`
+	RevokedAt mysql.NullTime `db:"revoked_at"`
+}
+
+func (k apiKey) toDomain() domain.APIKey {
+	key := domain.APIKey{
+		ID:        k.ID,
+		Name:      k.Name,
+		Prefix:    k.Prefix,
+		Hash:      k.Hash,
+		CreatedBy: k.CreatedBy,
+		CreatedAt: k.CreatedAt.Time,
+	}
+	if k.ExpiresAt.Valid {
+		key.ExpiresAt = &k.ExpiresAt.Time
+	}
+	if k.RevokedAt.Valid {
+		key.RevokedAt = &k.RevokedAt.Time
+	}
+	return key
+}
+
+type apiKeyScope struct {
+	KeyID string `db:"key_id"`
+	Scope string `db:"scope"`
+}
+
+const apiKeyColumns = "id, name, prefix, hash, created_by, created_at, expires_at, revoked_at"
+
+func NewAPIKeyStore(db db.DB) *APIKeyStore {
+	return &APIKeyStore{db: db}
+}
+
+type APIKeyStore struct {
+	db db.DB
+}
+
+func (s *APIKeyStore) WithTx(db db.DB) domain.APIKeyStore {
+	return &APIKeyStore{db: db}
+}
+
+func (s *APIKeyStore) CreateAPIKey(ctx context.Context, k *domain.APIKey) error {
+	k.ID = uuid.New()
+	k.CreatedAt = time.Now().UTC()
+
+	var expiresAt *time.Time
+	if k.ExpiresAt != nil {
+		t := k.ExpiresAt.UTC()
+		expiresAt = &t
+	}
+
+	query := "INSERT INTO api_key(id, name, prefix, hash, created_by, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?)"
+
+	if _, err := s.db.ExecContext(ctx, query, k.ID, k.Name, k.Prefix, k.Hash, k.CreatedBy, k.CreatedAt, expiresAt); err != nil {
+		return fmt.Errorf("APIKeyStore.CreateAPIKey(%s): %w", k.Name, err)
+	}
+
+	if err := s.insertScopes(ctx, k.ID.String(), k.Scopes); err != nil {
+		return fmt.Errorf("APIKeyStore.CreateAPIKey(%s): %w", k.Name, err)
+	}
+
+	return nil
+}
+
+func (s *APIKeyStore) GetAPIKey(ctx context.Context, id string) (domain.APIKey, error) {
+	key, err := s.getAPIKey(ctx, "id", id)
+	if err != nil {
+		return key, fmt.Errorf("APIKeyStore.GetAPIKey(%s): %w", id, err)
+	}
+
+	return key, nil
+}
+
+func (s *APIKeyStore) GetAPIKeyByHash(ctx context.Context, hash string) (domain.APIKey, error) {
+	key, err := s.getAPIKey(ctx, "hash", hash)
+	if err != nil {
+		return key, fmt.Errorf("APIKeyStore.GetAPIKeyByHash: %w", err)
+	}
+
+	return key, nil
+}
+
+func (s *APIKeyStore) getAPIKey(ctx context.Context, column, value string) (domain.APIKey, error) {
+	query := "SELECT " + apiKeyColumns + " FROM api_key WHERE " + column + "=?"
+
+	var result apiKey
+	if err := GetContext(ctx, s.db, &result, query, value); err != nil {
+		return domain.APIKey{}, err
+	}
+
+	key := result.toDomain()
+
+	query = "SELECT scope FROM api_key_scope WHERE key_id=? ORDER BY scope"
+
+	if err := SelectContext(ctx, s.db, &key.Scopes, query, key.ID); err != nil {
+		return domain.APIKey{}, err
+	}
+
+	return key, nil
+}
+
+func (s *APIKeyStore) ListAPIKeys(ctx context.Context) ([]domain.APIKey, error) {
+	var results []apiKey
+	if err := SelectContext(ctx, s.db, &results, "SELECT "+apiKeyColumns+" FROM api_key ORDER BY created_at, id"); err != nil {
+		return nil, fmt.Errorf("APIKeyStore.ListAPIKeys: %w", err)
+	}
+
+	var scopes []apiKeyScope
+	if err := SelectContext(ctx, s.db, &scopes, "SELECT key_id, scope FROM api_key_scope ORDER BY key_id, scope"); err != nil {
+		return nil, fmt.Errorf("APIKeyStore.ListAPIKeys: %w", err)
+	}
+
+	byKey := make(map[string][]string)
+	for _, sc := range scopes {
+		byKey[sc.KeyID] = append(byKey[sc.KeyID], sc.Scope)
+	}
+
+	keys := make([]domain.APIKey, 0, len(results))
+	for _, r := range results {
+		k := r.toDomain()
+		k.Scopes = byKey[k.ID.String()]
+		keys = append(keys, k)
+	}
+
+	return keys, nil
+}
+
+func (s *APIKeyStore) ReplaceAPIKeyScopes(ctx context.Context, id string, scopes []string) error {
+	var n int
+	if err := GetContext(ctx, s.db, &n, "SELECT COUNT(*) FROM api_key WHERE id=? AND revoked_at IS NULL FOR UPDATE", id); err != nil {
+		return fmt.Errorf("APIKeyStore.ReplaceAPIKeyScopes(%s): %w", id, err)
+	}
+
+	if n == 0 {
+		return fmt.Errorf("APIKeyStore.ReplaceAPIKeyScopes(%s): %w", id, domain.ErrNotFound)
+	}
+
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM api_key_scope WHERE key_id=?", id); err != nil {
+		return fmt.Errorf("APIKeyStore.ReplaceAPIKeyScopes(%s): %w", id, err)
+	}
+
+	if err := s.insertScopes(ctx, id, scopes); err != nil {
+		return fmt.Errorf("APIKeyStore.ReplaceAPIKeyScopes(%s): %w", id, err)
+	}
+
+	return nil
+}
+
+func (s *APIKeyStore) RevokeAPIKey(ctx context.Context, id string) error {
+	query := "UPDATE api_key SET revoked_at=? WHERE id=? AND revoked_at IS NULL"
+
+	if err := execOne(ctx, s.db, domain.ErrNotFound, query, time.Now().UTC(), id); err != nil {
+		return fmt.Errorf("APIKeyStore.RevokeAPIKey(%s): %w", id, err)
+	}
+
+	return nil
+}
+
+func (s *APIKeyStore) insertScopes(ctx context.Context, id string, scopes []string) error {
+	for _, scope := range scopes {
+		if _, err := s.db.ExecContext(ctx, "INSERT INTO api_key_scope(key_id, scope) VALUES (?, ?)", id, scope); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/oshankkumar/sockshop/internal/db"
+	"github.com/oshankkumar/sockshop/internal/domain"
+)
+
+func NewCartStore(db db.DB) *CartStore {
+	return &CartStore{db: db}
+}
+
+type CartStore struct {
+	db db.DB
+}
+
+func (c *CartStore) WithTx(db db.DB) domain.CartStore {
+	return &CartStore{db: db}
+}
+
+func (c *CartStore) GetCart(ctx context.Context, customerID string) (domain.Cart, error) {
+	query := "SELECT customer_id, sock_id, quantity, unit_price FROM cart_item WHERE customer_id=? ORDER BY sock_id;"
+
+	cart := domain.Cart{CustomerID: customerID}
+	if err := SelectContext(ctx, c.db, &cart.Items, query, customerID); err != nil {
+		return cart, fmt.Errorf("CartStore.GetCart(%s): %w", customerID, err)
+	}
+
+	return cart, nil
+}
+
+func (c *CartStore) GetItem(ctx context.Context, customerID, sockID string) (domain.CartItem, error) {
+	query := "SELECT customer_id, sock_id, quantity, unit_price FROM cart_item WHERE customer_id=? AND sock_id=?;"
+
+	var item domain.CartItem
+	if err := GetContext(ctx, c.db, &item, query, customerID, sockID); err != nil {
+		return item, fmt.Errorf("CartStore.GetItem(%s, %s): %w", customerID, sockID, err)
+	}
+
+	return item, nil
+}
+
+func (c *CartStore) AddItem(ctx context.Context, item domain.CartItem) error {
+	query := "INSERT INTO cart_item(customer_id, sock_id, quantity, unit_price) VALUES (?, ?, ?, ?) " +
+		"ON DUPLICATE KEY UPDATE quantity = quantity + VALUES(quantity)"
+
+	if _, err := c.db.ExecContext(ctx, query, item.CustomerID, item.SockID, item.Quantity, item.UnitPrice); err != nil {
+		return fmt.Errorf("CartStore.AddItem(%s, %s): %w", item.CustomerID, item.SockID, err)
+	}
+
+	return nil
+}
+
+func (c *CartStore) UpdateItem(ctx context.Context, item domain.CartItem) error {
+	query := "UPDATE cart_item SET quantity=? WHERE customer_id=? AND sock_id=?"
+
+	if _, err := c.db.ExecContext(ctx, query, item.Quantity, item.CustomerID, item.SockID); err != nil {
+		return fmt.Errorf("CartStore.UpdateItem(%s, %s): %w", item.CustomerID, item.SockID, err)
+	}
+
+	return nil
+}
+
+func (c *CartStore) DeleteItem(ctx context.Context, customerID, sockID string) error {
+	query := "DELETE FROM cart_item WHERE customer_id=? AND sock_id=?"
+
+	if _, err := c.db.ExecContext(ctx, query, customerID, sockID); err != nil {
+		return fmt.Errorf("CartStore.DeleteItem(%s, %s): %w", customerID, sockID, err)
+	}
+
+	return nil
+}
+
+func (c *CartStore) DeleteCart(ctx context.Context, customerID string) error {
+	query := "DELETE FROM cart_item WHERE customer_id=?"
+
+	if _, err := c.db.ExecContext(ctx, query, customerID); err != nil {
+		return fmt.Errorf("CartStore.DeleteCart(%s): %w", customerID, err)
+	}
+
+	return nil
+}
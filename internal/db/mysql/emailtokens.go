@@ -0,0 +1,80 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+
+	"github.com/oshankkumar/sockshop/internal/db"
+	"github.com/oshankkumar/sockshop/internal/domain"
+)
+
+type emailToken struct {
+	Hash       string         `db:"hash"`
+	CustomerID string         `db:"customer_id"`
+	Purpose    string         `db:"purpose"`
+	Email      string         `db:"email"`
+	ExpiresAt  mysql.NullTime `db:"expires_at"`
+	CreatedAt  mysql.NullTime `db:"created_at"`
+}
+
+func (t emailToken) toDomain() domain.EmailToken {
+	return domain.EmailToken{
+		Hash:       t.Hash,
+		CustomerID: t.CustomerID,
+		Purpose:    t.Purpose,
+		Email:      t.Email,
+		ExpiresAt:  t.ExpiresAt.Time,
+		CreatedAt:  t.CreatedAt.Time,
+	}
+}
+
+func NewEmailTokenStore(db db.DB) *EmailTokenStore {
+	return &EmailTokenStore{db: db}
+}
+
+type EmailTokenStore struct {
+	db db.DB
+}
+
+func (e *EmailTokenStore) CreateEmailToken(ctx context.Context, t *domain.EmailToken) error {
+	t.CreatedAt = time.Now().UTC()
+
+	query := "INSERT INTO email_token(hash, customer_id, purpose, email, expires_at, created_at) VALUES (?, ?, ?, ?, ?, ?)"
+
+	_, err := e.db.ExecContext(ctx, query, t.Hash, t.CustomerID, t.Purpose, t.Email, t.ExpiresAt.UTC(), t.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("EmailTokenStore.CreateEmailToken(customerID=%s): %w", t.CustomerID, err)
+	}
+
+	return nil
+}
+
+func (e *EmailTokenStore) ConsumeEmailToken(ctx context.Context, hash, purpose string, now time.Time) (domain.EmailToken, error) {
+	query := "UPDATE email_token SET used_at=? WHERE hash=? AND purpose=? AND used_at IS NULL AND expires_at>?"
+
+	res, err := e.db.ExecContext(ctx, query, now.UTC(), hash, purpose, now.UTC())
+	if err != nil {
+		return domain.EmailToken{}, fmt.Errorf("EmailTokenStore.ConsumeEmailToken(%s): %w", purpose, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return domain.EmailToken{}, fmt.Errorf("EmailTokenStore.ConsumeEmailToken(%s): %w", purpose, err)
+	}
+
+	if n == 0 {
+		return domain.EmailToken{}, fmt.Errorf("EmailTokenStore.ConsumeEmailToken(%s): %w", purpose, domain.ErrNotFound)
+	}
+
+	query = "SELECT hash, customer_id, purpose, email, expires_at, created_at FROM email_token WHERE hash=?"
+
+	var result emailToken
+	if err := GetContext(ctx, e.db, &result, query, hash); err != nil {
+		return domain.EmailToken{}, fmt.Errorf("EmailTokenStore.ConsumeEmailToken(%s): %w", purpose, err)
+	}
+
+	return result.toDomain(), nil
+}
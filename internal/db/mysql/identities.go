@@ -0,0 +1,108 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+
+	"github.com/oshankkumar/sockshop/internal/db"
+	"github.com/oshankkumar/sockshop/internal/domain"
+)
+
+type customerIdentity struct {
+	Provider   string         `db:"provider"`
+	Subject    string         `db:"subject"`
+	CustomerID string         `db:"customer_id"`
+	CreatedAt  mysql.NullTime `db:"created_at"`
+}
+
+type oidcLogin struct {
+	StateHash string         `db:"state_hash"`
+	Provider  string         `db:"provider"`
+	Nonce     string         `db:"nonce"`
+	Verifier  string         `db:"verifier"`
+	ExpiresAt mysql.NullTime `db:"expires_at"`
+}
+
+func NewIdentityStore(db db.DB) *IdentityStore {
+	return &IdentityStore{db: db}
+}
+
+type IdentityStore struct {
+	db db.DB
+}
+
+func (s *IdentityStore) WithTx(db db.DB) domain.IdentityStore {
+	return &IdentityStore{db: db}
+}
+
+func (s *IdentityStore) GetIdentity(ctx context.Context, provider, subject string) (domain.Identity, error) {
+	query := "SELECT provider, subject, customer_id, created_at FROM customer_identity WHERE provider=? AND subject=?"
+
+	var result customerIdentity
+	if err := GetContext(ctx, s.db, &result, query, provider, subject); err != nil {
+		return domain.Identity{}, fmt.Errorf("IdentityStore.GetIdentity(%s, %s): %w", provider, subject, err)
+	}
+
+	return domain.Identity{
+		Provider:   result.Provider,
+		Subject:    result.Subject,
+		CustomerID: result.CustomerID,
+		CreatedAt:  result.CreatedAt.Time,
+	}, nil
+}
+
+func (s *IdentityStore) CreateIdentity(ctx context.Context, i *domain.Identity) error {
+	i.CreatedAt = time.Now().UTC()
+
+	query := "INSERT INTO customer_identity(provider, subject, customer_id, created_at) VALUES (?, ?, ?, ?)"
+
+	_, err := s.db.ExecContext(ctx, query, i.Provider, i.Subject, i.CustomerID, i.CreatedAt)
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == ErrCodeDupe {
+		return domain.DuplicateEntryError{Entity: "identity", Err: err}
+	}
+
+	if err != nil {
+		return fmt.Errorf("IdentityStore.CreateIdentity(%s, %s): %w", i.Provider, i.Subject, err)
+	}
+
+	return nil
+}
+
+func (s *IdentityStore) CreateOIDCLogin(ctx context.Context, l *domain.OIDCLogin) error {
+	query := "INSERT INTO oidc_login(state_hash, provider, nonce, verifier, expires_at) VALUES (?, ?, ?, ?, ?)"
+
+	if _, err := s.db.ExecContext(ctx, query, l.StateHash, l.Provider, l.Nonce, l.Verifier, l.ExpiresAt.UTC()); err != nil {
+		return fmt.Errorf("IdentityStore.CreateOIDCLogin(%s): %w", l.Provider, err)
+	}
+
+	return nil
+}
+
+func (s *IdentityStore) ConsumeOIDCLogin(ctx context.Context, stateHash string) (domain.OIDCLogin, error) {
+	query := "SELECT state_hash, provider, nonce, verifier, expires_at FROM oidc_login WHERE state_hash=?"
+
+	var result oidcLogin
+	if err := GetContext(ctx, s.db, &result, query, stateHash); err != nil {
+		return domain.OIDCLogin{}, fmt.Errorf("IdentityStore.ConsumeOIDCLogin: %w", err)
+	}
+
+	// Only the caller deleting the login may use it, so that a state
+	// redirected back twice concurrently completes a single login.
+	if err := execOne(ctx, s.db, domain.ErrNotFound, "DELETE FROM oidc_login WHERE state_hash=?", stateHash); err != nil {
+		return domain.OIDCLogin{}, fmt.Errorf("IdentityStore.ConsumeOIDCLogin: %w", err)
+	}
+
+	return domain.OIDCLogin{
+		StateHash: result.StateHash,
+		Provider:  result.Provider,
+		Nonce:     result.Nonce,
+		Verifier:  result.Verifier,
+		ExpiresAt: result.ExpiresAt.Time,
+	}, nil
+}
@@ -0,0 +1,51 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/oshankkumar/sockshop/internal/db"
+	"github.com/oshankkumar/sockshop/internal/envelope"
+)
+
+// LegacyCard is a card stored before the encryption of card numbers, with its
+// number in clear in the long_num column.
+type LegacyCard struct {
+	ID     string `db:"id"`
+	Number string `db:"long_num"`
+}
+
+func NewLegacyCardStore(db db.DB) *LegacyCardStore {
+	return &LegacyCardStore{db: db}
+}
+
+// LegacyCardStore encrypts the card numbers stored in clear. It needs the card
+// table as left by deploy/migrations/012_card_encryption.sql, with both the
+// long_num and pan columns.
+type LegacyCardStore struct {
+	db db.DB
+}
+
+// GetLegacyCards returns up to limit cards whose number is still in clear.
+func (l *LegacyCardStore) GetLegacyCards(ctx context.Context, limit int) ([]LegacyCard, error) {
+	query := "SELECT id, long_num FROM card WHERE pan IS NULL AND long_num IS NOT NULL ORDER BY id LIMIT ?;"
+
+	var cards []LegacyCard
+	if err := SelectContext(ctx, l.db, &cards, query, limit); err != nil {
+		return nil, fmt.Errorf("LegacyCardStore.GetLegacyCards: %w", err)
+	}
+
+	return cards, nil
+}
+
+// MigrateLegacyCard replaces the number and the CCV of the card with the
+// encrypted number and its non sensitive details.
+func (l *LegacyCardStore) MigrateLegacyCard(ctx context.Context, id string, pan envelope.Sealed, fingerprint, last4, brand string) error {
+	query := "UPDATE card SET pan=?, data_key=?, key_id=?, fingerprint=?, last4=?, brand=?, long_num=NULL, ccv=NULL WHERE id=? AND pan IS NULL;"
+
+	if _, err := l.db.ExecContext(ctx, query, pan.Ciphertext, pan.DataKey, pan.KeyID, fingerprint, last4, brand, id); err != nil {
+		return fmt.Errorf("LegacyCardStore.MigrateLegacyCard(%s): %w", id, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+
+	"github.com/oshankkumar/sockshop/internal/db"
+	"github.com/oshankkumar/sockshop/internal/domain"
+)
+
+type loginAttempts struct {
+	Key         string         `db:"attempt_key"`
+	Failures    int            `db:"failures"`
+	LastFailure mysql.NullTime `db:"last_failure"`
+}
+
+func (l loginAttempts) toDomain() domain.LoginAttempts {
+	return domain.LoginAttempts{Key: l.Key, Failures: l.Failures, LastFailure: l.LastFailure.Time}
+}
+
+func NewLoginAttemptStore(db db.DB) *LoginAttemptStore {
+	return &LoginAttemptStore{db: db}
+}
+
+type LoginAttemptStore struct {
+	db db.DB
+}
+
+func (l *LoginAttemptStore) GetLoginAttempts(ctx context.Context, key string) (domain.LoginAttempts, error) {
+	query := "SELECT attempt_key, failures, last_failure FROM login_attempt WHERE attempt_key=?"
+
+	var result loginAttempts
+	err := GetContext(ctx, l.db, &result, query, key)
+
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return domain.LoginAttempts{Key: key}, nil
+	case err != nil:
+		return domain.LoginAttempts{}, fmt.Errorf("LoginAttemptStore.GetLoginAttempts(%s): %w", key, err)
+	}
+
+	return result.toDomain(), nil
+}
+
+func (l *LoginAttemptStore) RecordLoginFailure(ctx context.Context, key string, now time.Time, window time.Duration) (domain.LoginAttempts, error) {
+	now = now.UTC()
+
+	query := "INSERT INTO login_attempt(attempt_key, failures, last_failure) VALUES (?, 1, ?) " +
+		"ON DUPLICATE KEY UPDATE failures=IF(last_failure < ?, 1, failures+1), last_failure=VALUES(last_failure)"
+
+	if _, err := l.db.ExecContext(ctx, query, key, now, now.Add(-window)); err != nil {
+		return domain.LoginAttempts{}, fmt.Errorf("LoginAttemptStore.RecordLoginFailure(%s): %w", key, err)
+	}
+
+	return l.GetLoginAttempts(ctx, key)
+}
+
+func (l *LoginAttemptStore) ResetLoginAttempts(ctx context.Context, key string) error {
+	if _, err := l.db.ExecContext(ctx, "DELETE FROM login_attempt WHERE attempt_key=?", key); err != nil {
+		return fmt.Errorf("LoginAttemptStore.ResetLoginAttempts(%s): %w", key, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,168 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+
+	"github.com/oshankkumar/sockshop/internal/db"
+	"github.com/oshankkumar/sockshop/internal/domain"
+	"github.com/oshankkumar/sockshop/internal/envelope"
+)
+
+type customerTOTP struct {
+	CustomerID  string         `db:"customer_id"`
+	Secret      []byte         `db:"secret"`
+	DataKey     []byte         `db:"data_key"`
+	KeyID       string         `db:"key_id"`
+	Confirmed   bool           `db:"confirmed"`
+	LastCounter int64          `db:"last_counter"`
+	CreatedAt   mysql.NullTime `db:"created_at"`
+}
+
+func (t customerTOTP) toDomain() domain.TOTP {
+	return domain.TOTP{
+		CustomerID:  t.CustomerID,
+		Secret:      envelope.Sealed{KeyID: t.KeyID, DataKey: t.DataKey, Ciphertext: t.Secret},
+		Confirmed:   t.Confirmed,
+		LastCounter: t.LastCounter,
+		CreatedAt:   t.CreatedAt.Time,
+	}
+}
+
+type mfaChallenge struct {
+	Hash       string         `db:"hash"`
+	CustomerID string         `db:"customer_id"`
+	ExpiresAt  mysql.NullTime `db:"expires_at"`
+}
+
+func NewMFAStore(db db.DB) *MFAStore {
+	return &MFAStore{db: db}
+}
+
+type MFAStore struct {
+	db db.DB
+}
+
+func (m *MFAStore) WithTx(db db.DB) domain.MFAStore {
+	return &MFAStore{db: db}
+}
+
+func (m *MFAStore) GetTOTP(ctx context.Context, customerID string) (domain.TOTP, error) {
+	query := "SELECT customer_id, secret, data_key, key_id, confirmed, last_counter, created_at FROM customer_totp WHERE customer_id=?"
+
+	var result customerTOTP
+	if err := GetContext(ctx, m.db, &result, query, customerID); err != nil {
+		return domain.TOTP{}, fmt.Errorf("MFAStore.GetTOTP(%s): %w", customerID, err)
+	}
+
+	return result.toDomain(), nil
+}
+
+func (m *MFAStore) SaveTOTP(ctx context.Context, t *domain.TOTP) error {
+	t.CreatedAt = time.Now().UTC()
+
+	query := "REPLACE INTO customer_totp(customer_id, secret, data_key, key_id, confirmed, last_counter, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)"
+
+	_, err := m.db.ExecContext(ctx, query, t.CustomerID, t.Secret.Ciphertext, t.Secret.DataKey, t.Secret.KeyID, t.Confirmed, t.LastCounter, t.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("MFAStore.SaveTOTP(%s): %w", t.CustomerID, err)
+	}
+
+	return nil
+}
+
+func (m *MFAStore) ConfirmTOTP(ctx context.Context, customerID string, counter int64) error {
+	query := "UPDATE customer_totp SET confirmed=TRUE, last_counter=? WHERE customer_id=? AND NOT confirmed"
+
+	if err := execOne(ctx, m.db, domain.ErrNotFound, query, counter, customerID); err != nil {
+		return fmt.Errorf("MFAStore.ConfirmTOTP(%s): %w", customerID, err)
+	}
+
+	return nil
+}
+
+func (m *MFAStore) UseTOTPCounter(ctx context.Context, customerID string, counter int64) error {
+	query := "UPDATE customer_totp SET last_counter=? WHERE customer_id=? AND last_counter < ?"
+
+	if err := execOne(ctx, m.db, domain.ErrConflict, query, counter, customerID, counter); err != nil {
+		return fmt.Errorf("MFAStore.UseTOTPCounter(%s): %w", customerID, err)
+	}
+
+	return nil
+}
+
+func (m *MFAStore) ReplaceRecoveryCodes(ctx context.Context, customerID string, hashes []string) error {
+	if _, err := m.db.ExecContext(ctx, "DELETE FROM recovery_code WHERE customer_id=?", customerID); err != nil {
+		return fmt.Errorf("MFAStore.ReplaceRecoveryCodes(%s): %w", customerID, err)
+	}
+
+	for _, h := range hashes {
+		query := "INSERT INTO recovery_code(customer_id, code_hash) VALUES (?, ?)"
+		if _, err := m.db.ExecContext(ctx, query, customerID, h); err != nil {
+			return fmt.Errorf("MFAStore.ReplaceRecoveryCodes(%s): %w", customerID, err)
+		}
+	}
+
+	return nil
+}
+
+func (m *MFAStore) UseRecoveryCode(ctx context.Context, customerID, hash string) error {
+	query := "UPDATE recovery_code SET used_at=? WHERE customer_id=? AND code_hash=? AND used_at IS NULL"
+
+	if err := execOne(ctx, m.db, domain.ErrNotFound, query, time.Now().UTC(), customerID, hash); err != nil {
+		return fmt.Errorf("MFAStore.UseRecoveryCode(%s): %w", customerID, err)
+	}
+
+	return nil
+}
+
+func (m *MFAStore) CreateMFAChallenge(ctx context.Context, c *domain.MFAChallenge) error {
+	query := "INSERT INTO mfa_challenge(hash, customer_id, expires_at) VALUES (?, ?, ?)"
+
+	if _, err := m.db.ExecContext(ctx, query, c.Hash, c.CustomerID, c.ExpiresAt.UTC()); err != nil {
+		return fmt.Errorf("MFAStore.CreateMFAChallenge(customerID=%s): %w", c.CustomerID, err)
+	}
+
+	return nil
+}
+
+func (m *MFAStore) GetMFAChallenge(ctx context.Context, hash string) (domain.MFAChallenge, error) {
+	query := "SELECT hash, customer_id, expires_at FROM mfa_challenge WHERE hash=?"
+
+	var result mfaChallenge
+	if err := GetContext(ctx, m.db, &result, query, hash); err != nil {
+		return domain.MFAChallenge{}, fmt.Errorf("MFAStore.GetMFAChallenge: %w", err)
+	}
+
+	return domain.MFAChallenge{Hash: result.Hash, CustomerID: result.CustomerID, ExpiresAt: result.ExpiresAt.Time}, nil
+}
+
+func (m *MFAStore) DeleteMFAChallenge(ctx context.Context, hash string) error {
+	if _, err := m.db.ExecContext(ctx, "DELETE FROM mfa_challenge WHERE hash=?", hash); err != nil {
+		return fmt.Errorf("MFAStore.DeleteMFAChallenge: %w", err)
+	}
+
+	return nil
+}
+
+// execOne runs the update, failing with errNone if it affected no rows.
+func execOne(ctx context.Context, db db.DB, errNone error, query string, args ...interface{}) error {
+	res, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return errNone
+	}
+
+	return nil
+}
@@ -0,0 +1,224 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/google/uuid"
+
+	"github.com/oshankkumar/sockshop/internal/db"
+	"github.com/oshankkumar/sockshop/internal/domain"
+)
+
+const orderQuery = "SELECT id, customer_id, card_id, street, number, country, city, postcode, " +
+	"subtotal, shipping, total, status, created_at FROM customer_order "
+
+type order struct {
+	ID         uuid.UUID      `db:"id"`
+	CustomerID string         `db:"customer_id"`
+	CardID     string         `db:"card_id"`
+	Street     string         `db:"street"`
+	Number     string         `db:"number"`
+	Country    string         `db:"country"`
+	City       string         `db:"city"`
+	PostCode   string         `db:"postcode"`
+	Subtotal   float64        `db:"subtotal"`
+	Shipping   float64        `db:"shipping"`
+	Total      float64        `db:"total"`
+	Status     string         `db:"status"`
+	CreatedAt  mysql.NullTime `db:"created_at"`
+}
+
+type orderStatus struct {
+	Status    string         `db:"status"`
+	CreatedAt mysql.NullTime `db:"created_at"`
+}
+
+func NewOrderStore(db db.DB) *OrderStore {
+	return &OrderStore{db: db}
+}
+
+type OrderStore struct {
+	db db.DB
+}
+
+func (o *OrderStore) WithTx(db db.DB) domain.OrderStore {
+	return &OrderStore{db: db}
+}
+
+func (o *OrderStore) CreateOrder(ctx context.Context, ord *domain.Order) error {
+	ord.ID = uuid.New()
+	ord.CreatedAt = time.Now().UTC().Truncate(time.Second)
+
+	query := "INSERT INTO customer_order(id, customer_id, card_id, street, number, country, city, postcode, " +
+		"subtotal, shipping, total, status, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+
+	_, err := o.db.ExecContext(ctx, query,
+		ord.ID,
+		ord.CustomerID,
+		ord.CardID,
+		ord.Address.Street,
+		ord.Address.Number,
+		ord.Address.Country,
+		ord.Address.City,
+		ord.Address.PostCode,
+		ord.Subtotal,
+		ord.Shipping,
+		ord.Total,
+		ord.Status,
+		ord.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("OrderStore.CreateOrder(customerID=%s): %w", ord.CustomerID, err)
+	}
+
+	query = "INSERT INTO order_item(order_id, sock_id, quantity, unit_price) VALUES (?, ?, ?, ?)"
+	for _, item := range ord.Items {
+		if _, err := o.db.ExecContext(ctx, query, ord.ID, item.SockID, item.Quantity, item.UnitPrice); err != nil {
+			return fmt.Errorf("OrderStore.CreateOrder(customerID=%s): order_item: %w", ord.CustomerID, err)
+		}
+	}
+
+	if err := o.addStatus(ctx, ord.ID.String(), ord.Status, ord.CreatedAt); err != nil {
+		return fmt.Errorf("OrderStore.CreateOrder(customerID=%s): %w", ord.CustomerID, err)
+	}
+
+	ord.History = []domain.OrderStatusChange{{Status: ord.Status, CreatedAt: ord.CreatedAt}}
+
+	return nil
+}
+
+func (o *OrderStore) GetOrder(ctx context.Context, id string) (domain.Order, error) {
+	var result order
+	if err := GetContext(ctx, o.db, &result, orderQuery+"WHERE id=?;", id); err != nil {
+		return domain.Order{}, fmt.Errorf("OrderStore.GetOrder(%s): %w", id, err)
+	}
+
+	ord, err := o.toDomain(ctx, result)
+	if err != nil {
+		return ord, fmt.Errorf("OrderStore.GetOrder(%s): %w", id, err)
+	}
+
+	return ord, nil
+}
+
+func (o *OrderStore) GetOrders(ctx context.Context, page domain.Page) ([]domain.Order, error) {
+	query := orderQuery
+
+	var args []interface{}
+	if page.After != nil {
+		query += "WHERE id > ? "
+		args = append(args, page.After.ID)
+	}
+
+	query += "ORDER BY id LIMIT ?"
+	args = append(args, page.Limit)
+
+	if page.After == nil {
+		query += " OFFSET ?"
+		args = append(args, page.Offset)
+	}
+
+	orders, err := o.selectOrders(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("OrderStore.GetOrders(): %w", err)
+	}
+
+	return orders, nil
+}
+
+func (o *OrderStore) GetCustomerOrders(ctx context.Context, customerID string) ([]domain.Order, error) {
+	orders, err := o.selectOrders(ctx, orderQuery+"WHERE customer_id=? ORDER BY created_at DESC, id;", customerID)
+	if err != nil {
+		return nil, fmt.Errorf("OrderStore.GetCustomerOrders(%s): %w", customerID, err)
+	}
+
+	return orders, nil
+}
+
+func (o *OrderStore) UpdateStatus(ctx context.Context, id string, status domain.OrderStatus) error {
+	res, err := o.db.ExecContext(ctx, "UPDATE customer_order SET status=? WHERE id=?", status, id)
+	if err != nil {
+		return fmt.Errorf("OrderStore.UpdateStatus(%s): %w", id, err)
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		if _, err := o.GetOrder(ctx, id); err != nil {
+			return fmt.Errorf("OrderStore.UpdateStatus(%s): %w", id, err)
+		}
+	}
+
+	if err := o.addStatus(ctx, id, status, time.Now().UTC()); err != nil {
+		return fmt.Errorf("OrderStore.UpdateStatus(%s): %w", id, err)
+	}
+
+	return nil
+}
+
+func (o *OrderStore) addStatus(ctx context.Context, id string, status domain.OrderStatus, at time.Time) error {
+	query := "INSERT INTO order_status(order_id, status, created_at) VALUES (?, ?, ?)"
+
+	if _, err := o.db.ExecContext(ctx, query, id, status, at); err != nil {
+		return fmt.Errorf("order_status: %w", err)
+	}
+
+	return nil
+}
+
+func (o *OrderStore) selectOrders(ctx context.Context, query string, args ...interface{}) ([]domain.Order, error) {
+	var results []order
+	if err := SelectContext(ctx, o.db, &results, query, args...); err != nil {
+		return nil, err
+	}
+
+	orders := make([]domain.Order, 0, len(results))
+	for _, res := range results {
+		ord, err := o.toDomain(ctx, res)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, ord)
+	}
+
+	return orders, nil
+}
+
+func (o *OrderStore) toDomain(ctx context.Context, res order) (domain.Order, error) {
+	ord := domain.Order{
+		ID:         res.ID,
+		CustomerID: res.CustomerID,
+		CardID:     res.CardID,
+		Address: domain.Address{
+			Street:   res.Street,
+			Number:   res.Number,
+			Country:  res.Country,
+			City:     res.City,
+			PostCode: res.PostCode,
+		},
+		Subtotal:  res.Subtotal,
+		Shipping:  res.Shipping,
+		Total:     res.Total,
+		Status:    domain.OrderStatus(res.Status),
+		CreatedAt: res.CreatedAt.Time,
+	}
+
+	query := "SELECT sock_id, quantity, unit_price FROM order_item WHERE order_id=? ORDER BY sock_id;"
+	if err := SelectContext(ctx, o.db, &ord.Items, query, res.ID); err != nil {
+		return ord, fmt.Errorf("order_item(%s): %w", res.ID, err)
+	}
+
+	var history []orderStatus
+
+	query = "SELECT status, created_at FROM order_status WHERE order_id=? ORDER BY created_at, id;"
+	if err := SelectContext(ctx, o.db, &history, query, res.ID); err != nil {
+		return ord, fmt.Errorf("order_status(%s): %w", res.ID, err)
+	}
+
+	for _, h := range history {
+		ord.History = append(ord.History, domain.OrderStatusChange{Status: domain.OrderStatus(h.Status), CreatedAt: h.CreatedAt.Time})
+	}
+
+	return ord, nil
+}
@@ -0,0 +1,112 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/google/uuid"
+
+	"github.com/oshankkumar/sockshop/internal/db"
+	"github.com/oshankkumar/sockshop/internal/domain"
+)
+
+type payment struct {
+	ID              uuid.UUID      `db:"id"`
+	OrderID         string         `db:"order_id"`
+	Attempt         int            `db:"attempt"`
+	IdempotencyKey  string         `db:"idempotency_key"`
+	Amount          float64        `db:"amount"`
+	Status          string         `db:"status"`
+	AuthorizationID string         `db:"authorization_id"`
+	DeclineReason   string         `db:"decline_reason"`
+	CreatedAt       mysql.NullTime `db:"created_at"`
+	UpdatedAt       mysql.NullTime `db:"updated_at"`
+}
+
+func NewPaymentStore(db db.DB) *PaymentStore {
+	return &PaymentStore{db: db}
+}
+
+type PaymentStore struct {
+	db db.DB
+}
+
+func (p *PaymentStore) WithTx(db db.DB) domain.PaymentStore {
+	return &PaymentStore{db: db}
+}
+
+func (p *PaymentStore) CreatePayment(ctx context.Context, pay *domain.Payment) error {
+	pay.ID = uuid.New()
+	pay.CreatedAt = time.Now().UTC()
+	pay.UpdatedAt = pay.CreatedAt
+
+	query := "INSERT INTO payment(id, order_id, attempt, idempotency_key, amount, status, authorization_id, decline_reason, created_at, updated_at) " +
+		"VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+
+	_, err := p.db.ExecContext(ctx, query,
+		pay.ID,
+		pay.OrderID,
+		pay.Attempt,
+		pay.IdempotencyKey,
+		pay.Amount,
+		pay.Status,
+		pay.AuthorizationID,
+		pay.DeclineReason,
+		pay.CreatedAt,
+		pay.UpdatedAt,
+	)
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == ErrCodeDupe {
+		return domain.DuplicateEntryError{Entity: "payment", Err: err}
+	}
+
+	if err != nil {
+		return fmt.Errorf("PaymentStore.CreatePayment(orderID=%s): %w", pay.OrderID, err)
+	}
+
+	return nil
+}
+
+func (p *PaymentStore) UpdatePayment(ctx context.Context, pay *domain.Payment) error {
+	pay.UpdatedAt = time.Now().UTC()
+
+	query := "UPDATE payment SET status=?, authorization_id=?, decline_reason=?, updated_at=? WHERE id=?"
+
+	if _, err := p.db.ExecContext(ctx, query, pay.Status, pay.AuthorizationID, pay.DeclineReason, pay.UpdatedAt, pay.ID); err != nil {
+		return fmt.Errorf("PaymentStore.UpdatePayment(%s): %w", pay.ID, err)
+	}
+
+	return nil
+}
+
+func (p *PaymentStore) GetOrderPayments(ctx context.Context, orderID string) ([]domain.Payment, error) {
+	query := "SELECT id, order_id, attempt, idempotency_key, amount, status, authorization_id, decline_reason, created_at, updated_at " +
+		"FROM payment WHERE order_id=? ORDER BY attempt;"
+
+	var results []payment
+	if err := SelectContext(ctx, p.db, &results, query, orderID); err != nil {
+		return nil, fmt.Errorf("PaymentStore.GetOrderPayments(%s): %w", orderID, err)
+	}
+
+	payments := make([]domain.Payment, 0, len(results))
+	for _, res := range results {
+		payments = append(payments, domain.Payment{
+			ID:              res.ID,
+			OrderID:         res.OrderID,
+			Attempt:         res.Attempt,
+			IdempotencyKey:  res.IdempotencyKey,
+			Amount:          res.Amount,
+			Status:          domain.PaymentStatus(res.Status),
+			AuthorizationID: res.AuthorizationID,
+			DeclineReason:   res.DeclineReason,
+			CreatedAt:       res.CreatedAt.Time,
+			UpdatedAt:       res.UpdatedAt.Time,
+		})
+	}
+
+	return payments, nil
+}
@@ -0,0 +1,172 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/google/uuid"
+
+	"github.com/oshankkumar/sockshop/internal/db"
+	"github.com/oshankkumar/sockshop/internal/domain"
+)
+
+type reservation struct {
+	ID        uuid.UUID      `db:"id"`
+	SockID    string         `db:"sock_id"`
+	Owner     string         `db:"owner"`
+	Quantity  int            `db:"quantity"`
+	Status    string         `db:"status"`
+	ExpiresAt mysql.NullTime `db:"expires_at"`
+	CreatedAt mysql.NullTime `db:"created_at"`
+}
+
+func NewReservationStore(db db.DB) *ReservationStore {
+	return &ReservationStore{db: db}
+}
+
+type ReservationStore struct {
+	db db.DB
+}
+
+func (r *ReservationStore) WithTx(db db.DB) domain.ReservationStore {
+	return &ReservationStore{db: db}
+}
+
+// Reserve locks the sock row to serialise concurrent reservations of the same
+// sock. Run it on a store bound to a transaction (see WithTx) so that the lock
+// is held until the reservation is written.
+func (r *ReservationStore) Reserve(ctx context.Context, res *domain.Reservation) error {
+	var count int
+	if err := GetContext(ctx, r.db, &count, "SELECT count FROM sock WHERE id=? FOR UPDATE", res.SockID); err != nil {
+		return fmt.Errorf("ReservationStore.Reserve(%s): %w", res.SockID, err)
+	}
+
+	now := time.Now().UTC()
+
+	var reserved int
+	query := "SELECT COALESCE(SUM(quantity), 0) FROM reservation WHERE sock_id=? AND status=? AND expires_at > ? FOR UPDATE"
+	if err := GetContext(ctx, r.db, &reserved, query, res.SockID, domain.ReservationStatusActive, now); err != nil {
+		return fmt.Errorf("ReservationStore.Reserve(%s): %w", res.SockID, err)
+	}
+
+	if count-reserved < res.Quantity {
+		return fmt.Errorf("ReservationStore.Reserve(%s): %w", res.SockID, domain.ErrInsufficientStock)
+	}
+
+	res.ID = uuid.New()
+	res.Status = domain.ReservationStatusActive
+	res.CreatedAt = now
+
+	query = "INSERT INTO reservation(id, sock_id, owner, quantity, status, expires_at, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)"
+
+	_, err := r.db.ExecContext(ctx, query, res.ID, res.SockID, res.Owner, res.Quantity, res.Status, res.ExpiresAt.UTC(), res.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("ReservationStore.Reserve(%s): %w", res.SockID, err)
+	}
+
+	return nil
+}
+
+func (r *ReservationStore) GetOwnerReservations(ctx context.Context, owner string) ([]domain.Reservation, error) {
+	query := "SELECT id, sock_id, owner, quantity, status, expires_at, created_at FROM reservation " +
+		"WHERE owner=? AND status=? AND expires_at > ? ORDER BY sock_id;"
+
+	var results []reservation
+	if err := SelectContext(ctx, r.db, &results, query, owner, domain.ReservationStatusActive, time.Now().UTC()); err != nil {
+		return nil, fmt.Errorf("ReservationStore.GetOwnerReservations(%s): %w", owner, err)
+	}
+
+	reservations := make([]domain.Reservation, 0, len(results))
+	for _, res := range results {
+		reservations = append(reservations, domain.Reservation{
+			ID:        res.ID,
+			SockID:    res.SockID,
+			Owner:     res.Owner,
+			Quantity:  res.Quantity,
+			Status:    domain.ReservationStatus(res.Status),
+			ExpiresAt: res.ExpiresAt.Time,
+			CreatedAt: res.CreatedAt.Time,
+		})
+	}
+
+	return reservations, nil
+}
+
+// Commit only decrements the stock of the reservations which have not
+// expired yet. The expired ones may have been replaced by new reservations of
+// the same owner before the sweeper ran, and would decrement it twice.
+func (r *ReservationStore) Commit(ctx context.Context, owner string) (map[string]int, error) {
+	now := time.Now().UTC()
+
+	var rows []struct {
+		SockID   string `db:"sock_id"`
+		Quantity int    `db:"quantity"`
+		Count    int    `db:"count"`
+	}
+
+	query := "SELECT reservation.sock_id, reservation.quantity, sock.count FROM reservation JOIN sock ON sock.id = reservation.sock_id " +
+		"WHERE reservation.owner=? AND reservation.status=? AND reservation.expires_at > ? FOR UPDATE"
+
+	if err := SelectContext(ctx, r.db, &rows, query, owner, domain.ReservationStatusActive, now); err != nil {
+		return nil, fmt.Errorf("ReservationStore.Commit(%s): %w", owner, err)
+	}
+
+	committed := make(map[string]int)
+	onHand := make(map[string]int)
+	for _, row := range rows {
+		committed[row.SockID] += row.Quantity
+		onHand[row.SockID] = row.Count
+	}
+
+	for sockID, n := range committed {
+		if onHand[sockID] < n {
+			return nil, fmt.Errorf("ReservationStore.Commit(%s): sock %s: %w", owner, sockID, domain.ErrInsufficientStock)
+		}
+
+		if _, err := r.db.ExecContext(ctx, "UPDATE sock SET count = count - ? WHERE id=?", n, sockID); err != nil {
+			return nil, fmt.Errorf("ReservationStore.Commit(%s): decrement stock: %w", owner, err)
+		}
+	}
+
+	query = "UPDATE reservation SET status=? WHERE owner=? AND status=? AND expires_at > ?"
+
+	_, err := r.db.ExecContext(ctx, query, domain.ReservationStatusCommitted, owner, domain.ReservationStatusActive, now)
+	if err != nil {
+		return nil, fmt.Errorf("ReservationStore.Commit(%s): %w", owner, err)
+	}
+
+	return committed, nil
+}
+
+func (r *ReservationStore) Release(ctx context.Context, owner string) error {
+	if err := r.setStatus(ctx, owner, domain.ReservationStatusReleased); err != nil {
+		return fmt.Errorf("ReservationStore.Release(%s): %w", owner, err)
+	}
+
+	return nil
+}
+
+func (r *ReservationStore) setStatus(ctx context.Context, owner string, status domain.ReservationStatus) error {
+	query := "UPDATE reservation SET status=? WHERE owner=? AND status=?"
+
+	_, err := r.db.ExecContext(ctx, query, status, owner, domain.ReservationStatusActive)
+	return err
+}
+
+func (r *ReservationStore) Expire(ctx context.Context, t time.Time) (int, error) {
+	query := "UPDATE reservation SET status=? WHERE status=? AND expires_at <= ?"
+
+	res, err := r.db.ExecContext(ctx, query, domain.ReservationStatusExpired, domain.ReservationStatusActive, t.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("ReservationStore.Expire: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("ReservationStore.Expire: %w", err)
+	}
+
+	return int(n), nil
+}
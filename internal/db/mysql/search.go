@@ -0,0 +1,49 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/oshankkumar/sockshop/internal/db"
+	"github.com/oshankkumar/sockshop/internal/domain"
+)
+
+const matchExpr = "MATCH(sock.name, sock.description) AGAINST (? IN NATURAL LANGUAGE MODE)"
+
+type sockHit struct {
+	sock
+	Score sql.NullFloat64 `db:"score"`
+}
+
+// SockSearcher implements domain.SockSearcher with the FULLTEXT index on
+// sock(name, description).
+type SockSearcher struct {
+	db db.DB
+}
+
+func NewSockSearcher(db db.DB) *SockSearcher {
+	return &SockSearcher{db: db}
+}
+
+func (s *SockSearcher) Search(ctx context.Context, query string, limit int) ([]domain.SockHit, error) {
+	q := "SELECT sock.id, sock.name, sock.description, sock.price, sock.count, sock.image_urls, " +
+		reservedExpr + " AS reserved, GROUP_CONCAT(tag.name) AS tag_name, " + matchExpr + " AS score " +
+		"FROM sock LEFT JOIN sock_tag ON sock.id = sock_tag.sock_id LEFT JOIN tag ON sock_tag.tag_id = tag.id " +
+		"WHERE " + matchExpr + " " +
+		"GROUP BY sock.id ORDER BY score DESC, sock.id LIMIT ?"
+
+	var results []sockHit
+	if err := sqlx.SelectContext(ctx, s.db, &results, q, query, query, limit); err != nil {
+		return nil, fmt.Errorf("SockSearcher.Search(%s): %w", query, err)
+	}
+
+	var hits []domain.SockHit
+	for _, res := range results {
+		hits = append(hits, domain.SockHit{Sock: res.toDomain(), Score: res.Score.Float64})
+	}
+
+	return hits, nil
+}
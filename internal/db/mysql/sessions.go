@@ -0,0 +1,122 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/google/uuid"
+
+	"github.com/oshankkumar/sockshop/internal/db"
+	"github.com/oshankkumar/sockshop/internal/domain"
+)
+
+type session struct {
+	ID          uuid.UUID      `db:"id"`
+	CustomerID  string         `db:"customer_id"`
+	RefreshHash string         `db:"refresh_hash"`
+	ExpiresAt   mysql.NullTime `db:"expires_at"`
+	CreatedAt   mysql.NullTime `db:"created_at"`
+	RevokedAt   mysql.NullTime `db:"revoked_at"`
+}
+
+func (s session) toDomain() domain.Session {
+	sess := domain.Session{
+		ID:          s.ID,
+		CustomerID:  s.CustomerID,
+		RefreshHash: s.RefreshHash,
+		ExpiresAt:   s.ExpiresAt.Time,
+		CreatedAt:   s.CreatedAt.Time,
+	}
+	if s.RevokedAt.Valid {
+		sess.RevokedAt = &s.RevokedAt.Time
+	}
+	return sess
+}
+
+const sessionColumns = "id, customer_id, refresh_hash, expires_at, created_at, revoked_at"
+
+func NewSessionStore(db db.DB) *SessionStore {
+	return &SessionStore{db: db}
+}
+
+type SessionStore struct {
+	db db.DB
+}
+
+func (s *SessionStore) CreateSession(ctx context.Context, sess *domain.Session) error {
+	sess.ID = uuid.New()
+	sess.CreatedAt = time.Now().UTC()
+
+	query := "INSERT INTO session(id, customer_id, refresh_hash, expires_at, created_at) VALUES (?, ?, ?, ?, ?)"
+
+	_, err := s.db.ExecContext(ctx, query, sess.ID, sess.CustomerID, sess.RefreshHash, sess.ExpiresAt.UTC(), sess.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("SessionStore.CreateSession(customerID=%s): %w", sess.CustomerID, err)
+	}
+
+	return nil
+}
+
+func (s *SessionStore) GetSession(ctx context.Context, id string) (domain.Session, error) {
+	query := "SELECT " + sessionColumns + " FROM session WHERE id=?;"
+
+	var result session
+	if err := GetContext(ctx, s.db, &result, query, id); err != nil {
+		return domain.Session{}, fmt.Errorf("SessionStore.GetSession(%s): %w", id, err)
+	}
+
+	return result.toDomain(), nil
+}
+
+func (s *SessionStore) GetSessionByRefreshHash(ctx context.Context, hash string) (domain.Session, error) {
+	query := "SELECT " + sessionColumns + " FROM session WHERE refresh_hash=?;"
+
+	var result session
+	if err := GetContext(ctx, s.db, &result, query, hash); err != nil {
+		return domain.Session{}, fmt.Errorf("SessionStore.GetSessionByRefreshHash: %w", err)
+	}
+
+	return result.toDomain(), nil
+}
+
+func (s *SessionStore) RotateRefresh(ctx context.Context, id, oldHash, newHash string, expiresAt time.Time) error {
+	query := "UPDATE session SET refresh_hash=?, expires_at=? WHERE id=? AND refresh_hash=? AND revoked_at IS NULL"
+
+	res, err := s.db.ExecContext(ctx, query, newHash, expiresAt.UTC(), id, oldHash)
+	if err != nil {
+		return fmt.Errorf("SessionStore.RotateRefresh(%s): %w", id, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("SessionStore.RotateRefresh(%s): %w", id, err)
+	}
+
+	if n == 0 {
+		return fmt.Errorf("SessionStore.RotateRefresh(%s): %w", id, domain.ErrNotFound)
+	}
+
+	return nil
+}
+
+func (s *SessionStore) RevokeSession(ctx context.Context, id string) error {
+	query := "UPDATE session SET revoked_at=? WHERE id=? AND revoked_at IS NULL"
+
+	if _, err := s.db.ExecContext(ctx, query, time.Now().UTC(), id); err != nil {
+		return fmt.Errorf("SessionStore.RevokeSession(%s): %w", id, err)
+	}
+
+	return nil
+}
+
+func (s *SessionStore) RevokeCustomerSessions(ctx context.Context, customerID, exceptID string) error {
+	query := "UPDATE session SET revoked_at=? WHERE customer_id=? AND id<>? AND revoked_at IS NULL"
+
+	if _, err := s.db.ExecContext(ctx, query, time.Now().UTC(), customerID, exceptID); err != nil {
+		return fmt.Errorf("SessionStore.RevokeCustomerSessions(customerID=%s): %w", customerID, err)
+	}
+
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/go-sql-driver/mysql"
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 
@@ -14,9 +15,13 @@ import (
 	"github.com/oshankkumar/sockshop/internal/domain"
 )
 
+// reservedExpr sums the units of a sock held by unexpired active reservations.
+const reservedExpr = "(SELECT COALESCE(SUM(r.quantity), 0) FROM reservation r " +
+	"WHERE r.sock_id = sock.id AND r.status = 'active' AND r.expires_at > UTC_TIMESTAMP(6))"
+
 const baseQuery = "SELECT sock.id, sock.name, sock.description, sock.price, sock.count, sock.image_urls, " +
-	"GROUP_CONCAT(tag.name) AS tag_name " +
-	"FROM sock JOIN sock_tag ON sock.id = sock_tag.sock_id JOIN tag ON sock_tag.tag_id = tag.id "
+	reservedExpr + " AS reserved, GROUP_CONCAT(tag.name) AS tag_name " +
+	"FROM sock LEFT JOIN sock_tag ON sock.id = sock_tag.sock_id LEFT JOIN tag ON sock_tag.tag_id = tag.id "
 
 type sock struct {
 	ID          uuid.UUID       `db:"id"`
@@ -24,10 +29,31 @@ type sock struct {
 	Description sql.NullString  `db:"description"`
 	Price       sql.NullFloat64 `db:"price"`
 	Count       sql.NullInt32   `db:"count"`
+	Reserved    int             `db:"reserved"`
 	ImageURLs   sql.NullString  `db:"image_urls"`
 	TagName     sql.NullString  `db:"tag_name"`
 }
 
+func (s sock) toDomain() domain.Sock {
+	var tags []domain.Tag
+	if s.TagName.Valid {
+		for _, t := range strings.Split(s.TagName.String, ",") {
+			tags = append(tags, domain.Tag{Name: t})
+		}
+	}
+
+	return domain.Sock{
+		ID:          s.ID,
+		Name:        s.Name.String,
+		Description: s.Description.String,
+		ImageURLs:   s.ImageURLs.String,
+		Price:       s.Price.Float64,
+		Count:       int(s.Count.Int32),
+		Reserved:    s.Reserved,
+		Tags:        tags,
+	}
+}
+
 type SockStore struct {
 	db db.DB
 }
@@ -36,59 +62,133 @@ func NewSockStore(db db.DB) *SockStore {
 	return &SockStore{db: db}
 }
 
-func (s *SockStore) List(ctx context.Context, tags []string, order string, limit, offset int) ([]domain.Sock, error) {
-	var tagCond string
-	if len(tags) > 0 {
-		tagCond = "WHERE tag.name IN ( ?" + strings.Repeat(",?", len(tags)-1) + ") "
+func (s *SockStore) WithTx(db db.DB) domain.SockStore {
+	return &SockStore{db: db}
+}
+
+var sockSortColumns = map[domain.SockSortField]string{
+	domain.SortByID:    "sock.id",
+	domain.SortByName:  "sock.name",
+	domain.SortByPrice: "sock.price",
+	domain.SortByCount: "sock.count",
+}
+
+func orderByClause(order domain.SockOrder) (string, error) {
+	col, ok := sockSortColumns[order.Field]
+	if !ok {
+		return "", fmt.Errorf("%w: unsupported sort field %q", domain.ErrInvalid, order.Field)
 	}
 
-	query := baseQuery + tagCond + "GROUP BY id ORDER BY ?"
+	dir := "ASC"
+	if order.Desc {
+		dir = "DESC"
+	}
 
-	var results []sock
+	if col == "sock.id" {
+		return "ORDER BY sock.id " + dir, nil
+	}
 
-	args := make([]interface{}, 0, len(tags)+1)
-	for _, t := range tags {
-		args = append(args, t)
+	return "ORDER BY " + col + " " + dir + ", sock.id " + dir, nil
+}
+
+// keysetCond returns the condition selecting the socks sorted after key.
+func keysetCond(order domain.SockOrder, key domain.Key) (string, []interface{}) {
+	op := ">"
+	if order.Desc {
+		op = "<"
 	}
-	args = append(args, order)
 
-	if err := sqlx.SelectContext(ctx, s.db, &results, query, args...); err != nil {
-		return nil, fmt.Errorf("SockStore.List: %w", err)
+	col := sockSortColumns[order.Field]
+	if col == "sock.id" {
+		return "sock.id " + op + " ?", []interface{}{key.ID}
 	}
 
-	var socks []domain.Sock
-	for _, res := range results {
-		var tags []domain.Tag
-		for _, t := range strings.Split(res.TagName.String, ",") {
-			tags = append(tags, domain.Tag{Name: t})
+	return "(" + col + ", sock.id) " + op + " (?, ?)", []interface{}{key.Value, key.ID}
+}
+
+// filterConds returns the WHERE conditions selecting the socks matching f.
+func filterConds(f domain.SockFilter) ([]string, []interface{}) {
+	var (
+		conds []string
+		args  []interface{}
+	)
+
+	if len(f.Tags) > 0 {
+		cond := "sock.id IN (SELECT st.sock_id FROM sock_tag st JOIN tag t ON st.tag_id = t.id " +
+			"WHERE t.name IN ( ?" + strings.Repeat(",?", len(f.Tags)-1) + ")"
+		for _, t := range f.Tags {
+			args = append(args, t)
 		}
-		socks = append(socks, domain.Sock{
-			ID:          res.ID,
-			Name:        res.Name.String,
-			Description: res.Description.String,
-			ImageURLs:   res.ImageURLs.String,
-			Price:       res.Price.Float64,
-			Count:       int(res.Count.Int32),
-			Tags:        tags,
-		})
+
+		if f.TagMode == domain.TagMatchAll {
+			cond += " GROUP BY st.sock_id HAVING COUNT(DISTINCT t.name) = ?"
+			args = append(args, len(f.Tags))
+		}
+
+		conds = append(conds, cond+")")
 	}
-	return socks, nil
+
+	if f.MinPrice != nil {
+		conds = append(conds, "sock.price >= ?")
+		args = append(args, *f.MinPrice)
+	}
+
+	if f.MaxPrice != nil {
+		conds = append(conds, "sock.price <= ?")
+		args = append(args, *f.MaxPrice)
+	}
+
+	if f.InStock {
+		conds = append(conds, "sock.count > "+reservedExpr)
+	}
+
+	return conds, args
 }
 
-func (s *SockStore) Count(ctx context.Context, tags []string) (int, error) {
-	query := "SELECT COUNT(DISTINCT sock.id) FROM sock JOIN sock_tag ON sock.id=sock_tag.sock_id JOIN tag ON sock_tag.tag_id=tag.id"
+func whereClause(conds []string) string {
+	if len(conds) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(conds, " AND ") + " "
+}
 
-	var tagCond string
-	if len(tags) > 0 {
-		tagCond = "WHERE tag.name IN ( ?" + strings.Repeat(",?", len(tags)-1) + ") "
+func (s *SockStore) List(ctx context.Context, q domain.SockQuery) ([]domain.Sock, error) {
+	orderBy, err := orderByClause(q.Order)
+	if err != nil {
+		return nil, fmt.Errorf("SockStore.List: %w", err)
 	}
 
-	args := make([]interface{}, 0, len(tags))
-	for _, t := range tags {
-		args = append(args, t)
+	conds, args := filterConds(q.SockFilter)
+
+	if q.Page.After != nil {
+		cond, condArgs := keysetCond(q.Order, *q.Page.After)
+		conds = append(conds, cond)
+		args = append(args, condArgs...)
+	}
+
+	query := baseQuery + whereClause(conds) + "GROUP BY sock.id " + orderBy + " LIMIT ?"
+	args = append(args, q.Page.Limit)
+
+	if q.Page.After == nil {
+		query += " OFFSET ?"
+		args = append(args, q.Page.Offset)
 	}
 
-	query += tagCond + ";"
+	var results []sock
+	if err := sqlx.SelectContext(ctx, s.db, &results, query, args...); err != nil {
+		return nil, fmt.Errorf("SockStore.List: %w", err)
+	}
+
+	var socks []domain.Sock
+	for _, res := range results {
+		socks = append(socks, res.toDomain())
+	}
+	return socks, nil
+}
+
+func (s *SockStore) Count(ctx context.Context, f domain.SockFilter) (int, error) {
+	conds, args := filterConds(f)
+	query := "SELECT COUNT(*) FROM sock " + whereClause(conds)
 
 	var count int
 	if err := sqlx.GetContext(ctx, s.db, &count, query, args...); err != nil {
@@ -98,6 +198,67 @@ func (s *SockStore) Count(ctx context.Context, tags []string) (int, error) {
 	return count, nil
 }
 
+// Facets counts the socks matching f per tag and per price bucket of
+// domain.PriceBucketBounds.
+func (s *SockStore) Facets(ctx context.Context, f domain.SockFilter) (domain.SockFacets, error) {
+	conds, args := filterConds(f)
+
+	var tagCounts []struct {
+		Name  string `db:"name"`
+		Count int    `db:"count"`
+	}
+
+	query := "SELECT tag.name AS name, COUNT(DISTINCT sock.id) AS count " +
+		"FROM sock JOIN sock_tag ON sock.id = sock_tag.sock_id JOIN tag ON sock_tag.tag_id = tag.id " +
+		whereClause(conds) + "GROUP BY tag.name ORDER BY tag.name"
+
+	if err := sqlx.SelectContext(ctx, s.db, &tagCounts, query, args...); err != nil {
+		return domain.SockFacets{}, fmt.Errorf("SockStore.Facets: tags: %w", err)
+	}
+
+	bounds := domain.PriceBucketBounds
+
+	var bucketExpr strings.Builder
+	bucketExpr.WriteString("CASE")
+	for i := len(bounds) - 1; i >= 0; i-- {
+		fmt.Fprintf(&bucketExpr, " WHEN sock.price >= %v THEN %d", bounds[i], i)
+	}
+	bucketExpr.WriteString(" END")
+
+	var priceCounts []struct {
+		Bucket sql.NullInt32 `db:"bucket"`
+		Count  int           `db:"count"`
+	}
+
+	query = "SELECT " + bucketExpr.String() + " AS bucket, COUNT(*) AS count FROM sock " +
+		whereClause(conds) + "GROUP BY bucket"
+
+	if err := sqlx.SelectContext(ctx, s.db, &priceCounts, query, args...); err != nil {
+		return domain.SockFacets{}, fmt.Errorf("SockStore.Facets: prices: %w", err)
+	}
+
+	var facets domain.SockFacets
+	for _, t := range tagCounts {
+		facets.Tags = append(facets.Tags, domain.TagFacet{Name: t.Name, Count: t.Count})
+	}
+
+	for i, lower := range bounds {
+		pf := domain.PriceFacet{Min: lower}
+		if i+1 < len(bounds) {
+			upper := bounds[i+1]
+			pf.Max = &upper
+		}
+		for _, p := range priceCounts {
+			if p.Bucket.Valid && int(p.Bucket.Int32) == i {
+				pf.Count = p.Count
+			}
+		}
+		facets.Prices = append(facets.Prices, pf)
+	}
+
+	return facets, nil
+}
+
 func (s *SockStore) Get(ctx context.Context, id string) (domain.Sock, error) {
 	query := baseQuery + " WHERE sock.id = ? GROUP BY sock.id;"
 
@@ -112,20 +273,25 @@ func (s *SockStore) Get(ctx context.Context, id string) (domain.Sock, error) {
 		return domain.Sock{}, fmt.Errorf("SockStore.Get(%s): %w", id, err)
 	}
 
-	var tags []domain.Tag
-	for _, t := range strings.Split(result.TagName.String, ",") {
-		tags = append(tags, domain.Tag{Name: t})
+	return result.toDomain(), nil
+}
+
+// GetForUpdate locks the sock row, like ReservationStore.Reserve, before
+// reading the sock. Run it on a store bound to a transaction (see WithTx) so
+// that the lock is held until the sock is written back.
+func (s *SockStore) GetForUpdate(ctx context.Context, id string) (domain.Sock, error) {
+	var locked uuid.UUID
+
+	err := sqlx.GetContext(ctx, s.db, &locked, "SELECT id FROM sock WHERE id = ? FOR UPDATE;", id)
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return domain.Sock{}, fmt.Errorf("SockStore.GetForUpdate(%s): %w", id, domain.ErrNotFound)
+	case err != nil:
+		return domain.Sock{}, fmt.Errorf("SockStore.GetForUpdate(%s): %w", id, err)
 	}
 
-	return domain.Sock{
-		ID:          result.ID,
-		Name:        result.Name.String,
-		Description: result.Description.String,
-		ImageURLs:   result.ImageURLs.String,
-		Price:       result.Price.Float64,
-		Count:       int(result.Count.Int32),
-		Tags:        tags,
-	}, nil
+	return s.Get(ctx, id)
 }
 
 func (s *SockStore) Tags(ctx context.Context) ([]string, error) {
@@ -138,10 +304,84 @@ func (s *SockStore) Tags(ctx context.Context) ([]string, error) {
 	return tags, nil
 }
 
-func (s *SockStore) Create(ctx context.Context, sock domain.Sock) error {
-	panic("implement me")
+// Create inserts the sock and links it to its tags, creating any tag which
+// does not exist yet. Run it on a store bound to a transaction (see WithTx) so
+// that the sock and its sock_tag rows are written atomically.
+func (s *SockStore) Create(ctx context.Context, sock *domain.Sock) error {
+	sock.ID = uuid.New()
+
+	query := "INSERT INTO sock(id, name, description, price, count, image_urls) VALUES (?, ?, ?, ?, ?, ?)"
+
+	_, err := s.db.ExecContext(ctx, query,
+		sock.ID,
+		sock.Name,
+		sock.Description,
+		sock.Price,
+		sock.Count,
+		sock.ImageURLs,
+	)
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == ErrCodeDupe {
+		return domain.DuplicateEntryError{Entity: "sock", Err: err}
+	}
+
+	if err != nil {
+		return fmt.Errorf("SockStore.Create(%s): %w", sock.Name, err)
+	}
+
+	if err := s.linkTags(ctx, sock.ID.String(), sock.Tags); err != nil {
+		return fmt.Errorf("SockStore.Create(%s): %w", sock.Name, err)
+	}
+
+	return nil
 }
 
+// Update overwrites the sock and replaces its tags with sock.Tags.
 func (s *SockStore) Update(ctx context.Context, sock domain.Sock) error {
-	panic("implement me")
+	query := "UPDATE sock SET name=?, description=?, price=?, count=?, image_urls=? WHERE id=?"
+
+	_, err := s.db.ExecContext(ctx, query,
+		sock.Name,
+		sock.Description,
+		sock.Price,
+		sock.Count,
+		sock.ImageURLs,
+		sock.ID,
+	)
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == ErrCodeDupe {
+		return domain.DuplicateEntryError{Entity: "sock", Err: err}
+	}
+
+	if err != nil {
+		return fmt.Errorf("SockStore.Update(%s): %w", sock.ID, err)
+	}
+
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM sock_tag WHERE sock_id=?", sock.ID); err != nil {
+		return fmt.Errorf("SockStore.Update(%s): delete sock_tag: %w", sock.ID, err)
+	}
+
+	if err := s.linkTags(ctx, sock.ID.String(), sock.Tags); err != nil {
+		return fmt.Errorf("SockStore.Update(%s): %w", sock.ID, err)
+	}
+
+	return nil
+}
+
+func (s *SockStore) linkTags(ctx context.Context, sockID string, tags []domain.Tag) error {
+	for _, t := range tags {
+		query := "INSERT INTO tag(name) SELECT ? FROM DUAL WHERE NOT EXISTS (SELECT 1 FROM tag WHERE name=?)"
+		if _, err := s.db.ExecContext(ctx, query, t.Name, t.Name); err != nil {
+			return fmt.Errorf("insert tag(%s): %w", t.Name, err)
+		}
+
+		query = "INSERT INTO sock_tag(sock_id, tag_id) SELECT ?, id FROM tag WHERE name=? LIMIT 1"
+		if _, err := s.db.ExecContext(ctx, query, sockID, t.Name); err != nil {
+			return fmt.Errorf("insert sock_tag(%s): %w", t.Name, err)
+		}
+	}
+
+	return nil
 }
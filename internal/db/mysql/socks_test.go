@@ -0,0 +1,156 @@
+package mysql
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/oshankkumar/sockshop/internal/domain"
+)
+
+func TestOrderByClause(t *testing.T) {
+	tests := []struct {
+		name    string
+		order   domain.SockOrder
+		want    string
+		wantErr error
+	}{
+		{name: "id", order: domain.SockOrder{Field: domain.SortByID}, want: "ORDER BY sock.id ASC"},
+		{name: "id descending", order: domain.SockOrder{Field: domain.SortByID, Desc: true}, want: "ORDER BY sock.id DESC"},
+		{name: "ties broken by id", order: domain.SockOrder{Field: domain.SortByPrice}, want: "ORDER BY sock.price ASC, sock.id ASC"},
+		{name: "descending ties", order: domain.SockOrder{Field: domain.SortByCount, Desc: true}, want: "ORDER BY sock.count DESC, sock.id DESC"},
+		{name: "unknown field", order: domain.SockOrder{Field: "description"}, wantErr: domain.ErrInvalid},
+		{name: "injected column", order: domain.SockOrder{Field: "price; DROP TABLE sock"}, wantErr: domain.ErrInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := orderByClause(tt.order)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeysetCond(t *testing.T) {
+	tests := []struct {
+		name     string
+		order    domain.SockOrder
+		key      domain.Key
+		wantCond string
+		wantArgs []interface{}
+	}{
+		{name: "id", order: domain.SockOrder{Field: domain.SortByID}, key: domain.Key{ID: "a"}, wantCond: "sock.id > ?", wantArgs: []interface{}{"a"}},
+		{name: "id descending", order: domain.SockOrder{Field: domain.SortByID, Desc: true}, key: domain.Key{ID: "a"}, wantCond: "sock.id < ?", wantArgs: []interface{}{"a"}},
+		{
+			name:     "ties broken by id",
+			order:    domain.SockOrder{Field: domain.SortByPrice},
+			key:      domain.Key{Value: 10.5, ID: "a"},
+			wantCond: "(sock.price, sock.id) > (?, ?)",
+			wantArgs: []interface{}{10.5, "a"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cond, args := keysetCond(tt.order, tt.key)
+			if cond != tt.wantCond || !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Fatalf("got %q %v, want %q %v", cond, args, tt.wantCond, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func floatPtr(f float64) *float64 { return &f }
+
+func TestFilterConds(t *testing.T) {
+	const tagsIn = "sock.id IN (SELECT st.sock_id FROM sock_tag st JOIN tag t ON st.tag_id = t.id WHERE t.name IN ( ?,?)"
+
+	tests := []struct {
+		name      string
+		filter    domain.SockFilter
+		wantConds []string
+		wantArgs  []interface{}
+	}{
+		{name: "no filter"},
+		{
+			name:      "any tag",
+			filter:    domain.SockFilter{Tags: []string{"blue", "wool"}, TagMode: domain.TagMatchAny},
+			wantConds: []string{tagsIn + ")"},
+			wantArgs:  []interface{}{"blue", "wool"},
+		},
+		{
+			name:      "all tags",
+			filter:    domain.SockFilter{Tags: []string{"blue", "wool"}, TagMode: domain.TagMatchAll},
+			wantConds: []string{tagsIn + " GROUP BY st.sock_id HAVING COUNT(DISTINCT t.name) = ?)"},
+			wantArgs:  []interface{}{"blue", "wool", 2},
+		},
+		{
+			name:      "price range",
+			filter:    domain.SockFilter{MinPrice: floatPtr(10), MaxPrice: floatPtr(20)},
+			wantConds: []string{"sock.price >= ?", "sock.price <= ?"},
+			wantArgs:  []interface{}{10.0, 20.0},
+		},
+		{
+			name:      "in stock",
+			filter:    domain.SockFilter{InStock: true},
+			wantConds: []string{"sock.count > " + reservedExpr},
+		},
+		{
+			name: "every filter",
+			filter: domain.SockFilter{
+				Tags:     []string{"blue", "wool"},
+				TagMode:  domain.TagMatchAll,
+				MaxPrice: floatPtr(20),
+				InStock:  true,
+			},
+			wantConds: []string{
+				tagsIn + " GROUP BY st.sock_id HAVING COUNT(DISTINCT t.name) = ?)",
+				"sock.price <= ?",
+				"sock.count > " + reservedExpr,
+			},
+			wantArgs: []interface{}{"blue", "wool", 2, 20.0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conds, args := filterConds(tt.filter)
+
+			if !reflect.DeepEqual(conds, tt.wantConds) {
+				t.Fatalf("got conditions %q, want %q", conds, tt.wantConds)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Fatalf("got args %v, want %v", args, tt.wantArgs)
+			}
+
+			// Every placeholder is bound.
+			if n := strings.Count(strings.Join(conds, " "), "?"); n != len(args) {
+				t.Fatalf("got %d placeholders for %d args", n, len(args))
+			}
+		})
+	}
+}
+
+func TestReservedExpr(t *testing.T) {
+	// Only the unexpired active reservations hold stock.
+	for _, want := range []string{"r.sock_id = sock.id", "r.status = 'active'", "r.expires_at > UTC_TIMESTAMP(6)"} {
+		if !strings.Contains(reservedExpr, want) {
+			t.Fatalf("got %q, want it to select %q", reservedExpr, want)
+		}
+	}
+}
+
+func TestWhereClause(t *testing.T) {
+	if got := whereClause(nil); got != "" {
+		t.Fatalf("got %q, want no clause", got)
+	}
+	if got, want := whereClause([]string{"a = ?", "b = ?"}), "WHERE a = ? AND b = ? "; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
@@ -4,15 +4,28 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/go-sql-driver/mysql"
 	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
 
 	"github.com/oshankkumar/sockshop/internal/db"
 	"github.com/oshankkumar/sockshop/internal/domain"
 )
 
-const ErrCodeDupe = 1062
+const (
+	ErrCodeDupe          = 1062
+	ErrCodeRowReferenced = 1451
+)
+
+// customerColumns are the columns of a domain.User. Customers without an
+// email, such as those registered by an identity provider, have a NULL email
+// so that they do not collide on its unique index.
+const customerColumns = "customer.id, customer.first_name, customer.last_name, COALESCE(customer.email, '') AS email, customer.username, customer.password, customer.salt, customer.version, customer.email_verified"
+
+// cardColumns are the columns of a domain.Card, on a card table aliased c.
+const cardColumns = "c.id, c.token, c.last4, c.brand, c.expires, c.version"
 
 func NewUserStore(db db.DB) *UserStore {
 	return &UserStore{db: db}
@@ -23,8 +36,7 @@ type UserStore struct {
 }
 
 func (u *UserStore) GetUserByName(ctx context.Context, uname string) (domain.User, error) {
-	query := "SELECT customer.id, customer.first_name, customer.last_name, customer.email, customer.username, customer.password, customer.salt " +
-		"FROM customer WHERE username=?;"
+	query := "SELECT " + customerColumns + " FROM customer WHERE username=?;"
 
 	var user domain.User
 	if err := GetContext(ctx, u.db, &user, query, uname); err != nil {
@@ -34,9 +46,19 @@ func (u *UserStore) GetUserByName(ctx context.Context, uname string) (domain.Use
 	return user, u.addAttributes(ctx, &user)
 }
 
+func (u *UserStore) GetUserByEmail(ctx context.Context, email string) (domain.User, error) {
+	query := "SELECT " + customerColumns + " FROM customer WHERE email=?;"
+
+	var user domain.User
+	if err := GetContext(ctx, u.db, &user, query, email); err != nil {
+		return user, fmt.Errorf("UserStore.GetUserByEmail: %w", err)
+	}
+
+	return user, u.addAttributes(ctx, &user)
+}
+
 func (u *UserStore) GetUser(ctx context.Context, id string) (domain.User, error) {
-	query := "SELECT customer.id, customer.first_name, customer.last_name, customer.email, customer.username, customer.password, customer.salt " +
-		"FROM customer WHERE customer.id=?;"
+	query := "SELECT " + customerColumns + " FROM customer WHERE customer.id=?;"
 
 	var user domain.User
 	if err := GetContext(ctx, u.db, &user, query, id); err != nil {
@@ -61,24 +83,47 @@ func (u *UserStore) addAttributes(ctx context.Context, user *domain.User) error
 		return fmt.Errorf("UserStore.addAttributes(%s): %w", user.ID, err)
 	}
 
+	query = "SELECT role FROM customer_role WHERE customer_id=? ORDER BY role;"
+
+	var roles []domain.Role
+	if err := SelectContext(ctx, u.db, &roles, query, user.ID); err != nil {
+		return fmt.Errorf("UserStore.addAttributes(%s): %w", user.ID, err)
+	}
+
 	user.AddressIDs = addrs
 	user.CardIDs = cards
+	user.Roles = roles
 
 	return nil
 }
 
-func (u *UserStore) GetUsers(ctx context.Context) ([]domain.User, error) {
-	query := "SELECT customer.id, customer.first_name, customer.last_name, customer.email, customer.username, customer.password, customer.salt " +
-		"FROM customer;"
+// GetUsers returns a page of users ordered by id. Only the keyset ID of
+// page.After is used.
+func (u *UserStore) GetUsers(ctx context.Context, page domain.Page) ([]domain.User, error) {
+	query := "SELECT " + customerColumns + " FROM customer "
+
+	var args []interface{}
+	if page.After != nil {
+		query += "WHERE customer.id > ? "
+		args = append(args, page.After.ID)
+	}
+
+	query += "ORDER BY customer.id LIMIT ?"
+	args = append(args, page.Limit)
+
+	if page.After == nil {
+		query += " OFFSET ?"
+		args = append(args, page.Offset)
+	}
 
 	var users []domain.User
-	if err := SelectContext(ctx, u.db, &users, query); err != nil {
-		return users, fmt.Errorf("UserStore.GetUser(): %w", err)
+	if err := SelectContext(ctx, u.db, &users, query, args...); err != nil {
+		return users, fmt.Errorf("UserStore.GetUsers(): %w", err)
 	}
 
-	for _, user := range users {
-		if err := u.addAttributes(ctx, &user); err != nil {
-			return users, fmt.Errorf("UserStore.GetUser(): %w", err)
+	for i := range users {
+		if err := u.addAttributes(ctx, &users[i]); err != nil {
+			return users, fmt.Errorf("UserStore.GetUsers(): %w", err)
 		}
 	}
 
@@ -86,7 +131,7 @@ func (u *UserStore) GetUsers(ctx context.Context) ([]domain.User, error) {
 }
 
 func (u *UserStore) GetAddress(ctx context.Context, id string) (domain.Address, error) {
-	query := "SELECT id, street, number, country, city, postcode FROM address WHERE id=?;"
+	query := "SELECT id, street, number, country, city, postcode, version FROM address WHERE id=?;"
 
 	var addr domain.Address
 	if err := GetContext(ctx, u.db, &addr, query, id); err != nil {
@@ -97,7 +142,7 @@ func (u *UserStore) GetAddress(ctx context.Context, id string) (domain.Address,
 }
 
 func (u *UserStore) GetUserAddresses(ctx context.Context, userID string) ([]domain.Address, error) {
-	query := "SELECT a.id, a.street, a.number, a.country, a.city, a.postcode " +
+	query := "SELECT a.id, a.street, a.number, a.country, a.city, a.postcode, a.version " +
 		"FROM customer_address ca JOIN address a ON ca.address_id=a.id " +
 		"WHERE ca.customer_id=?;"
 
@@ -110,7 +155,7 @@ func (u *UserStore) GetUserAddresses(ctx context.Context, userID string) ([]doma
 }
 
 func (u *UserStore) GetCard(ctx context.Context, id string) (domain.Card, error) {
-	query := "SELECT id, long_num, expires, ccv FROM card WHERE id=?;"
+	query := "SELECT " + cardColumns + " FROM card c WHERE c.id=?;"
 
 	var card domain.Card
 	if err := GetContext(ctx, u.db, &card, query, id); err != nil {
@@ -121,7 +166,7 @@ func (u *UserStore) GetCard(ctx context.Context, id string) (domain.Card, error)
 }
 
 func (u *UserStore) GetUserCards(ctx context.Context, userID string) ([]domain.Card, error) {
-	query := "SELECT c.id, c.long_num, c.expires, c.ccv " +
+	query := "SELECT " + cardColumns + " " +
 		"FROM card c JOIN customer_card cc ON c.id=cc.card_id " +
 		"WHERE cc.customer_id=?;"
 
@@ -134,7 +179,7 @@ func (u *UserStore) GetUserCards(ctx context.Context, userID string) ([]domain.C
 }
 
 func (u *UserStore) CreateUser(ctx context.Context, user *domain.User) error {
-	query := "INSERT INTO customer(id, first_name, last_name, email, username, password, salt) VALUES (?, ?, ?, ?, ?, ?, ?)"
+	query := "INSERT INTO customer(id, first_name, last_name, email, username, password, salt) VALUES (?, ?, ?, NULLIF(?, ''), ?, ?, ?)"
 
 	user.ID = uuid.New()
 
@@ -160,6 +205,96 @@ func (u *UserStore) CreateUser(ctx context.Context, user *domain.User) error {
 	return nil
 }
 
+func (u *UserStore) UpdateUser(ctx context.Context, user *domain.User) error {
+	// The assignments run left to right, so email_verified is computed
+	// against the email before the update.
+	err := updateVersioned(ctx, u.db, "customer", "email_verified=(email_verified AND email<=>NULLIF(?, '')), first_name=?, last_name=?, email=NULLIF(?, '')",
+		[]interface{}{user.Email, user.FirstName, user.LastName, user.Email}, user.ID.String(), user.Version)
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == ErrCodeDupe {
+		return domain.DuplicateEntryError{Entity: "user", Err: err}
+	}
+
+	if err != nil {
+		return fmt.Errorf("UserStore.UpdateUser(%s): %w", user.ID, err)
+	}
+
+	user.Version++
+	return nil
+}
+
+func (u *UserStore) MarkEmailVerified(ctx context.Context, id, email string) error {
+	query := "UPDATE customer SET email_verified=TRUE WHERE id=? AND email=?"
+
+	res, err := u.db.ExecContext(ctx, query, id, email)
+	if err != nil {
+		return fmt.Errorf("UserStore.MarkEmailVerified(%s): %w", id, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("UserStore.MarkEmailVerified(%s): %w", id, err)
+	}
+
+	// An already verified email is not changed, and affects no rows.
+	if n == 0 {
+		var exists int
+		if err := GetContext(ctx, u.db, &exists, "SELECT 1 FROM customer WHERE id=? AND email=?", id, email); err != nil {
+			return fmt.Errorf("UserStore.MarkEmailVerified(%s): %w", id, err)
+		}
+	}
+
+	return nil
+}
+
+// updateVersioned sets the columns of the row of table with the given id if
+// its version is still version, and increments the version.
+func updateVersioned(ctx context.Context, db db.DB, table, set string, args []interface{}, id string, version int) error {
+	query := "UPDATE " + table + " SET " + set + ", version=version+1 WHERE id=? AND version=?"
+
+	res, err := db.ExecContext(ctx, query, append(args, id, version)...)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n > 0 {
+		return nil
+	}
+
+	var exists int
+	if err := GetContext(ctx, db, &exists, "SELECT 1 FROM "+table+" WHERE id=?", id); err != nil {
+		return err
+	}
+
+	return domain.ErrVersionMismatch
+}
+
+func (u *UserStore) UpdatePassword(ctx context.Context, id, hash string) error {
+	query := "UPDATE customer SET password=?, salt='' WHERE id=?"
+
+	if _, err := u.db.ExecContext(ctx, query, hash, id); err != nil {
+		return fmt.Errorf("UserStore.UpdatePassword(%s): %w", id, err)
+	}
+
+	return nil
+}
+
+func (u *UserStore) AddUserRole(ctx context.Context, id string, role domain.Role) error {
+	query := "INSERT IGNORE INTO customer_role(customer_id, role) VALUES (?, ?)"
+
+	if _, err := u.db.ExecContext(ctx, query, id, role); err != nil {
+		return fmt.Errorf("UserStore.AddUserRole(%s, %s): %w", id, role, err)
+	}
+
+	return nil
+}
+
 func (u *UserStore) CreateAddress(ctx context.Context, addrID string, userID string) error {
 	query := "INSERT INTO customer_address(customer_id, address_id) VALUES (?, ?)"
 
@@ -184,8 +319,207 @@ func (u *UserStore) WithTx(db db.DB) domain.UserStore {
 	return &UserStore{db: db}
 }
 
+// Delete runs several statements. Run it on a store bound to a transaction
+// (see WithTx) so that the entity is deleted atomically.
 func (u *UserStore) Delete(ctx context.Context, entity string, id string) error {
-	panic("not implemented") // TODO: Implement
+	var err error
+
+	switch entity {
+	case domain.EntityCustomer:
+		err = u.deleteCustomer(ctx, id)
+	case domain.EntityAddress:
+		err = u.deleteLinked(ctx, "customer_address", "address_id", "address", []string{id})
+	case domain.EntityCard:
+		err = u.deleteCards(ctx, []string{id})
+	default:
+		err = fmt.Errorf("%w: unknown entity %q", domain.ErrInvalid, entity)
+	}
+
+	if err != nil {
+		return fmt.Errorf("UserStore.Delete(%s, %s): %w", entity, id, err)
+	}
+
+	return nil
+}
+
+func (u *UserStore) deleteCustomer(ctx context.Context, id string) error {
+	if err := u.purgeCustomerData(ctx, id); err != nil {
+		return err
+	}
+
+	res, err := u.db.ExecContext(ctx, "DELETE FROM customer WHERE id=?", id)
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == ErrCodeRowReferenced {
+		return fmt.Errorf("%w: customer has orders", domain.ErrConflict)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return domain.ErrNotFound
+	}
+
+	return nil
+}
+
+// Anonymise blanks the names and replaces the username and email of the
+// customer with a value derived from its id, which leaves it unable to log
+// in. The street, number and postcode of the addresses its orders were
+// shipped to are erased too. Run it on a store bound to a transaction (see
+// WithTx).
+func (u *UserStore) Anonymise(ctx context.Context, id string) error {
+	if _, err := u.GetUser(ctx, id); err != nil {
+		return fmt.Errorf("UserStore.Anonymise(%s): %w", id, err)
+	}
+
+	query := "UPDATE customer SET first_name='', last_name='', " +
+		"username=?, email=?, email_verified=FALSE, password='', salt='' WHERE id=?"
+
+	name := anonymousName(id)
+	if _, err := u.db.ExecContext(ctx, query, name, name, id); err != nil {
+		return fmt.Errorf("UserStore.Anonymise(%s): %w", id, err)
+	}
+
+	if err := u.purgeCustomerData(ctx, id); err != nil {
+		return fmt.Errorf("UserStore.Anonymise(%s): %w", id, err)
+	}
+
+	query = "UPDATE customer_order SET street='', number='', postcode='' WHERE customer_id=?"
+
+	if _, err := u.db.ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("UserStore.Anonymise(%s): orders: %w", id, err)
+	}
+
+	return nil
+}
+
+// anonymousName derives the username and email of an anonymised customer from
+// its id. It fits both columns and is not a valid email address.
+func anonymousName(id string) string {
+	name := strings.ReplaceAll(id, "-", "")
+	if len(name) > 20 {
+		name = name[:20]
+	}
+	return name
+}
+
+// purgeCustomerData deletes the addresses, cards, roles, sessions, email
+// tokens, second factors and cart of the customer, and releases the stock
+// reserved by the cart.
+func (u *UserStore) purgeCustomerData(ctx context.Context, id string) error {
+	var addrs []string
+	if err := SelectContext(ctx, u.db, &addrs, "SELECT address_id FROM customer_address WHERE customer_id=?", id); err != nil {
+		return err
+	}
+
+	if err := u.deleteLinked(ctx, "customer_address", "address_id", "address", addrs); err != nil {
+		return err
+	}
+
+	var cards []string
+	if err := SelectContext(ctx, u.db, &cards, "SELECT card_id FROM customer_card WHERE customer_id=?", id); err != nil {
+		return err
+	}
+
+	if err := u.deleteCards(ctx, cards); err != nil {
+		return err
+	}
+
+	if err := NewReservationStore(u.db).Release(ctx, domain.CartReservationOwner(id)); err != nil {
+		return err
+	}
+
+	for _, table := range []string{"customer_role", "session", "email_token", "customer_totp", "recovery_code", "mfa_challenge", "customer_identity", "cart_item"} {
+		if _, err := u.db.ExecContext(ctx, "DELETE FROM "+table+" WHERE customer_id=?", id); err != nil {
+			return fmt.Errorf("delete %s: %w", table, err)
+		}
+	}
+
+	return nil
+}
+
+// deleteCards deletes the cards and the vault entries of their numbers, unless
+// another card still uses the same token. The audit records of the entries
+// are kept.
+func (u *UserStore) deleteCards(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	query, args, err := sqlx.In("SELECT token FROM card WHERE id IN (?)", ids)
+	if err != nil {
+		return err
+	}
+
+	var tokens []string
+	if err := SelectContext(ctx, u.db, &tokens, query, args...); err != nil {
+		return err
+	}
+
+	if err := u.deleteLinked(ctx, "customer_card", "card_id", "card", ids); err != nil {
+		return err
+	}
+
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	query, args, err = sqlx.In("DELETE FROM vault_entry WHERE token IN (?) AND token NOT IN (SELECT token FROM card)", tokens)
+	if err != nil {
+		return err
+	}
+
+	if _, err := u.db.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("delete vault_entry: %w", err)
+	}
+
+	return nil
+}
+
+// deleteLinked deletes the rows of table with the given ids, and the rows of
+// linkTable referencing them by linkCol.
+func (u *UserStore) deleteLinked(ctx context.Context, linkTable, linkCol, table string, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	query, args, err := sqlx.In("DELETE FROM "+linkTable+" WHERE "+linkCol+" IN (?)", ids)
+	if err != nil {
+		return err
+	}
+
+	if _, err := u.db.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("delete %s: %w", linkTable, err)
+	}
+
+	query, args, err = sqlx.In("DELETE FROM "+table+" WHERE id IN (?)", ids)
+	if err != nil {
+		return err
+	}
+
+	res, err := u.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("delete %s: %w", table, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return domain.ErrNotFound
+	}
+
+	return nil
 }
 
 func NewAddressStore(db db.DB) *AddressStore {
@@ -216,6 +550,17 @@ func (a *AddressStore) CreateAddress(ctx context.Context, addr *domain.Address)
 	return err
 }
 
+func (a *AddressStore) UpdateAddress(ctx context.Context, addr *domain.Address) error {
+	err := updateVersioned(ctx, a.db, "address", "street=?, number=?, country=?, city=?, postcode=?",
+		[]interface{}{addr.Street, addr.Number, addr.Country, addr.City, addr.PostCode}, addr.ID.String(), addr.Version)
+	if err != nil {
+		return fmt.Errorf("AddressStore.UpdateAddress(%s): %w", addr.ID, err)
+	}
+
+	addr.Version++
+	return nil
+}
+
 func NewCardStore(db db.DB) *CardStore {
 	return &CardStore{db: db}
 }
@@ -231,13 +576,14 @@ func (c *CardStore) WithTx(db db.DB) domain.CardStore {
 func (c *CardStore) CreateCard(ctx context.Context, card *domain.Card) error {
 	card.ID = uuid.New()
 
-	query := "INSERT INTO card(id, long_num, expires, ccv) VALUES (?, ?, ?, ?)"
+	query := "INSERT INTO card(id, token, last4, brand, expires) VALUES (?, ?, ?, ?, ?)"
 
 	_, err := c.db.ExecContext(ctx, query,
 		card.ID,
-		card.LongNum,
+		card.Token,
+		card.Last4,
+		card.Brand,
 		card.Expires,
-		card.CCV,
 	)
 
 	var mysqlErr *mysql.MySQLError
@@ -247,3 +593,13 @@ func (c *CardStore) CreateCard(ctx context.Context, card *domain.Card) error {
 
 	return err
 }
+
+func (c *CardStore) UpdateCard(ctx context.Context, card *domain.Card) error {
+	err := updateVersioned(ctx, c.db, "card", "expires=?", []interface{}{card.Expires}, card.ID.String(), card.Version)
+	if err != nil {
+		return fmt.Errorf("CardStore.UpdateCard(%s): %w", card.ID, err)
+	}
+
+	card.Version++
+	return nil
+}
@@ -0,0 +1,24 @@
+package mysql
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAnonymousName(t *testing.T) {
+	ids := []string{"6fc5e4b2-3a8e-4c1f-9d2b-5e7a1c0f8b3d", "0a1b2c3d-4e5f-4a6b-8c7d-9e0f1a2b3c4d", "short-id"}
+	want := []string{"6fc5e4b23a8e4c1f9d2b", "0a1b2c3d4e5f4a6b8c7d", "shortid"}
+
+	for i, id := range ids {
+		got := anonymousName(id)
+		if got != want[i] {
+			t.Fatalf("anonymousName(%q) = %q, want %q", id, got, want[i])
+		}
+
+		// The name replaces the username and email, which are at most 20
+		// and 40 characters, and must not be a deliverable address.
+		if len(got) > 20 || strings.Contains(got, "@") {
+			t.Fatalf("anonymousName(%q) = %q, want at most 20 characters and no @", id, got)
+		}
+	}
+}
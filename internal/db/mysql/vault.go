@@ -0,0 +1,117 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/go-sql-driver/mysql"
+
+	"github.com/oshankkumar/sockshop/internal/db"
+	"github.com/oshankkumar/sockshop/internal/domain"
+	"github.com/oshankkumar/sockshop/internal/envelope"
+	"github.com/oshankkumar/sockshop/internal/vault"
+)
+
+type vaultEntry struct {
+	Token       string         `db:"token"`
+	PAN         []byte         `db:"pan"`
+	DataKey     []byte         `db:"data_key"`
+	KeyID       string         `db:"key_id"`
+	Fingerprint string         `db:"fingerprint"`
+	CreatedAt   mysql.NullTime `db:"created_at"`
+}
+
+func (v vaultEntry) toVault() vault.Entry {
+	return vault.Entry{
+		Token:       v.Token,
+		PAN:         envelope.Sealed{KeyID: v.KeyID, DataKey: v.DataKey, Ciphertext: v.PAN},
+		Fingerprint: v.Fingerprint,
+		CreatedAt:   v.CreatedAt.Time,
+	}
+}
+
+const vaultEntryColumns = "token, pan, data_key, key_id, fingerprint, created_at"
+
+func NewVaultStore(db db.DB) *VaultStore {
+	return &VaultStore{db: db}
+}
+
+type VaultStore struct {
+	db db.DB
+}
+
+func (v *VaultStore) CreateEntry(ctx context.Context, e vault.Entry) error {
+	query := "INSERT INTO vault_entry(" + vaultEntryColumns + ") VALUES (?, ?, ?, ?, ?, ?)"
+
+	_, err := v.db.ExecContext(ctx, query, e.Token, e.PAN.Ciphertext, e.PAN.DataKey, e.PAN.KeyID, e.Fingerprint, e.CreatedAt)
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == ErrCodeDupe {
+		return domain.DuplicateEntryError{Entity: "vault entry", Err: err}
+	}
+
+	if err != nil {
+		return fmt.Errorf("VaultStore.CreateEntry: %w", err)
+	}
+
+	return nil
+}
+
+func (v *VaultStore) GetEntry(ctx context.Context, token string) (vault.Entry, error) {
+	query := "SELECT " + vaultEntryColumns + " FROM vault_entry WHERE token=?;"
+
+	var result vaultEntry
+	if err := GetContext(ctx, v.db, &result, query, token); err != nil {
+		return vault.Entry{}, fmt.Errorf("VaultStore.GetEntry(%s): %w", token, err)
+	}
+
+	return result.toVault(), nil
+}
+
+func (v *VaultStore) GetEntryByFingerprint(ctx context.Context, fingerprint string) (vault.Entry, error) {
+	query := "SELECT " + vaultEntryColumns + " FROM vault_entry WHERE fingerprint=?;"
+
+	var result vaultEntry
+	if err := GetContext(ctx, v.db, &result, query, fingerprint); err != nil {
+		return vault.Entry{}, fmt.Errorf("VaultStore.GetEntryByFingerprint: %w", err)
+	}
+
+	return result.toVault(), nil
+}
+
+func (v *VaultStore) GetEntriesNotUnderKey(ctx context.Context, keyID string, limit int) ([]vault.Entry, error) {
+	query := "SELECT " + vaultEntryColumns + " FROM vault_entry WHERE key_id<>? ORDER BY token LIMIT ?;"
+
+	var results []vaultEntry
+	if err := SelectContext(ctx, v.db, &results, query, keyID, limit); err != nil {
+		return nil, fmt.Errorf("VaultStore.GetEntriesNotUnderKey(%s): %w", keyID, err)
+	}
+
+	entries := make([]vault.Entry, 0, len(results))
+	for _, r := range results {
+		entries = append(entries, r.toVault())
+	}
+
+	return entries, nil
+}
+
+func (v *VaultStore) UpdateEntryKey(ctx context.Context, e vault.Entry) error {
+	query := "UPDATE vault_entry SET data_key=?, key_id=? WHERE token=?;"
+
+	if _, err := v.db.ExecContext(ctx, query, e.PAN.DataKey, e.PAN.KeyID, e.Token); err != nil {
+		return fmt.Errorf("VaultStore.UpdateEntryKey(%s): %w", e.Token, err)
+	}
+
+	return nil
+}
+
+func (v *VaultStore) AppendAudit(ctx context.Context, r vault.AuditRecord) error {
+	query := "INSERT INTO vault_audit(token, caller, allowed, created_at) VALUES (?, ?, ?, ?)"
+
+	if _, err := v.db.ExecContext(ctx, query, r.Token, r.Caller, r.Allowed, r.Time); err != nil {
+		return fmt.Errorf("VaultStore.AppendAudit(%s): %w", r.Token, err)
+	}
+
+	return nil
+}
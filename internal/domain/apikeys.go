@@ -0,0 +1,42 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/oshankkumar/sockshop/internal/db"
+)
+
+// APIKey lets a service call the API without a customer login. Only the hash
+// of the key is stored, along with a prefix identifying it to humans.
+type APIKey struct {
+	ID        uuid.UUID
+	Name      string
+	Prefix    string
+	Hash      string
+	Scopes    []string
+	CreatedBy string
+	CreatedAt time.Time
+	ExpiresAt *time.Time
+	RevokedAt *time.Time
+}
+
+// APIKeyScopes are the permissions API keys may be granted. Managing API keys
+// is left to admins, so that a leaked key cannot mint others.
+var APIKeyScopes = []string{PermCatalogueWrite, PermCustomersRead, PermOrdersManage}
+
+type APIKeyStore interface {
+	CreateAPIKey(ctx context.Context, k *APIKey) error
+	GetAPIKey(ctx context.Context, id string) (APIKey, error)
+	GetAPIKeyByHash(ctx context.Context, hash string) (APIKey, error)
+	ListAPIKeys(ctx context.Context) ([]APIKey, error)
+	// ReplaceAPIKeyScopes fails with ErrNotFound unless the key exists and
+	// is not revoked.
+	ReplaceAPIKeyScopes(ctx context.Context, id string, scopes []string) error
+	// RevokeAPIKey fails with ErrNotFound unless the key exists and is not
+	// revoked yet.
+	RevokeAPIKey(ctx context.Context, id string) error
+	WithTx(db db.DB) APIKeyStore
+}
@@ -0,0 +1,73 @@
+package domain
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+
+	"github.com/oshankkumar/sockshop/internal/db"
+)
+
+// SessionCartPrefix starts the IDs of the carts of anonymous sessions, which
+// keeps them apart from customer IDs.
+const SessionCartPrefix = "session-"
+
+// NewSessionCartID returns a random cart ID for an anonymous session.
+func NewSessionCartID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return SessionCartPrefix + hex.EncodeToString(b), nil
+}
+
+// IsSessionCartID reports whether id is a cart ID NewSessionCartID could
+// have returned.
+func IsSessionCartID(id string) bool {
+	rest, ok := strings.CutPrefix(id, SessionCartPrefix)
+	if !ok || len(rest) != 32 {
+		return false
+	}
+
+	for _, r := range rest {
+		if (r < '0' || r > '9') && (r < 'a' || r > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// CartReservationOwner is the owner of the reservations holding the stock of
+// the items of a cart. Those of an order are owned by its ID.
+func CartReservationOwner(customerID string) string {
+	return "cart:" + customerID
+}
+
+// CartItem is a line of a cart. UnitPrice is the sock price at the time the
+// item was first added to the cart.
+type CartItem struct {
+	CustomerID string  `db:"customer_id"`
+	SockID     string  `db:"sock_id"`
+	Quantity   int     `db:"quantity"`
+	UnitPrice  float64 `db:"unit_price"`
+}
+
+// Cart belongs to a customer or, before login, to an anonymous session. In
+// both cases CustomerID identifies it, a session cart ID for the latter.
+type Cart struct {
+	CustomerID string
+	Items      []CartItem
+}
+
+type CartStore interface {
+	WithTx(db db.DB) CartStore
+	GetCart(ctx context.Context, customerID string) (Cart, error)
+	GetItem(ctx context.Context, customerID, sockID string) (CartItem, error)
+	// AddItem adds the item to the cart. If the cart already holds the sock
+	// the quantities are summed and the existing unit price is kept.
+	AddItem(ctx context.Context, item CartItem) error
+	UpdateItem(ctx context.Context, item CartItem) error
+	DeleteItem(ctx context.Context, customerID, sockID string) error
+	DeleteCart(ctx context.Context, customerID string) error
+}
@@ -0,0 +1,31 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// Purposes of the tokens mailed to customers.
+const (
+	PurposePasswordReset = "password_reset"
+	PurposeVerifyEmail   = "verify_email"
+)
+
+// EmailToken is a single use token mailed to a customer. Only the hash of the
+// token is stored. Email is the address the token was sent to.
+type EmailToken struct {
+	Hash       string
+	CustomerID string
+	Purpose    string
+	Email      string
+	ExpiresAt  time.Time
+	CreatedAt  time.Time
+}
+
+type EmailTokenStore interface {
+	CreateEmailToken(ctx context.Context, t *EmailToken) error
+	// ConsumeEmailToken marks the token with the hash and purpose used and
+	// returns it. It fails with ErrNotFound if the token does not exist, has
+	// expired at now or was already used.
+	ConsumeEmailToken(ctx context.Context, hash, purpose string, now time.Time) (EmailToken, error)
+}
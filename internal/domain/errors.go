@@ -1,8 +1,19 @@
 package domain
 
-import "errors"
+import (
+	"errors"
+	"strings"
+)
 
-var ErrNotFound = errors.New("not found")
+var (
+	ErrNotFound          = errors.New("not found")
+	ErrInvalid           = errors.New("invalid")
+	ErrInsufficientStock = errors.New("insufficient stock")
+	ErrPaymentDeclined   = errors.New("payment declined")
+	ErrRefundDeclined    = errors.New("refund declined")
+	ErrConflict          = errors.New("conflict")
+	ErrVersionMismatch   = errors.New("version mismatch")
+)
 
 type DuplicateEntryError struct {
 	Entity string
@@ -12,3 +23,24 @@ type DuplicateEntryError struct {
 func (d DuplicateEntryError) Error() string { return d.Entity + ":" + d.Err.Error() }
 
 func (d DuplicateEntryError) Unwrap() error { return d.Err }
+
+// FieldError is the reason a field of a request is invalid.
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+// ValidationError lists the invalid fields of a request. It matches ErrInvalid.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (v ValidationError) Error() string {
+	msgs := make([]string, 0, len(v.Fields))
+	for _, f := range v.Fields {
+		msgs = append(msgs, f.Field+": "+f.Message)
+	}
+	return ErrInvalid.Error() + ": " + strings.Join(msgs, ", ")
+}
+
+func (v ValidationError) Unwrap() error { return ErrInvalid }
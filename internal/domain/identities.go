@@ -0,0 +1,36 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"github.com/oshankkumar/sockshop/internal/db"
+)
+
+// Identity links the subject of an external identity provider to a customer.
+type Identity struct {
+	Provider   string
+	Subject    string
+	CustomerID string
+	CreatedAt  time.Time
+}
+
+// OIDCLogin is a login waiting for the identity provider to redirect the
+// customer back. Only the hash of its state is stored.
+type OIDCLogin struct {
+	StateHash string
+	Provider  string
+	Nonce     string
+	Verifier  string
+	ExpiresAt time.Time
+}
+
+type IdentityStore interface {
+	GetIdentity(ctx context.Context, provider, subject string) (Identity, error)
+	CreateIdentity(ctx context.Context, i *Identity) error
+	CreateOIDCLogin(ctx context.Context, l *OIDCLogin) error
+	// ConsumeOIDCLogin deletes the login with the state hash and returns it,
+	// so that a state is used once. It fails with ErrNotFound otherwise.
+	ConsumeOIDCLogin(ctx context.Context, stateHash string) (OIDCLogin, error)
+	WithTx(db db.DB) IdentityStore
+}
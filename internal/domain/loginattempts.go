@@ -0,0 +1,24 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// LoginAttempts are the recent failed logins of a key, a username or a client
+// IP.
+type LoginAttempts struct {
+	Key         string
+	Failures    int
+	LastFailure time.Time
+}
+
+type LoginAttemptStore interface {
+	// GetLoginAttempts returns the failed logins of the key, with no failures
+	// when none were recorded.
+	GetLoginAttempts(ctx context.Context, key string) (LoginAttempts, error)
+	// RecordLoginFailure counts a failed login of the key at now and returns
+	// the updated attempts. Failures older than window are forgotten first.
+	RecordLoginFailure(ctx context.Context, key string, now time.Time, window time.Duration) (LoginAttempts, error)
+	ResetLoginAttempts(ctx context.Context, key string) error
+}
@@ -0,0 +1,50 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"github.com/oshankkumar/sockshop/internal/db"
+	"github.com/oshankkumar/sockshop/internal/envelope"
+)
+
+// TOTP is the TOTP authenticator a customer enrolled. It protects logins once
+// confirmed with a first code. LastCounter is the time step of the last code
+// used, codes of earlier steps are rejected.
+type TOTP struct {
+	CustomerID  string
+	Secret      envelope.Sealed
+	Confirmed   bool
+	LastCounter int64
+	CreatedAt   time.Time
+}
+
+// MFAChallenge is a login waiting for its second factor. Only the hash of its
+// token is stored.
+type MFAChallenge struct {
+	Hash       string
+	CustomerID string
+	ExpiresAt  time.Time
+}
+
+type MFAStore interface {
+	GetTOTP(ctx context.Context, customerID string) (TOTP, error)
+	// SaveTOTP creates or replaces the TOTP of the customer.
+	SaveTOTP(ctx context.Context, t *TOTP) error
+	// ConfirmTOTP confirms the TOTP, failing with ErrNotFound unless the
+	// customer has an unconfirmed one.
+	ConfirmTOTP(ctx context.Context, customerID string, counter int64) error
+	// UseTOTPCounter records the counter of a used code, failing with
+	// ErrConflict unless it is after LastCounter.
+	UseTOTPCounter(ctx context.Context, customerID string, counter int64) error
+	// ReplaceRecoveryCodes replaces the recovery codes of the customer with
+	// the given hashes.
+	ReplaceRecoveryCodes(ctx context.Context, customerID string, hashes []string) error
+	// UseRecoveryCode marks the recovery code with the hash used, failing
+	// with ErrNotFound unless the customer has it unused.
+	UseRecoveryCode(ctx context.Context, customerID, hash string) error
+	CreateMFAChallenge(ctx context.Context, c *MFAChallenge) error
+	GetMFAChallenge(ctx context.Context, hash string) (MFAChallenge, error)
+	DeleteMFAChallenge(ctx context.Context, hash string) error
+	WithTx(db db.DB) MFAStore
+}
@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"github.com/oshankkumar/sockshop/internal/db"
+
+	"github.com/google/uuid"
+)
+
+type OrderStatus string
+
+const (
+	OrderStatusCreated         OrderStatus = "created"
+	OrderStatusPaid            OrderStatus = "paid"
+	OrderStatusPaymentDeclined OrderStatus = "payment_declined"
+	OrderStatusShipped         OrderStatus = "shipped"
+	OrderStatusDelivered       OrderStatus = "delivered"
+	OrderStatusCancelled       OrderStatus = "cancelled"
+)
+
+// Unpaid reports whether an order in the status still awaits its payment.
+func (s OrderStatus) Unpaid() bool {
+	return s == OrderStatusCreated || s == OrderStatusPaymentDeclined
+}
+
+type OrderItem struct {
+	SockID    string  `db:"sock_id"`
+	Quantity  int     `db:"quantity"`
+	UnitPrice float64 `db:"unit_price"`
+}
+
+type OrderStatusChange struct {
+	Status    OrderStatus
+	CreatedAt time.Time
+}
+
+// Order is a placed order. The shipping address is copied into the order so
+// that it stays intact when the customer later edits or deletes the address.
+type Order struct {
+	ID         uuid.UUID
+	CustomerID string
+	CardID     string
+	Address    Address
+	Items      []OrderItem
+	Subtotal   float64
+	Shipping   float64
+	Total      float64
+	Status     OrderStatus
+	History    []OrderStatusChange
+	CreatedAt  time.Time
+}
+
+type OrderStore interface {
+	WithTx(db db.DB) OrderStore
+	// CreateOrder persists the order with its items and initial status.
+	CreateOrder(ctx context.Context, order *Order) error
+	GetOrder(ctx context.Context, id string) (Order, error)
+	// GetOrders returns a page of orders ordered by id. Only the keyset ID of
+	// page.After is used.
+	GetOrders(ctx context.Context, page Page) ([]Order, error)
+	GetCustomerOrders(ctx context.Context, customerID string) ([]Order, error)
+	// UpdateStatus sets the order status and records it in the history.
+	UpdateStatus(ctx context.Context, id string, status OrderStatus) error
+}
@@ -0,0 +1,17 @@
+package domain
+
+// Page selects a window of a sorted collection. When After is set the window
+// starts right after the item it identifies (keyset pagination) and Offset is
+// ignored.
+type Page struct {
+	Limit  int
+	Offset int
+	After  *Key
+}
+
+// Key is the position of an item in a sort order: the value of the sort
+// field and the item id, which breaks ties between equal values.
+type Key struct {
+	Value any
+	ID    string
+}
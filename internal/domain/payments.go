@@ -0,0 +1,45 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"github.com/oshankkumar/sockshop/internal/db"
+
+	"github.com/google/uuid"
+)
+
+type PaymentStatus string
+
+const (
+	PaymentStatusPending    PaymentStatus = "pending"
+	PaymentStatusAuthorized PaymentStatus = "authorized"
+	PaymentStatusCaptured   PaymentStatus = "captured"
+	PaymentStatusDeclined   PaymentStatus = "declined"
+	PaymentStatusVoided     PaymentStatus = "voided"
+	PaymentStatusRefunded   PaymentStatus = "refunded"
+	PaymentStatusFailed     PaymentStatus = "failed"
+)
+
+// Payment is one attempt at charging an order. The IdempotencyKey is sent to
+// the payment gateway so that replaying an attempt never charges twice.
+type Payment struct {
+	ID              uuid.UUID
+	OrderID         string
+	Attempt         int
+	IdempotencyKey  string
+	Amount          float64
+	Status          PaymentStatus
+	AuthorizationID string
+	DeclineReason   string
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+type PaymentStore interface {
+	WithTx(db db.DB) PaymentStore
+	CreatePayment(ctx context.Context, p *Payment) error
+	UpdatePayment(ctx context.Context, p *Payment) error
+	// GetOrderPayments returns the payment attempts of the order, oldest first.
+	GetOrderPayments(ctx context.Context, orderID string) ([]Payment, error)
+}
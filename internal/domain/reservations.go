@@ -0,0 +1,51 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"github.com/oshankkumar/sockshop/internal/db"
+
+	"github.com/google/uuid"
+)
+
+type ReservationStatus string
+
+const (
+	ReservationStatusActive    ReservationStatus = "active"
+	ReservationStatusCommitted ReservationStatus = "committed"
+	ReservationStatusReleased  ReservationStatus = "released"
+	ReservationStatusExpired   ReservationStatus = "expired"
+)
+
+// Reservation holds Quantity units of a sock for its Owner, a cart or an
+// order, until it expires. Active reservations are not available for sale;
+// committing a reservation takes its units out of stock for good.
+type Reservation struct {
+	ID        uuid.UUID
+	SockID    string
+	Owner     string
+	Quantity  int
+	Status    ReservationStatus
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+type ReservationStore interface {
+	WithTx(db db.DB) ReservationStore
+	// Reserve persists an active reservation. It fails with
+	// ErrInsufficientStock when fewer units than r.Quantity are available.
+	Reserve(ctx context.Context, r *Reservation) error
+	// GetOwnerReservations returns the active reservations of the owner.
+	GetOwnerReservations(ctx context.Context, owner string) ([]Reservation, error)
+	// Commit takes the units of the owner's active reservations which have
+	// not expired out of stock, marks them committed and returns the units
+	// committed per sock ID. It fails with ErrInsufficientStock, committing
+	// nothing, when a sock has fewer units on hand than reserved.
+	Commit(ctx context.Context, owner string) (map[string]int, error)
+	// Release gives the units of the owner's active reservations back.
+	Release(ctx context.Context, owner string) error
+	// Expire marks the active reservations which expired before t and returns
+	// how many there were.
+	Expire(ctx context.Context, t time.Time) (int, error)
+}
@@ -0,0 +1,36 @@
+package domain
+
+// Role is granted to users on top of the customer abilities every user has.
+type Role string
+
+const RoleAdmin Role = "admin"
+
+// Permissions guarding the admin operations of the API.
+const (
+	PermCatalogueWrite = "catalogue:write"
+	PermCustomersRead  = "customers:read"
+	PermOrdersManage   = "orders:manage"
+	PermAPIKeysManage  = "apikeys:manage"
+)
+
+// RolePermissions lists the permissions each role grants.
+var RolePermissions = map[Role][]string{
+	RoleAdmin: {PermCatalogueWrite, PermCustomersRead, PermOrdersManage, PermAPIKeysManage},
+}
+
+// Permissions returns the permissions granted by the roles, without
+// duplicates.
+func Permissions(roles []Role) []string {
+	seen := make(map[string]bool)
+
+	var perms []string
+	for _, r := range roles {
+		for _, p := range RolePermissions[r] {
+			if !seen[p] {
+				seen[p] = true
+				perms = append(perms, p)
+			}
+		}
+	}
+	return perms
+}
@@ -0,0 +1,33 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Session is a login of a customer. Access tokens name the session they were
+// issued for, and the session holds the hash of its current refresh token;
+// revoking the session invalidates both.
+type Session struct {
+	ID          uuid.UUID
+	CustomerID  string
+	RefreshHash string
+	ExpiresAt   time.Time
+	CreatedAt   time.Time
+	RevokedAt   *time.Time
+}
+
+type SessionStore interface {
+	CreateSession(ctx context.Context, s *Session) error
+	GetSession(ctx context.Context, id string) (Session, error)
+	GetSessionByRefreshHash(ctx context.Context, hash string) (Session, error)
+	// RotateRefresh replaces the refresh token hash of the session, failing
+	// with ErrNotFound unless the session still holds oldHash.
+	RotateRefresh(ctx context.Context, id, oldHash, newHash string, expiresAt time.Time) error
+	RevokeSession(ctx context.Context, id string) error
+	// RevokeCustomerSessions revokes all the sessions of the customer but
+	// exceptID, all of them when it is empty.
+	RevokeCustomerSessions(ctx context.Context, customerID, exceptID string) error
+}
@@ -3,6 +3,8 @@ package domain
 import (
 	"context"
 
+	"github.com/oshankkumar/sockshop/internal/db"
+
 	"github.com/google/uuid"
 )
 
@@ -18,22 +20,122 @@ type Sock struct {
 	ImageURLs   string
 	Price       float64
 	Count       int
-	Tags        []Tag
+	// Reserved is the number of units held by active reservations. They are
+	// part of Count but cannot be sold.
+	Reserved int
+	Tags     []Tag
+}
+
+// Available returns the number of units which can still be sold.
+func (s Sock) Available() int {
+	if s.Reserved > s.Count {
+		return 0
+	}
+	return s.Count - s.Reserved
+}
+
+// SockSortField is a sock attribute the catalogue can be sorted by.
+type SockSortField string
+
+const (
+	SortByID    SockSortField = "id"
+	SortByName  SockSortField = "name"
+	SortByPrice SockSortField = "price"
+	SortByCount SockSortField = "count"
+)
+
+// SockSortFields is the whitelist of fields socks can be sorted by.
+var SockSortFields = []SockSortField{SortByID, SortByName, SortByPrice, SortByCount}
+
+type SockOrder struct {
+	Field SockSortField
+	Desc  bool
+}
+
+// TagMatchMode tells whether a sock must carry all or any of the filtered tags.
+type TagMatchMode string
+
+const (
+	TagMatchAny TagMatchMode = "any"
+	TagMatchAll TagMatchMode = "all"
+)
+
+// SockFilter selects socks. Zero valued fields do not filter.
+type SockFilter struct {
+	Tags     []string
+	TagMode  TagMatchMode
+	MinPrice *float64
+	MaxPrice *float64
+	// InStock selects the socks with available units.
+	InStock bool
+}
+
+// SockQuery selects a sorted page of the socks matching its filter.
+type SockQuery struct {
+	SockFilter
+	Order SockOrder
+	Page  Page
+}
+
+// PriceBucketBounds are the lower bounds of the price facet buckets, each
+// bucket ending where the next begins. The last bucket is unbounded.
+var PriceBucketBounds = []float64{0, 10, 15, 20, 50}
+
+type TagFacet struct {
+	Name  string
+	Count int
+}
+
+// PriceFacet counts the socks priced in [Min, Max). A nil Max is unbounded.
+type PriceFacet struct {
+	Min   float64
+	Max   *float64
+	Count int
+}
+
+type SockFacets struct {
+	Tags   []TagFacet
+	Prices []PriceFacet
 }
 
 type SockStore interface {
+	WithTx(db db.DB) SockStore
 	SockStoreReader
 	SockStoreWriter
 }
 
 type SockStoreReader interface {
-	List(ctx context.Context, tags []string, order string, limit, offset int) ([]Sock, error)
-	Count(ctx context.Context, tags []string) (int, error)
+	List(ctx context.Context, q SockQuery) ([]Sock, error)
+	Count(ctx context.Context, f SockFilter) (int, error)
+	Facets(ctx context.Context, f SockFilter) (SockFacets, error)
 	Get(ctx context.Context, id string) (Sock, error)
 	Tags(ctx context.Context) ([]string, error)
 }
 
 type SockStoreWriter interface {
-	Create(ctx context.Context, sock Sock) error
+	// GetForUpdate returns the sock like Get and locks it until the end of
+	// the transaction of the store, so that concurrent read-modify-write
+	// updates of the sock are serialised.
+	GetForUpdate(ctx context.Context, id string) (Sock, error)
+	Create(ctx context.Context, sock *Sock) error
 	Update(ctx context.Context, sock Sock) error
 }
+
+// SockHit is a sock matching a search query and its relevance score. Scores
+// are only comparable between hits of the same search.
+type SockHit struct {
+	Sock  Sock
+	Score float64
+}
+
+// SockSearcher ranks socks by the relevance of their name and description to
+// a free text query, most relevant first.
+type SockSearcher interface {
+	Search(ctx context.Context, query string, limit int) ([]SockHit, error)
+}
+
+// SockIndexer is implemented by searchers which maintain their own index and
+// must be told about every sock written to the SockStore.
+type SockIndexer interface {
+	Index(ctx context.Context, sock Sock) error
+}
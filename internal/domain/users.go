@@ -18,6 +18,10 @@ type User struct {
 	Salt       string    `db:"salt"`
 	AddressIDs []string  `db:"-"`
 	CardIDs    []string  `db:"-"`
+	Roles      []Role    `db:"-"`
+	Version    int       `db:"version"`
+	// EmailVerified is set once the customer proved it owns Email.
+	EmailVerified bool `db:"email_verified"`
 }
 
 type Address struct {
@@ -27,19 +31,32 @@ type Address struct {
 	Country  string    `db:"country"`
 	City     string    `db:"city"`
 	PostCode string    `db:"postcode"`
+	Version  int       `db:"version"`
 }
 
+// Card is a payment card. The card number is kept in the vault and the card
+// only holds its token. The CCV is never stored.
 type Card struct {
 	ID      uuid.UUID `db:"id"`
-	LongNum string    `db:"long_num"`
+	Token   string    `db:"token"`
+	Last4   string    `db:"last4"`
+	Brand   string    `db:"brand"`
 	Expires string    `db:"expires"`
-	CCV     string    `db:"ccv"`
+	Version int       `db:"version"`
 }
 
+// Entities UserStoreWriter.Delete can delete.
+const (
+	EntityCustomer = "customer"
+	EntityAddress  = "address"
+	EntityCard     = "card"
+)
+
 type UserStoreReader interface {
 	GetUserByName(ctx context.Context, uname string) (User, error)
+	GetUserByEmail(ctx context.Context, email string) (User, error)
 	GetUser(ctx context.Context, id string) (User, error)
-	GetUsers(ctx context.Context) ([]User, error)
+	GetUsers(ctx context.Context, page Page) ([]User, error)
 	GetAddress(ctx context.Context, id string) (Address, error)
 	GetUserAddresses(ctx context.Context, userID string) ([]Address, error)
 	GetCard(ctx context.Context, id string) (Card, error)
@@ -48,18 +65,43 @@ type UserStoreReader interface {
 
 type UserStoreWriter interface {
 	CreateUser(ctx context.Context, user *User) error
+	// UpdateUser updates the names and email of the user if its stored
+	// version is still user.Version, which is then incremented. It fails with
+	// ErrVersionMismatch otherwise. Changing the email clears EmailVerified.
+	UpdateUser(ctx context.Context, user *User) error
+	// MarkEmailVerified sets EmailVerified, failing with ErrNotFound unless
+	// the user still has the email.
+	MarkEmailVerified(ctx context.Context, id, email string) error
+	// UpdatePassword replaces the user's password hash. The hash embeds its
+	// own salt, so the legacy salt column is cleared.
+	UpdatePassword(ctx context.Context, id, hash string) error
+	// AddUserRole grants the role to the user. Granting a role twice is a
+	// no-op.
+	AddUserRole(ctx context.Context, id string, role Role) error
 	CreateAddress(ctx context.Context, addrID string, userID string) error
+	// Delete deletes the customer, address or card with the rows linking it to
+	// other entities. Deleting a customer deletes all of its data and fails
+	// with ErrConflict while it has orders.
 	Delete(ctx context.Context, entity, id string) error
+	// Anonymise erases the personal data of the customer, keeping the
+	// customer row its orders reference.
+	Anonymise(ctx context.Context, id string) error
 	CreateCard(ctx context.Context, cardID string, id string) error
 }
 
 type CardStore interface {
 	CreateCard(ctx context.Context, card *Card) error
+	// UpdateCard updates the expiry of the card, under the same versioning
+	// rules as UserStoreWriter.UpdateUser.
+	UpdateCard(ctx context.Context, card *Card) error
 	WithTx(db db.DB) CardStore
 }
 
 type AddressStore interface {
 	CreateAddress(ctx context.Context, addr *Address) error
+	// UpdateAddress overwrites the address, under the same versioning rules as
+	// UserStoreWriter.UpdateUser.
+	UpdateAddress(ctx context.Context, addr *Address) error
 	WithTx(db db.DB) AddressStore
 }
 
@@ -0,0 +1,202 @@
+// Package envelope implements envelope encryption. Every value is encrypted
+// with its own random data key, and the data key is encrypted (wrapped) with
+// a key encryption key of a KeyProvider. Rotating the key encryption key only
+// rewraps the data keys: the values themselves are never re-encrypted.
+//
+// Both layers use AES-256-GCM with a random nonce prepended to the ciphertext.
+package envelope
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrUnknownKey is returned when a value was sealed with a key the
+	// KeyProvider does not hold.
+	ErrUnknownKey = errors.New("unknown key encryption key")
+	// ErrDecrypt is returned when a ciphertext or wrapped data key does not
+	// decrypt, because it was tampered with or the key is wrong.
+	ErrDecrypt = errors.New("envelope decryption failed")
+)
+
+const keySize = 32
+
+// Key is a key encryption key.
+type Key struct {
+	ID     string
+	Secret []byte
+}
+
+// KeyProvider holds the key encryption keys.
+type KeyProvider interface {
+	// CurrentKey returns the key new data keys are wrapped with.
+	CurrentKey(ctx context.Context) (Key, error)
+	// Key returns the key with the id, which may no longer be current.
+	Key(ctx context.Context, id string) (Key, error)
+}
+
+// Sealed is an encrypted value together with its wrapped data key.
+type Sealed struct {
+	// KeyID is the id of the key encryption key DataKey is wrapped with.
+	KeyID      string
+	DataKey    []byte
+	Ciphertext []byte
+}
+
+type Sealer struct {
+	keys           KeyProvider
+	fingerprintKey []byte
+}
+
+// NewSealer returns a Sealer wrapping data keys with the keys of the provider.
+// fingerprintKey keys the HMAC of Fingerprint.
+func NewSealer(keys KeyProvider, fingerprintKey []byte) *Sealer {
+	return &Sealer{keys: keys, fingerprintKey: fingerprintKey}
+}
+
+// Seal encrypts plaintext with a new data key wrapped with the current key.
+func (s *Sealer) Seal(ctx context.Context, plaintext []byte) (Sealed, error) {
+	kek, err := s.keys.CurrentKey(ctx)
+	if err != nil {
+		return Sealed{}, fmt.Errorf("envelope.Seal: %w", err)
+	}
+
+	dek := make([]byte, keySize)
+	if _, err := rand.Read(dek); err != nil {
+		return Sealed{}, fmt.Errorf("envelope.Seal: generate data key: %w", err)
+	}
+
+	ciphertext, err := encrypt(dek, plaintext, nil)
+	if err != nil {
+		return Sealed{}, fmt.Errorf("envelope.Seal: %w", err)
+	}
+
+	wrapped, err := encrypt(kek.Secret, dek, []byte(kek.ID))
+	if err != nil {
+		return Sealed{}, fmt.Errorf("envelope.Seal: wrap data key: %w", err)
+	}
+
+	return Sealed{KeyID: kek.ID, DataKey: wrapped, Ciphertext: ciphertext}, nil
+}
+
+// Open decrypts a sealed value.
+func (s *Sealer) Open(ctx context.Context, sealed Sealed) ([]byte, error) {
+	dek, err := s.unwrap(ctx, sealed)
+	if err != nil {
+		return nil, fmt.Errorf("envelope.Open: %w", err)
+	}
+
+	plaintext, err := decrypt(dek, sealed.Ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("envelope.Open: %w", err)
+	}
+
+	return plaintext, nil
+}
+
+// Rewrap wraps the data key of a sealed value with the current key. It
+// reports whether the value was wrapped with an older key and has changed.
+func (s *Sealer) Rewrap(ctx context.Context, sealed Sealed) (Sealed, bool, error) {
+	kek, err := s.keys.CurrentKey(ctx)
+	if err != nil {
+		return sealed, false, fmt.Errorf("envelope.Rewrap: %w", err)
+	}
+
+	if sealed.KeyID == kek.ID {
+		return sealed, false, nil
+	}
+
+	dek, err := s.unwrap(ctx, sealed)
+	if err != nil {
+		return sealed, false, fmt.Errorf("envelope.Rewrap: %w", err)
+	}
+
+	wrapped, err := encrypt(kek.Secret, dek, []byte(kek.ID))
+	if err != nil {
+		return sealed, false, fmt.Errorf("envelope.Rewrap: wrap data key: %w", err)
+	}
+
+	return Sealed{KeyID: kek.ID, DataKey: wrapped, Ciphertext: sealed.Ciphertext}, true, nil
+}
+
+// CurrentKeyID returns the id of the key new data keys are wrapped with.
+func (s *Sealer) CurrentKeyID(ctx context.Context) (string, error) {
+	kek, err := s.keys.CurrentKey(ctx)
+	if err != nil {
+		return "", fmt.Errorf("envelope.CurrentKeyID: %w", err)
+	}
+	return kek.ID, nil
+}
+
+// Fingerprint returns the hex encoded HMAC-SHA256 of plaintext. Sealing is
+// randomised, so fingerprints are what equal values can be looked up or kept
+// unique by.
+func (s *Sealer) Fingerprint(plaintext []byte) string {
+	mac := hmac.New(sha256.New, s.fingerprintKey)
+	mac.Write(plaintext)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *Sealer) unwrap(ctx context.Context, sealed Sealed) ([]byte, error) {
+	kek, err := s.keys.Key(ctx, sealed.KeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	dek, err := decrypt(kek.Secret, sealed.DataKey, []byte(kek.ID))
+	if err != nil {
+		return nil, fmt.Errorf("unwrap data key: %w", err)
+	}
+
+	return dek, nil
+}
+
+func encrypt(key, plaintext, additionalData []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("generate nonce: %w", err)
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func decrypt(key, ciphertext, additionalData []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+
+	nonce, ciphertext := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+
+	plaintext, err := aead.Open(nil, nonce, ciphertext, additionalData)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+
+	return plaintext, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
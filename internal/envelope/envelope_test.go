@@ -0,0 +1,122 @@
+package envelope
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+func testKeyring(current string, ids ...string) *Keyring {
+	k := &Keyring{Current: current, Keys: make(map[string][]byte), FingerprintKey: bytes.Repeat([]byte{0xf0}, keySize)}
+	for i, id := range ids {
+		k.Keys[id] = bytes.Repeat([]byte{byte(i + 1)}, keySize)
+	}
+	return k
+}
+
+func TestSealerRewrap(t *testing.T) {
+	ctx := context.Background()
+	plaintext := []byte("4111111111111111")
+
+	old := NewSealer(testKeyring("2024-01", "2024-01"), nil)
+
+	sealed, err := old.Seal(ctx, plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		keys        *Keyring
+		wantChanged bool
+		wantKeyID   string
+		wantErr     error
+	}{
+		{name: "current key", keys: testKeyring("2024-01", "2024-01"), wantKeyID: "2024-01"},
+		{name: "rotated key", keys: testKeyring("2024-06", "2024-01", "2024-06"), wantChanged: true, wantKeyID: "2024-06"},
+		{name: "old key removed", keys: testKeyring("2024-06", "2024-06"), wantKeyID: "2024-01", wantErr: ErrUnknownKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewSealer(tt.keys, nil)
+
+			rewrapped, changed, err := s.Rewrap(ctx, sealed)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Rewrap: got error %v, want %v", err, tt.wantErr)
+			}
+			if changed != tt.wantChanged || rewrapped.KeyID != tt.wantKeyID {
+				t.Fatalf("Rewrap: got key %s changed %v, want key %s changed %v", rewrapped.KeyID, changed, tt.wantKeyID, tt.wantChanged)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			// Only the data key is rewrapped, the value is not re-encrypted.
+			if !bytes.Equal(rewrapped.Ciphertext, sealed.Ciphertext) {
+				t.Fatal("Rewrap changed the ciphertext")
+			}
+
+			got, err := s.Open(ctx, rewrapped)
+			if err != nil {
+				t.Fatalf("Open: %v", err)
+			}
+			if !bytes.Equal(got, plaintext) {
+				t.Fatalf("Open: got %q, want %q", got, plaintext)
+			}
+		})
+	}
+}
+
+func TestSealerOpenTampered(t *testing.T) {
+	ctx := context.Background()
+	s := NewSealer(testKeyring("k1", "k1", "k2"), nil)
+
+	sealed, err := s.Seal(ctx, []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	flip := func(b []byte) []byte {
+		b = bytes.Clone(b)
+		b[len(b)-1] ^= 1
+		return b
+	}
+
+	tests := []struct {
+		name    string
+		sealed  Sealed
+		wantErr error
+	}{
+		{name: "ciphertext", sealed: Sealed{KeyID: "k1", DataKey: sealed.DataKey, Ciphertext: flip(sealed.Ciphertext)}, wantErr: ErrDecrypt},
+		{name: "data key", sealed: Sealed{KeyID: "k1", DataKey: flip(sealed.DataKey), Ciphertext: sealed.Ciphertext}, wantErr: ErrDecrypt},
+		// The key ID is authenticated with the data key.
+		{name: "key ID", sealed: Sealed{KeyID: "k2", DataKey: sealed.DataKey, Ciphertext: sealed.Ciphertext}, wantErr: ErrDecrypt},
+		{name: "unknown key ID", sealed: Sealed{KeyID: "k3", DataKey: sealed.DataKey, Ciphertext: sealed.Ciphertext}, wantErr: ErrUnknownKey},
+		{name: "short ciphertext", sealed: Sealed{KeyID: "k1", DataKey: sealed.DataKey, Ciphertext: []byte{1, 2}}, wantErr: ErrDecrypt},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := s.Open(ctx, tt.sealed); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Open: got %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSealerFingerprint(t *testing.T) {
+	a := NewSealer(testKeyring("k1", "k1"), []byte("key a"))
+	b := NewSealer(testKeyring("k1", "k1"), []byte("key b"))
+
+	if a.Fingerprint([]byte("4111")) != a.Fingerprint([]byte("4111")) {
+		t.Fatal("fingerprints of equal values differ")
+	}
+	if a.Fingerprint([]byte("4111")) == a.Fingerprint([]byte("4112")) {
+		t.Fatal("fingerprints of different values are equal")
+	}
+	if a.Fingerprint([]byte("4111")) == b.Fingerprint([]byte("4111")) {
+		t.Fatal("fingerprints do not depend on the key")
+	}
+}
@@ -0,0 +1,92 @@
+package envelope
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Keyring is a KeyProvider holding its keys in memory.
+type Keyring struct {
+	// Current is the id of the key new data keys are wrapped with.
+	Current string `json:"current"`
+	// Keys are the AES-256 key encryption keys by id.
+	Keys map[string][]byte `json:"keys"`
+	// FingerprintKey is the key of Sealer fingerprints. Unlike the key
+	// encryption keys it cannot be rotated without recomputing them.
+	FingerprintKey []byte `json:"fingerprint_key"`
+}
+
+// LoadKeyfile reads a keyring from a JSON file of base64 encoded 32 bytes keys:
+//
+//	{
+//	  "current": "2024-06",
+//	  "keys": {"2024-01": "...", "2024-06": "..."},
+//	  "fingerprint_key": "..."
+//	}
+//
+// A key is rotated by adding a new key and making it current. The old key is
+// needed until all the data keys wrapped with it have been rewrapped.
+func LoadKeyfile(path string) (*Keyring, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("envelope.LoadKeyfile: %w", err)
+	}
+
+	var k Keyring
+	if err := json.Unmarshal(b, &k); err != nil {
+		return nil, fmt.Errorf("envelope.LoadKeyfile(%s): %w", path, err)
+	}
+
+	if err := k.validate(); err != nil {
+		return nil, fmt.Errorf("envelope.LoadKeyfile(%s): %w", path, err)
+	}
+
+	return &k, nil
+}
+
+// NewRandomKeyring returns a keyring of random keys, which only lives as long
+// as the process.
+func NewRandomKeyring() (*Keyring, error) {
+	key, fingerprintKey := make([]byte, keySize), make([]byte, keySize)
+
+	for _, b := range [][]byte{key, fingerprintKey} {
+		if _, err := rand.Read(b); err != nil {
+			return nil, fmt.Errorf("envelope.NewRandomKeyring: %w", err)
+		}
+	}
+
+	return &Keyring{Current: "ephemeral", Keys: map[string][]byte{"ephemeral": key}, FingerprintKey: fingerprintKey}, nil
+}
+
+func (k *Keyring) CurrentKey(ctx context.Context) (Key, error) {
+	return k.Key(ctx, k.Current)
+}
+
+func (k *Keyring) Key(_ context.Context, id string) (Key, error) {
+	secret, ok := k.Keys[id]
+	if !ok {
+		return Key{}, fmt.Errorf("%w: %q", ErrUnknownKey, id)
+	}
+	return Key{ID: id, Secret: secret}, nil
+}
+
+func (k *Keyring) validate() error {
+	if _, ok := k.Keys[k.Current]; !ok {
+		return fmt.Errorf("%w: current key %q", ErrUnknownKey, k.Current)
+	}
+
+	for id, secret := range k.Keys {
+		if len(secret) != keySize {
+			return fmt.Errorf("key %q must be %d bytes", id, keySize)
+		}
+	}
+
+	if len(k.FingerprintKey) < keySize {
+		return fmt.Errorf("fingerprint key must be at least %d bytes", keySize)
+	}
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package mail
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/oshankkumar/sockshop/internal/app"
+)
+
+// NewFileMailer returns a mailer writing each email to its own .eml file in
+// dir, the outbox, which is created if missing.
+func NewFileMailer(dir, from string) (*FileMailer, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create outbox: %w", err)
+	}
+
+	return &FileMailer{dir: dir, from: from}, nil
+}
+
+// FileMailer drops emails in a local outbox directory, for development and
+// end-to-end tests without a mail server. Files are named after the time they
+// were written, so that they sort oldest first.
+type FileMailer struct {
+	dir  string
+	from string
+}
+
+func (m *FileMailer) Send(_ context.Context, msg app.Email) error {
+	now := time.Now()
+
+	body, err := format(m.from, msg, now)
+	if err != nil {
+		return fmt.Errorf("FileMailer.Send: %w", err)
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return fmt.Errorf("FileMailer.Send: %w", err)
+	}
+
+	name := fmt.Sprintf("%s-%s.eml", now.UTC().Format("20060102T150405.000000000"), hex.EncodeToString(suffix))
+
+	// The email is written under a temporary name and renamed, so that
+	// readers of the outbox never see a partial file.
+	tmp := filepath.Join(m.dir, "."+name+".tmp")
+	if err := os.WriteFile(tmp, body, 0o600); err != nil {
+		return fmt.Errorf("FileMailer.Send: %w", err)
+	}
+
+	if err := os.Rename(tmp, filepath.Join(m.dir, name)); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("FileMailer.Send: %w", err)
+	}
+
+	return nil
+}
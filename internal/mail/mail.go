@@ -0,0 +1,68 @@
+// Package mail provides app.Mailer implementations: an SMTP client, a mailer
+// dropping messages as files in a local outbox directory, and an in-memory
+// mailer for tests.
+package mail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oshankkumar/sockshop/internal/app"
+)
+
+// ErrHeaderInjection is returned for emails with line breaks in their
+// recipient or subject.
+var ErrHeaderInjection = errors.New("line break in email header")
+
+// format renders the email as an RFC 5322 message.
+func format(from string, msg app.Email, date time.Time) ([]byte, error) {
+	for _, h := range []string{from, msg.To, msg.Subject} {
+		if strings.ContainsAny(h, "\r\n") {
+			return nil, ErrHeaderInjection
+		}
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n"))
+
+	return b.Bytes(), nil
+}
+
+func NewMemoryMailer() *MemoryMailer {
+	return &MemoryMailer{}
+}
+
+// MemoryMailer keeps the emails it is sent, so that tests can read them.
+type MemoryMailer struct {
+	mu   sync.Mutex
+	sent []app.Email
+}
+
+func (m *MemoryMailer) Send(_ context.Context, msg app.Email) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+// Sent returns the emails sent so far, oldest first.
+func (m *MemoryMailer) Sent() []app.Email {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]app.Email(nil), m.sent...)
+}
@@ -0,0 +1,55 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"net"
+	netmail "net/mail"
+	"net/smtp"
+	"time"
+
+	"github.com/oshankkumar/sockshop/internal/app"
+)
+
+// NewSMTPMailer returns a mailer relaying emails through the SMTP server at
+// addr. The server is authenticated with PLAIN when username is set, which
+// net/smtp only allows over TLS or to localhost.
+func NewSMTPMailer(addr, from, username, password string) (*SMTPMailer, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("smtp address %q: %w", addr, err)
+	}
+
+	sender, err := netmail.ParseAddress(from)
+	if err != nil {
+		return nil, fmt.Errorf("smtp sender %q: %w", from, err)
+	}
+
+	m := &SMTPMailer{addr: addr, from: from, sender: sender.Address}
+	if username != "" {
+		m.auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return m, nil
+}
+
+type SMTPMailer struct {
+	addr string
+	from string
+	// sender is the bare address of from, for the SMTP envelope.
+	sender string
+	auth   smtp.Auth
+}
+
+func (m *SMTPMailer) Send(_ context.Context, msg app.Email) error {
+	body, err := format(m.from, msg, time.Now())
+	if err != nil {
+		return fmt.Errorf("SMTPMailer.Send: %w", err)
+	}
+
+	if err := smtp.SendMail(m.addr, m.auth, m.sender, []string{msg.To}, body); err != nil {
+		return fmt.Errorf("SMTPMailer.Send: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,52 @@
+package oidc
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// ProviderConfig configures an identity provider the shop logs customers in
+// with.
+type ProviderConfig struct {
+	// Name identifies the provider in the login URLs, such as google in
+	// /login/oidc/google.
+	Name         string `json:"name"`
+	Issuer       string `json:"issuer"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	// RedirectURL is the callback URL registered with the provider.
+	RedirectURL string `json:"redirect_url"`
+	// Scopes default to openid, email and profile.
+	Scopes []string `json:"scopes"`
+	// TrustEmail links the identities to the existing customers with the
+	// same email, when the provider verified it. Only enable it for
+	// providers that verify the emails they assert.
+	TrustEmail bool `json:"trust_email"`
+}
+
+// LoadConfig reads the JSON array of provider configs at path.
+func LoadConfig(path string) ([]ProviderConfig, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read oidc config: %w", err)
+	}
+
+	var confs []ProviderConfig
+	if err := json.Unmarshal(b, &confs); err != nil {
+		return nil, fmt.Errorf("parse oidc config %s: %w", path, err)
+	}
+
+	names := make(map[string]bool)
+	for _, c := range confs {
+		if c.Name == "" || c.Issuer == "" || c.ClientID == "" {
+			return nil, fmt.Errorf("oidc config %s: name, issuer and client_id are required", path)
+		}
+		if names[c.Name] {
+			return nil, fmt.Errorf("oidc config %s: duplicate provider %q", path, c.Name)
+		}
+		names[c.Name] = true
+	}
+
+	return confs, nil
+}
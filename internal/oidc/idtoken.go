@@ -0,0 +1,232 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/oshankkumar/sockshop/internal/app"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid id token")
+	ErrUnknownKey   = errors.New("unknown id token signing key")
+)
+
+// clockSkew is the leeway given to the expiry of ID tokens.
+const clockSkew = time.Minute
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// audience is the aud claim, a string or an array of strings.
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*a = audience{s}
+		return nil
+	}
+
+	var ss []string
+	if err := json.Unmarshal(b, &ss); err != nil {
+		return err
+	}
+	*a = ss
+	return nil
+}
+
+func (a audience) contains(s string) bool {
+	for _, v := range a {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// flexBool is a boolean claim some providers send as a string.
+type flexBool bool
+
+func (f *flexBool) UnmarshalJSON(b []byte) error {
+	switch string(b) {
+	case "true", `"true"`:
+		*f = true
+	case "false", `"false"`, "null":
+		*f = false
+	default:
+		return fmt.Errorf("invalid boolean %s", b)
+	}
+	return nil
+}
+
+type idTokenClaims struct {
+	Issuer            string   `json:"iss"`
+	Subject           string   `json:"sub"`
+	Audience          audience `json:"aud"`
+	AuthorizedParty   string   `json:"azp"`
+	ExpiresAt         int64    `json:"exp"`
+	IssuedAt          int64    `json:"iat"`
+	Nonce             string   `json:"nonce"`
+	Email             string   `json:"email"`
+	EmailVerified     flexBool `json:"email_verified"`
+	PreferredUsername string   `json:"preferred_username"`
+	GivenName         string   `json:"given_name"`
+	FamilyName        string   `json:"family_name"`
+}
+
+// jwk is an RSA JSON Web Key.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type jwkSet struct {
+	Keys []jwk `json:"keys"`
+}
+
+func (k jwk) publicKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, fmt.Errorf("key %s: modulus: %w", k.Kid, err)
+	}
+
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil || len(e) == 0 || len(e) > 4 {
+		return nil, fmt.Errorf("key %s: invalid exponent", k.Kid)
+	}
+
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(new(big.Int).SetBytes(e).Int64()),
+	}, nil
+}
+
+func newJWK(kid string, key *rsa.PublicKey) jwk {
+	return jwk{
+		Kty: "RSA",
+		Kid: kid,
+		Use: "sig",
+		Alg: "RS256",
+		N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+// parseIDToken returns the header and the claims of the compact RS256 token,
+// once its signature is verified with the key lookup returns for its kid.
+// Other algorithms are rejected, which rules out algorithm confusion attacks.
+func parseIDToken(raw string, lookup func(kid string) (*rsa.PublicKey, error)) (idTokenClaims, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return idTokenClaims{}, ErrInvalidToken
+	}
+
+	var h jwtHeader
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return idTokenClaims{}, ErrInvalidToken
+	}
+
+	if h.Alg != "RS256" {
+		return idTokenClaims{}, fmt.Errorf("%w: unexpected algorithm %q", ErrInvalidToken, h.Alg)
+	}
+
+	key, err := lookup(h.Kid)
+	if err != nil {
+		return idTokenClaims{}, err
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return idTokenClaims{}, ErrInvalidToken
+	}
+
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig); err != nil {
+		return idTokenClaims{}, fmt.Errorf("%w: bad signature", ErrInvalidToken)
+	}
+
+	var c idTokenClaims
+	if err := decodeSegment(parts[1], &c); err != nil {
+		return idTokenClaims{}, ErrInvalidToken
+	}
+
+	return c, nil
+}
+
+// validate checks the claims the relying party must check, per OpenID
+// Connect Core 3.1.3.7.
+func (c idTokenClaims) validate(issuer, clientID, nonce string, now time.Time) error {
+	switch {
+	case c.Issuer != issuer:
+		return fmt.Errorf("%w: unexpected issuer %q", ErrInvalidToken, c.Issuer)
+	case !c.Audience.contains(clientID):
+		return fmt.Errorf("%w: not issued to the client", ErrInvalidToken)
+	case len(c.Audience) > 1 && c.AuthorizedParty != clientID:
+		return fmt.Errorf("%w: not authorized to the client", ErrInvalidToken)
+	case now.After(time.Unix(c.ExpiresAt, 0).Add(clockSkew)):
+		return fmt.Errorf("%w: expired", ErrInvalidToken)
+	case subtle.ConstantTimeCompare([]byte(c.Nonce), []byte(nonce)) != 1:
+		return fmt.Errorf("%w: nonce mismatch", ErrInvalidToken)
+	case c.Subject == "":
+		return fmt.Errorf("%w: no subject", ErrInvalidToken)
+	}
+	return nil
+}
+
+func (c idTokenClaims) toApp() app.OIDCClaims {
+	return app.OIDCClaims{
+		Subject:           c.Subject,
+		Email:             c.Email,
+		EmailVerified:     bool(c.EmailVerified),
+		PreferredUsername: c.PreferredUsername,
+		GivenName:         c.GivenName,
+		FamilyName:        c.FamilyName,
+	}
+}
+
+func decodeSegment(seg string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// signIDToken signs the claims with RS256, for the mock provider.
+func signIDToken(key *rsa.PrivateKey, kid string, claims any) (string, error) {
+	h, err := json.Marshal(jwtHeader{Alg: "RS256", Kid: kid})
+	if err != nil {
+		return "", err
+	}
+
+	c, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signed := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(nil, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
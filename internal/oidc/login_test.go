@@ -0,0 +1,292 @@
+package oidc
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/oshankkumar/sockshop/api"
+	"github.com/oshankkumar/sockshop/internal/app"
+	"github.com/oshankkumar/sockshop/internal/db"
+	"github.com/oshankkumar/sockshop/internal/db/memory"
+	"github.com/oshankkumar/sockshop/internal/domain"
+)
+
+// noopDriver is a database driver whose transactions do nothing, for the
+// services running the memory stores in transactions.
+type noopDriver struct{}
+
+func (noopDriver) Open(string) (driver.Conn, error) { return noopConn{}, nil }
+
+type noopConn struct{}
+
+func (noopConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("noop: no statements") }
+func (noopConn) Close() error                        { return nil }
+func (noopConn) Begin() (driver.Tx, error)           { return noopConn{}, nil }
+func (noopConn) Commit() error                       { return nil }
+func (noopConn) Rollback() error                     { return nil }
+
+func init() {
+	sql.Register("noop", noopDriver{})
+}
+
+func newNoopTxBeginner(t *testing.T) *sqlx.DB {
+	t.Helper()
+
+	dbx, err := sqlx.Open("noop", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { dbx.Close() })
+	return dbx
+}
+
+// userStore keeps the customers in memory. It only implements the methods
+// OIDCService calls.
+type userStore struct {
+	domain.UserStore
+
+	mu    sync.Mutex
+	users []domain.User
+}
+
+func (s *userStore) WithTx(db.DB) domain.UserStore { return s }
+
+func (s *userStore) find(match func(domain.User) bool) (domain.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if match(u) {
+			return u, nil
+		}
+	}
+	return domain.User{}, domain.ErrNotFound
+}
+
+func (s *userStore) GetUser(_ context.Context, id string) (domain.User, error) {
+	return s.find(func(u domain.User) bool { return u.ID.String() == id })
+}
+
+func (s *userStore) GetUserByEmail(_ context.Context, email string) (domain.User, error) {
+	return s.find(func(u domain.User) bool { return u.Email != "" && u.Email == email })
+}
+
+func (s *userStore) CreateUser(_ context.Context, user *domain.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if u.Username == user.Username || (user.Email != "" && u.Email == user.Email) {
+			return domain.DuplicateEntryError{Entity: "user", Err: errors.New("duplicate user")}
+		}
+	}
+
+	user.ID = uuid.New()
+	s.users = append(s.users, *user)
+	return nil
+}
+
+func (s *userStore) MarkEmailVerified(_ context.Context, id, email string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, u := range s.users {
+		if u.ID.String() == id && u.Email == email {
+			s.users[i].EmailVerified = true
+			return nil
+		}
+	}
+	return domain.ErrNotFound
+}
+
+type loginTest struct {
+	mock  *MockProvider
+	svc   *app.OIDCService
+	users *userStore
+	now   time.Time
+}
+
+func newLoginTest(t *testing.T, user app.OIDCClaims, trustEmail bool, existing ...domain.User) *loginTest {
+	t.Helper()
+
+	mock, p := newTestProvider(t, user, trustEmail)
+	users := &userStore{users: existing}
+
+	lt := &loginTest{mock: mock, users: users, now: time.Now()}
+	lt.svc = &app.OIDCService{
+		Providers:  map[string]app.OIDCProvider{"mock": p},
+		Identities: memory.NewIdentityStore(),
+		UserStore:  users,
+		Users:      &app.UserService{UserStore: users},
+		TxBeginner: newNoopTxBeginner(t),
+		Clock:      app.ClockFunc(func() time.Time { return lt.now }),
+	}
+	return lt
+}
+
+// login runs the authorization code flow up to the callback, and returns
+// its state and code.
+func (lt *loginTest) login(t *testing.T) (state, code string) {
+	t.Helper()
+
+	authURL, err := lt.svc.StartLogin(context.Background(), "mock")
+	if err != nil {
+		t.Fatalf("StartLogin: %v", err)
+	}
+
+	q := authorize(t, authURL)
+	return q.Get("state"), q.Get("code")
+}
+
+func isUnauthorized(err error) bool { return errors.Is(err, api.ErrUnauthorized) }
+
+func isDuplicate(err error) bool {
+	var dupe domain.DuplicateEntryError
+	return errors.As(err, &dupe)
+}
+
+func TestOIDCServiceCompleteLogin(t *testing.T) {
+	alice := domain.User{ID: uuid.New(), Username: "alice", Email: "alice@example.com"}
+
+	tests := []struct {
+		name       string
+		claims     app.OIDCClaims
+		trustEmail bool
+		// callback alters the state and code of the callback.
+		callback func(t *testing.T, lt *loginTest, state, code string) (string, string)
+		wantErr  func(error) bool
+		// wantUser checks the logged in customer.
+		wantUser func(t *testing.T, lt *loginTest, user *api.User)
+	}{
+		{
+			name:       "links a verified email of a trusted provider",
+			claims:     app.OIDCClaims{Subject: "g-1", Email: alice.Email, EmailVerified: true},
+			trustEmail: true,
+			wantUser: func(t *testing.T, _ *loginTest, user *api.User) {
+				if user.ID != alice.ID {
+					t.Errorf("got customer %s, want %s", user.ID, alice.ID)
+				}
+			},
+		},
+		{
+			name:       "refuses to link an unverified email",
+			claims:     app.OIDCClaims{Subject: "g-1", Email: alice.Email},
+			trustEmail: true,
+			wantErr:    isDuplicate,
+		},
+		{
+			name:    "refuses to link the email of an untrusted provider",
+			claims:  app.OIDCClaims{Subject: "g-1", Email: alice.Email, EmailVerified: true},
+			wantErr: isDuplicate,
+		},
+		{
+			name:       "provisions a customer",
+			claims:     app.OIDCClaims{Subject: "g-2", Email: "bob@example.com", EmailVerified: true, PreferredUsername: "Bob", GivenName: "Bob"},
+			trustEmail: true,
+			wantUser: func(t *testing.T, lt *loginTest, user *api.User) {
+				if user.ID == alice.ID || user.Email != "bob@example.com" || !user.EmailVerified || user.FirstName != "Bob" {
+					t.Errorf("got customer %+v, want a new verified customer", user)
+				}
+				if len(lt.users.users) != 2 {
+					t.Errorf("got %d customers, want 2", len(lt.users.users))
+				}
+			},
+		},
+		{
+			name:   "provisions a customer without an email",
+			claims: app.OIDCClaims{Subject: "g-3"},
+			wantUser: func(t *testing.T, _ *loginTest, user *api.User) {
+				if user.Email != "" || user.EmailVerified || user.Username == "" {
+					t.Errorf("got customer %+v, want a new customer without an email", user)
+				}
+			},
+		},
+		{
+			name:   "unknown state",
+			claims: app.OIDCClaims{Subject: "g-1"},
+			callback: func(_ *testing.T, _ *loginTest, _, code string) (string, string) {
+				return "other-state", code
+			},
+			wantErr: isUnauthorized,
+		},
+		{
+			name:   "expired state",
+			claims: app.OIDCClaims{Subject: "g-1"},
+			callback: func(_ *testing.T, lt *loginTest, state, code string) (string, string) {
+				lt.now = lt.now.Add(11 * time.Minute)
+				return state, code
+			},
+			wantErr: isUnauthorized,
+		},
+		{
+			name:   "state of another login",
+			claims: app.OIDCClaims{Subject: "g-1"},
+			callback: func(t *testing.T, lt *loginTest, _, code string) (string, string) {
+				// The code was issued for the first login's nonce and
+				// verifier.
+				state, _ := lt.login(t)
+				return state, code
+			},
+			wantErr: isUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lt := newLoginTest(t, tt.claims, tt.trustEmail, alice)
+
+			state, code := lt.login(t)
+			if tt.callback != nil {
+				state, code = tt.callback(t, lt, state, code)
+			}
+
+			user, err := lt.svc.CompleteLogin(context.Background(), "mock", state, code)
+
+			switch {
+			case tt.wantErr == nil && err != nil:
+				t.Fatalf("CompleteLogin: %v", err)
+			case tt.wantErr != nil && !tt.wantErr(err):
+				t.Fatalf("CompleteLogin: got error %v", err)
+			case tt.wantUser != nil:
+				tt.wantUser(t, lt, user)
+			}
+		})
+	}
+}
+
+func TestOIDCServiceCompleteLoginLinksOnce(t *testing.T) {
+	lt := newLoginTest(t, app.OIDCClaims{Subject: "g-1", Email: "carol@example.com", EmailVerified: true}, true)
+	ctx := context.Background()
+
+	state, code := lt.login(t)
+	first, err := lt.svc.CompleteLogin(ctx, "mock", state, code)
+	if err != nil {
+		t.Fatalf("first CompleteLogin: %v", err)
+	}
+
+	// The state is single use.
+	if _, err := lt.svc.CompleteLogin(ctx, "mock", state, code); !errors.Is(err, api.ErrUnauthorized) {
+		t.Fatalf("replayed CompleteLogin: got error %v, want %v", err, api.ErrUnauthorized)
+	}
+
+	// The identity stays linked even after its email changes.
+	lt.mock.SetUser(app.OIDCClaims{Subject: "g-1", Email: "carol@example.org"})
+
+	state, code = lt.login(t)
+	second, err := lt.svc.CompleteLogin(ctx, "mock", state, code)
+	if err != nil {
+		t.Fatalf("second CompleteLogin: %v", err)
+	}
+
+	if second.ID != first.ID || len(lt.users.users) != 1 {
+		t.Fatalf("got customer %s of %d, want %s only", second.ID, len(lt.users.users), first.ID)
+	}
+}
@@ -0,0 +1,229 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/oshankkumar/sockshop/internal/app"
+)
+
+const mockKeyID = "mock-1"
+
+type mockGrant struct {
+	clientID    string
+	redirectURI string
+	challenge   string
+	nonce       string
+	claims      app.OIDCClaims
+	expiresAt   time.Time
+}
+
+// MockProvider is an in-process OpenID Connect provider for tests and local
+// runs. Its authorization endpoint logs in the configured user without any
+// prompt and redirects straight back to the client. It serves at the root of
+// its host, which is the issuer.
+type MockProvider struct {
+	ClientID     string
+	ClientSecret string
+
+	key     *rsa.PrivateKey
+	handler http.Handler
+
+	mu     sync.Mutex
+	user   app.OIDCClaims
+	grants map[string]mockGrant
+}
+
+// NewMockProvider returns a provider logging user in to the client.
+func NewMockProvider(clientID, clientSecret string, user app.OIDCClaims) (*MockProvider, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, fmt.Errorf("generate mock provider key: %w", err)
+	}
+
+	m := &MockProvider{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		key:          key,
+		user:         user,
+		grants:       make(map[string]mockGrant),
+	}
+
+	r := chi.NewRouter()
+	r.Get("/.well-known/openid-configuration", m.discovery)
+	r.Get("/authorize", m.authorize)
+	r.Post("/token", m.token)
+	r.Get("/jwks", m.jwks)
+	m.handler = r
+
+	return m, nil
+}
+
+// SetUser changes the user logged in by the next authorizations.
+func (m *MockProvider) SetUser(user app.OIDCClaims) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.user = user
+}
+
+func (m *MockProvider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.handler.ServeHTTP(w, r)
+}
+
+func issuerOf(r *http.Request) string {
+	if r.TLS != nil {
+		return "https://" + r.Host
+	}
+	return "http://" + r.Host
+}
+
+func (m *MockProvider) discovery(w http.ResponseWriter, r *http.Request) {
+	iss := issuerOf(r)
+
+	respondMock(w, http.StatusOK, discovery{
+		Issuer:                iss,
+		AuthorizationEndpoint: iss + "/authorize",
+		TokenEndpoint:         iss + "/token",
+		JWKSURI:               iss + "/jwks",
+	})
+}
+
+func (m *MockProvider) jwks(w http.ResponseWriter, _ *http.Request) {
+	respondMock(w, http.StatusOK, jwkSet{Keys: []jwk{newJWK(mockKeyID, &m.key.PublicKey)}})
+}
+
+func (m *MockProvider) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	redirectURI, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || !redirectURI.IsAbs() {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case q.Get("client_id") != m.ClientID:
+		http.Error(w, "unknown client_id", http.StatusBadRequest)
+		return
+	case q.Get("response_type") != "code", q.Get("code_challenge_method") != "S256", q.Get("code_challenge") == "":
+		redirectMock(w, r, redirectURI, url.Values{"error": {"invalid_request"}, "state": {q.Get("state")}})
+		return
+	}
+
+	code, err := randomMockCode()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	m.mu.Lock()
+	m.grants[code] = mockGrant{
+		clientID:    m.ClientID,
+		redirectURI: redirectURI.String(),
+		challenge:   q.Get("code_challenge"),
+		nonce:       q.Get("nonce"),
+		claims:      m.user,
+		expiresAt:   time.Now().Add(time.Minute),
+	}
+	m.mu.Unlock()
+
+	redirectMock(w, r, redirectURI, url.Values{"code": {code}, "state": {q.Get("state")}})
+}
+
+func (m *MockProvider) token(w http.ResponseWriter, r *http.Request) {
+	clientID, secret, ok := r.BasicAuth()
+	if ok {
+		clientID, _ = url.QueryUnescape(clientID)
+		secret, _ = url.QueryUnescape(secret)
+	}
+
+	if !ok || clientID != m.ClientID || subtle.ConstantTimeCompare([]byte(secret), []byte(m.ClientSecret)) != 1 {
+		respondMock(w, http.StatusUnauthorized, tokenResponse{Error: "invalid_client"})
+		return
+	}
+
+	if r.PostFormValue("grant_type") != "authorization_code" {
+		respondMock(w, http.StatusBadRequest, tokenResponse{Error: "unsupported_grant_type"})
+		return
+	}
+
+	code := r.PostFormValue("code")
+
+	// Codes are single use, even when the exchange fails.
+	m.mu.Lock()
+	grant, ok := m.grants[code]
+	delete(m.grants, code)
+	m.mu.Unlock()
+
+	switch {
+	case !ok, time.Now().After(grant.expiresAt), grant.redirectURI != r.PostFormValue("redirect_uri"):
+		respondMock(w, http.StatusBadRequest, tokenResponse{Error: "invalid_grant"})
+		return
+	case codeChallenge(r.PostFormValue("code_verifier")) != grant.challenge:
+		respondMock(w, http.StatusBadRequest, tokenResponse{Error: "invalid_grant", ErrorDescription: "code_verifier mismatch"})
+		return
+	}
+
+	now := time.Now()
+
+	idToken, err := signIDToken(m.key, mockKeyID, idTokenClaims{
+		Issuer:            issuerOf(r),
+		Subject:           grant.claims.Subject,
+		Audience:          audience{grant.clientID},
+		ExpiresAt:         now.Add(5 * time.Minute).Unix(),
+		IssuedAt:          now.Unix(),
+		Nonce:             grant.nonce,
+		Email:             grant.claims.Email,
+		EmailVerified:     flexBool(grant.claims.EmailVerified),
+		PreferredUsername: grant.claims.PreferredUsername,
+		GivenName:         grant.claims.GivenName,
+		FamilyName:        grant.claims.FamilyName,
+	})
+	if err != nil {
+		respondMock(w, http.StatusInternalServerError, tokenResponse{Error: "server_error"})
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	respondMock(w, http.StatusOK, map[string]any{
+		"access_token": "mock-access-token",
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+func redirectMock(w http.ResponseWriter, r *http.Request, to *url.URL, params url.Values) {
+	u := *to
+	q := u.Query()
+	for k, v := range params {
+		q[k] = v
+	}
+	u.RawQuery = q.Encode()
+
+	http.Redirect(w, r, u.String(), http.StatusFound)
+}
+
+func respondMock(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func randomMockCode() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,244 @@
+// Package oidc implements app.OIDCProvider, the relying party side of the
+// OpenID Connect authorization code flow with PKCE, and a mock provider to
+// run it against locally.
+package oidc
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oshankkumar/sockshop/internal/app"
+)
+
+// jwksRefreshInterval limits how often the signing keys are fetched again
+// for an unknown key ID, so that forged tokens cannot flood the provider.
+const jwksRefreshInterval = time.Minute
+
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type tokenResponse struct {
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// Provider is an app.OIDCProvider. Its endpoints are discovered from the
+// issuer on first use, and its signing keys are cached.
+type Provider struct {
+	conf   ProviderConfig
+	client *http.Client
+	now    func() time.Time
+
+	mu          sync.Mutex
+	disc        *discovery
+	keys        map[string]*rsa.PublicKey
+	keysFetched time.Time
+}
+
+func NewProvider(conf ProviderConfig, client *http.Client) *Provider {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if len(conf.Scopes) == 0 {
+		conf.Scopes = []string{"openid", "email", "profile"}
+	}
+	return &Provider{conf: conf, client: client, now: time.Now}
+}
+
+func (p *Provider) TrustsEmail() bool {
+	return p.conf.TrustEmail
+}
+
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	disc, err := p.discover(ctx)
+	if err != nil {
+		return "", fmt.Errorf("Provider.AuthCodeURL(%s): %w", p.conf.Name, err)
+	}
+
+	u, err := url.Parse(disc.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("Provider.AuthCodeURL(%s): %w", p.conf.Name, err)
+	}
+
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", p.conf.ClientID)
+	q.Set("redirect_uri", p.conf.RedirectURL)
+	q.Set("scope", strings.Join(p.conf.Scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", codeChallenge(verifier))
+	q.Set("code_challenge_method", "S256")
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+}
+
+func (p *Provider) Exchange(ctx context.Context, code, verifier, nonce string) (app.OIDCClaims, error) {
+	disc, err := p.discover(ctx)
+	if err != nil {
+		return app.OIDCClaims{}, fmt.Errorf("Provider.Exchange(%s): %w", p.conf.Name, err)
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.conf.RedirectURL},
+		"code_verifier": {verifier},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, disc.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return app.OIDCClaims{}, fmt.Errorf("Provider.Exchange(%s): %w", p.conf.Name, err)
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	// RFC 6749 2.3.1 form-encodes the credentials before the basic scheme.
+	req.SetBasicAuth(url.QueryEscape(p.conf.ClientID), url.QueryEscape(p.conf.ClientSecret))
+
+	var tr tokenResponse
+	if err := p.do(req, &tr); err != nil && tr.Error == "" {
+		return app.OIDCClaims{}, fmt.Errorf("Provider.Exchange(%s): %w", p.conf.Name, err)
+	}
+
+	if tr.Error != "" {
+		return app.OIDCClaims{}, fmt.Errorf("Provider.Exchange(%s): token endpoint: %s: %s", p.conf.Name, tr.Error, tr.ErrorDescription)
+	}
+
+	claims, err := parseIDToken(tr.IDToken, func(kid string) (*rsa.PublicKey, error) {
+		return p.key(ctx, disc, kid)
+	})
+	if err != nil {
+		return app.OIDCClaims{}, fmt.Errorf("Provider.Exchange(%s): %w", p.conf.Name, err)
+	}
+
+	if err := claims.validate(p.conf.Issuer, p.conf.ClientID, nonce, p.now()); err != nil {
+		return app.OIDCClaims{}, fmt.Errorf("Provider.Exchange(%s): %w", p.conf.Name, err)
+	}
+
+	return claims.toApp(), nil
+}
+
+// discover fetches the provider metadata once, and checks that it belongs to
+// the configured issuer.
+func (p *Provider) discover(ctx context.Context) (*discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.disc != nil {
+		return p.disc, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(p.conf.Issuer, "/")+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var disc discovery
+	if err := p.do(req, &disc); err != nil {
+		return nil, fmt.Errorf("discovery: %w", err)
+	}
+
+	if disc.Issuer != p.conf.Issuer {
+		return nil, fmt.Errorf("discovery: issuer %q does not match %q", disc.Issuer, p.conf.Issuer)
+	}
+
+	if disc.AuthorizationEndpoint == "" || disc.TokenEndpoint == "" || disc.JWKSURI == "" {
+		return nil, fmt.Errorf("discovery: missing endpoints")
+	}
+
+	p.disc = &disc
+	return p.disc, nil
+}
+
+// key returns the signing key with the ID, fetching the key set again when
+// the ID is unknown, as providers rotate their keys.
+func (p *Provider) key(ctx context.Context, disc *discovery, kid string) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+
+	if p.keys != nil && p.now().Sub(p.keysFetched) < jwksRefreshInterval {
+		return nil, ErrUnknownKey
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, disc.JWKSURI, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var set jwkSet
+	if err := p.do(req, &set); err != nil {
+		return nil, fmt.Errorf("jwks: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("jwks: %w", err)
+		}
+		keys[k.Kid] = key
+	}
+
+	p.keys = keys
+	p.keysFetched = p.now()
+
+	key, ok := p.keys[kid]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	return key, nil
+}
+
+// do sends the request and decodes its JSON response into v. v is decoded
+// from error responses too, which carry OAuth error codes.
+func (p *Provider) do(req *http.Request, v any) error {
+	res, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+
+	decodeErr := json.Unmarshal(body, v)
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s: unexpected status %d", req.Method, req.URL.Redacted(), res.StatusCode)
+	}
+
+	return decodeErr
+}
+
+// codeChallenge is the S256 PKCE challenge of the verifier.
+func codeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,160 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/oshankkumar/sockshop/internal/app"
+)
+
+const (
+	testClientID     = "sockshop"
+	testClientSecret = "s3cret"
+	testRedirectURL  = "http://shop.test/login/oidc/mock/callback"
+)
+
+// newTestProvider starts a mock provider logging user in, and returns the
+// provider for it.
+func newTestProvider(t *testing.T, user app.OIDCClaims, trustEmail bool) (*MockProvider, *Provider) {
+	t.Helper()
+
+	mock, err := NewMockProvider(testClientID, testClientSecret, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(mock)
+	t.Cleanup(srv.Close)
+
+	p := NewProvider(ProviderConfig{
+		Name:         "mock",
+		Issuer:       srv.URL,
+		ClientID:     testClientID,
+		ClientSecret: testClientSecret,
+		RedirectURL:  testRedirectURL,
+		TrustEmail:   trustEmail,
+	}, srv.Client())
+
+	return mock, p
+}
+
+// authorize follows the authorization URL to the mock provider, and returns
+// the query of the redirect back to the client.
+func authorize(t *testing.T, authURL string) url.Values {
+	t.Helper()
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+
+	res, err := client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusFound {
+		t.Fatalf("authorize: got status %d, want %d", res.StatusCode, http.StatusFound)
+	}
+
+	loc, err := url.Parse(res.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := loc.Scheme + "://" + loc.Host + loc.Path; got != testRedirectURL {
+		t.Fatalf("authorize: redirected to %s, want %s", got, testRedirectURL)
+	}
+
+	return loc.Query()
+}
+
+func TestProviderExchange(t *testing.T) {
+	user := app.OIDCClaims{Subject: "alice-1", Email: "alice@example.com", EmailVerified: true, PreferredUsername: "alice"}
+
+	tests := []struct {
+		name string
+		// exchange alters the code, verifier and nonce sent to the token
+		// endpoint.
+		exchange func(code, verifier, nonce string) (string, string, string)
+		wantErr  error
+	}{
+		{
+			name:     "round trip",
+			exchange: func(code, verifier, nonce string) (string, string, string) { return code, verifier, nonce },
+		},
+		{
+			name:     "nonce mismatch",
+			exchange: func(code, verifier, _ string) (string, string, string) { return code, verifier, "other-nonce" },
+			wantErr:  ErrInvalidToken,
+		},
+		{
+			name:     "wrong verifier",
+			exchange: func(code, _, nonce string) (string, string, string) { return code, "other-verifier", nonce },
+			wantErr:  errAny,
+		},
+		{
+			name:     "unknown code",
+			exchange: func(_, verifier, nonce string) (string, string, string) { return "other-code", verifier, nonce },
+			wantErr:  errAny,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, p := newTestProvider(t, user, true)
+			ctx := context.Background()
+
+			authURL, err := p.AuthCodeURL(ctx, "state-1", "nonce-1", "verifier-1")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			q := authorize(t, authURL)
+			if q.Get("state") != "state-1" {
+				t.Fatalf("got state %q, want state-1", q.Get("state"))
+			}
+
+			code, verifier, nonce := tt.exchange(q.Get("code"), "verifier-1", "nonce-1")
+			claims, err := p.Exchange(ctx, code, verifier, nonce)
+
+			switch {
+			case tt.wantErr == nil && err != nil:
+				t.Fatalf("Exchange: %v", err)
+			case tt.wantErr == nil && claims != user:
+				t.Fatalf("Exchange: got claims %+v, want %+v", claims, user)
+			case tt.wantErr == errAny && err == nil:
+				t.Fatal("Exchange: got no error")
+			case tt.wantErr != nil && tt.wantErr != errAny && !errors.Is(err, tt.wantErr):
+				t.Fatalf("Exchange: got error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProviderExchangeCodeSingleUse(t *testing.T) {
+	_, p := newTestProvider(t, app.OIDCClaims{Subject: "alice-1"}, false)
+	ctx := context.Background()
+
+	authURL, err := p.AuthCodeURL(ctx, "state-1", "nonce-1", "verifier-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code := authorize(t, authURL).Get("code")
+
+	if _, err := p.Exchange(ctx, code, "verifier-1", "nonce-1"); err != nil {
+		t.Fatalf("first Exchange: %v", err)
+	}
+
+	if _, err := p.Exchange(ctx, code, "verifier-1", "nonce-1"); err == nil {
+		t.Fatal("second Exchange: got no error")
+	}
+}
+
+// errAny is a wanted error matching any error.
+var errAny = errors.New("any error")
@@ -0,0 +1,105 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const argon2idID = "argon2id"
+
+// Argon2id hashes passwords with argon2id into PHC strings of the form
+// $argon2id$v=19$m=<memory>,t=<time>,p=<threads>$<salt>$<key>.
+type Argon2id struct {
+	// Memory is the memory cost in KiB.
+	Memory  uint32
+	Time    uint32
+	Threads uint8
+	SaltLen int
+	KeyLen  uint32
+}
+
+// DefaultArgon2id follows the OWASP recommended parameters.
+var DefaultArgon2id = Argon2id{Memory: 19 * 1024, Time: 2, Threads: 1, SaltLen: 16, KeyLen: 32}
+
+func (a Argon2id) Hash(password string) (string, error) {
+	salt := make([]byte, a.SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("Argon2id.Hash: salt: %w", err)
+	}
+
+	key := argon2.IDKey([]byte(password), salt, a.Time, a.Memory, a.Threads, a.KeyLen)
+
+	return a.encode(salt, key), nil
+}
+
+func (a Argon2id) encode(salt, key []byte) string {
+	return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2idID, argon2.Version, a.Memory, a.Time, a.Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	)
+}
+
+func (a Argon2id) Verify(password, hash string) (bool, error) {
+	return Verify(password, hash)
+}
+
+// NeedsRehash reports whether hash was not produced by a with its current
+// parameters.
+func (a Argon2id) NeedsRehash(hash string) bool {
+	params, salt, key, err := decodeArgon2id(hash)
+	if err != nil {
+		return true
+	}
+
+	return params.Memory != a.Memory || params.Time != a.Time || params.Threads != a.Threads ||
+		len(salt) != a.SaltLen || uint32(len(key)) != a.KeyLen
+}
+
+func verifyArgon2id(password, hash string) (bool, error) {
+	params, salt, key, err := decodeArgon2id(hash)
+	if err != nil {
+		return false, err
+	}
+
+	other := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, uint32(len(key)))
+	return subtle.ConstantTimeCompare(key, other) == 1, nil
+}
+
+func decodeArgon2id(hash string) (Argon2id, []byte, []byte, error) {
+	var params Argon2id
+
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != argon2idID {
+		return params, nil, nil, fmt.Errorf("malformed %s hash", argon2idID)
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("unsupported %s version %q", argon2idID, parts[2])
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads); err != nil {
+		return params, nil, nil, fmt.Errorf("malformed %s parameters: %w", argon2idID, err)
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("malformed %s salt: %w", argon2idID, err)
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, fmt.Errorf("malformed %s key", argon2idID)
+	}
+
+	params.SaltLen = len(salt)
+	params.KeyLen = uint32(len(key))
+
+	return params, salt, key, nil
+}
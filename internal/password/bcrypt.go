@@ -0,0 +1,38 @@
+package password
+
+import (
+	"fmt"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Bcrypt hashes passwords with bcrypt. Passwords longer than 72 bytes are
+// rejected by bcrypt.
+type Bcrypt struct {
+	Cost int
+}
+
+var DefaultBcrypt = Bcrypt{Cost: 12}
+
+func (b Bcrypt) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), b.Cost)
+	if err != nil {
+		return "", fmt.Errorf("Bcrypt.Hash: %w", err)
+	}
+
+	return string(hash), nil
+}
+
+func (b Bcrypt) Verify(password, hash string) (bool, error) {
+	return Verify(password, hash)
+}
+
+// NeedsRehash reports whether hash is not a bcrypt hash of cost b.Cost.
+func (b Bcrypt) NeedsRehash(hash string) bool {
+	if !isBcrypt(hash) {
+		return true
+	}
+
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != b.Cost
+}
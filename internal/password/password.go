@@ -0,0 +1,62 @@
+// Package password hashes passwords with argon2id or bcrypt. Hashes are
+// self-describing strings in the PHC format (bcrypt keeps its own modular
+// crypt format), so any supported hash can be verified whatever the hasher
+// currently in use.
+package password
+
+import (
+	"crypto/sha1"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrUnknownScheme is returned when verifying a hash of an unsupported scheme.
+var ErrUnknownScheme = errors.New("unknown password hash scheme")
+
+// Verify reports whether password matches hash, whichever supported scheme
+// produced it.
+func Verify(password, hash string) (bool, error) {
+	switch {
+	case strings.HasPrefix(hash, "$"+argon2idID+"$"):
+		return verifyArgon2id(password, hash)
+	case isBcrypt(hash):
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+		return err == nil, err
+	default:
+		return false, ErrUnknownScheme
+	}
+}
+
+func isBcrypt(hash string) bool {
+	for _, prefix := range []string{"$2a$", "$2b$", "$2y$"} {
+		if strings.HasPrefix(hash, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsLegacy reports whether hash is a legacy salted SHA-1 hex digest rather
+// than a self-describing hash.
+func IsLegacy(hash string) bool {
+	return !strings.HasPrefix(hash, "$")
+}
+
+// VerifyLegacy reports whether password matches a legacy hash, the hex SHA-1
+// digest of the salt followed by the password.
+func VerifyLegacy(password, salt, hash string) bool {
+	h := sha1.New()
+	_, _ = io.WriteString(h, salt)
+	_, _ = io.WriteString(h, password)
+
+	digest := fmt.Sprintf("%x", h.Sum(nil))
+	return subtle.ConstantTimeCompare([]byte(digest), []byte(hash)) == 1
+}
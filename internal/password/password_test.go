@@ -0,0 +1,118 @@
+package password
+
+import (
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// Cheap parameters, so that the tests run fast.
+var (
+	testArgon2id = Argon2id{Memory: 64, Time: 1, Threads: 1, SaltLen: 16, KeyLen: 32}
+	testBcrypt   = Bcrypt{Cost: 4}
+)
+
+func mustHash(t *testing.T, h interface{ Hash(string) (string, error) }, password string) string {
+	t.Helper()
+
+	hash, err := h.Hash(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+func legacyHash(salt, password string) string {
+	return fmt.Sprintf("%x", sha1.Sum([]byte(salt+password)))
+}
+
+func TestVerify(t *testing.T) {
+	argonHash := mustHash(t, testArgon2id, "s3cret")
+	bcryptHash := mustHash(t, testBcrypt, "s3cret")
+
+	tests := []struct {
+		name     string
+		password string
+		hash     string
+		want     bool
+		wantErr  error
+	}{
+		{name: "argon2id", password: "s3cret", hash: argonHash, want: true},
+		{name: "argon2id mismatch", password: "wrong", hash: argonHash},
+		{name: "bcrypt", password: "s3cret", hash: bcryptHash, want: true},
+		{name: "bcrypt mismatch", password: "wrong", hash: bcryptHash},
+		{name: "legacy hash", password: "s3cret", hash: legacyHash("salt", "s3cret"), wantErr: ErrUnknownScheme},
+		{name: "unknown scheme", password: "s3cret", hash: "$md5$abc", wantErr: ErrUnknownScheme},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Verify(tt.password, tt.hash)
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyLegacy(t *testing.T) {
+	hash := legacyHash("c748112bc027878aa62812ba1ae00e40ad46d497", "password")
+	if !IsLegacy(hash) {
+		t.Fatal("legacy hash not detected")
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		salt     string
+		want     bool
+	}{
+		{name: "match", password: "password", salt: "c748112bc027878aa62812ba1ae00e40ad46d497", want: true},
+		{name: "wrong password", password: "passw0rd", salt: "c748112bc027878aa62812ba1ae00e40ad46d497"},
+		{name: "wrong salt", password: "password", salt: "other"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VerifyLegacy(tt.password, tt.salt, hash); got != tt.want {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	argonHash := mustHash(t, testArgon2id, "s3cret")
+	bcryptHash := mustHash(t, testBcrypt, "s3cret")
+
+	stronger := testArgon2id
+	stronger.Time = 2
+
+	tests := []struct {
+		name   string
+		hasher interface{ NeedsRehash(string) bool }
+		hash   string
+		want   bool
+	}{
+		{name: "argon2id current", hasher: testArgon2id, hash: argonHash},
+		{name: "argon2id weaker parameters", hasher: stronger, hash: argonHash, want: true},
+		{name: "argon2id from bcrypt", hasher: testArgon2id, hash: bcryptHash, want: true},
+		{name: "argon2id from legacy", hasher: testArgon2id, hash: legacyHash("salt", "s3cret"), want: true},
+		{name: "bcrypt current", hasher: testBcrypt, hash: bcryptHash},
+		{name: "bcrypt other cost", hasher: Bcrypt{Cost: 5}, hash: bcryptHash, want: true},
+		{name: "bcrypt from argon2id", hasher: testBcrypt, hash: argonHash, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.hasher.NeedsRehash(tt.hash); got != tt.want {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+// Package payment provides app.PaymentGateway implementations: an in-process
+// fake processor for tests and local runs, and an HTTP client for processors
+// speaking the API served by NewStubHandler.
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+
+	"github.com/oshankkumar/sockshop/internal/app"
+)
+
+// ErrUnknownAuthorization is returned for operations on an authorization the
+// gateway never issued.
+var ErrUnknownAuthorization = errors.New("unknown authorization")
+
+// Decline reasons reported by the fake gateway.
+const (
+	DeclineAmountLimit   = "amount_limit_exceeded"
+	DeclineCardBlocked   = "card_blocked"
+	DeclineProcessor     = "processor_declined"
+	DeclineInvalidState  = "invalid_authorization_state"
+	DeclineAmountInvalid = "invalid_amount"
+)
+
+// FakeRules configures when the fake gateway declines an authorization.
+type FakeRules struct {
+	// DeclineAbove declines amounts strictly greater than it. Zero disables
+	// the rule.
+	DeclineAbove float64
+	// DeclineBINs declines card numbers starting with any of the prefixes.
+	DeclineBINs []string
+	// FailureRate is the probability, between 0 and 1, that an otherwise
+	// valid authorization is declined.
+	FailureRate float64
+	// Seed seeds the random failures so that runs are reproducible.
+	Seed int64
+}
+
+type authState int
+
+const (
+	authAuthorized authState = iota
+	authCaptured
+	authVoided
+	authRefunded
+)
+
+type fakeAuth struct {
+	amount float64
+	state  authState
+}
+
+// FakeGateway is a deterministic in-process payment processor. It keeps its
+// state in memory and honours idempotency keys like a real processor would.
+type FakeGateway struct {
+	rules FakeRules
+
+	mu    sync.Mutex
+	rnd   *rand.Rand
+	seq   int
+	byKey map[string]app.PaymentResult
+	auths map[string]*fakeAuth
+}
+
+func NewFakeGateway(rules FakeRules) *FakeGateway {
+	return &FakeGateway{
+		rules: rules,
+		rnd:   rand.New(rand.NewSource(rules.Seed)),
+		byKey: make(map[string]app.PaymentResult),
+		auths: make(map[string]*fakeAuth),
+	}
+}
+
+func (f *FakeGateway) Authorize(_ context.Context, req app.PaymentRequest) (app.PaymentResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if res, ok := f.byKey[req.IdempotencyKey]; ok && req.IdempotencyKey != "" {
+		return res, nil
+	}
+
+	res := f.decide(req)
+	if !res.Declined {
+		f.seq++
+		res.AuthorizationID = fmt.Sprintf("auth_%06d", f.seq)
+		f.auths[res.AuthorizationID] = &fakeAuth{amount: req.Amount, state: authAuthorized}
+	}
+
+	if req.IdempotencyKey != "" {
+		f.byKey[req.IdempotencyKey] = res
+	}
+
+	return res, nil
+}
+
+func (f *FakeGateway) decide(req app.PaymentRequest) app.PaymentResult {
+	switch {
+	case req.Amount <= 0:
+		return declined(DeclineAmountInvalid)
+	case f.rules.DeclineAbove > 0 && req.Amount > f.rules.DeclineAbove:
+		return declined(DeclineAmountLimit)
+	}
+
+	for _, bin := range f.rules.DeclineBINs {
+		if bin != "" && strings.HasPrefix(req.CardNumber, bin) {
+			return declined(DeclineCardBlocked)
+		}
+	}
+
+	if f.rules.FailureRate > 0 && f.rnd.Float64() < f.rules.FailureRate {
+		return declined(DeclineProcessor)
+	}
+
+	return app.PaymentResult{}
+}
+
+// Capture captures an authorization. Capturing it again succeeds without
+// charging twice.
+func (f *FakeGateway) Capture(_ context.Context, authorizationID string, amount float64) (app.PaymentResult, error) {
+	return f.transition(authorizationID, func(a *fakeAuth) app.PaymentResult {
+		switch {
+		case a.state == authCaptured:
+		case a.state != authAuthorized:
+			return declined(DeclineInvalidState)
+		case amount <= 0 || amount > a.amount:
+			return declined(DeclineAmountInvalid)
+		default:
+			a.state = authCaptured
+		}
+		return app.PaymentResult{}
+	})
+}
+
+func (f *FakeGateway) Void(_ context.Context, authorizationID string) (app.PaymentResult, error) {
+	return f.transition(authorizationID, func(a *fakeAuth) app.PaymentResult {
+		switch a.state {
+		case authAuthorized:
+			a.state = authVoided
+		case authVoided:
+		default:
+			return declined(DeclineInvalidState)
+		}
+		return app.PaymentResult{}
+	})
+}
+
+func (f *FakeGateway) Refund(_ context.Context, authorizationID string, amount float64) (app.PaymentResult, error) {
+	return f.transition(authorizationID, func(a *fakeAuth) app.PaymentResult {
+		switch {
+		case a.state == authRefunded:
+		case a.state != authCaptured:
+			return declined(DeclineInvalidState)
+		case amount <= 0 || amount > a.amount:
+			return declined(DeclineAmountInvalid)
+		default:
+			a.state = authRefunded
+		}
+		return app.PaymentResult{}
+	})
+}
+
+func (f *FakeGateway) transition(authorizationID string, fn func(a *fakeAuth) app.PaymentResult) (app.PaymentResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	a, ok := f.auths[authorizationID]
+	if !ok {
+		return app.PaymentResult{}, fmt.Errorf("%w: %s", ErrUnknownAuthorization, authorizationID)
+	}
+
+	res := fn(a)
+	res.AuthorizationID = authorizationID
+	return res, nil
+}
+
+func declined(reason string) app.PaymentResult {
+	return app.PaymentResult{Declined: true, DeclineReason: reason}
+}
@@ -0,0 +1,183 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/oshankkumar/sockshop/internal/app"
+)
+
+func TestFakeGatewayAuthorize(t *testing.T) {
+	rules := FakeRules{DeclineAbove: 100, DeclineBINs: []string{"4000", ""}}
+
+	tests := []struct {
+		name       string
+		req        app.PaymentRequest
+		wantReason string
+	}{
+		{name: "approved", req: app.PaymentRequest{Amount: 50, CardNumber: "4111111111111111"}},
+		{name: "at the limit", req: app.PaymentRequest{Amount: 100, CardNumber: "4111111111111111"}},
+		{name: "above the limit", req: app.PaymentRequest{Amount: 100.01, CardNumber: "4111111111111111"}, wantReason: DeclineAmountLimit},
+		{name: "zero amount", req: app.PaymentRequest{Amount: 0, CardNumber: "4111111111111111"}, wantReason: DeclineAmountInvalid},
+		{name: "negative amount", req: app.PaymentRequest{Amount: -5, CardNumber: "4111111111111111"}, wantReason: DeclineAmountInvalid},
+		{name: "blocked BIN", req: app.PaymentRequest{Amount: 50, CardNumber: "4000056655665556"}, wantReason: DeclineCardBlocked},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := NewFakeGateway(rules).Authorize(context.Background(), tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if res.Declined != (tt.wantReason != "") || res.DeclineReason != tt.wantReason {
+				t.Fatalf("got %+v, want decline reason %q", res, tt.wantReason)
+			}
+			if !res.Declined && res.AuthorizationID == "" {
+				t.Fatal("approved without an authorization ID")
+			}
+		})
+	}
+}
+
+func TestFakeGatewayIdempotency(t *testing.T) {
+	f := NewFakeGateway(FakeRules{})
+	ctx := context.Background()
+	req := app.PaymentRequest{IdempotencyKey: "order-1", Amount: 20, CardNumber: "4111111111111111"}
+
+	first, err := f.Authorize(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A replayed key gets the first result, even with another amount.
+	req.Amount = 30
+	second, err := f.Authorize(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if second != first {
+		t.Fatalf("replayed Authorize: got %+v, want %+v", second, first)
+	}
+
+	req.IdempotencyKey = "order-2"
+	third, err := f.Authorize(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if third.AuthorizationID == first.AuthorizationID {
+		t.Fatal("another key got the same authorization")
+	}
+}
+
+func TestFakeGatewayFailureRateIsSeeded(t *testing.T) {
+	outcomes := func() []bool {
+		f := NewFakeGateway(FakeRules{FailureRate: 0.5, Seed: 42})
+
+		var declined []bool
+		for i := 0; i < 20; i++ {
+			res, err := f.Authorize(context.Background(), app.PaymentRequest{Amount: 10})
+			if err != nil {
+				t.Fatal(err)
+			}
+			declined = append(declined, res.Declined)
+		}
+		return declined
+	}
+
+	a, b := outcomes(), outcomes()
+
+	var n int
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("outcome %d differs between runs with the same seed", i)
+		}
+		if a[i] {
+			n++
+		}
+	}
+
+	if n == 0 || n == len(a) {
+		t.Fatalf("got %d declines of %d, want some", n, len(a))
+	}
+}
+
+func TestFakeGatewayTransitions(t *testing.T) {
+	type step struct {
+		op         string
+		amount     float64
+		wantReason string
+	}
+
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name:  "capture then refund",
+			steps: []step{{op: "capture", amount: 20}, {op: "refund", amount: 20}},
+		},
+		{
+			name:  "capture twice",
+			steps: []step{{op: "capture", amount: 20}, {op: "capture", amount: 20}},
+		},
+		{
+			name:  "capture more than authorized",
+			steps: []step{{op: "capture", amount: 20.01, wantReason: DeclineAmountInvalid}},
+		},
+		{
+			name:  "void then capture",
+			steps: []step{{op: "void"}, {op: "void"}, {op: "capture", amount: 20, wantReason: DeclineInvalidState}},
+		},
+		{
+			name:  "refund before capture",
+			steps: []step{{op: "refund", amount: 20, wantReason: DeclineInvalidState}},
+		},
+		{
+			name:  "void after capture",
+			steps: []step{{op: "capture", amount: 20}, {op: "void", wantReason: DeclineInvalidState}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewFakeGateway(FakeRules{})
+			ctx := context.Background()
+
+			auth, err := f.Authorize(ctx, app.PaymentRequest{Amount: 20})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i, s := range tt.steps {
+				var res app.PaymentResult
+
+				switch s.op {
+				case "capture":
+					res, err = f.Capture(ctx, auth.AuthorizationID, s.amount)
+				case "void":
+					res, err = f.Void(ctx, auth.AuthorizationID)
+				case "refund":
+					res, err = f.Refund(ctx, auth.AuthorizationID, s.amount)
+				}
+
+				if err != nil {
+					t.Fatalf("step %d %s: %v", i, s.op, err)
+				}
+				if res.DeclineReason != s.wantReason {
+					t.Fatalf("step %d %s: got %+v, want decline reason %q", i, s.op, res, s.wantReason)
+				}
+			}
+		})
+	}
+}
+
+func TestFakeGatewayUnknownAuthorization(t *testing.T) {
+	_, err := NewFakeGateway(FakeRules{}).Capture(context.Background(), "auth_999999", 10)
+	if !errors.Is(err, ErrUnknownAuthorization) {
+		t.Fatalf("got %v, want %v", err, ErrUnknownAuthorization)
+	}
+}
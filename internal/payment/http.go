@@ -0,0 +1,130 @@
+package payment
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/oshankkumar/sockshop/internal/app"
+)
+
+// authorizeRequest, operationRequest and operationResponse are the wire types
+// of the processor API.
+type authorizeRequest struct {
+	Amount      float64 `json:"amount"`
+	Currency    string  `json:"currency"`
+	CardNumber  string  `json:"card_number"`
+	CardExpires string  `json:"card_expires"`
+}
+
+type operationRequest struct {
+	Amount float64 `json:"amount,omitempty"`
+}
+
+type operationResponse struct {
+	AuthorizationID string `json:"authorization_id,omitempty"`
+	Declined        bool   `json:"declined"`
+	DeclineReason   string `json:"decline_reason,omitempty"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// HTTPGateway is an app.PaymentGateway calling a processor over HTTP. The
+// idempotency key of an authorization is sent in the Idempotency-Key header.
+type HTTPGateway struct {
+	BaseURL string
+	Client  *http.Client
+}
+
+func NewHTTPGateway(baseURL string) *HTTPGateway {
+	return &HTTPGateway{BaseURL: strings.TrimRight(baseURL, "/"), Client: http.DefaultClient}
+}
+
+func (h *HTTPGateway) Authorize(ctx context.Context, req app.PaymentRequest) (app.PaymentResult, error) {
+	body := authorizeRequest{
+		Amount:      req.Amount,
+		Currency:    req.Currency,
+		CardNumber:  req.CardNumber,
+		CardExpires: req.CardExpires,
+	}
+
+	res, err := h.do(ctx, "/authorizations", req.IdempotencyKey, body)
+	if err != nil {
+		return res, fmt.Errorf("HTTPGateway.Authorize: %w", err)
+	}
+	return res, nil
+}
+
+func (h *HTTPGateway) Capture(ctx context.Context, authorizationID string, amount float64) (app.PaymentResult, error) {
+	res, err := h.do(ctx, operationPath(authorizationID, "capture"), "", operationRequest{Amount: amount})
+	if err != nil {
+		return res, fmt.Errorf("HTTPGateway.Capture(authorizationID=%s): %w", authorizationID, err)
+	}
+	return res, nil
+}
+
+func (h *HTTPGateway) Void(ctx context.Context, authorizationID string) (app.PaymentResult, error) {
+	res, err := h.do(ctx, operationPath(authorizationID, "void"), "", operationRequest{})
+	if err != nil {
+		return res, fmt.Errorf("HTTPGateway.Void(authorizationID=%s): %w", authorizationID, err)
+	}
+	return res, nil
+}
+
+func (h *HTTPGateway) Refund(ctx context.Context, authorizationID string, amount float64) (app.PaymentResult, error) {
+	res, err := h.do(ctx, operationPath(authorizationID, "refund"), "", operationRequest{Amount: amount})
+	if err != nil {
+		return res, fmt.Errorf("HTTPGateway.Refund(authorizationID=%s): %w", authorizationID, err)
+	}
+	return res, nil
+}
+
+func operationPath(authorizationID, op string) string {
+	return "/authorizations/" + url.PathEscape(authorizationID) + "/" + op
+}
+
+func (h *HTTPGateway) do(ctx context.Context, path, idempotencyKey string, body any) (app.PaymentResult, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return app.PaymentResult{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.BaseURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return app.PaymentResult{}, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+
+	resp, err := h.Client.Do(req)
+	if err != nil {
+		return app.PaymentResult{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var e errorResponse
+		_ = json.NewDecoder(resp.Body).Decode(&e)
+		return app.PaymentResult{}, fmt.Errorf("processor responded %d: %s", resp.StatusCode, e.Error)
+	}
+
+	var out operationResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return app.PaymentResult{}, fmt.Errorf("decode processor response: %w", err)
+	}
+
+	return app.PaymentResult{
+		AuthorizationID: out.AuthorizationID,
+		Declined:        out.Declined,
+		DeclineReason:   out.DeclineReason,
+	}, nil
+}
@@ -0,0 +1,86 @@
+package payment
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/oshankkumar/sockshop/internal/app"
+)
+
+// NewStubHandler serves the processor API HTTPGateway talks to on top of gw,
+// typically a FakeGateway, so that the HTTP client can be exercised locally.
+func NewStubHandler(gw app.PaymentGateway) http.Handler {
+	r := chi.NewRouter()
+
+	r.Post("/authorizations", func(w http.ResponseWriter, r *http.Request) {
+		var req authorizeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respond(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+			return
+		}
+
+		res, err := gw.Authorize(r.Context(), app.PaymentRequest{
+			IdempotencyKey: r.Header.Get("Idempotency-Key"),
+			Amount:         req.Amount,
+			Currency:       req.Currency,
+			CardNumber:     req.CardNumber,
+			CardExpires:    req.CardExpires,
+		})
+		respondResult(w, res, err)
+	})
+
+	r.Post("/authorizations/{id}/{op}", func(w http.ResponseWriter, r *http.Request) {
+		var req operationRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respond(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+			return
+		}
+
+		id := chi.URLParam(r, "id")
+
+		var (
+			res app.PaymentResult
+			err error
+		)
+
+		switch chi.URLParam(r, "op") {
+		case "capture":
+			res, err = gw.Capture(r.Context(), id, req.Amount)
+		case "void":
+			res, err = gw.Void(r.Context(), id)
+		case "refund":
+			res, err = gw.Refund(r.Context(), id, req.Amount)
+		default:
+			respond(w, http.StatusNotFound, errorResponse{Error: "unknown operation"})
+			return
+		}
+
+		respondResult(w, res, err)
+	})
+
+	return r
+}
+
+func respondResult(w http.ResponseWriter, res app.PaymentResult, err error) {
+	switch {
+	case errors.Is(err, ErrUnknownAuthorization):
+		respond(w, http.StatusNotFound, errorResponse{Error: err.Error()})
+	case err != nil:
+		respond(w, http.StatusBadGateway, errorResponse{Error: err.Error()})
+	default:
+		respond(w, http.StatusOK, operationResponse{
+			AuthorizationID: res.AuthorizationID,
+			Declined:        res.Declined,
+			DeclineReason:   res.DeclineReason,
+		})
+	}
+}
+
+func respond(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
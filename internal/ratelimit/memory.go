@@ -0,0 +1,54 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often MemoryStore forgets the full buckets.
+const sweepInterval = time.Minute
+
+type memoryBucket struct {
+	Bucket
+	limit Limit
+}
+
+// MemoryStore keeps the buckets in memory, limiting the clients of a single
+// instance. Buckets that refilled are swept, so that idle clients do not
+// use memory.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	lastSweep time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*memoryBucket)}
+}
+
+func (m *MemoryStore) Take(_ context.Context, key string, limit Limit, now time.Time) (Decision, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if now.Sub(m.lastSweep) >= sweepInterval {
+		m.sweep(now)
+	}
+
+	b, ok := m.buckets[key]
+	if !ok || b.limit != limit {
+		b = &memoryBucket{Bucket: NewBucket(limit, now), limit: limit}
+		m.buckets[key] = b
+	}
+
+	return b.Take(limit, now), nil
+}
+
+func (m *MemoryStore) sweep(now time.Time) {
+	for key, b := range m.buckets {
+		if b.Full(b.limit, now) {
+			delete(m.buckets, key)
+		}
+	}
+	m.lastSweep = now
+}
@@ -0,0 +1,156 @@
+// Package ratelimit implements token bucket rate limiting. The buckets are
+// kept by a Store: MemoryStore limits the clients of a single instance, and
+// a Store shared by the instances, such as one over Redis, limits them
+// globally.
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"time"
+)
+
+// Limit is a bucket holding Burst tokens and refilled with Rate tokens per
+// second. Every request takes a token.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Window is how long an empty bucket takes to refill.
+func (l Limit) Window() time.Duration {
+	return time.Duration(float64(l.Burst) / l.Rate * float64(time.Second))
+}
+
+// Decision is the outcome of taking a token.
+type Decision struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is the time until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is the time until a token is available, zero when
+	// allowed.
+	RetryAfter time.Duration
+}
+
+// Store takes tokens from the buckets of the clients. Take must be atomic
+// for a key, as concurrent requests of a client race for its tokens.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Decision, error)
+}
+
+// Bucket is the state of a token bucket, for the stores to keep.
+type Bucket struct {
+	Tokens  float64
+	Updated time.Time
+}
+
+// NewBucket returns a full bucket.
+func NewBucket(limit Limit, now time.Time) Bucket {
+	return Bucket{Tokens: float64(limit.Burst), Updated: now}
+}
+
+// Take refills the bucket for the time elapsed since its last update and
+// takes a token, if there is one.
+func (b *Bucket) Take(limit Limit, now time.Time) Decision {
+	burst := float64(limit.Burst)
+
+	if elapsed := now.Sub(b.Updated).Seconds(); elapsed > 0 {
+		b.Tokens = math.Min(burst, b.Tokens+elapsed*limit.Rate)
+		b.Updated = now
+	}
+
+	d := Decision{Limit: limit.Burst}
+
+	if b.Tokens >= 1 {
+		b.Tokens--
+		d.Allowed = true
+	} else {
+		d.RetryAfter = seconds((1 - b.Tokens) / limit.Rate)
+	}
+
+	d.Remaining = int(math.Floor(b.Tokens))
+	d.Reset = seconds((burst - b.Tokens) / limit.Rate)
+
+	return d
+}
+
+// Full reports whether the bucket refilled by now, so that it can be
+// forgotten.
+func (b Bucket) Full(limit Limit, now time.Time) bool {
+	return b.Tokens+now.Sub(b.Updated).Seconds()*limit.Rate >= float64(limit.Burst)
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// LimitedError rejects a request of a client out of tokens.
+type LimitedError struct {
+	RetryAfter time.Duration
+}
+
+func (e LimitedError) Error() string {
+	return "rate limit exceeded, retry after " + e.RetryAfter.String()
+}
+
+// Client keys of the rules.
+const (
+	// KeyIP limits every client IP.
+	KeyIP = "ip"
+	// KeyClient limits every API key and user, and the client IP of the
+	// anonymous requests.
+	KeyClient = "client"
+)
+
+// Rule limits the requests to the routes with the pattern. An empty method
+// matches every method.
+type Rule struct {
+	Method  string  `json:"method"`
+	Pattern string  `json:"pattern"`
+	Rate    float64 `json:"rate"`
+	Burst   int     `json:"burst"`
+	// Key is KeyIP or KeyClient, the default.
+	Key string `json:"key"`
+}
+
+func (r Rule) Limit() Limit {
+	return Limit{Rate: r.Rate, Burst: r.Burst}
+}
+
+func (r Rule) validate() error {
+	switch {
+	case r.Pattern == "":
+		return fmt.Errorf("rate limit rule: pattern is required")
+	case r.Rate <= 0 || r.Burst < 1:
+		return fmt.Errorf("rate limit rule %s %s: rate must be positive and burst at least 1", r.Method, r.Pattern)
+	case r.Key != "" && r.Key != KeyIP && r.Key != KeyClient:
+		return fmt.Errorf("rate limit rule %s %s: unknown key %q", r.Method, r.Pattern, r.Key)
+	}
+	return nil
+}
+
+// LoadRules reads the JSON array of rules at path.
+func LoadRules(path string) ([]Rule, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read rate limit rules: %w", err)
+	}
+
+	var rules []Rule
+	if err := json.Unmarshal(b, &rules); err != nil {
+		return nil, fmt.Errorf("parse rate limit rules %s: %w", path, err)
+	}
+
+	for _, r := range rules {
+		if err := r.validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	return rules, nil
+}
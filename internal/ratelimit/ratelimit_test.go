@@ -0,0 +1,170 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBucketTake(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	limit := Limit{Rate: 2, Burst: 3}
+
+	tests := []struct {
+		// after is the time since start of the take.
+		after time.Duration
+		want  Decision
+	}{
+		{after: 0, want: Decision{Allowed: true, Limit: 3, Remaining: 2, Reset: 500 * time.Millisecond}},
+		{after: 0, want: Decision{Allowed: true, Limit: 3, Remaining: 1, Reset: time.Second}},
+		{after: 0, want: Decision{Allowed: true, Limit: 3, Remaining: 0, Reset: 1500 * time.Millisecond}},
+		{after: 0, want: Decision{Limit: 3, Remaining: 0, Reset: 1500 * time.Millisecond, RetryAfter: 500 * time.Millisecond}},
+		{after: 250 * time.Millisecond, want: Decision{Limit: 3, Remaining: 0, Reset: 1250 * time.Millisecond, RetryAfter: 250 * time.Millisecond}},
+		{after: 500 * time.Millisecond, want: Decision{Allowed: true, Limit: 3, Remaining: 0, Reset: 1500 * time.Millisecond}},
+		// A clock going backwards refills nothing.
+		{after: 0, want: Decision{Limit: 3, Remaining: 0, Reset: 1500 * time.Millisecond, RetryAfter: 500 * time.Millisecond}},
+		// The bucket refills up to its burst only.
+		{after: time.Hour, want: Decision{Allowed: true, Limit: 3, Remaining: 2, Reset: 500 * time.Millisecond}},
+	}
+
+	b := NewBucket(limit, start)
+	for i, tt := range tests {
+		if got := b.Take(limit, start.Add(tt.after)); got != tt.want {
+			t.Fatalf("take %d at %s: got %+v, want %+v", i, tt.after, got, tt.want)
+		}
+	}
+}
+
+func TestBucketFull(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	limit := Limit{Rate: 1, Burst: 2}
+
+	b := NewBucket(limit, start)
+	if !b.Full(limit, start) {
+		t.Fatal("new bucket not full")
+	}
+
+	b.Take(limit, start)
+	b.Take(limit, start)
+
+	tests := []struct {
+		after time.Duration
+		want  bool
+	}{
+		{after: time.Second, want: false},
+		{after: 2 * time.Second, want: true},
+	}
+
+	for _, tt := range tests {
+		if got := b.Full(limit, start.Add(tt.after)); got != tt.want {
+			t.Errorf("Full after %s: got %v, want %v", tt.after, got, tt.want)
+		}
+	}
+}
+
+func TestMemoryStoreTake(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	limit := Limit{Rate: 1, Burst: 2}
+
+	m := NewMemoryStore()
+
+	take := func(key string, limit Limit, at time.Time) Decision {
+		t.Helper()
+
+		d, err := m.Take(ctx, key, limit, at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+
+	for i := 0; i < 2; i++ {
+		if d := take("ip:10.0.0.1", limit, start); !d.Allowed {
+			t.Fatalf("take %d: got %+v, want allowed", i, d)
+		}
+	}
+	if d := take("ip:10.0.0.1", limit, start); d.Allowed {
+		t.Fatalf("take past the burst: got %+v, want limited", d)
+	}
+
+	// Every key has its own bucket.
+	if d := take("ip:10.0.0.2", limit, start); !d.Allowed {
+		t.Fatalf("take of another key: got %+v, want allowed", d)
+	}
+
+	// A changed limit starts a new bucket.
+	if d := take("ip:10.0.0.1", Limit{Rate: 1, Burst: 5}, start); !d.Allowed || d.Remaining != 4 {
+		t.Fatalf("take with another limit: got %+v, want allowed with 4 remaining", d)
+	}
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	slow := Limit{Rate: 0.001, Burst: 1}
+	fast := Limit{Rate: 10, Burst: 1}
+
+	m := NewMemoryStore()
+
+	for i := 0; i < 10; i++ {
+		if _, err := m.Take(ctx, fmt.Sprintf("client:%d", i), fast, start); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := m.Take(ctx, "client:slow", slow, start); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.Take(ctx, "client:new", fast, start.Add(sweepInterval)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The refilled buckets are forgotten, the others kept.
+	if _, ok := m.buckets["client:0"]; ok {
+		t.Error("refilled bucket kept")
+	}
+	if _, ok := m.buckets["client:slow"]; !ok {
+		t.Error("bucket not refilled yet swept")
+	}
+	if n := len(m.buckets); n != 2 {
+		t.Errorf("got %d buckets, want 2", n)
+	}
+
+	if d, err := m.Take(ctx, "client:slow", slow, start.Add(sweepInterval)); err != nil || d.Allowed {
+		t.Fatalf("take of a kept bucket: got %+v, %v, want limited", d, err)
+	}
+}
+
+func TestLoadRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		wantErr bool
+	}{
+		{name: "valid", json: `[{"method":"POST","pattern":"/login","rate":0.5,"burst":5,"key":"ip"},{"pattern":"/catalogue","rate":10,"burst":20}]`},
+		{name: "empty", json: `[]`},
+		{name: "no pattern", json: `[{"rate":1,"burst":1}]`, wantErr: true},
+		{name: "zero rate", json: `[{"pattern":"/login","rate":0,"burst":1}]`, wantErr: true},
+		{name: "zero burst", json: `[{"pattern":"/login","rate":1,"burst":0}]`, wantErr: true},
+		{name: "unknown key", json: `[{"pattern":"/login","rate":1,"burst":1,"key":"header"}]`, wantErr: true},
+		{name: "not JSON", json: `rules`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "rules.json")
+			if err := os.WriteFile(path, []byte(tt.json), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := LoadRules(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadRules: got %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+// Package search provides an in-memory, inverted index implementation of
+// domain.SockSearcher. It needs no database and is meant for tests and local
+// runs; results are ranked with BM25 over the sock name and description.
+package search
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/google/uuid"
+
+	"github.com/oshankkumar/sockshop/internal/domain"
+)
+
+const (
+	// nameWeight is how many times a term in the name counts compared to the
+	// same term in the description.
+	nameWeight = 3
+
+	bm25K1 = 1.2
+	bm25B  = 0.75
+
+	loadBatchSize = 100
+)
+
+type document struct {
+	sock   domain.Sock
+	terms  map[string]float64
+	length float64
+}
+
+type Index struct {
+	mu       sync.RWMutex
+	docs     map[uuid.UUID]*document
+	postings map[string]map[uuid.UUID]float64
+	totalLen float64
+}
+
+func NewIndex() *Index {
+	return &Index{
+		docs:     make(map[uuid.UUID]*document),
+		postings: make(map[string]map[uuid.UUID]float64),
+	}
+}
+
+// Load indexes every sock of the store.
+func (i *Index) Load(ctx context.Context, r domain.SockStoreReader) error {
+	q := domain.SockQuery{
+		Order: domain.SockOrder{Field: domain.SortByID},
+		Page:  domain.Page{Limit: loadBatchSize},
+	}
+
+	for {
+		socks, err := r.List(ctx, q)
+		if err != nil {
+			return fmt.Errorf("Index.Load: %w", err)
+		}
+
+		for _, s := range socks {
+			_ = i.Index(ctx, s)
+		}
+
+		if len(socks) < loadBatchSize {
+			return nil
+		}
+
+		q.Page.After = &domain.Key{ID: socks[len(socks)-1].ID.String()}
+	}
+}
+
+// Index adds the sock to the index, replacing any previous version of it.
+func (i *Index) Index(_ context.Context, sock domain.Sock) error {
+	doc := &document{sock: sock, terms: make(map[string]float64)}
+
+	for _, t := range tokenize(sock.Name) {
+		doc.terms[t] += nameWeight
+		doc.length += nameWeight
+	}
+	for _, t := range tokenize(sock.Description) {
+		doc.terms[t]++
+		doc.length++
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.remove(sock.ID)
+
+	i.docs[sock.ID] = doc
+	i.totalLen += doc.length
+	for t, tf := range doc.terms {
+		if i.postings[t] == nil {
+			i.postings[t] = make(map[uuid.UUID]float64)
+		}
+		i.postings[t][sock.ID] = tf
+	}
+
+	return nil
+}
+
+func (i *Index) remove(id uuid.UUID) {
+	doc, ok := i.docs[id]
+	if !ok {
+		return
+	}
+
+	for t := range doc.terms {
+		delete(i.postings[t], id)
+		if len(i.postings[t]) == 0 {
+			delete(i.postings, t)
+		}
+	}
+
+	i.totalLen -= doc.length
+	delete(i.docs, id)
+}
+
+func (i *Index) Search(_ context.Context, query string, limit int) ([]domain.SockHit, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	n := float64(len(i.docs))
+	if n == 0 {
+		return nil, nil
+	}
+	avgLen := i.totalLen / n
+
+	scores := make(map[uuid.UUID]float64)
+	for _, t := range dedupe(tokenize(query)) {
+		postings := i.postings[t]
+		if len(postings) == 0 {
+			continue
+		}
+
+		df := float64(len(postings))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+
+		for id, tf := range postings {
+			norm := bm25K1 * (1 - bm25B + bm25B*i.docs[id].length/avgLen)
+			scores[id] += idf * tf * (bm25K1 + 1) / (tf + norm)
+		}
+	}
+
+	hits := make([]domain.SockHit, 0, len(scores))
+	for id, score := range scores {
+		hits = append(hits, domain.SockHit{Sock: i.docs[id].sock, Score: score})
+	}
+
+	sort.Slice(hits, func(a, b int) bool {
+		if hits[a].Score != hits[b].Score {
+			return hits[a].Score > hits[b].Score
+		}
+		return hits[a].Sock.ID.String() < hits[b].Sock.ID.String()
+	})
+
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+
+	return hits, nil
+}
+
+func tokenize(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func dedupe(terms []string) []string {
+	seen := make(map[string]bool, len(terms))
+
+	var out []string
+	for _, t := range terms {
+		if !seen[t] {
+			seen[t] = true
+			out = append(out, t)
+		}
+	}
+	return out
+}
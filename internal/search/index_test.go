@@ -0,0 +1,179 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/oshankkumar/sockshop/internal/domain"
+)
+
+// sockReader lists its socks by ID, a page at a time.
+type sockReader struct {
+	domain.SockStoreReader
+
+	socks []domain.Sock
+	// pages counts the calls to List.
+	pages int
+}
+
+func newSockReader(socks ...domain.Sock) *sockReader {
+	sorted := append([]domain.Sock(nil), socks...)
+	sort.Slice(sorted, func(a, b int) bool { return sorted[a].ID.String() < sorted[b].ID.String() })
+	return &sockReader{socks: sorted}
+}
+
+func (r *sockReader) List(_ context.Context, q domain.SockQuery) ([]domain.Sock, error) {
+	r.pages++
+
+	var page []domain.Sock
+	for _, s := range r.socks {
+		if q.Page.After != nil && s.ID.String() <= q.Page.After.ID {
+			continue
+		}
+		if len(page) == q.Page.Limit {
+			break
+		}
+		page = append(page, s)
+	}
+	return page, nil
+}
+
+func newSock(name, description string) domain.Sock {
+	return domain.Sock{ID: uuid.New(), Name: name, Description: description}
+}
+
+// names returns the names of the socks hit.
+func names(hits []domain.SockHit) []string {
+	var out []string
+	for _, h := range hits {
+		out = append(out, h.Sock.Name)
+	}
+	return out
+}
+
+func newTestIndex(t *testing.T, socks ...domain.Sock) *Index {
+	t.Helper()
+
+	i := NewIndex()
+	for _, s := range socks {
+		if err := i.Index(context.Background(), s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return i
+}
+
+func TestIndexSearch(t *testing.T) {
+	i := newTestIndex(t,
+		newSock("Wool hiking", "Warm socks for cold trails."),
+		newSock("Cotton crew", "Everyday socks, with a touch of wool."),
+		newSock("Argyle", "Classic diamond pattern in soft cotton."),
+		newSock("Running", "Breathable ankle socks."),
+	)
+
+	tests := []struct {
+		name  string
+		query string
+		limit int
+		want  []string
+	}{
+		{name: "name ranks above description", query: "wool", want: []string{"Wool hiking", "Cotton crew"}},
+		{name: "case and punctuation", query: "COTTON!", want: []string{"Cotton crew", "Argyle"}},
+		{name: "any term matches, shorter socks first", query: "diamond ankle", want: []string{"Running", "Argyle"}},
+		{name: "rarer terms weigh more", query: "socks breathable", want: []string{"Running", "Wool hiking", "Cotton crew"}},
+		{name: "repeated terms count once", query: "wool wool wool", want: []string{"Wool hiking", "Cotton crew"}},
+		{name: "limit", query: "wool", limit: 1, want: []string{"Wool hiking"}},
+		{name: "no match", query: "cashmere"},
+		{name: "no terms", query: "  ,. "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hits, err := i.Search(context.Background(), tt.query, tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := names(hits); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+
+			for n := 1; n < len(hits); n++ {
+				if hits[n].Score > hits[n-1].Score {
+					t.Fatalf("got scores %v, want them descending", hits)
+				}
+			}
+		})
+	}
+}
+
+func TestIndexSearchEmpty(t *testing.T) {
+	hits, err := NewIndex().Search(context.Background(), "wool", 10)
+	if err != nil || len(hits) != 0 {
+		t.Fatalf("got %v %v, want no hits", hits, err)
+	}
+}
+
+func TestIndexReindex(t *testing.T) {
+	ctx := context.Background()
+
+	sock := newSock("Wool hiking", "Warm socks.")
+	i := newTestIndex(t, sock, newSock("Cotton crew", "Everyday socks."))
+
+	sock.Name, sock.Description = "Linen summer", "Light socks."
+	if err := i.Index(ctx, sock); err != nil {
+		t.Fatal(err)
+	}
+
+	if hits, _ := i.Search(ctx, "wool", 0); len(hits) != 0 {
+		t.Fatalf("got %q, want the old terms unindexed", names(hits))
+	}
+
+	hits, _ := i.Search(ctx, "linen", 0)
+	if got := names(hits); !reflect.DeepEqual(got, []string{"Linen summer"}) {
+		t.Fatalf("got %q, want the new version of the sock", got)
+	}
+
+	hits, _ = i.Search(ctx, "socks", 0)
+	if len(hits) != 2 {
+		t.Fatalf("got %q, want each sock once", names(hits))
+	}
+
+	if want := float64(2*nameWeight + 2 + 2*nameWeight + 2); i.totalLen != want {
+		t.Fatalf("got total length %v, want %v", i.totalLen, want)
+	}
+}
+
+func TestIndexLoad(t *testing.T) {
+	ctx := context.Background()
+
+	var socks []domain.Sock
+	for n := 0; n < 2*loadBatchSize+1; n++ {
+		socks = append(socks, newSock(fmt.Sprintf("Sock %d", n), "Plain socks."))
+	}
+	socks[loadBatchSize+7].Description = "Striped socks."
+
+	r := newSockReader(socks...)
+	i := NewIndex()
+
+	if err := i.Load(ctx, r); err != nil {
+		t.Fatal(err)
+	}
+
+	if r.pages != 3 {
+		t.Fatalf("got %d pages listed, want 3", r.pages)
+	}
+	if len(i.docs) != len(socks) {
+		t.Fatalf("got %d socks indexed, want %d", len(i.docs), len(socks))
+	}
+
+	hits, _ := i.Search(ctx, "striped", 0)
+	if got := names(hits); !reflect.DeepEqual(got, []string{socks[loadBatchSize+7].Name}) {
+		t.Fatalf("got %q, want %q", got, socks[loadBatchSize+7].Name)
+	}
+}
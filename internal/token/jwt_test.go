@@ -0,0 +1,101 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCodecVerify(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+
+	_, edKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := edKey.Public().(ed25519.PublicKey)
+
+	hs := NewHS256([]byte("secret"), "sockshop")
+	ed := NewEdDSA(edKey, "sockshop")
+
+	claims := Claims{Subject: "alice", SessionID: "s1", IssuedAt: now.Unix(), ExpiresAt: now.Add(time.Minute).Unix()}
+
+	sign := func(c *Codec, claims Claims) string {
+		tok, err := c.Sign(claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tok
+	}
+
+	// withHeader replaces the header of the token, keeping its signature.
+	withHeader := func(tok, header string) string {
+		_, rest, _ := strings.Cut(tok, ".")
+		return base64.RawURLEncoding.EncodeToString([]byte(header)) + "." + rest
+	}
+
+	noSubject := claims
+	noSubject.Subject = ""
+
+	tests := []struct {
+		name    string
+		codec   *Codec
+		token   string
+		now     time.Time
+		wantErr error
+	}{
+		{name: "HS256", codec: hs, token: sign(hs, claims), now: now},
+		{name: "EdDSA", codec: ed, token: sign(ed, claims), now: now},
+		{name: "just before expiry", codec: hs, token: sign(hs, claims), now: now.Add(time.Minute - time.Second)},
+		{name: "at expiry", codec: hs, token: sign(hs, claims), now: now.Add(time.Minute), wantErr: ErrExpired},
+		{name: "EdDSA token to an HS256 codec", codec: hs, token: sign(ed, claims), now: now, wantErr: ErrInvalid},
+		{name: "HS256 token to an EdDSA codec", codec: ed, token: sign(hs, claims), now: now, wantErr: ErrInvalid},
+		{
+			// The classic confusion: an HS256 token keyed with the public key.
+			name:    "HS256 token signed with the EdDSA public key",
+			codec:   ed,
+			token:   sign(NewHS256(pub, "sockshop"), claims),
+			now:     now,
+			wantErr: ErrInvalid,
+		},
+		{name: "alg none", codec: hs, token: withHeader(sign(hs, claims), `{"alg":"none","typ":"JWT"}`), now: now, wantErr: ErrInvalid},
+		{name: "other key", codec: hs, token: sign(NewHS256([]byte("other"), "sockshop"), claims), now: now, wantErr: ErrInvalid},
+		{name: "other issuer", codec: hs, token: sign(NewHS256([]byte("secret"), "elsewhere"), claims), now: now, wantErr: ErrInvalid},
+		{name: "no subject", codec: hs, token: sign(hs, noSubject), now: now, wantErr: ErrInvalid},
+		{name: "two parts", codec: hs, token: "a.b", now: now, wantErr: ErrInvalid},
+		{name: "garbage", codec: hs, token: "a.b.c", now: now, wantErr: ErrInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.codec.Verify(tt.token, tt.now)
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && (got.Subject != claims.Subject || got.SessionID != claims.SessionID || got.Issuer != "sockshop") {
+				t.Fatalf("got claims %+v", got)
+			}
+		})
+	}
+}
+
+func TestCodecVerifyTamperedPayload(t *testing.T) {
+	c := NewHS256([]byte("secret"), "sockshop")
+
+	tok, err := c.Sign(Claims{Subject: "alice", ExpiresAt: time.Now().Add(time.Hour).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h, _, _ := strings.Cut(tok, ".")
+	sig := tok[strings.LastIndex(tok, ".")+1:]
+	forged := base64.RawURLEncoding.EncodeToString([]byte(`{"iss":"sockshop","sub":"admin","roles":["admin"],"exp":9999999999}`))
+
+	if _, err := c.Verify(h+"."+forged+"."+sig, time.Now()); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("got %v, want %v", err, ErrInvalid)
+	}
+}
@@ -0,0 +1,100 @@
+// Package totp implements RFC 6238 time-based one-time passwords with the
+// parameters authenticator apps support by default: HMAC-SHA1, 6 digits and a
+// 30 seconds period.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+const (
+	Digits = 6
+	Period = 30 * time.Second
+	// SecretSize is the size of generated secrets, the 160 bits RFC 4226
+	// recommends.
+	SecretSize = 20
+)
+
+// Encoding is the unpadded base32 encoding of secrets in otpauth URIs.
+var Encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+func NewSecret() ([]byte, error) {
+	secret := make([]byte, SecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("totp.NewSecret: %w", err)
+	}
+	return secret, nil
+}
+
+// Counter returns the time step t falls in.
+func Counter(t time.Time) int64 {
+	return t.Unix() / int64(Period/time.Second)
+}
+
+// Code returns the RFC 4226 HOTP code of the counter.
+func Code(secret []byte, counter int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(counter))
+
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < Digits; i++ {
+		mod *= 10
+	}
+
+	return fmt.Sprintf("%0*d", Digits, value%mod)
+}
+
+// Verify reports whether code is the code at t, or at up to skew time steps
+// before or after it to allow for clock drift, and returns the counter it
+// matched. Callers should reject counters already used, so that a code cannot
+// be replayed.
+func Verify(secret []byte, code string, t time.Time, skew int) (int64, bool) {
+	if len(code) != Digits {
+		return 0, false
+	}
+
+	now := Counter(t)
+	for i := -skew; i <= skew; i++ {
+		counter := now + int64(i)
+		if subtle.ConstantTimeCompare([]byte(Code(secret, counter)), []byte(code)) == 1 {
+			return counter, true
+		}
+	}
+
+	return 0, false
+}
+
+// URI returns the otpauth URI enrolling the secret in an authenticator app,
+// usually shown as a QR code.
+func URI(issuer, account string, secret []byte) string {
+	u := url.URL{
+		Scheme: "otpauth",
+		Host:   "totp",
+		Path:   "/" + issuer + ":" + account,
+	}
+
+	q := url.Values{}
+	q.Set("secret", Encoding.EncodeToString(secret))
+	q.Set("issuer", issuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", fmt.Sprint(Digits))
+	q.Set("period", fmt.Sprint(int(Period/time.Second)))
+	u.RawQuery = q.Encode()
+
+	return u.String()
+}
@@ -0,0 +1,244 @@
+// Package validate checks structs against declarative rules in their
+// `validate` field tags:
+//
+//	type User struct {
+//		Username string `json:"username" validate:"required,max=20"`
+//		Email    string `json:"email" validate:"required,email"`
+//	}
+//
+// The rules are
+//
+//   - required: the field is not its zero value, or a nil pointer;
+//   - min=n, max=n: bounds of a number, or of the length of a string or slice;
+//   - len=n: exact length of a string or slice;
+//   - email: the string is an email address;
+//   - digits: the string only has ASCII digits;
+//   - oneof=a b c: the string is one of the space separated values.
+//
+// Rules other than required are skipped for empty strings and slices. Pointers are checked
+// through, so optional fields of partial updates are checked when present.
+// Nested structs are checked recursively. Fields are reported by their JSON
+// name, prefixed with the name of the enclosing fields.
+package validate
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// FieldError is the reason a field is invalid.
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+// Errors lists the invalid fields of a struct.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, f := range e {
+		msgs = append(msgs, f.Field+": "+f.Message)
+	}
+	return "validation failed: " + strings.Join(msgs, ", ")
+}
+
+// Struct checks the struct v, or the struct v points to. It returns Errors
+// listing every invalid field, or nil. It panics on malformed rules.
+func Struct(v interface{}) error {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var errs Errors
+	checkStruct(rv, "", &errs)
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+type rule struct {
+	name string
+	arg  string
+}
+
+type field struct {
+	index int
+	name  string
+	rules []rule
+}
+
+var cache sync.Map // reflect.Type -> []field
+
+func fields(t reflect.Type) []field {
+	if ff, ok := cache.Load(t); ok {
+		return ff.([]field)
+	}
+
+	var ff []field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+
+		name := sf.Name
+		if tag, _, _ := strings.Cut(sf.Tag.Get("json"), ","); tag == "-" {
+			continue
+		} else if tag != "" {
+			name = tag
+		}
+
+		f := field{index: i, name: name}
+		if tag := sf.Tag.Get("validate"); tag != "" {
+			for _, r := range strings.Split(tag, ",") {
+				n, arg, _ := strings.Cut(r, "=")
+				f.rules = append(f.rules, rule{name: n, arg: arg})
+			}
+		}
+		ff = append(ff, f)
+	}
+
+	cache.Store(t, ff)
+	return ff
+}
+
+func checkStruct(v reflect.Value, prefix string, errs *Errors) {
+	for _, f := range fields(v.Type()) {
+		fv := v.Field(f.index)
+		name := prefix + f.name
+
+		if msg := checkField(fv, f.rules); msg != "" {
+			*errs = append(*errs, FieldError{Field: name, Message: msg})
+			continue
+		}
+
+		if fv = reflect.Indirect(fv); fv.Kind() == reflect.Struct {
+			checkStruct(fv, name+".", errs)
+		}
+	}
+}
+
+// checkField returns the message of the first rule v breaks, or "".
+func checkField(v reflect.Value, rules []rule) string {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			for _, r := range rules {
+				if r.name == "required" {
+					return "is required"
+				}
+			}
+			return ""
+		}
+		v = v.Elem()
+	}
+
+	for _, r := range rules {
+		if r.name == "required" {
+			if v.IsZero() {
+				return "is required"
+			}
+			continue
+		}
+
+		if empty(v) {
+			continue
+		}
+
+		if msg := check(v, r); msg != "" {
+			return msg
+		}
+	}
+
+	return ""
+}
+
+func check(v reflect.Value, r rule) string {
+	switch r.name {
+	case "min", "max":
+		n, err := strconv.ParseFloat(r.arg, 64)
+		if err != nil {
+			panic(fmt.Sprintf("validate: bad %s argument %q", r.name, r.arg))
+		}
+
+		size, unit := measure(v)
+		switch {
+		case r.name == "min" && size < n && unit != "":
+			return "must have at least " + r.arg + " " + unit
+		case r.name == "min" && size < n:
+			return "must be at least " + r.arg
+		case r.name == "max" && size > n && unit != "":
+			return "must have at most " + r.arg + " " + unit
+		case r.name == "max" && size > n:
+			return "must be at most " + r.arg
+		}
+	case "len":
+		n, err := strconv.Atoi(r.arg)
+		if err != nil {
+			panic(fmt.Sprintf("validate: bad len argument %q", r.arg))
+		}
+
+		if size, unit := measure(v); int(size) != n {
+			return "must have " + r.arg + " " + unit
+		}
+	case "email":
+		addr, err := mail.ParseAddress(v.String())
+		if err != nil || addr.Address != v.String() {
+			return "must be an email address"
+		}
+	case "digits":
+		for _, c := range v.String() {
+			if c < '0' || c > '9' {
+				return "must only have digits"
+			}
+		}
+	case "oneof":
+		for _, allowed := range strings.Fields(r.arg) {
+			if v.String() == allowed {
+				return ""
+			}
+		}
+		return "must be one of " + strings.Join(strings.Fields(r.arg), ", ")
+	default:
+		panic(fmt.Sprintf("validate: unknown rule %q", r.name))
+	}
+
+	return ""
+}
+
+// empty reports whether v is an empty string or slice.
+func empty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	default:
+		return false
+	}
+}
+
+// measure returns the value of a number, or the length of a string or slice
+// with its unit.
+func measure(v reflect.Value) (float64, string) {
+	switch v.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), "characters"
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return float64(v.Len()), "items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), ""
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), ""
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), ""
+	default:
+		panic(fmt.Sprintf("validate: cannot measure %s", v.Kind()))
+	}
+}
@@ -0,0 +1,211 @@
+package validate
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type address struct {
+	Street string `json:"street" validate:"required,max=10"`
+}
+
+type order struct {
+	Name     string   `json:"name" validate:"required,min=2,max=5"`
+	Code     string   `json:"code" validate:"len=4,digits"`
+	Email    string   `json:"email" validate:"email"`
+	Status   string   `json:"status" validate:"oneof=open closed"`
+	Quantity int      `json:"quantity" validate:"min=1,max=10"`
+	Price    float64  `json:"price" validate:"min=0"`
+	Tags     []string `json:"tags" validate:"max=2"`
+	Note     *string  `json:"note" validate:"max=3"`
+	Address  *address `json:"address"`
+	Billing  address  `json:"billing"`
+	Internal string   `json:"-" validate:"required"`
+}
+
+func strPtr(s string) *string { return &s }
+
+// valid returns an order breaking no rule.
+func valid() order {
+	return order{Name: "socks", Quantity: 1, Billing: address{Street: "Main"}}
+}
+
+func TestStruct(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(o *order)
+		want   Errors
+	}{
+		{name: "valid", modify: func(o *order) {}},
+		{
+			name:   "required",
+			modify: func(o *order) { o.Name = "" },
+			want:   Errors{{Field: "name", Message: "is required"}},
+		},
+		{
+			name:   "min length",
+			modify: func(o *order) { o.Name = "s" },
+			want:   Errors{{Field: "name", Message: "must have at least 2 characters"}},
+		},
+		{
+			name:   "max length",
+			modify: func(o *order) { o.Name = "argyle" },
+			want:   Errors{{Field: "name", Message: "must have at most 5 characters"}},
+		},
+		{
+			// Five runes of two bytes each.
+			name:   "lengths in runes",
+			modify: func(o *order) { o.Name = "ééééé" },
+		},
+		{
+			name:   "len",
+			modify: func(o *order) { o.Code = "123" },
+			want:   Errors{{Field: "code", Message: "must have 4 characters"}},
+		},
+		{
+			name:   "len in runes",
+			modify: func(o *order) { o.Code = "１２３" },
+			want:   Errors{{Field: "code", Message: "must have 4 characters"}},
+		},
+		{
+			name:   "digits",
+			modify: func(o *order) { o.Code = "12a4" },
+			want:   Errors{{Field: "code", Message: "must only have digits"}},
+		},
+		{
+			name:   "non ASCII digits",
+			modify: func(o *order) { o.Code = "١٢٣٤" },
+			want:   Errors{{Field: "code", Message: "must only have digits"}},
+		},
+		{
+			name:   "email",
+			modify: func(o *order) { o.Email = "alice@example.com" },
+		},
+		{
+			name:   "invalid email",
+			modify: func(o *order) { o.Email = "alice" },
+			want:   Errors{{Field: "email", Message: "must be an email address"}},
+		},
+		{
+			name:   "email with a display name",
+			modify: func(o *order) { o.Email = "Alice <alice@example.com>" },
+			want:   Errors{{Field: "email", Message: "must be an email address"}},
+		},
+		{
+			name:   "oneof",
+			modify: func(o *order) { o.Status = "closed" },
+		},
+		{
+			name:   "not oneof",
+			modify: func(o *order) { o.Status = "pending" },
+			want:   Errors{{Field: "status", Message: "must be one of open, closed"}},
+		},
+		{
+			name:   "number below min",
+			modify: func(o *order) { o.Quantity = 0 },
+			want:   Errors{{Field: "quantity", Message: "must be at least 1"}},
+		},
+		{
+			name:   "number above max",
+			modify: func(o *order) { o.Quantity = 11 },
+			want:   Errors{{Field: "quantity", Message: "must be at most 10"}},
+		},
+		{
+			name:   "negative float",
+			modify: func(o *order) { o.Price = -0.5 },
+			want:   Errors{{Field: "price", Message: "must be at least 0"}},
+		},
+		{
+			name:   "too many items",
+			modify: func(o *order) { o.Tags = []string{"a", "b", "c"} },
+			want:   Errors{{Field: "tags", Message: "must have at most 2 items"}},
+		},
+		{
+			name:   "nil pointer",
+			modify: func(o *order) { o.Note = nil },
+		},
+		{
+			name:   "pointer checked through",
+			modify: func(o *order) { o.Note = strPtr("long") },
+			want:   Errors{{Field: "note", Message: "must have at most 3 characters"}},
+		},
+		{
+			name:   "nested struct",
+			modify: func(o *order) { o.Billing.Street = "" },
+			want:   Errors{{Field: "billing.street", Message: "is required"}},
+		},
+		{
+			name:   "nested struct pointer",
+			modify: func(o *order) { o.Address = &address{Street: "Long Street"} },
+			want:   Errors{{Field: "address.street", Message: "must have at most 10 characters"}},
+		},
+		{
+			name: "every invalid field",
+			modify: func(o *order) {
+				o.Name, o.Quantity, o.Billing.Street = "", 0, ""
+			},
+			want: Errors{
+				{Field: "name", Message: "is required"},
+				{Field: "quantity", Message: "must be at least 1"},
+				{Field: "billing.street", Message: "is required"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := valid()
+			tt.modify(&o)
+
+			err := Struct(&o)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("got %v, want no error", err)
+				}
+				return
+			}
+
+			var got Errors
+			if !errors.As(err, &got) {
+				t.Fatalf("got %v, want Errors", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStructSkipsEmptyValues(t *testing.T) {
+	// Only required rejects empty strings and slices, the other rules are
+	// for the values which are present.
+	var v struct {
+		Code  string   `json:"code" validate:"len=4,digits"`
+		Email string   `json:"email" validate:"email"`
+		Kind  string   `json:"kind" validate:"oneof=a b"`
+		Tags  []string `json:"tags" validate:"min=1"`
+	}
+
+	if err := Struct(v); err != nil {
+		t.Fatalf("got %v, want no error", err)
+	}
+}
+
+func TestStructNotAStruct(t *testing.T) {
+	if err := Struct("socks"); err != nil {
+		t.Fatalf("got %v, want no error", err)
+	}
+}
+
+func TestStructMalformedRule(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("got no panic, want one for the unknown rule")
+		}
+	}()
+
+	_ = Struct(struct {
+		Name string `validate:"maximum=3"`
+	}{Name: "socks"})
+}
@@ -0,0 +1,189 @@
+// Package vault tokenizes card numbers. The card numbers are kept encrypted in
+// the vault, and the rest of the shop only handles the opaque tokens standing
+// for them. Turning a token back into its card number is restricted to an
+// allow-list of callers, and every attempt is audited.
+package vault
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/oshankkumar/sockshop/internal/domain"
+	"github.com/oshankkumar/sockshop/internal/envelope"
+)
+
+// ErrForbidden is returned when a caller outside the allow-list detokenizes.
+var ErrForbidden = errors.New("vault: caller not allowed to detokenize")
+
+// CallerPayments is the payment path, the only caller that needs card numbers.
+const CallerPayments = "payments"
+
+// Cipher encrypts the card numbers. envelope.Sealer implements it.
+type Cipher interface {
+	Seal(ctx context.Context, plaintext []byte) (envelope.Sealed, error)
+	Open(ctx context.Context, sealed envelope.Sealed) ([]byte, error)
+	Rewrap(ctx context.Context, sealed envelope.Sealed) (envelope.Sealed, bool, error)
+	CurrentKeyID(ctx context.Context) (string, error)
+	Fingerprint(plaintext []byte) string
+}
+
+// Entry is a card number stored in the vault.
+type Entry struct {
+	Token string
+	PAN   envelope.Sealed
+	// Fingerprint is a keyed hash of the card number, unique across entries.
+	Fingerprint string
+	CreatedAt   time.Time
+}
+
+// AuditRecord is a detokenization attempt.
+type AuditRecord struct {
+	Token   string
+	Caller  string
+	Allowed bool
+	Time    time.Time
+}
+
+// Store is the backend of the vault. Missing entries are reported with
+// domain.ErrNotFound, and an entry with an existing fingerprint with
+// domain.DuplicateEntryError.
+type Store interface {
+	CreateEntry(ctx context.Context, e Entry) error
+	GetEntry(ctx context.Context, token string) (Entry, error)
+	GetEntryByFingerprint(ctx context.Context, fingerprint string) (Entry, error)
+	// GetEntriesNotUnderKey returns up to limit entries whose data key is not
+	// wrapped with the key encryption key keyID.
+	GetEntriesNotUnderKey(ctx context.Context, keyID string, limit int) ([]Entry, error)
+	// UpdateEntryKey replaces the wrapped data key of the entry.
+	UpdateEntryKey(ctx context.Context, e Entry) error
+	AppendAudit(ctx context.Context, r AuditRecord) error
+}
+
+type Vault struct {
+	store   Store
+	cipher  Cipher
+	allowed map[string]bool
+}
+
+// New returns a vault storing the card numbers encrypted by cipher in store.
+// Only allowedCallers can detokenize.
+func New(store Store, cipher Cipher, allowedCallers ...string) *Vault {
+	allowed := make(map[string]bool, len(allowedCallers))
+	for _, c := range allowedCallers {
+		allowed[c] = true
+	}
+	return &Vault{store: store, cipher: cipher, allowed: allowed}
+}
+
+// Tokenize returns the token of the card number. A card number already in the
+// vault keeps its token.
+func (v *Vault) Tokenize(ctx context.Context, pan string) (string, error) {
+	fingerprint := v.cipher.Fingerprint([]byte(pan))
+
+	e, err := v.store.GetEntryByFingerprint(ctx, fingerprint)
+	if err == nil {
+		return e.Token, nil
+	}
+	if !errors.Is(err, domain.ErrNotFound) {
+		return "", fmt.Errorf("vault.Tokenize: %w", err)
+	}
+
+	sealed, err := v.cipher.Seal(ctx, []byte(pan))
+	if err != nil {
+		return "", fmt.Errorf("vault.Tokenize: %w", err)
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return "", fmt.Errorf("vault.Tokenize: %w", err)
+	}
+
+	e = Entry{Token: token, PAN: sealed, Fingerprint: fingerprint, CreatedAt: time.Now().UTC()}
+
+	err = v.store.CreateEntry(ctx, e)
+	if errors.As(err, &domain.DuplicateEntryError{}) {
+		// The same card number was tokenized concurrently.
+		if e, err = v.store.GetEntryByFingerprint(ctx, fingerprint); err == nil {
+			return e.Token, nil
+		}
+	}
+	if err != nil {
+		return "", fmt.Errorf("vault.Tokenize: %w", err)
+	}
+
+	return token, nil
+}
+
+// Detokenize returns the card number of the token. The attempt is audited
+// first, and fails with ErrForbidden unless the caller is allowed.
+func (v *Vault) Detokenize(ctx context.Context, caller, token string) (string, error) {
+	allowed := v.allowed[caller]
+
+	err := v.store.AppendAudit(ctx, AuditRecord{Token: token, Caller: caller, Allowed: allowed, Time: time.Now().UTC()})
+	if err != nil {
+		return "", fmt.Errorf("vault.Detokenize(caller=%s): audit: %w", caller, err)
+	}
+
+	if !allowed {
+		return "", fmt.Errorf("vault.Detokenize(caller=%s): %w", caller, ErrForbidden)
+	}
+
+	e, err := v.store.GetEntry(ctx, token)
+	if err != nil {
+		return "", fmt.Errorf("vault.Detokenize(caller=%s): %w", caller, err)
+	}
+
+	pan, err := v.cipher.Open(ctx, e.PAN)
+	if err != nil {
+		return "", fmt.Errorf("vault.Detokenize(caller=%s): %w", caller, err)
+	}
+
+	return string(pan), nil
+}
+
+const rotateBatchSize = 100
+
+// RotateKeys rewraps the data keys of the entries with the current key
+// encryption key, after which the older keys can be retired. It returns the
+// number of rewrapped entries.
+func (v *Vault) RotateKeys(ctx context.Context) (int, error) {
+	keyID, err := v.cipher.CurrentKeyID(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("vault.RotateKeys: %w", err)
+	}
+
+	var n int
+	for {
+		entries, err := v.store.GetEntriesNotUnderKey(ctx, keyID, rotateBatchSize)
+		if err != nil {
+			return n, fmt.Errorf("vault.RotateKeys: %w", err)
+		}
+
+		if len(entries) == 0 {
+			return n, nil
+		}
+
+		for _, e := range entries {
+			if e.PAN, _, err = v.cipher.Rewrap(ctx, e.PAN); err != nil {
+				return n, fmt.Errorf("vault.RotateKeys(token=%s): %w", e.Token, err)
+			}
+
+			if err := v.store.UpdateEntryKey(ctx, e); err != nil {
+				return n, fmt.Errorf("vault.RotateKeys: %w", err)
+			}
+			n++
+		}
+	}
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate token: %w", err)
+	}
+	return "tok_" + hex.EncodeToString(b), nil
+}
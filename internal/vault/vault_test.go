@@ -0,0 +1,138 @@
+package vault_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/oshankkumar/sockshop/internal/db/memory"
+	"github.com/oshankkumar/sockshop/internal/domain"
+	"github.com/oshankkumar/sockshop/internal/envelope"
+	"github.com/oshankkumar/sockshop/internal/vault"
+)
+
+func newKeyring(current string, ids ...string) *envelope.Keyring {
+	k := &envelope.Keyring{Current: current, Keys: make(map[string][]byte), FingerprintKey: bytes.Repeat([]byte{0xf0}, 32)}
+	for _, id := range ids {
+		k.Keys[id] = bytes.Repeat([]byte(id), 32)[:32]
+	}
+	return k
+}
+
+func newVault(store vault.Store, keys *envelope.Keyring) *vault.Vault {
+	return vault.New(store, envelope.NewSealer(keys, keys.FingerprintKey), vault.CallerPayments)
+}
+
+func TestVaultTokenize(t *testing.T) {
+	ctx := context.Background()
+	v := newVault(memory.NewVaultStore(), newKeyring("k1", "k1"))
+
+	a, err := v.Tokenize(ctx, "4111111111111111")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	again, err := v.Tokenize(ctx, "4111111111111111")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := v.Tokenize(ctx, "5555555555554444")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a != again {
+		t.Errorf("the same card number got tokens %s and %s", a, again)
+	}
+	if a == other {
+		t.Errorf("different card numbers got the same token %s", a)
+	}
+}
+
+func TestVaultDetokenize(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewVaultStore()
+	v := newVault(store, newKeyring("k1", "k1"))
+
+	token, err := v.Tokenize(ctx, "4111111111111111")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		caller  string
+		token   string
+		want    string
+		wantErr error
+	}{
+		{caller: vault.CallerPayments, token: token, want: "4111111111111111"},
+		{caller: "orders", token: token, wantErr: vault.ErrForbidden},
+		{caller: "", token: token, wantErr: vault.ErrForbidden},
+		{caller: vault.CallerPayments, token: "tok_unknown", wantErr: domain.ErrNotFound},
+	}
+
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("%s %s", tt.caller, tt.token), func(t *testing.T) {
+			got, err := v.Detokenize(ctx, tt.caller, tt.token)
+
+			if !errors.Is(err, tt.wantErr) || got != tt.want {
+				t.Fatalf("got %q, %v, want %q, %v", got, err, tt.want, tt.wantErr)
+			}
+
+			// Every attempt is audited, allowed or not.
+			audit := store.Audit()
+			if len(audit) != i+1 {
+				t.Fatalf("got %d audit records, want %d", len(audit), i+1)
+			}
+
+			last := audit[len(audit)-1]
+			if last.Caller != tt.caller || last.Token != tt.token || last.Allowed != (tt.wantErr != vault.ErrForbidden) {
+				t.Fatalf("got audit record %+v", last)
+			}
+		})
+	}
+}
+
+func TestVaultRotateKeys(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewVaultStore()
+
+	// More entries than a rotation batch.
+	pans := make(map[string]string)
+	old := newVault(store, newKeyring("k1", "k1"))
+	for i := 0; i < 150; i++ {
+		pan := fmt.Sprintf("4111%012d", i)
+
+		token, err := old.Tokenize(ctx, pan)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pans[token] = pan
+	}
+
+	rotated := newVault(store, newKeyring("k2", "k1", "k2"))
+
+	n, err := rotated.RotateKeys(ctx)
+	if err != nil {
+		t.Fatalf("RotateKeys: %v", err)
+	}
+	if n != len(pans) {
+		t.Fatalf("RotateKeys: rewrapped %d entries, want %d", n, len(pans))
+	}
+
+	if n, err := rotated.RotateKeys(ctx); err != nil || n != 0 {
+		t.Fatalf("second RotateKeys: got %d, %v, want nothing to rewrap", n, err)
+	}
+
+	// The retired key is no longer needed.
+	retired := newVault(store, newKeyring("k2", "k2"))
+	for token, pan := range pans {
+		got, err := retired.Detokenize(ctx, vault.CallerPayments, token)
+		if err != nil || got != pan {
+			t.Fatalf("Detokenize(%s): got %q, %v, want %q", token, got, err, pan)
+		}
+	}
+}